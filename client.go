@@ -3,6 +3,7 @@ package tdx
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -88,10 +89,26 @@ func DialWith(dial ios.DialFunc, op ...client.Option) (cli *Client, err error) {
 		c.Logger.Debug(true)                           //关闭日志打印
 		c.Logger.SetLevel(LevelInfo)                   //设置日志级别
 		c.Logger.WithHEX()                             //以HEX显示
+		c.Event.OnDisconnect = nil                     //重连时会重新执行选项,避免重复包装
 		c.SetOption(op...)                             //自定义选项
 		c.Event.OnReadFrom = protocol.ReadFrom         //分包
 		c.Event.OnDealMessage = cli.handlerDealMessage //解析数据并处理
+		if h, ok := c.Tag.Get(tagHook); ok && cli.Hook() == nil {
+			cli.SetHook(h.(Hook)) //WithHook
+		}
+		onDisconnect := c.Event.OnDisconnect //自定义选项设置的
+		c.Event.OnDisconnect = func(c *client.Client, err error) {
+			if h := cli.Hook(); h != nil {
+				h.Disconnected(c.GetKey(), err)
+			}
+			if onDisconnect != nil {
+				onDisconnect(c, err)
+			}
+		}
 		c.Event.OnConnected = func(c *client.Client) error {
+			if h := cli.Hook(); h != nil {
+				h.Connected(c.GetKey(), atomic.AddInt32(&cli.connects, 1) > 1)
+			}
 			//无数据超时时间是60秒,30秒发送一个心跳包
 			c.GoTimerWriter(30*time.Second, func(w ios.MoreWriter) error {
 				bs := protocol.MHeart.Frame().Bytes()
@@ -115,10 +132,15 @@ func DialWith(dial ios.DialFunc, op ...client.Option) (cli *Client, err error) {
 }
 
 type Client struct {
-	*client.Client              //客户端实例
-	Wait           *wait.Entity //异步回调,设置超时时间,超时则返回错误
-	m              *maps.Safe   //有部分解析需要用到代码,返回数据获取不到,固请求的时候缓存下
-	msgID          uint32       //消息id,使用SendFrame自动累加
+	*client.Client                        //客户端实例
+	Wait           *wait.Entity           //异步回调,设置超时时间,超时则返回错误
+	m              *maps.Safe             //有部分解析需要用到代码,返回数据获取不到,固请求的时候缓存下
+	msgID          uint32                 //消息id,使用SendFrame自动累加
+	hook           atomic.Value           //观测回调,见 Hook
+	ctx            context.Context        //请求的上下文,见 WithContext
+	base           *Client                //WithContext 的原客户端,共享消息id和观测回调
+	exTradingDay   func(t time.Time) bool //扩展行情的交易日判断,见 WithExTradingDay
+	connects       int32                  //连接次数
 }
 
 // handlerDealMessage 处理服务器响应的数据
@@ -128,12 +150,18 @@ func (this *Client) handlerDealMessage(c *client.Client, msg ios.Acker) {
 		if e := recover(); e != nil {
 			logs.Err(e)
 			debug.PrintStack()
+			if h := this.Hook(); h != nil {
+				h.DecodeError(0, fmt.Errorf("%v", e))
+			}
 		}
 	}()
 
 	f, err := protocol.Decode(msg.Payload())
 	if err != nil {
 		logs.Err(err)
+		if h := this.Hook(); h != nil {
+			h.DecodeError(0, err)
+		}
 		return
 	}
 
@@ -215,10 +243,10 @@ func (this *Client) handlerDealMessage(c *client.Client, msg ios.Acker) {
 		resp, err = protocol.MEx.DecodeBars(f.Data, val.(protocol.ExBarsCache))
 
 	case protocol.TypeExMinute:
-		resp, err = protocol.MEx.DecodeMinute(f.Data)
+		resp, err = protocol.MEx.DecodeMinute(f.Data, val.(protocol.ExMinuteCache))
 
 	case protocol.TypeExHistMinute:
-		resp, err = protocol.MEx.DecodeHistMinute(f.Data)
+		resp, err = protocol.MEx.DecodeHistMinute(f.Data, val.(protocol.ExMinuteCache))
 
 	case protocol.TypeExTrade:
 		resp, err = protocol.MEx.DecodeTrade(f.Data, val.(protocol.ExTradeCache))
@@ -227,7 +255,7 @@ func (this *Client) handlerDealMessage(c *client.Client, msg ios.Acker) {
 		resp, err = protocol.MEx.DecodeHistTrade(f.Data, val.(protocol.ExTradeCache))
 
 	case protocol.TypeExBarsRange:
-		resp, err = protocol.MEx.DecodeBarsRange(f.Data)
+		resp, err = protocol.MEx.DecodeBarsRange(f.Data, val.(protocol.ExBarsRangeCache))
 
 	default:
 		err = fmt.Errorf("通讯类型未解析:0x%X", f.Type)
@@ -236,9 +264,15 @@ func (this *Client) handlerDealMessage(c *client.Client, msg ios.Acker) {
 
 	if err != nil {
 		logs.Err(err)
+		if h := this.Hook(); h != nil {
+			h.DecodeError(f.Type, err)
+		}
 		return
 	}
 
+	if this.Hook() != nil {
+		resp = sizedResp{resp: resp, size: len(f.Data)}
+	}
 	this.Wait.Done(conv.String(f.MsgID), resp)
 
 }
@@ -250,14 +284,47 @@ func (this *Client) SetTimeout(t time.Duration) {
 
 // SendFrame 发送数据,并等待响应
 func (this *Client) SendFrame(f *protocol.Frame, cache ...any) (any, error) {
-	f.MsgID = atomic.AddUint32(&this.msgID, 1)
+	f.MsgID = atomic.AddUint32(&this.root().msgID, 1)
 	if len(cache) > 0 {
 		this.m.Set(conv.String(f.MsgID), cache[0])
 	}
-	if _, err := this.Client.Write(f.Bytes()); err != nil {
+	bs := f.Bytes()
+
+	h := this.Hook()
+	var info *RequestInfo
+	ctx := this.context()
+	if h != nil {
+		info = &RequestInfo{Type: f.Type, MsgID: f.MsgID, Addr: this.GetKey(), Sent: len(bs), Start: time.Now()}
+		ctx = h.RequestStart(ctx, info)
+	}
+
+	var result any
+	_, err := this.Client.Write(bs)
+	if err == nil {
+		//wait 只有超时才会返回错误
+		if result, err = this.Wait.Wait(conv.String(f.MsgID)); err != nil {
+			err = ErrTimeout
+		}
+	}
+	if r, ok := result.(sizedResp); ok {
+		result = r.resp
+		if info != nil {
+			info.Received = r.size
+		}
+	}
+
+	if h != nil {
+		info.Duration = time.Since(info.Start)
+		if err != nil {
+			info.Err = err
+			info.Timeout = errors.Is(err, ErrTimeout)
+		}
+		h.RequestFinish(ctx, info)
+	}
+	if err != nil {
 		return nil, err
 	}
-	return this.Wait.Wait(conv.String(f.MsgID))
+	return result, nil
 }
 
 // GetCount 获取市场内的股票数量
@@ -370,17 +437,22 @@ func (this *Client) GetIndexCodeAll() ([]string, error) {
 	return ls, nil
 }
 
-// GetQuote 获取盘口五档报价
+// GetQuote 获取盘口五档报价,ETF/基金/可转债等非股票/指数代码需要先初始化 DefaultCodes,见 GetQuoteWithCodes
 func (this *Client) GetQuote(codes ...string) (protocol.QuotesResp, error) {
+	return this.GetQuoteWithCodes(DefaultCodes, codes...)
+}
+
+// GetQuoteWithCodes 获取盘口五档报价,非股票/指数代码按 cs 里的小数位数修正价格,cs 为nil时这类代码返回错误
+func (this *Client) GetQuoteWithCodes(cs ICodes, codes ...string) (protocol.QuotesResp, error) {
 	for i := range codes {
 		//如果是股票代码,则加上前缀
 		codes[i] = protocol.AddPrefix(codes[i])
 		if !protocol.IsStock(codes[i]) && !protocol.IsIndex(codes[i]) {
-			if DefaultCodes == nil {
+			if cs == nil {
 				return nil, errors.New("DefaultCodes未初始化")
 			}
 			//不是股票/指数代码的话，根据codes的信息加上前缀
-			//codes[i] = DefaultCodes.AddExchange(codes[i])
+			//codes[i] = cs.AddExchange(codes[i])
 			codes[i] = protocol.AddPrefix(codes[i])
 		}
 	}
@@ -404,7 +476,7 @@ func (this *Client) GetQuote(codes ...string) (protocol.QuotesResp, error) {
 			// 股票类代码才需要按 Decimal 修正价格;
 			// 指数(含板块指数 880xxx)与基金行情原始解码价格即正确, 跳过修正。
 			if !protocol.IsStock(code) && !protocol.IsIndex(code) {
-				m := DefaultCodes.Get(code)
+				m := cs.Get(code)
 				if m == nil {
 					return nil, fmt.Errorf("未查询到代码[%s]相关信息", code)
 				}
@@ -568,6 +640,40 @@ func (this *Client) GetReportFile(file string) ([]byte, error) {
 	return buf, nil
 }
 
+// GetFinancialReportList 获取专业财务数据(gpcw)文件列表,来自 tdxfin/gpcw.txt,每个报告期一个文件
+func (this *Client) GetFinancialReportList() ([]*protocol.FinancialReportFile, error) {
+	raw, err := this.GetReportFile(protocol.FileGpcwList)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%s 无数据", protocol.FileGpcwList)
+	}
+	return protocol.ParseFinancialReportList(raw), nil
+}
+
+// GetFinancialReportFile 下载专业财务数据文件原始字节(zip),filename 例 gpcw20240930.zip
+func (this *Client) GetFinancialReportFile(filename string) ([]byte, error) {
+	raw, err := this.GetReportFile(protocol.DirGpcw + filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%s 无数据", filename)
+	}
+	return raw, nil
+}
+
+// GetFinancialReport 获取指定报告期的全市场专业财务数据,date 例 20240930
+// 单个文件较大(数MB),频繁使用请用 Gpcw 做本地缓存
+func (this *Client) GetFinancialReport(date string) ([]*protocol.FinancialReport, error) {
+	raw, err := this.GetFinancialReportFile("gpcw" + date + ".zip")
+	if err != nil {
+		return nil, err
+	}
+	return protocol.DecodeFinancialReportZip(raw)
+}
+
 // GetZHBFiles 下载板块/配置数据总包 zhb.zip(report file 0x06B9)并解压，返回 文件名→原始字节。
 // zhb.zip 内含 tdxzs.cfg(板块指数代码)、tdxbk.cfg(概念板块)、incon.dat(行业分类)等配置文件。
 func (this *Client) GetZHBFiles() (map[string][]byte, error) {
@@ -31,6 +31,16 @@ func DialExHqHosts(hosts []string, op ...client.Option) (*Client, error) {
 	return dialExHqWith(NewExRangeDial(hosts), op...)
 }
 
+const tagExTradingDay = "tdx.ex.trading.day"
+
+// WithExTradingDay 扩展行情的交易日判断,用于还原夜盘和美股的真实时间(见 protocol.ExSession),
+// 默认只按周一到周五(protocol.ExWeekday),可以传 tdx.Workday.Is 排除节假日,只在连接时设置一次
+func WithExTradingDay(f func(t time.Time) bool) client.Option {
+	return func(c *client.Client) {
+		c.Tag.Set(tagExTradingDay, f)
+	}
+}
+
 // dialExHqWith 建立扩展行情连接:握手用 ExSetup,心跳用品种数量请求。
 func dialExHqWith(dial ios.DialFunc, op ...client.Option) (cli *Client, err error) {
 	cli = &Client{
@@ -44,6 +54,9 @@ func dialExHqWith(dial ios.DialFunc, op ...client.Option) (cli *Client, err erro
 		c.SetOption(op...)
 		c.Event.OnReadFrom = protocol.ReadFrom
 		c.Event.OnDealMessage = cli.handlerDealMessage
+		if f, ok := c.Tag.Get(tagExTradingDay); ok && cli.exTradingDay == nil {
+			cli.exTradingDay, _ = f.(func(t time.Time) bool) //重连时不再修改
+		}
 		c.Event.OnConnected = func(c *client.Client) error {
 			// 握手(响应忽略)
 			if _, err := c.Write(protocol.MEx.FrameSetup().Bytes()); err != nil {
@@ -114,7 +127,7 @@ func (this *Client) ExQuoteList(market, category uint8, start, count uint16) ([]
 
 // ExBars K线(category 同标准 KLINE 类型)。
 func (this *Client) ExBars(category, market uint8, code string, start, count uint16) ([]protocol.ExKline, error) {
-	r, err := this.SendFrame(protocol.MEx.FrameBars(category, market, code, start, count), protocol.ExBarsCache{Category: category})
+	r, err := this.SendFrame(protocol.MEx.FrameBars(category, market, code, start, count), protocol.ExBarsCache{Category: category, Market: market, IsTradingDay: this.exTradingDay})
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +136,7 @@ func (this *Client) ExBars(category, market uint8, code string, start, count uin
 
 // ExMinute 当日分时。
 func (this *Client) ExMinute(market uint8, code string) ([]protocol.ExMinuteTick, error) {
-	r, err := this.SendFrame(protocol.MEx.FrameMinute(market, code))
+	r, err := this.SendFrame(protocol.MEx.FrameMinute(market, code), protocol.ExMinuteCache{Market: market, IsTradingDay: this.exTradingDay})
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +145,7 @@ func (this *Client) ExMinute(market uint8, code string) ([]protocol.ExMinuteTick
 
 // ExHistMinute 历史分时(date=YYYYMMDD)。
 func (this *Client) ExHistMinute(market uint8, code string, date uint32) ([]protocol.ExMinuteTick, error) {
-	r, err := this.SendFrame(protocol.MEx.FrameHistMinute(market, code, date))
+	r, err := this.SendFrame(protocol.MEx.FrameHistMinute(market, code, date), protocol.ExMinuteCache{Market: market, Date: date, IsTradingDay: this.exTradingDay})
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +154,7 @@ func (this *Client) ExHistMinute(market uint8, code string, date uint32) ([]prot
 
 // ExTrade 当日分笔成交。
 func (this *Client) ExTrade(market uint8, code string, start, count uint16) ([]protocol.ExTradeTick, error) {
-	r, err := this.SendFrame(protocol.MEx.FrameTrade(market, code, start, count), protocol.ExTradeCache{Market: market})
+	r, err := this.SendFrame(protocol.MEx.FrameTrade(market, code, start, count), protocol.ExTradeCache{Market: market, IsTradingDay: this.exTradingDay})
 	if err != nil {
 		return nil, err
 	}
@@ -150,7 +163,7 @@ func (this *Client) ExTrade(market uint8, code string, start, count uint16) ([]p
 
 // ExHistTrade 历史分笔成交(date=YYYYMMDD)。
 func (this *Client) ExHistTrade(market uint8, code string, date uint32, start, count uint16) ([]protocol.ExTradeTick, error) {
-	r, err := this.SendFrame(protocol.MEx.FrameHistTrade(market, code, date, start, count), protocol.ExTradeCache{Market: market})
+	r, err := this.SendFrame(protocol.MEx.FrameHistTrade(market, code, date, start, count), protocol.ExTradeCache{Market: market, Date: date, IsTradingDay: this.exTradingDay})
 	if err != nil {
 		return nil, err
 	}
@@ -159,7 +172,7 @@ func (this *Client) ExHistTrade(market uint8, code string, date uint32, start, c
 
 // ExBarsRange 历史K线区间(date/date2=YYYYMMDD)。
 func (this *Client) ExBarsRange(market uint8, code string, date, date2 uint32) ([]protocol.ExRangeKline, error) {
-	r, err := this.SendFrame(protocol.MEx.FrameBarsRange(market, code, date, date2))
+	r, err := this.SendFrame(protocol.MEx.FrameBarsRange(market, code, date, date2), protocol.ExBarsRangeCache{Market: market, IsTradingDay: this.exTradingDay})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// needArgs 检查参数数量
+func needArgs(args []string, min int, name string) error {
+	if len(args) < min {
+		return errors.New("缺少参数 " + name)
+	}
+	return nil
+}
+
+// parseDate 解析 20060102 或 2006-01-02
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("日期格式错误: " + s)
+}
+
+// dateArg 历史接口的日期参数,统一为 20060102
+func dateArg(s string) (string, error) {
+	t, err := parseDate(s)
+	if err != nil {
+		return "", err
+	}
+	return t.Format("20060102"), nil
+}
+
+func quoteCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "code"); err != nil {
+			return err
+		}
+		cfg, err := g.load()
+		if err != nil {
+			return err
+		}
+		return cfg.withClient(func(c *tdx.Client) (any, error) {
+			//ETF等非股票/指数代码需要 DefaultCodes 修正价格
+			for _, code := range args {
+				if code = protocol.AddPrefix(code); tdx.DefaultCodes == nil && !protocol.IsStock(code) && !protocol.IsIndex(code) {
+					codes, err := cfg.NewCodes(c)
+					if err != nil {
+						return nil, err
+					}
+					tdx.DefaultCodes = codes
+				}
+			}
+			return c.GetQuote(args...)
+		})
+	}
+}
+
+// klineArgs kline 命令的参数
+type klineArgs struct {
+	period string
+	index  bool
+	start  uint
+	count  uint
+	all    bool
+	from   string
+	to     string
+	fq     string
+}
+
+func klineCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	a := &klineArgs{}
+	fs.StringVar(&a.period, "period", "day", "周期 1m/5m/15m/30m/60m/day/week/month/quarter/year")
+	fs.BoolVar(&a.index, "index", false, "指数K线")
+	fs.UintVar(&a.start, "start", 0, "从最新往前的偏移")
+	fs.UintVar(&a.count, "count", 100, "数量,最多800")
+	fs.BoolVar(&a.all, "all", false, "全部K线")
+	fs.StringVar(&a.from, "from", "", "开始日期(含),例如 20240101")
+	fs.StringVar(&a.to, "to", "", "结束日期(含)")
+	fs.StringVar(&a.fq, "fq", "", "复权 qfq/hfq,只支持股票K线")
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "code"); err != nil {
+			return err
+		}
+		return withClient(g, func(c *tdx.Client) (any, error) {
+			return a.get(c, args[0])
+		})
+	}
+}
+
+func (this *klineArgs) get(c *tdx.Client, code string) (protocol.Klines, error) {
+	typ, ok := protocol.KlinePeriods[strings.ToLower(this.period)]
+	if !ok {
+		return nil, errors.New("未知的周期: " + this.period)
+	}
+	var from, to time.Time
+	var err error
+	if this.from != "" {
+		if from, err = parseDate(this.from); err != nil {
+			return nil, err
+		}
+	}
+	if this.to != "" {
+		if to, err = parseDate(this.to); err != nil {
+			return nil, err
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if this.fq != "" && (this.fq != "qfq" && this.fq != "hfq" || this.index) {
+		return nil, errors.New("复权只支持股票K线,fq 为 qfq 或 hfq")
+	}
+	//同 HTTP 服务,周及以上的复权K线由复权后的日线合成
+	group := protocol.KlineGroup(typ)
+	merge := this.fq != "" && group != nil
+
+	var ks, day protocol.Klines
+	if this.fq != "" {
+		//复权因子需要全部日线
+		resp, err := c.GetKlineDayAll(code)
+		if err != nil {
+			return nil, err
+		}
+		day = resp.List
+	}
+	switch {
+	case this.fq != "" && (merge || typ == protocol.TypeKlineDay):
+		ks = day
+	default:
+		var resp *protocol.KlineResp
+		switch {
+		case this.all || !from.IsZero():
+			until := func(k *protocol.Kline) bool { return !from.IsZero() && k.Time.Before(from) }
+			if this.index {
+				resp, err = c.GetIndexUntil(typ, code, until)
+			} else {
+				resp, err = c.GetKlineUntil(typ, code, until)
+			}
+		case this.index:
+			resp, err = c.GetIndex(typ, code, uint16(this.start), uint16(this.count))
+		default:
+			resp, err = c.GetKline(typ, code, uint16(this.start), uint16(this.count))
+		}
+		if err != nil {
+			return nil, err
+		}
+		ks = resp.List
+	}
+
+	if this.fq != "" {
+		g, err := c.GetGbbq(code)
+		if err != nil {
+			return nil, err
+		}
+		xs := protocol.XRXDs(nil)
+		for _, v := range g.List {
+			if v.IsXRXD() {
+				xs = append(xs, v.XRXD())
+			}
+		}
+		ks = protocol.ApplyFactors(ks, xs.Pre(day).Factors(), this.fq == "qfq")
+		if merge {
+			ks = protocol.MergeKlines(ks, group)
+		}
+	}
+
+	//日期范围
+	if !from.IsZero() || !to.IsZero() {
+		res := protocol.Klines{}
+		for _, k := range ks {
+			if (from.IsZero() || !k.Time.Before(from)) && (to.IsZero() || k.Time.Before(to)) {
+				res = append(res, k)
+			}
+		}
+		return res, nil
+	}
+	//复权时日线和合成的K线是全部获取的,按 start/count 分页
+	if this.fq != "" && (merge || typ == protocol.TypeKlineDay) && !this.all {
+		end := max(len(ks)-int(this.start), 0)
+		return ks[max(end-int(this.count), 0):end], nil
+	}
+	return ks, nil
+}
+
+func minuteCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	date := fs.String("date", "", "历史日期,例如 20240102,默认当天")
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "code"); err != nil {
+			return err
+		}
+		return withClient(g, func(c *tdx.Client) (any, error) {
+			if *date == "" {
+				return c.GetMinute(args[0])
+			}
+			d, err := dateArg(*date)
+			if err != nil {
+				return nil, err
+			}
+			return c.GetHistoryMinute(d, args[0])
+		})
+	}
+}
+
+func tradeCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	date := fs.String("date", "", "历史日期,例如 20240102,默认当天")
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "code"); err != nil {
+			return err
+		}
+		return withClient(g, func(c *tdx.Client) (any, error) {
+			if *date == "" {
+				return c.GetTradeAll(args[0])
+			}
+			d, err := dateArg(*date)
+			if err != nil {
+				return nil, err
+			}
+			return c.GetHistoryTradeDay(d, args[0])
+		})
+	}
+}
+
+func gbbqCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "code"); err != nil {
+			return err
+		}
+		return withClient(g, func(c *tdx.Client) (any, error) {
+			return c.GetGbbq(args[0])
+		})
+	}
+}
+
+func blockCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	file := fs.String("file", protocol.BlockFileGN, "板块文件")
+	index := fs.Bool("index", false, "带板块指数代码")
+	return func(g *globalFlags, args []string) error {
+		return withClient(g, func(c *tdx.Client) (any, error) {
+			if *index {
+				return c.GetBlockDataWithIndex(*file)
+			}
+			return c.GetBlockData(*file)
+		})
+	}
+}
+
+func reportCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	out := fs.String("out", "", "保存的文件,默认输出到标准输出")
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "file"); err != nil {
+			return err
+		}
+		cfg, err := g.load()
+		if err != nil {
+			return err
+		}
+		c, err := cfg.Dial()
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		bs, err := c.GetReportFile(args[0])
+		if err != nil {
+			return err
+		}
+		if *out != "" {
+			return os.WriteFile(*out, bs, 0o644)
+		}
+		_, err = os.Stdout.Write(bs)
+		return err
+	}
+}
+
+func exMarketsCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	return func(g *globalFlags, args []string) error {
+		return withExClient(g, func(c *tdx.Client) (any, error) {
+			return c.ExMarkets()
+		})
+	}
+}
+
+func exQuoteCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "market:code"); err != nil {
+			return err
+		}
+		return withExClient(g, func(c *tdx.Client) (any, error) {
+			ls := []*protocol.ExQuote(nil)
+			for _, v := range args {
+				market, code, err := splitExCode(v)
+				if err != nil {
+					return nil, err
+				}
+				q, err := c.ExQuote(market, code)
+				if err != nil {
+					return nil, err
+				}
+				ls = append(ls, q)
+			}
+			return ls, nil
+		})
+	}
+}
+
+// splitExCode 解析 market:code,例如 47:IF2506
+func splitExCode(s string) (uint8, string, error) {
+	m, code, ok := strings.Cut(s, ":")
+	if !ok || code == "" {
+		return 0, "", errors.New("格式应为 market:code: " + s)
+	}
+	market, err := strconv.ParseUint(m, 10, 8)
+	if err != nil {
+		return 0, "", errors.New("市场错误: " + s)
+	}
+	return uint8(market), code, nil
+}
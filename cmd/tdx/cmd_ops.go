@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/extend/daemon"
+	"github.com/injoyai/tdx/extend/httpserver"
+)
+
+// hostLatency 服务器测速结果
+type hostLatency struct {
+	Host    string  `json:"host"`
+	Latency float64 `json:"latency"` //连接耗时,毫秒
+	Error   string  `json:"error"`
+}
+
+func hostsProbeCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	ex := fs.Bool("ex", false, "扩展行情服务器")
+	return func(g *globalFlags, args []string) error {
+		cfg, err := g.load()
+		if err != nil {
+			return err
+		}
+		hosts, port := cfg.Hosts, "7709"
+		if *ex {
+			hosts, port = cfg.ExHosts, tdx.ExPort
+		}
+		return cfg.output(probeHosts(hosts, port, cfg.Timeout))
+	}
+}
+
+// probeHosts 并发测试 tcp 连接耗时,可用的按耗时排序在前
+func probeHosts(hosts []string, port string, timeout time.Duration) []*hostLatency {
+	ls := make([]*hostLatency, len(hosts))
+	wg := sync.WaitGroup{}
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			addr := host
+			if !strings.Contains(addr, ":") {
+				addr += ":" + port
+			}
+			ls[i] = &hostLatency{Host: host}
+			now := time.Now()
+			c, err := net.DialTimeout("tcp", addr, timeout)
+			if err != nil {
+				ls[i].Error = err.Error()
+				return
+			}
+			ls[i].Latency = float64(time.Since(now).Microseconds()) / 1000
+			c.Close()
+		}(i, host)
+	}
+	wg.Wait()
+	sort.SliceStable(ls, func(i, j int) bool {
+		if (ls[i].Error == "") != (ls[j].Error == "") {
+			return ls[i].Error == ""
+		}
+		return ls[i].Latency < ls[j].Latency
+	})
+	return ls
+}
+
+func pullKlineCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	types := fs.String("types", extend.Day, "K线类型,逗号分隔 day,minute")
+	goroutines := fs.Int("goroutines", 1, "协程数量")
+	start := fs.String("start", "", "数据开始日期,例如 20200101")
+	return func(g *globalFlags, args []string) error {
+		cfg, err := g.load()
+		if err != nil {
+			return err
+		}
+		pc := extend.PullKlineConfig{
+			Codes:      args,
+			Types:      splitList(*types),
+			Dir:        cfg.DataDir,
+			Goroutines: *goroutines,
+		}
+		if *start != "" {
+			if pc.StartAt, err = parseDate(*start); err != nil {
+				return err
+			}
+		}
+		pk, err := extend.NewPullKline(pc)
+		if err != nil {
+			return err
+		}
+		m, err := cfg.NewManage()
+		if err != nil {
+			return err
+		}
+		return pk.Update(m, true)
+	}
+}
+
+func pullTradeCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	year := fs.Int("year", 0, "只拉取某一年,默认全部")
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "code"); err != nil {
+			return err
+		}
+		cfg, err := g.load()
+		if err != nil {
+			return err
+		}
+		m, err := cfg.NewManage()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		pt := extend.NewPullTrade(filepath.Join(cfg.DataDir, "trade"))
+		for _, code := range args {
+			if *year > 0 {
+				err = pt.PullYear(ctx, m, *year, code)
+			} else {
+				err = pt.Pull(ctx, m, code)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", code, err)
+			}
+		}
+		return nil
+	}
+}
+
+func serveHTTPCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	addr := fs.String("addr", "", "监听地址,默认配置文件的 http.addr")
+	ex := fs.Bool("ex", false, "启用扩展行情路由,同配置文件的 http.ex")
+	return func(g *globalFlags, args []string) error {
+		cfg, err := g.load()
+		if err != nil {
+			return err
+		}
+		if *addr != "" {
+			cfg.HTTP.Addr = *addr
+		}
+		if *ex {
+			cfg.HTTP.Ex = true
+		}
+		s, err := httpserver.Default(cfg.serverOptions()...)
+		if err != nil {
+			return err
+		}
+		go func() {
+			ch := make(chan os.Signal, 1)
+			signal.Notify(ch, os.Interrupt)
+			<-ch
+			s.Close()
+		}()
+		fmt.Fprintln(os.Stderr, "HTTP 服务:", cfg.HTTP.Addr)
+		if err = s.Run(); errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+func daemonCmd(fs *flag.FlagSet) func(g *globalFlags, args []string) error {
+	return func(g *globalFlags, args []string) error {
+		if err := needArgs(args, 1, "file"); err != nil {
+			return err
+		}
+		cfg, err := daemon.LoadConfig(args[0])
+		if err != nil {
+			return err
+		}
+		d, err := daemon.New(cfg)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		return d.Run(ctx)
+	}
+}
+
+// serverOptions 配置对应的 HTTP 服务选项
+func (this *Config) serverOptions() []httpserver.Option {
+	h := this.HTTP
+	opts := []httpserver.Option{
+		httpserver.WithAddr(h.Addr),
+		httpserver.WithHosts(this.Hosts...),
+		httpserver.WithPoolSize(this.Pool),
+	}
+	if h.Ex {
+		opts = append(opts, httpserver.WithExHqHosts(this.ExHosts...), httpserver.WithExPoolSize(this.Pool))
+	}
+	if h.Cache != nil {
+		opts = append(opts, httpserver.WithCache(*h.Cache))
+	}
+	if h.CacheDir != "" {
+		opts = append(opts, httpserver.WithCacheDir(h.CacheDir))
+	}
+	if h.APIKeyFile != "" {
+		opts = append(opts, httpserver.WithAPIKeyFile(h.APIKeyFile))
+	}
+	if h.TokenSecret != "" {
+		opts = append(opts, httpserver.WithTokenSecret(h.TokenSecret))
+	}
+	if h.Metrics != nil {
+		opts = append(opts, httpserver.WithMetrics(*h.Metrics))
+	}
+	return opts
+}
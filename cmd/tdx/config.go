@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/lib/xorms"
+	"gopkg.in/yaml.v3"
+)
+
+// Config 配置文件,YAML(JSON 也可以),命令行参数优先于配置文件:
+//
+//	hosts: ["124.71.187.122", "121.36.81.195"]   # 标准行情服务器,默认 tdx.Hosts
+//	ex_hosts: ["112.74.214.43:7727"]             # 扩展行情服务器,默认 tdx.ExHosts
+//	pool: 2                                      # 连接池大小
+//	format: table                                # 输出格式 table/json/csv/ndjson/arrow
+//	timeout: 5s                                  # 请求超时
+//	data_dir: ./data/database                    # 数据目录(代码/工作日/拉取的K线等)
+//	http:                                        # serve http
+//	  addr: ":8080"
+//	  ex: true
+//	  cache: 1000
+//	  api_key_file: keys.json
+//
+// 未指定 -config 时依次查找 $TDX_CONFIG、./tdx.yaml、~/.tdx.yaml,都没有时使用默认值。
+type Config struct {
+	Hosts   []string      `yaml:"hosts"`
+	ExHosts []string      `yaml:"ex_hosts"`
+	Pool    int           `yaml:"pool"`
+	Format  string        `yaml:"format"`
+	Timeout time.Duration `yaml:"timeout"`
+	DataDir string        `yaml:"data_dir"`
+	HTTP    HTTPConfig    `yaml:"http"`
+}
+
+// HTTPConfig serve http 的配置,见 extend/httpserver 的选项
+type HTTPConfig struct {
+	Addr        string `yaml:"addr"`
+	Ex          bool   `yaml:"ex"` //启用扩展行情路由,使用 ex_hosts
+	Cache       *int   `yaml:"cache"`
+	CacheDir    string `yaml:"cache_dir"`
+	APIKeyFile  string `yaml:"api_key_file"`
+	TokenSecret string `yaml:"token_secret"`
+	Metrics     *bool  `yaml:"metrics"`
+}
+
+// LoadConfig 读取配置文件,filename 为空时按默认位置查找,找不到时返回默认配置
+func LoadConfig(filename string) (*Config, error) {
+	cfg := &Config{}
+	if filename == "" {
+		filename = findConfig()
+	}
+	if filename != "" {
+		bs, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(bs, cfg); err != nil {
+			return nil, errors.New(filename + ": " + err.Error())
+		}
+	}
+	cfg.fill()
+	return cfg, nil
+}
+
+func findConfig() string {
+	if f := os.Getenv("TDX_CONFIG"); f != "" {
+		return f
+	}
+	ls := []string{"tdx.yaml"}
+	if home, err := os.UserHomeDir(); err == nil {
+		ls = append(ls, filepath.Join(home, ".tdx.yaml"))
+	}
+	for _, f := range ls {
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	return ""
+}
+
+// fill 默认值
+func (this *Config) fill() {
+	if len(this.Hosts) == 0 {
+		this.Hosts = tdx.Hosts
+	}
+	if len(this.ExHosts) == 0 {
+		this.ExHosts = tdx.ExHosts
+	}
+	if this.Pool <= 0 {
+		this.Pool = 1
+	}
+	if this.Format == "" {
+		this.Format = formatTable
+	}
+	if this.Timeout <= 0 {
+		this.Timeout = 5 * time.Second
+	}
+	if this.DataDir == "" {
+		this.DataDir = tdx.DefaultDatabaseDir
+	}
+	if this.HTTP.Addr == "" {
+		this.HTTP.Addr = ":8080"
+	}
+}
+
+// Dial 连接标准行情
+func (this *Config) Dial() (*tdx.Client, error) {
+	c, err := tdx.DialHostsRange(this.Hosts, tdx.WithDebug(false))
+	if err != nil {
+		return nil, err
+	}
+	c.SetTimeout(this.Timeout)
+	return c, nil
+}
+
+// DialEx 连接扩展行情
+func (this *Config) DialEx() (*tdx.Client, error) {
+	c, err := tdx.DialExHqHosts(this.ExHosts, tdx.WithDebug(false))
+	if err != nil {
+		return nil, err
+	}
+	c.SetTimeout(this.Timeout)
+	return c, nil
+}
+
+// NewPool 标准行情连接池
+func (this *Config) NewPool() (*tdx.Pool, error) {
+	return tdx.NewPool(this.Dial, this.Pool)
+}
+
+// NewManage 代码、工作日管理,数据库在 data_dir
+func (this *Config) NewManage() (*tdx.Manage, error) {
+	return tdx.NewManage(
+		tdx.WithDialPool(func() (tdx.IPool, error) { return this.NewPool() }),
+		tdx.WithDialCodes(func(c *tdx.Client) (tdx.ICodes, error) { return this.NewCodes(c) }),
+		tdx.WithDialWorkday(func(c *tdx.Client) (*tdx.Workday, error) {
+			return tdx.NewWorkday(tdx.WithWorkdayClient(c), tdx.WithWorkdayDialDB(this.dialDB("workday.db")))
+		}),
+	)
+}
+
+// NewCodes 代码管理,数据库在 data_dir
+func (this *Config) NewCodes(c *tdx.Client) (*tdx.Codes, error) {
+	return tdx.NewCodes(tdx.WithCodesClient(c), tdx.WithCodesDialDB(this.dialDB("codes.db")))
+}
+
+func (this *Config) dialDB(name string) tdx.DialDBFunc {
+	return func() (*xorms.Engine, error) { return xorms.NewSqlite(filepath.Join(this.DataDir, name)) }
+}
+
+// globalFlags 每个子命令都有的参数,零值表示使用配置文件
+type globalFlags struct {
+	config  string
+	hosts   string
+	exHosts string
+	pool    int
+	format  string
+	timeout time.Duration
+	dataDir string
+}
+
+// register 注册到 fs,默认值为当前值,子命令前已设置的参数不会被覆盖
+func (this *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&this.config, "config", this.config, "配置文件,默认 $TDX_CONFIG、./tdx.yaml、~/.tdx.yaml")
+	fs.StringVar(&this.hosts, "hosts", this.hosts, "标准行情服务器,逗号分隔")
+	fs.StringVar(&this.exHosts, "ex-hosts", this.exHosts, "扩展行情服务器,逗号分隔")
+	fs.IntVar(&this.pool, "pool", this.pool, "连接池大小")
+	fs.StringVar(&this.format, "o", this.format, "输出格式 table/json/csv/ndjson/arrow")
+	fs.DurationVar(&this.timeout, "timeout", this.timeout, "请求超时")
+	fs.StringVar(&this.dataDir, "data", this.dataDir, "数据目录")
+}
+
+// load 读取配置文件并用命令行参数覆盖
+func (this *globalFlags) load() (*Config, error) {
+	cfg, err := LoadConfig(this.config)
+	if err != nil {
+		return nil, err
+	}
+	if this.hosts != "" {
+		cfg.Hosts = splitList(this.hosts)
+	}
+	if this.exHosts != "" {
+		cfg.ExHosts = splitList(this.exHosts)
+	}
+	if this.pool > 0 {
+		cfg.Pool = this.pool
+	}
+	if this.format != "" {
+		cfg.Format = this.format
+	}
+	if this.timeout > 0 {
+		cfg.Timeout = this.timeout
+	}
+	if this.dataDir != "" {
+		cfg.DataDir = this.dataDir
+	}
+	switch cfg.Format {
+	case formatTable, formatJSON, "csv", "ndjson", "arrow":
+	default:
+		return nil, errors.New("未知的输出格式: " + cfg.Format)
+	}
+	return cfg, nil
+}
+
+// splitList 逗号分隔,去掉空白和空项
+func splitList(s string) []string {
+	ls := []string(nil)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ls = append(ls, v)
+		}
+	}
+	return ls
+}
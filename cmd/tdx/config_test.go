@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx"
+)
+
+func TestLoadConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "tdx.yaml")
+	err := os.WriteFile(filename, []byte(`
+hosts: ["1.1.1.1", "2.2.2.2:7709"]
+pool: 3
+timeout: 2s
+http:
+  addr: ":9090"
+  cache: 0
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Hosts) != 2 || cfg.Pool != 3 || cfg.Timeout != 2*time.Second || cfg.HTTP.Addr != ":9090" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	//未配置的使用默认值
+	if cfg.Format != formatTable || cfg.DataDir != tdx.DefaultDatabaseDir || len(cfg.ExHosts) != len(tdx.ExHosts) {
+		t.Errorf("默认值 = %+v", cfg)
+	}
+	//cache: 0 表示关闭缓存,和未配置不同
+	if cfg.HTTP.Cache == nil || *cfg.HTTP.Cache != 0 || cfg.HTTP.Metrics != nil {
+		t.Errorf("http = %+v", cfg.HTTP)
+	}
+
+	//命令行参数优先,子命令前后的参数都生效
+	g := &globalFlags{}
+	root := flag.NewFlagSet("tdx", flag.ContinueOnError)
+	g.register(root)
+	if err := root.Parse([]string{"-config", filename, "-o", "csv", "quote"}); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("quote", flag.ContinueOnError)
+	g.register(fs)
+	if err := fs.Parse([]string{"-hosts", " 3.3.3.3, ,", "sz000001"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = g.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Format != "csv" || len(cfg.Hosts) != 1 || cfg.Hosts[0] != "3.3.3.3" || cfg.Pool != 3 {
+		t.Errorf("cfg = %+v", cfg)
+	}
+
+	g.format = "xml"
+	if _, err := g.load(); err == nil {
+		t.Error("未知的输出格式应返回错误")
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "none.yaml")); err == nil {
+		t.Error("指定的配置文件不存在应返回错误")
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		name string
+		rest int
+	}{
+		{[]string{"quote", "sz000001"}, "quote", 1},
+		{[]string{"pull", "kline", "-types", "day"}, "pull kline", 2},
+		{[]string{"ex", "quote", "47:IF2506"}, "ex quote", 1},
+		{[]string{"pull"}, "", 1},
+		{nil, "", 0},
+	} {
+		cmd, rest := findCommand(c.args)
+		name := ""
+		if cmd != nil {
+			name = cmd.name
+		}
+		if name != c.name || len(rest) != c.rest {
+			t.Errorf("%v: %q %v", c.args, name, rest)
+		}
+	}
+
+	if m, code, err := splitExCode("47:IF2506"); err != nil || m != 47 || code != "IF2506" {
+		t.Errorf("splitExCode = %d %s %v", m, code, err)
+	}
+	for _, s := range []string{"IF2506", "x:IF2506", "47:", "300:IF2506"} {
+		if _, _, err := splitExCode(s); err == nil {
+			t.Errorf("%s 应返回错误", s)
+		}
+	}
+}
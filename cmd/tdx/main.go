@@ -0,0 +1,115 @@
+// tdx 命令行工具,把库的常用功能(行情、K线、分时、成交、股本变迁、板块、报表、
+// 服务器测速、数据拉取、HTTP 服务、扩展行情)放在一个命令下:
+//
+//	tdx quote sz000001 sh600000
+//	tdx kline -period day -count 10 -fq qfq sz000001
+//	tdx -o csv trade -date 20240102 sz000001     (公共参数也可以放在子命令前)
+//	tdx pull kline -types day,minute sz000001
+//	tdx serve http
+//
+// 公共参数见 globalFlags,配置文件见 Config。
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// command 子命令,name 可以是两级,例如 "pull kline"
+type command struct {
+	name  string
+	args  string
+	desc  string
+	flags func(fs *flag.FlagSet) func(g *globalFlags, args []string) error //注册参数,返回执行函数
+}
+
+var commands = []*command{
+	{name: "quote", args: "<code>...", desc: "五档行情", flags: quoteCmd},
+	{name: "kline", args: "<code>", desc: "K线,支持周期、日期范围和复权", flags: klineCmd},
+	{name: "minute", args: "<code>", desc: "分时,-date 为历史分时", flags: minuteCmd},
+	{name: "trade", args: "<code>", desc: "分时成交,-date 为历史成交", flags: tradeCmd},
+	{name: "gbbq", args: "<code>", desc: "股本变迁(除权除息)", flags: gbbqCmd},
+	{name: "block", args: "", desc: "板块", flags: blockCmd},
+	{name: "report", args: "<file>", desc: "下载报表文件,例如 tdxfin/gpcw.txt", flags: reportCmd},
+	{name: "hosts probe", args: "", desc: "服务器测速,按连接耗时排序", flags: hostsProbeCmd},
+	{name: "pull kline", args: "[code]...", desc: "拉取K线到数据目录,不指定代码时为全部股票、ETF和指数", flags: pullKlineCmd},
+	{name: "pull trade", args: "<code>...", desc: "拉取历史成交到数据目录", flags: pullTradeCmd},
+	{name: "serve http", args: "", desc: "启动 HTTP 服务,见 extend/httpserver", flags: serveHTTPCmd},
+	{name: "daemon", args: "<file>", desc: "按配置文件定时执行数据任务,见 extend/daemon", flags: daemonCmd},
+	{name: "ex markets", args: "", desc: "扩展行情市场列表", flags: exMarketsCmd},
+	{name: "ex quote", args: "<market:code>...", desc: "扩展行情报价,例如 47:IF2506", flags: exQuoteCmd},
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "错误:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	//子命令前的公共参数
+	g := &globalFlags{}
+	root := flag.NewFlagSet("tdx", flag.ContinueOnError)
+	root.Usage = usage
+	g.register(root)
+	if err := root.Parse(args); err != nil {
+		return err
+	}
+	args = root.Args()
+
+	cmd, args := findCommand(args)
+	if cmd == nil {
+		usage()
+		return flag.ErrHelp
+	}
+
+	//子命令的参数,公共参数也可以放在这里
+	fs := flag.NewFlagSet("tdx "+cmd.name, flag.ContinueOnError)
+	g.register(fs)
+	exec := cmd.flags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: tdx %s [参数] %s\n  %s\n\n", cmd.name, cmd.args, cmd.desc)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return exec(g, fs.Args())
+}
+
+// findCommand 按一级或两级名称查找子命令
+func findCommand(args []string) (*command, []string) {
+	for _, n := range []int{2, 1} {
+		if len(args) < n {
+			continue
+		}
+		name := strings.Join(args[:n], " ")
+		for _, c := range commands {
+			if c.name == name {
+				return c, args[n:]
+			}
+		}
+	}
+	return nil, args
+}
+
+func usage() {
+	w := os.Stderr
+	fmt.Fprintln(w, "用法: tdx [公共参数] <命令> [参数]")
+	fmt.Fprintln(w, "\n命令:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %-18s %s\n", c.name, c.args, c.desc)
+	}
+	fmt.Fprintln(w, "\n公共参数(也可以放在命令后):")
+	fs := flag.NewFlagSet("tdx", flag.ContinueOnError)
+	fs.SetOutput(w)
+	(&globalFlags{}).register(fs)
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\n查看命令的参数: tdx <命令> -h")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend/httpserver"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// output 按配置的格式输出到标准输出,不能表格化的数据(或 -o json)输出缩进的 JSON
+func (this *Config) output(data any) error {
+	if this.Format != formatJSON {
+		ok, err := httpserver.WriteTable(os.Stdout, this.Format, data)
+		if ok || err != nil {
+			return err
+		}
+	}
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	return e.Encode(data)
+}
+
+// withClient 连接标准行情,执行 fn 并输出结果
+func withClient(g *globalFlags, fn func(c *tdx.Client) (any, error)) error {
+	cfg, err := g.load()
+	if err != nil {
+		return err
+	}
+	return cfg.withClient(fn)
+}
+
+func (this *Config) withClient(fn func(c *tdx.Client) (any, error)) error {
+	c, err := this.Dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	data, err := fn(c)
+	if err != nil {
+		return err
+	}
+	return this.output(data)
+}
+
+// withExClient 连接扩展行情,同 withClient
+func withExClient(g *globalFlags, fn func(c *tdx.Client) (any, error)) error {
+	cfg, err := g.load()
+	if err != nil {
+		return err
+	}
+	c, err := cfg.DialEx()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	data, err := fn(c)
+	if err != nil {
+		return err
+	}
+	return cfg.output(data)
+}
@@ -0,0 +1,82 @@
+package tdx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// CompanyCache F10 原文缓存,键为 市场+代码 和分类的 Filename/Start/Length,
+// 资讯文件可能是多个代码共用的,偏移和长度只在同一个代码下唯一,
+// 资讯更新后服务端返回的偏移或长度会变化,所以同一个键的内容不会变
+type CompanyCache interface {
+	Get(exchange protocol.Exchange, code string, cat protocol.CompanyCategory) (string, bool)
+	Set(exchange protocol.Exchange, code string, cat protocol.CompanyCategory, text string) error
+}
+
+// NewCompanyFileCache 本地文件缓存,dir 默认 ./data/f10
+func NewCompanyFileCache(dir string) *CompanyFileCache {
+	if dir == "" {
+		dir = filepath.Join(DefaultDataDir, "f10")
+	}
+	return &CompanyFileCache{Dir: dir}
+}
+
+// CompanyFileCache 每个分类一个文本文件,<Dir>/<市场+代码>/<资讯文件名>/<start>_<length>.txt
+type CompanyFileCache struct {
+	Dir string
+}
+
+// Filename 分类对应的缓存文件
+func (this *CompanyFileCache) Filename(exchange protocol.Exchange, code string, cat protocol.CompanyCategory) string {
+	name := strings.TrimSuffix(filepath.Base(cat.Filename), filepath.Ext(cat.Filename))
+	return filepath.Join(this.Dir, exchange.String()+code, name, fmt.Sprintf("%d_%d.txt", cat.Start, cat.Length))
+}
+
+func (this *CompanyFileCache) Get(exchange protocol.Exchange, code string, cat protocol.CompanyCategory) (string, bool) {
+	bs, err := os.ReadFile(this.Filename(exchange, code, cat))
+	if err != nil {
+		return "", false
+	}
+	return string(bs), true
+}
+
+func (this *CompanyFileCache) Set(exchange protocol.Exchange, code string, cat protocol.CompanyCategory, text string) error {
+	filename := this.Filename(exchange, code, cat)
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, []byte(text), 0666)
+}
+
+// GetCompanyF10 获取全部 F10 分类并解析常用内容(公司概况/股东/股本/分红/高管/主营构成/财务分析),
+// cache 可为nil,不为nil时优先读取缓存,未命中再请求并写入缓存
+func (this *Client) GetCompanyF10(exchange protocol.Exchange, code string, cache CompanyCache) (*protocol.CompanyF10, error) {
+	cats, err := this.GetCompanyCategory(exchange, code)
+	if err != nil {
+		return nil, err
+	}
+	f := &protocol.CompanyF10{Code: exchange.String() + code}
+	for _, cat := range cats {
+		text, ok := "", false
+		if cache != nil {
+			text, ok = cache.Get(exchange, code, cat)
+		}
+		if !ok {
+			text, err = this.GetCompanyContent(exchange, code, cat.Filename, cat.Start, cat.Length)
+			if err != nil {
+				return nil, err
+			}
+			if cache != nil {
+				if err = cache.Set(exchange, code, cat, text); err != nil {
+					return nil, err
+				}
+			}
+		}
+		f.Add(cat.Name, text)
+	}
+	return f, nil
+}
@@ -0,0 +1,25 @@
+package tdx
+
+import (
+	"testing"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+func TestCompanyFileCache(t *testing.T) {
+	c := NewCompanyFileCache(t.TempDir())
+	cat := protocol.CompanyCategory{Name: "公司概况", Filename: "600000.txt", Start: 0, Length: 100}
+	if err := c.Set(protocol.ExchangeSH, "600000", cat, "浦发银行"); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := c.Get(protocol.ExchangeSH, "600000", cat); !ok || s != "浦发银行" {
+		t.Errorf("Get = %q %v", s, ok)
+	}
+	//同一个资讯文件和偏移,不同代码不能命中
+	if _, ok := c.Get(protocol.ExchangeSZ, "600000", cat); ok {
+		t.Error("不同市场不应命中缓存")
+	}
+	if _, ok := c.Get(protocol.ExchangeSH, "600001", cat); ok {
+		t.Error("不同代码不应命中缓存")
+	}
+}
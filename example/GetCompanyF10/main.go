@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+func main() {
+	c, err := tdx.DialDefault()
+	logs.PanicErr(err)
+
+	//原文按 Filename/Start/Length 缓存到本地,资讯未更新时不再请求
+	cache := tdx.NewCompanyFileCache("./output/f10")
+	f, err := c.GetCompanyF10(protocol.ExchangeSZ, "000001", cache)
+	logs.PanicErr(err)
+
+	for _, v := range f.Sections {
+		logs.Debug(v.Name, "表格数量:", len(v.Tables))
+	}
+	logs.Debug("公司名称:", f.Profile["公司名称"])
+	for _, v := range f.Holders {
+		logs.Debugf("%s %s %s 持股:%.0f 占比:%.2f%% 增减:%s\n", v.Kind, v.Date, v.Name, v.Shares, v.Ratio, v.Change)
+	}
+	for _, v := range f.Dividend {
+		logs.Debug(v.Year, v.Plan, v.ExAt)
+	}
+	for _, v := range f.Segments {
+		logs.Debugf("%s %s %s 收入:%.0f 占比:%.2f%%\n", v.Kind, v.Date, v.Name, v.Revenue, v.RevenueRatio)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+)
+
+func main() {
+	c, err := tdx.DialDefault()
+	logs.PanicErr(err)
+
+	//文件列表,每个报告期一个文件
+	files, err := c.GetFinancialReportList()
+	logs.PanicErr(err)
+	for _, v := range files {
+		logs.Debug(v.Filename, v.Size)
+	}
+
+	//直接获取某个报告期
+	ls, err := c.GetFinancialReport("20240930")
+	logs.PanicErr(err)
+	logs.Debug("数量:", len(ls))
+
+	//本地缓存,按md5增量更新
+	g, err := tdx.NewGpcw(tdx.WithGpcwClient(c), tdx.WithGpcwDir("./output/gpcw"))
+	logs.PanicErr(err)
+
+	fs, err := g.GetCode("sz000001")
+	logs.PanicErr(err)
+	for _, v := range fs {
+		logs.Debugf("%d 每股收益:%.3f ROE:%.2f 营业收入:%.0f 归母净利润:%.0f\n", v.Date, v.EPS, v.ROE, v.Revenue, v.NetProfitParent)
+	}
+}
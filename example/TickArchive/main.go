@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend"
+)
+
+func main() {
+
+	m, err := tdx.NewManage()
+	logs.PanicErr(err)
+
+	ta := extend.NewTickArchive("./output/tick")
+
+	//补全今年的分笔成交
+	start := time.Date(time.Now().Year(), 1, 1, 0, 0, 0, 0, time.Local)
+	err = ta.Backfill(context.Background(), m, []string{"sz000001", "sh600000"}, start, time.Now())
+	logs.PanicErr(err)
+
+	//读取归档并合成5分钟K线
+	ks, err := ta.ReadKlines("sz000001", start, time.Now())
+	logs.PanicErr(err)
+	for _, v := range ks.Merge(5) {
+		logs.Debug(v)
+	}
+
+}
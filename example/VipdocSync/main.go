@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend"
+)
+
+func main() {
+
+	m, err := tdx.NewManage()
+	logs.PanicErr(err)
+
+	//生成 ./output/vipdoc-sync/vipdoc/<sh|sz|bj>/{lday,minline,fzline}
+	//可直接将通达信客户端或pytdx指向该目录,重复运行为增量同步
+	vs := extend.NewVipdocSync("./output/vipdoc-sync")
+
+	err = vs.Sync(context.Background(), m, "sz000001", "sh600000", "sh000001")
+	logs.PanicErr(err)
+
+	//全市场同步
+	//err = vs.SyncAll(context.Background(), m)
+
+	ks, err := extend.ReadDay("./output/vipdoc-sync", "sz000001")
+	logs.PanicErr(err)
+	logs.Debug("日线数量:", len(ks))
+
+}
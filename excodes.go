@@ -0,0 +1,460 @@
+package tdx
+
+import (
+	"errors"
+	"iter"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/tdx/lib/xorms"
+	"github.com/injoyai/tdx/protocol"
+	"xorm.io/xorm"
+)
+
+// 扩展行情(7727)的代码表,和 Codes 一样持久化到数据库并定时更新,
+// 按 市场/分类/代码/期货品种/名称 查询,不用记 31、47 这样的市场编号(见 protocol.ExchangeHK 等)。
+
+type ExCodesOption func(*ExCodes)
+
+type IExCodes interface {
+	Iter() iter.Seq2[string, *ExCodeModel]
+	Get(market protocol.Exchange, code string) *ExCodeModel
+	GetCode(code string) ExCodeModels
+	GetMarket(market protocol.Exchange) ExCodeModels
+	GetCategory(category uint8) ExCodeModels
+	GetRoot(root string) ExCodeModels
+	Search(keyword string, limit ...int) ExCodeModels
+	Markets() []*protocol.ExMarket
+	FindMarket(name string) (protocol.Exchange, bool)
+}
+
+func WithExCodesDB(db *xorms.Engine) ExCodesOption {
+	return func(c *ExCodes) {
+		c.db = db
+	}
+}
+
+func WithExCodesDialDB(dial DialDBFunc) ExCodesOption {
+	return func(c *ExCodes) {
+		c.dialDB = dial
+	}
+}
+
+func WithExCodesSpec(spec string) ExCodesOption {
+	return func(c *ExCodes) {
+		c.spec = spec
+	}
+}
+
+func WithExCodesRetry(retry int) ExCodesOption {
+	return func(c *ExCodes) {
+		c.retry = retry
+	}
+}
+
+// WithExCodesClient 扩展行情客户端,见 DialExHqDefault
+func WithExCodesClient(c *Client) ExCodesOption {
+	return func(cs *ExCodes) {
+		cs.c = c
+	}
+}
+
+func WithExCodesDialClient(dial DialClientFunc) ExCodesOption {
+	return func(c *ExCodes) {
+		c.dialClient = dial
+	}
+}
+
+func WithExCodesOption(op ...ExCodesOption) ExCodesOption {
+	return func(c *ExCodes) {
+		for _, v := range op {
+			if v != nil {
+				v(c)
+			}
+		}
+	}
+}
+
+func NewExCodesMysql(dsn string, op ...ExCodesOption) (*ExCodes, error) {
+	return NewExCodes(
+		WithExCodesDialDB(func() (*xorms.Engine, error) {
+			return xorms.NewMysql(dsn)
+		}),
+		WithExCodesOption(op...),
+	)
+}
+
+func NewExCodesSqlite(op ...ExCodesOption) (*ExCodes, error) {
+	return NewExCodes(op...)
+}
+
+func NewExCodes(op ...ExCodesOption) (*ExCodes, error) {
+	cs := &ExCodes{
+		spec:        DefaultExCodesSpec,
+		retry:       DefaultRetry,
+		ExCodesBase: NewExCodesBase(),
+		updateKey:   "excodes",
+	}
+
+	WithExCodesOption(op...)(cs)
+
+	var err error
+
+	// 初始化连接
+	if cs.c == nil {
+		if cs.dialClient == nil {
+			cs.dialClient = func() (*Client, error) { return DialExHqDefault() }
+		}
+		cs.c, err = cs.dialClient()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 初始化数据库
+	if cs.db == nil {
+		if cs.dialDB == nil {
+			cs.dialDB = func() (*xorms.Engine, error) { return xorms.NewSqlite(filepath.Join(DefaultDatabaseDir, "excodes.db")) }
+		}
+		cs.db, err = cs.dialDB()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = cs.db.Sync2(new(ExCodeModel)); err != nil {
+		return nil, err
+	}
+	cs.updated, err = NewUpdated(cs.db, 9, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 立即/定时更新
+	err = NewTimer(cs.spec, cs.retry, cs)
+
+	return cs, err
+}
+
+var _ IExCodes = &ExCodes{}
+
+type ExCodes struct {
+	spec  string //定时规则
+	retry int    //重试次数
+
+	dialDB     DialDBFunc
+	dialClient DialClientFunc
+
+	/*
+		内部字段
+	*/
+
+	c         *Client
+	db        *xorms.Engine
+	updateKey string
+	updated   *Updated
+
+	*ExCodesBase
+}
+
+func (this *ExCodes) Update() error {
+	codes, err := this.update()
+	if err != nil {
+		return err
+	}
+	this.ExCodesBase.Update(codes)
+	return nil
+}
+
+// update 更新扩展代码表并返回结果,当天更新过则直接返回数据库的
+func (this *ExCodes) update() ([]*ExCodeModel, error) {
+
+	if this.c == nil {
+		return nil, errors.New("client is nil")
+	}
+
+	list := []*ExCodeModel(nil)
+	if err := this.db.Find(&list); err != nil {
+		return nil, err
+	}
+
+	//如果更新过,则不更新
+	updated, err := this.updated.Updated(this.updateKey)
+	if err == nil && updated && len(list) > 0 {
+		return list, nil
+	}
+
+	list, err = this.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.db.SessionFunc(func(session *xorm.Session) error {
+		if _, err = session.Where("1=1").Delete(new(ExCodeModel)); err != nil {
+			return err
+		}
+		for i := 0; i < len(list); i += exCodesBatch {
+			if _, err = session.Insert(list[i:min(i+exCodesBatch, len(list))]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	//更新时间
+	err = this.updated.Update(this.updateKey)
+	return list, err
+}
+
+const exCodesBatch = 500 //每页品种数量,也是批量写入的数量
+
+// fetch 按 ExCount 分页获取全部品种,加上市场名称
+func (this *ExCodes) fetch() ([]*ExCodeModel, error) {
+	markets, err := this.c.ExMarkets()
+	if err != nil {
+		return nil, err
+	}
+	mMarket := make(map[uint8]protocol.ExMarket, len(markets))
+	for _, v := range markets {
+		mMarket[uint8(v.Market)] = v
+	}
+
+	count, err := this.c.ExCount()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*ExCodeModel, 0, count)
+	for start := 0; start < count; start += exCodesBatch {
+		ls, err := this.c.ExInstruments(uint32(start), exCodesBatch)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range ls {
+			list = append(list, NewExCodeModel(v, mMarket[v.Market]))
+		}
+		if len(ls) < exCodesBatch {
+			break
+		}
+	}
+	return list, nil
+}
+
+/*
+
+
+
+ */
+
+// ExCodeModel 扩展品种
+type ExCodeModel struct {
+	ID          int64  `json:"id"`                      //主键
+	Market      uint8  `json:"market" xorm:"index"`     //市场,同 protocol.Exchange,例 47=中金期货
+	Category    uint8  `json:"category"`                //分类,2=港股 3=期货
+	Code        string `json:"code" xorm:"index"`       //代码,例 IF2506
+	Name        string `json:"name"`                    //名称
+	Desc        string `json:"desc"`                    //描述
+	Root        string `json:"root" xorm:"index"`       //期货品种,例 IF、rb,不是期货合约时为空
+	MarketName  string `json:"marketName"`              //市场名称,例 中金所期货
+	MarketShort string `json:"marketShort"`             //市场简称
+	EditDate    int64  `json:"editDate" xorm:"updated"` //修改时间
+	InDate      int64  `json:"inDate" xorm:"created"`   //创建时间
+}
+
+func (*ExCodeModel) TableName() string {
+	return "excodes"
+}
+
+// NewExCodeModel 品种加上市场信息
+func NewExCodeModel(v protocol.ExInstrument, m protocol.ExMarket) *ExCodeModel {
+	return &ExCodeModel{
+		Market:      v.Market,
+		Category:    v.Category,
+		Code:        v.Code,
+		Name:        v.Name,
+		Desc:        v.Desc,
+		Root:        FuturesRoot(v.Code),
+		MarketName:  m.Name,
+		MarketShort: m.ShortName,
+	}
+}
+
+// FullCode 市场:代码,例 47:IF2506,和 HTTP 服务的扩展行情代码格式相同
+func (this *ExCodeModel) FullCode() string {
+	return strconv.Itoa(int(this.Market)) + ":" + this.Code
+}
+
+// Exchange 市场
+func (this *ExCodeModel) Exchange() protocol.Exchange {
+	return protocol.Exchange(this.Market)
+}
+
+type ExCodeModels []*ExCodeModel
+
+func (this ExCodeModels) Codes() []string {
+	codes := make([]string, len(this))
+	for i, v := range this {
+		codes[i] = v.FullCode()
+	}
+	return codes
+}
+
+// FuturesRoot 期货合约的品种,例 IF2506→IF、rb2510→rb、SR509→SR、IFL8(主连)→IF,
+// 不是 字母+数字 的代码返回空
+func FuturesRoot(code string) string {
+	i := 0
+	for i < len(code) && (code[i] >= 'a' && code[i] <= 'z' || code[i] >= 'A' && code[i] <= 'Z') {
+		i++
+	}
+	if i == 0 || i == len(code) {
+		return ""
+	}
+	for _, c := range code[i:] {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	//通达信的主连/指数 L8、L9
+	if i > 1 && len(code)-i == 1 && (code[i-1] == 'L' || code[i-1] == 'l') {
+		i--
+	}
+	return code[:i]
+}
+
+/*
+
+
+
+ */
+
+var _ IExCodes = &ExCodesBase{}
+
+func NewExCodesBase() *ExCodesBase {
+	c := &ExCodesBase{}
+	c.Update(nil)
+	return c
+}
+
+// ExCodesBase 内存中的扩展代码表
+type ExCodesBase struct {
+	list    []*ExCodeModel
+	m       map[string]*ExCodeModel //市场:代码
+	code    map[string]ExCodeModels //代码(大写)
+	root    map[string]ExCodeModels //品种(大写)
+	markets []*protocol.ExMarket
+	mu      sync.RWMutex
+}
+
+func (this *ExCodesBase) Update(ls []*ExCodeModel) {
+	m := make(map[string]*ExCodeModel, len(ls))
+	code := make(map[string]ExCodeModels, len(ls))
+	root := make(map[string]ExCodeModels)
+	markets := []*protocol.ExMarket(nil)
+	seen := map[uint8]bool{}
+	for _, v := range ls {
+		m[v.FullCode()] = v
+		upper := strings.ToUpper(v.Code)
+		code[upper] = append(code[upper], v)
+		if v.Root != "" {
+			upper = strings.ToUpper(v.Root)
+			root[upper] = append(root[upper], v)
+		}
+		if !seen[v.Market] {
+			seen[v.Market] = true
+			markets = append(markets, &protocol.ExMarket{Market: uint16(v.Market), Category: v.Category, Name: v.MarketName, ShortName: v.MarketShort})
+		}
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.list, this.m, this.code, this.root, this.markets = ls, m, code, root, markets
+}
+
+func (this *ExCodesBase) Iter() iter.Seq2[string, *ExCodeModel] {
+	this.mu.RLock()
+	ls := this.list
+	this.mu.RUnlock()
+	return func(yield func(string, *ExCodeModel) bool) {
+		for _, v := range ls {
+			if !yield(v.FullCode(), v) {
+				return
+			}
+		}
+	}
+}
+
+// Get 按市场和代码查询,代码区分大小写(期货代码有大小写之分,例 rb2510)
+func (this *ExCodesBase) Get(market protocol.Exchange, code string) *ExCodeModel {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.m[strconv.Itoa(int(market))+":"+code]
+}
+
+// GetCode 按代码查询各个市场的品种,不区分大小写
+func (this *ExCodesBase) GetCode(code string) ExCodeModels {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.code[strings.ToUpper(code)]
+}
+
+// GetMarket 市场的全部品种,例 protocol.ExchangeCFF
+func (this *ExCodesBase) GetMarket(market protocol.Exchange) ExCodeModels {
+	return this.filter(-1, func(v *ExCodeModel) bool { return v.Market == uint8(market) })
+}
+
+// GetCategory 分类的全部品种,2=港股 3=期货
+func (this *ExCodesBase) GetCategory(category uint8) ExCodeModels {
+	return this.filter(-1, func(v *ExCodeModel) bool { return v.Category == category })
+}
+
+// GetRoot 期货品种的全部合约(含主连等),不区分大小写,例 rb、IF
+func (this *ExCodesBase) GetRoot(root string) ExCodeModels {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.root[strings.ToUpper(root)]
+}
+
+// Search 代码或名称包含关键字的品种,不区分大小写
+func (this *ExCodesBase) Search(keyword string, limits ...int) ExCodeModels {
+	keyword = strings.ToUpper(keyword)
+	return this.filter(conv.Default(-1, limits...), func(v *ExCodeModel) bool {
+		return strings.Contains(strings.ToUpper(v.Code), keyword) || strings.Contains(strings.ToUpper(v.Name), keyword)
+	})
+}
+
+// Markets 有品种的市场
+func (this *ExCodesBase) Markets() []*protocol.ExMarket {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.markets
+}
+
+// FindMarket 按市场名称或简称查询市场,例 中金所期货、CZ
+func (this *ExCodesBase) FindMarket(name string) (protocol.Exchange, bool) {
+	for _, v := range this.Markets() {
+		if v.Name == name || strings.EqualFold(v.ShortName, name) {
+			return protocol.Exchange(v.Market), true
+		}
+	}
+	return 0, false
+}
+
+func (this *ExCodesBase) filter(limit int, f func(v *ExCodeModel) bool) ExCodeModels {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	ls := ExCodeModels(nil)
+	for _, v := range this.list {
+		if f(v) {
+			ls = append(ls, v)
+			if limit > 0 && len(ls) >= limit {
+				break
+			}
+		}
+	}
+	return ls
+}
@@ -0,0 +1,91 @@
+package tdx
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/injoyai/tdx/lib/xorms"
+	"github.com/injoyai/tdx/protocol"
+)
+
+func TestFuturesRoot(t *testing.T) {
+	for code, root := range map[string]string{
+		"IF2506": "IF", "rb2510": "rb", "SR509": "SR", "IFL8": "IF", "rbL9": "rb",
+		"00700": "", "AAPL": "", "IO2506-C-3800": "", "L2509": "L", "": "",
+	} {
+		if got := FuturesRoot(code); got != root {
+			t.Errorf("FuturesRoot(%s) = %q, want %q", code, got, root)
+		}
+	}
+}
+
+func TestExCodesBase(t *testing.T) {
+	cff := protocol.ExMarket{Market: 47, Category: 3, Name: "中金所期货", ShortName: "CZ"}
+	shf := protocol.ExMarket{Market: 30, Category: 3, Name: "上海期货", ShortName: "QS"}
+	hk := protocol.ExMarket{Market: 31, Category: 2, Name: "香港主板", ShortName: "KH"}
+	ls := []*ExCodeModel{
+		NewExCodeModel(protocol.ExInstrument{Category: 3, Market: 47, Code: "IF2506", Name: "沪深2506"}, cff),
+		NewExCodeModel(protocol.ExInstrument{Category: 3, Market: 47, Code: "IF2509", Name: "沪深2509"}, cff),
+		NewExCodeModel(protocol.ExInstrument{Category: 3, Market: 30, Code: "rb2510", Name: "螺纹钢2510"}, shf),
+		NewExCodeModel(protocol.ExInstrument{Category: 2, Market: 31, Code: "00700", Name: "腾讯控股"}, hk),
+	}
+
+	//写入数据库再读出,和 ExCodes.update 一样批量写入
+	db, err := xorms.NewSqlite(filepath.Join(t.TempDir(), "excodes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Sync2(new(ExCodeModel)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Insert(ls); err != nil {
+		t.Fatal(err)
+	}
+	ls = nil
+	if err = db.Find(&ls); err != nil || len(ls) != 4 || ls[2].Root != "rb" || ls[0].MarketName != "中金所期货" {
+		t.Fatalf("find = %v %v", ls, err)
+	}
+
+	cs := NewExCodesBase()
+	cs.Update(ls)
+	if v := cs.Get(protocol.ExchangeCFF, "IF2506"); v == nil || v.FullCode() != "47:IF2506" {
+		t.Errorf("Get = %v", v)
+	}
+	if v := cs.Get(protocol.ExchangeSHF, "RB2510"); v != nil {
+		t.Error("Get 区分大小写")
+	}
+	if v := cs.GetCode("RB2510"); len(v) != 1 || v[0].Market != 30 {
+		t.Errorf("GetCode = %v", v)
+	}
+	if v := cs.GetMarket(protocol.ExchangeCFF); len(v) != 2 {
+		t.Errorf("GetMarket = %v", v)
+	}
+	if v := cs.GetCategory(2); len(v) != 1 || v[0].Code != "00700" {
+		t.Errorf("GetCategory = %v", v)
+	}
+	if v := cs.GetRoot("if"); len(v) != 2 || v.Codes()[1] != "47:IF2509" {
+		t.Errorf("GetRoot = %v", v)
+	}
+	if v := cs.Search("沪深", 1); len(v) != 1 {
+		t.Errorf("Search = %v", v)
+	}
+	if v := cs.Search("腾讯"); len(v) != 1 {
+		t.Errorf("Search = %v", v)
+	}
+	if len(cs.Markets()) != 3 {
+		t.Errorf("Markets = %v", cs.Markets())
+	}
+	if m, ok := cs.FindMarket("cz"); !ok || m != protocol.ExchangeCFF {
+		t.Errorf("FindMarket = %v %v", m, ok)
+	}
+	if m, ok := cs.FindMarket("香港主板"); !ok || m != protocol.ExchangeHK {
+		t.Errorf("FindMarket = %v %v", m, ok)
+	}
+	n := 0
+	for range cs.Iter() {
+		n++
+	}
+	if n != 4 {
+		t.Errorf("Iter = %d", n)
+	}
+}
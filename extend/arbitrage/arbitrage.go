@@ -0,0 +1,267 @@
+// Package arbitrage 跨市场套利监控.
+//
+// A+H 两地上市股票的溢价(A股走标准行情 GetQuote,H股走扩展行情 ExQuote,港币汇率取扩展行情的汇率品种),
+// ETF 价格相对参考净值(IOPV,由 WithNAV 提供)的折溢价,
+// 超过阈值时通过 Notifier 提醒,同一个代码回到阈值内之后才会再次提醒。
+package arbitrage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+const (
+	DefaultInterval = 10 * time.Second //默认刷新间隔
+
+	quotePage = 80 //GetQuote 每次数量
+)
+
+// Notifier 提醒的发送方式,例如日志、钉钉、邮件
+type Notifier interface {
+	Notify(a *Alert) error
+}
+
+type NotifierFunc func(a *Alert) error
+
+func (this NotifierFunc) Notify(a *Alert) error { return this(a) }
+
+// LogNotifier 打印到日志,默认的提醒方式
+var LogNotifier = NotifierFunc(func(a *Alert) error {
+	logs.Info(a.String())
+	return nil
+})
+
+type Option func(*Monitor)
+
+// WithFacade 行情入口,默认 tdx.NewFacade
+func WithFacade(f *tdx.Facade) Option {
+	return func(m *Monitor) {
+		m.f = f
+	}
+}
+
+// WithFX 汇率,默认扩展行情的汇率 NewExFX
+func WithFX(fx FX) Option {
+	return func(m *Monitor) {
+		m.fx = fx
+	}
+}
+
+func WithNotifier(n Notifier) Option {
+	return func(m *Monitor) {
+		m.notifier = n
+	}
+}
+
+// WithPairs A+H 股票
+func WithPairs(ls ...Pair) Option {
+	return func(m *Monitor) {
+		m.pairs = append(m.pairs, ls...)
+	}
+}
+
+// WithETFs ETF代码,例 sh510300
+func WithETFs(codes ...string) Option {
+	return func(m *Monitor) {
+		m.etfs = append(m.etfs, codes...)
+	}
+}
+
+// WithPremium A/H溢价的提醒阈值(绝对值),例 0.3 为溢价或折价超过30%,0不提醒
+func WithPremium(threshold float64) Option {
+	return func(m *Monitor) {
+		m.premium = threshold
+	}
+}
+
+// WithDeviation ETF折溢价的提醒阈值(绝对值),例 0.005,0不提醒
+func WithDeviation(threshold float64) Option {
+	return func(m *Monitor) {
+		m.deviation = threshold
+	}
+}
+
+// WithInterval Run 的刷新间隔,默认 DefaultInterval
+func WithInterval(d time.Duration) Option {
+	return func(m *Monitor) {
+		m.interval = d
+	}
+}
+
+// WithNAV ETF参考净值(元),有ETF时必须指定,例如交易所/基金公司公布的IOPV。
+// 行情里的 ReversedBytes3 疑似IOPV,还没有和公布的IOPV核对过,暂不从行情解析
+func WithNAV(f func(q *protocol.Quote) float64) Option {
+	return func(m *Monitor) {
+		m.nav = f
+	}
+}
+
+func New(op ...Option) *Monitor {
+	m := &Monitor{
+		interval: DefaultInterval,
+		notifier: LogNotifier,
+		alerting: map[string]bool{},
+	}
+	for _, v := range op {
+		if v != nil {
+			v(m)
+		}
+	}
+	if m.f == nil {
+		m.f = tdx.NewFacade()
+	}
+	if m.fx == nil {
+		m.fx = NewExFX(m.f)
+	}
+	for i := range m.pairs {
+		m.pairs[i].H = hkCode(m.pairs[i].H)
+	}
+	return m
+}
+
+type Monitor struct {
+	pairs     []Pair
+	etfs      []string
+	premium   float64
+	deviation float64
+	interval  time.Duration
+	nav       func(q *protocol.Quote) float64
+
+	f        *tdx.Facade
+	fx       FX
+	notifier Notifier
+
+	alerting map[string]bool //正在提醒的 类型:代码
+	mu       sync.Mutex
+}
+
+// Result 一次检查的结果
+type Result struct {
+	Premiums   []*Premium   `json:"premiums"`
+	Deviations []*Deviation `json:"deviations"`
+	Alerts     []*Alert     `json:"alerts"` //这次新产生的提醒
+}
+
+// Run 每隔 interval 检查一次,直到 ctx 结束,单次出错只打印日志
+func (this *Monitor) Run(ctx context.Context) error {
+	t := time.NewTicker(this.interval)
+	defer t.Stop()
+	for {
+		if _, err := this.Check(); err != nil {
+			logs.Err(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Check 获取行情计算A/H溢价和ETF折溢价,新超过阈值的发送提醒
+func (this *Monitor) Check() (*Result, error) {
+	now := time.Now()
+	res := &Result{}
+
+	if len(this.pairs) > 0 {
+		rate, err := this.fx.Rate("HKD", "CNY")
+		if err != nil {
+			return nil, err
+		}
+		codes := make([]string, 0, len(this.pairs))
+		for _, v := range this.pairs {
+			codes = append(codes, v.A)
+		}
+		a, err := this.quotes(codes)
+		if err != nil {
+			return nil, err
+		}
+		codes = codes[:0]
+		for _, v := range this.pairs {
+			codes = append(codes, v.H)
+		}
+		h, err := this.f.Quote(codes...)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range this.pairs {
+			p := NewPremium(v, price(a[i]), price(h[i]), rate, now)
+			res.Premiums = append(res.Premiums, p)
+			res.Alerts = this.check(res.Alerts, KindPremium, v.A, v.Name, p.Premium, this.premium, now)
+		}
+	}
+
+	if len(this.etfs) > 0 {
+		if this.nav == nil {
+			return nil, errors.New("ETF折溢价需要 WithNAV 指定参考净值")
+		}
+		qs, err := this.quotes(this.etfs)
+		if err != nil {
+			return nil, err
+		}
+		for i, code := range this.etfs {
+			var nav float64
+			if qs[i] != nil {
+				nav = this.nav(qs[i])
+			}
+			d := NewDeviation(code, price(qs[i]), nav, now)
+			res.Deviations = append(res.Deviations, d)
+			res.Alerts = this.check(res.Alerts, KindDeviation, code, "", d.Deviation, this.deviation, now)
+		}
+	}
+
+	for _, v := range res.Alerts {
+		if err := this.notifier.Notify(v); err != nil {
+			logs.Err(err)
+		}
+	}
+	return res, nil
+}
+
+// check 新超过阈值时加入提醒,回到阈值内后清除状态
+func (this *Monitor) check(ls []*Alert, kind, code, name string, v, threshold float64, t time.Time) []*Alert {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	key := kind + ":" + code
+	if !over(v, threshold) {
+		delete(this.alerting, key)
+		return ls
+	}
+	if this.alerting[key] {
+		return ls
+	}
+	this.alerting[key] = true
+	return append(ls, &Alert{Kind: kind, Code: code, Name: name, Value: v, Threshold: threshold, Time: t})
+}
+
+// quotes 标准行情分批获取
+func (this *Monitor) quotes(codes []string) (protocol.QuotesResp, error) {
+	res := make(protocol.QuotesResp, 0, len(codes))
+	for i := 0; i < len(codes); i += quotePage {
+		ls, err := this.f.Quote(codes[i:min(i+quotePage, len(codes))]...)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ls...)
+	}
+	return res, nil
+}
+
+// hkCode 港股代码统一为 hk+5位,例 2318 02318.HK → hk02318
+func hkCode(code string) string {
+	s, err := protocol.ParseSymbol(code)
+	if err != nil || s.Exchange != protocol.ExchangeHK {
+		s = protocol.NewSymbol(protocol.ExchangeHK, strings.TrimSpace(code))
+	}
+	if len(s.Code) < 5 {
+		s.Code = strings.Repeat("0", 5-len(s.Code)) + s.Code
+	}
+	return s.String()
+}
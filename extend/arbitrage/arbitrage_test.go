@@ -0,0 +1,56 @@
+package arbitrage
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPremium(t *testing.T) {
+	now := time.Now()
+	p := NewPremium(Pair{A: "sh601318", H: "hk02318"}, 55, 50, 0.92, now)
+	if math.Abs(p.Premium-(55/46.0-1)) > 1e-9 {
+		t.Errorf("Premium = %v", p.Premium)
+	}
+	if p := NewPremium(Pair{}, 55, 0, 0.92, now); p.Premium != 0 {
+		t.Errorf("缺少价格 Premium = %v", p.Premium)
+	}
+	if d := NewDeviation("sh510300", 4.02, 4.0, now); math.Abs(d.Deviation-0.005) > 1e-9 {
+		t.Errorf("Deviation = %v", d.Deviation)
+	}
+
+	fx := FixedFX{"HKDCNY": 0.92}
+	if v, err := fx.Rate("cny", "hkd"); err != nil || math.Abs(v-1/0.92) > 1e-9 {
+		t.Errorf("Rate = %v %v", v, err)
+	}
+	if _, err := fx.Rate("USD", "CNY"); err == nil {
+		t.Error("没有的汇率应返回错误")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	m := New(WithFacade(nil), WithFX(FixedFX{}))
+	now := time.Now()
+	var ls []*Alert
+	for _, v := range []float64{0.1, 0.35, 0.4, 0.2, -0.31} {
+		ls = m.check(ls, KindPremium, "sh601318", "中国平安", v, 0.3, now)
+	}
+	//0.35 超过后 0.4 不重复提醒,回到 0.2 后 -0.31 再次提醒
+	if len(ls) != 2 || ls[0].Value != 0.35 || ls[1].Value != -0.31 {
+		t.Errorf("alerts = %v", ls)
+	}
+	if ls = m.check(nil, KindDeviation, "sh510300", "", 0.01, 0, now); len(ls) != 0 {
+		t.Error("阈值为0不提醒")
+	}
+
+	for code, want := range map[string]string{"2318": "hk02318", "02318.HK": "hk02318", "hk00700": "hk00700"} {
+		if got := hkCode(code); got != want {
+			t.Errorf("hkCode(%s) = %s, want %s", code, got, want)
+		}
+	}
+
+	//没有指定参考净值时ETF返回错误,不会去获取行情
+	if _, err := New(WithFX(FixedFX{}), WithETFs("sh510300")).Check(); err == nil {
+		t.Error("没有 WithNAV 应返回错误")
+	}
+}
@@ -0,0 +1,95 @@
+package arbitrage
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// FX 汇率数据源,1单位 from 兑换多少 to,例 Rate("HKD","CNY")=0.92
+type FX interface {
+	Rate(from, to string) (float64, error)
+}
+
+// FixedFX 固定汇率,键为 from+to,例 {"HKDCNY": 0.92},反向的汇率自动取倒数
+type FixedFX map[string]float64
+
+func (this FixedFX) Rate(from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+	if v := this[from+to]; v > 0 {
+		return v, nil
+	}
+	if v := this[to+from]; v > 0 {
+		return 1 / v, nil
+	}
+	return 0, errors.New("没有汇率: " + from + to)
+}
+
+// FXMarkets 扩展行情的汇率市场,10=基本汇率 11=交叉汇率
+var FXMarkets = []uint8{10, 11}
+
+// NewExFX 扩展行情的汇率,见 ExFX
+func NewExFX(f *tdx.Facade) *ExFX {
+	return &ExFX{f: f}
+}
+
+// ExFX 扩展行情的汇率,按 FXMarkets 顺序找 from+to,没有时找 to+from 取倒数,
+// 再没有时经美元换算(USDCNY/USDHKD)
+type ExFX struct {
+	f *tdx.Facade
+}
+
+func (this *ExFX) Rate(from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+	if v, ok := this.direct(from, to); ok {
+		return v, nil
+	}
+	if from != "USD" && to != "USD" {
+		a, okA := this.direct("USD", to)
+		b, okB := this.direct("USD", from)
+		if okA && okB {
+			return a / b, nil
+		}
+	}
+	return 0, errors.New("扩展行情没有汇率: " + from + to)
+}
+
+func (this *ExFX) direct(from, to string) (float64, bool) {
+	if v := this.price(from + to); v > 0 {
+		return v, true
+	}
+	if v := this.price(to + from); v > 0 {
+		return 1 / v, true
+	}
+	return 0, false
+}
+
+// price 汇率的最新价,查不到为0,直接用 ExQuote 的价格(Quote 的价格只到厘,汇率需要4位小数)
+func (this *ExFX) price(code string) float64 {
+	for _, market := range FXMarkets {
+		var v float64
+		err := this.f.Do(protocol.NewSymbol(protocol.Exchange(market), code), func(c *tdx.Client) error {
+			q, err := c.ExQuote(market, code)
+			if err != nil {
+				return err
+			}
+			v = q.Price
+			if v <= 0 {
+				v = q.PreClose
+			}
+			return nil
+		})
+		if err == nil && v > 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package arbitrage
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Pair A+H 两地上市的股票
+type Pair struct {
+	Name string `json:"name"`
+	A    string `json:"a"` //A股代码,例 sh601318
+	H    string `json:"h"` //港股代码,例 02318
+}
+
+// Premium A/H 溢价
+type Premium struct {
+	Pair
+	APrice  float64   `json:"aPrice"`  //A股价格(人民币)
+	HPrice  float64   `json:"hPrice"`  //H股价格(港币)
+	Rate    float64   `json:"rate"`    //港币兑人民币汇率
+	Premium float64   `json:"premium"` //溢价率,A股价格/(H股价格*汇率)-1,价格缺失时为0
+	Time    time.Time `json:"time"`
+}
+
+// NewPremium 计算A/H溢价
+func NewPremium(p Pair, a, h, rate float64, t time.Time) *Premium {
+	res := &Premium{Pair: p, APrice: a, HPrice: h, Rate: rate, Time: t}
+	if a > 0 && h > 0 && rate > 0 {
+		res.Premium = a/(h*rate) - 1
+	}
+	return res
+}
+
+// Deviation ETF 价格相对参考净值(IOPV)的偏离
+type Deviation struct {
+	Code      string    `json:"code"`
+	Price     float64   `json:"price"`
+	IOPV      float64   `json:"iopv"`
+	Deviation float64   `json:"deviation"` //溢价率,价格/IOPV-1,正为溢价负为折价,没有净值时为0
+	Time      time.Time `json:"time"`
+}
+
+// NewDeviation 计算ETF折溢价
+func NewDeviation(code string, p, iopv float64, t time.Time) *Deviation {
+	res := &Deviation{Code: code, Price: p, IOPV: iopv, Time: t}
+	if p > 0 && iopv > 0 {
+		res.Deviation = p/iopv - 1
+	}
+	return res
+}
+
+const (
+	KindPremium   = "ah"  //A/H溢价
+	KindDeviation = "etf" //ETF折溢价
+)
+
+// Alert 超过阈值的提醒
+type Alert struct {
+	Kind      string    `json:"kind"` //KindPremium KindDeviation
+	Code      string    `json:"code"` //A股代码或ETF代码
+	Name      string    `json:"name"`
+	Value     float64   `json:"value"`     //溢价率
+	Threshold float64   `json:"threshold"` //阈值
+	Time      time.Time `json:"time"`
+}
+
+func (this *Alert) String() string {
+	kind := "A/H溢价"
+	if this.Kind == KindDeviation {
+		kind = "ETF折溢价"
+	}
+	return fmt.Sprintf("[%s] %s%s %.2f%% 超过阈值 %.2f%%", kind, this.Code, this.Name, this.Value*100, this.Threshold*100)
+}
+
+// over 超过阈值(绝对值),阈值<=0时不提醒
+func over(v, threshold float64) bool {
+	return threshold > 0 && math.Abs(v) >= threshold
+}
+
+// price 最新价(元),没有成交时取昨收
+func price(q *protocol.Quote) float64 {
+	if q == nil || q.Kline == nil {
+		return 0
+	}
+	if q.Kline.Close > 0 {
+		return q.Kline.Close.Float64()
+	}
+	return q.Kline.Last.Float64()
+}
@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// 任务类型
+const (
+	JobCodes   = "codes"   //更新代码表
+	JobWorkday = "workday" //更新工作日
+	JobGbbq    = "gbbq"    //更新股本变迁
+	JobKline   = "kline"   //拉取K线,见 extend.PullKline
+	JobTrade   = "trade"   //归档当年的分时成交,见 extend.PullTrade
+	JobTick    = "tick"    //补全当年的分笔成交归档,见 extend.TickArchive
+	JobZHB     = "zhb"     //下载 zhb.zip 并解压到 dir
+	JobVipdoc  = "vipdoc"  //同步 vipdoc 目录,见 extend.VipdocSync
+)
+
+// Config 守护进程的配置文件(YAML,JSON 也可以):
+//
+//	source:
+//	  hosts: ["124.71.187.122", "121.36.81.195"]
+//	  pool: 2
+//	  dsn: "root:root@tcp(127.0.0.1:3306)/tdx"   # 代码/工作日/股本变迁存 MySQL,不填为 data_dir 下的 sqlite
+//	  data_dir: ./data/database
+//	status:
+//	  addr: ":8090"                              # GET /status,不填不启动
+//	shutdown_timeout: 30s                        # 退出时等待运行中任务的时间,超时后取消
+//	jobs:
+//	  - {name: codes, type: codes, spec: "0 1 9 * * *"}
+//	  - {name: day, type: kline, spec: "0 10 15 * * *", workday: true, types: [day], goroutines: 4}
+//	  - {name: tick, type: tick, spec: "0 40 15 * * *", workday: true, codes: [sz000001]}
+//	  - {name: vipdoc, type: vipdoc, spec: "0 30 15 * * *", workday: true, dir: ./vipdoc, subs: [lday]}
+//
+// spec 为带秒的 cron 表达式,codes/workday/gbbq 不填时为 tdx.DefaultCodesSpec 等。
+type Config struct {
+	Source          Source        `yaml:"source"`
+	Status          Status        `yaml:"status"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	Jobs            []*JobConfig  `yaml:"jobs"`
+}
+
+// Source 数据来源
+type Source struct {
+	Hosts   []string `yaml:"hosts"`    //服务器,默认 tdx.Hosts
+	Pool    int      `yaml:"pool"`     //连接池大小
+	DSN     string   `yaml:"dsn"`      //MySQL,见 tdx.NewManageMysql
+	DataDir string   `yaml:"data_dir"` //sqlite 数据库和任务的默认目录
+}
+
+// Status 状态接口
+type Status struct {
+	Addr string `yaml:"addr"`
+}
+
+// JobConfig 任务配置,Codes/Types/Goroutines/Dir/Subs 按任务类型使用
+type JobConfig struct {
+	Name          string        `yaml:"name"`
+	Type          string        `yaml:"type"`
+	Spec          string        `yaml:"spec"`
+	Workday       bool          `yaml:"workday"`        //只在工作日执行
+	OnStart       bool          `yaml:"on_start"`       //启动时执行一次
+	Retry         int           `yaml:"retry"`          //失败重试次数
+	RetryInterval time.Duration `yaml:"retry_interval"` //重试间隔,默认5分钟
+	Codes         []string      `yaml:"codes"`          //kline/trade/tick/vipdoc 的代码,默认全部
+	Types         []string      `yaml:"types"`          //kline 的类型 day/minute
+	Goroutines    int           `yaml:"goroutines"`     //kline 的协程数量
+	Dir           string        `yaml:"dir"`            //kline/trade/tick/zhb/vipdoc 的目录
+	Subs          []string      `yaml:"subs"`           //vipdoc 的目录 lday/minline/fzline
+}
+
+// LoadConfig 读取配置文件并校验
+func LoadConfig(filename string) (*Config, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(bs, cfg); err != nil {
+		return nil, errors.New(filename + ": " + err.Error())
+	}
+	return cfg, cfg.check()
+}
+
+// check 填充默认值并校验
+func (this *Config) check() error {
+	if len(this.Source.Hosts) == 0 {
+		this.Source.Hosts = tdx.Hosts
+	}
+	if this.Source.Pool <= 0 {
+		this.Source.Pool = 1
+	}
+	if this.Source.DataDir == "" {
+		this.Source.DataDir = tdx.DefaultDatabaseDir
+	}
+	if this.ShutdownTimeout <= 0 {
+		this.ShutdownTimeout = 30 * time.Second
+	}
+	if len(this.Jobs) == 0 {
+		return errors.New("没有配置任务")
+	}
+	names := map[string]bool{}
+	for i, v := range this.Jobs {
+		if v.Name == "" {
+			v.Name = v.Type
+		}
+		if names[v.Name] {
+			return fmt.Errorf("任务[%d] 名称重复: %s", i, v.Name)
+		}
+		names[v.Name] = true
+		switch v.Type {
+		case JobCodes:
+			v.Spec = defaultString(v.Spec, tdx.DefaultCodesSpec)
+		case JobWorkday:
+			v.Spec = defaultString(v.Spec, tdx.DefaultWorkdaySpec)
+		case JobGbbq:
+			v.Spec = defaultString(v.Spec, tdx.DefaultGbbqSpec)
+		case JobKline, JobTrade, JobTick, JobZHB, JobVipdoc:
+		default:
+			return fmt.Errorf("任务[%s] 未知的类型: %s", v.Name, v.Type)
+		}
+		if _, err := cronParser.Parse(v.Spec); err != nil {
+			return fmt.Errorf("任务[%s] spec 错误: %v", v.Name, err)
+		}
+		if v.RetryInterval <= 0 {
+			v.RetryInterval = 5 * time.Minute
+		}
+	}
+	return nil
+}
+
+// has 是否有某类任务
+func (this *Config) has(typ string) bool {
+	for _, v := range this.Jobs {
+		if v.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// cronParser 带秒的 cron 表达式,同 cron.WithSeconds
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,265 @@
+// Package daemon 按配置文件定时执行数据维护任务(代码/工作日/股本变迁更新、K线拉取、
+// 成交/分笔归档、zhb 下载、vipdoc 同步),支持只在工作日执行、优雅退出和状态接口。
+//
+// 代码表等组件创建时自带的定时更新(tdx.NewTimer)仍然生效,任务里的更新有 Updated 记录,
+// 同一天重复执行不会重复下载。
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/lib/xorms"
+	"github.com/robfig/cron/v3"
+)
+
+// JobStatus 任务状态,GET /status 返回
+type JobStatus struct {
+	Name      string        `json:"name"`
+	Type      string        `json:"type"`
+	Spec      string        `json:"spec"`
+	Workday   bool          `json:"workday"`
+	Running   bool          `json:"running"`
+	Runs      int           `json:"runs"`    //执行次数,不含跳过的
+	Fails     int           `json:"fails"`   //失败次数(重试后仍失败)
+	Skipped   int           `json:"skipped"` //非工作日或上次未结束而跳过的次数
+	LastRun   time.Time     `json:"lastRun"`
+	LastCost  time.Duration `json:"lastCost"`
+	LastError string        `json:"lastError"`
+	NextRun   time.Time     `json:"nextRun"`
+}
+
+type job struct {
+	*JobConfig
+	run   Runner
+	entry cron.EntryID
+
+	mu     sync.Mutex
+	status JobStatus
+}
+
+// Daemon 守护进程
+type Daemon struct {
+	cfg       *Config
+	manage    *tdx.Manage
+	isWorkday func() bool
+	jobs      []*job
+	cron      *cron.Cron
+	server    *http.Server
+
+	ctx    context.Context //任务的上下文,退出超时后取消
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New 按配置连接服务器、创建 tdx.Manage 和任务
+func New(cfg *Config) (*Daemon, error) {
+	m, err := newManage(cfg)
+	if err != nil {
+		return nil, err
+	}
+	runners := make([]Runner, len(cfg.Jobs))
+	for i, v := range cfg.Jobs {
+		if runners[i], err = newRunner(m, cfg.Source.DataDir, v); err != nil {
+			return nil, errors.New("任务[" + v.Name + "] " + err.Error())
+		}
+	}
+	d, err := newDaemon(cfg, runners, func() bool { return m.Workday.TodayIs() })
+	if err != nil {
+		return nil, err
+	}
+	d.manage = m
+	return d, nil
+}
+
+// newManage 数据库为 MySQL(dsn)或 data_dir 下的 sqlite,有 gbbq 任务时才加载股本变迁
+func newManage(cfg *Config) (*tdx.Manage, error) {
+	src := cfg.Source
+	dialDB := func(name string) tdx.DialDBFunc {
+		if src.DSN != "" {
+			return func() (*xorms.Engine, error) { return xorms.NewMysql(src.DSN) }
+		}
+		return func() (*xorms.Engine, error) { return xorms.NewSqlite(filepath.Join(src.DataDir, name)) }
+	}
+	op := []tdx.Option{
+		tdx.WithDialPool(func() (tdx.IPool, error) {
+			return tdx.NewPool(func() (*tdx.Client, error) {
+				return tdx.DialHostsRange(src.Hosts, tdx.WithDebug(false), tdx.WithRedial())
+			}, src.Pool)
+		}),
+		tdx.WithDialCodes(func(c *tdx.Client) (tdx.ICodes, error) {
+			return tdx.NewCodes(tdx.WithCodesClient(c), tdx.WithCodesDialDB(dialDB("codes.db")))
+		}),
+		tdx.WithDialWorkday(func(c *tdx.Client) (*tdx.Workday, error) {
+			return tdx.NewWorkday(tdx.WithWorkdayClient(c), tdx.WithWorkdayDialDB(dialDB("workday.db")))
+		}),
+	}
+	if cfg.has(JobGbbq) {
+		op = append(op, tdx.WithDialGbbq(func(c *tdx.Client) (tdx.IGbbq, error) {
+			return tdx.NewGbbq(tdx.WithGbbqClient(c), tdx.WithGbbqDialDB(dialDB("gbbq.db")))
+		}))
+	}
+	return tdx.NewManage(op...)
+}
+
+func newDaemon(cfg *Config, runners []Runner, isWorkday func() bool) (*Daemon, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Daemon{
+		cfg:       cfg,
+		isWorkday: isWorkday,
+		cron:      cron.New(cron.WithParser(cronParser)),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	for i, v := range cfg.Jobs {
+		j := &job{JobConfig: v, run: runners[i]}
+		j.status = JobStatus{Name: v.Name, Type: v.Type, Spec: v.Spec, Workday: v.Workday}
+		id, err := d.cron.AddFunc(v.Spec, func() { d.runJob(j) })
+		if err != nil {
+			cancel()
+			return nil, errors.New("任务[" + v.Name + "] " + err.Error())
+		}
+		j.entry = id
+		d.jobs = append(d.jobs, j)
+	}
+	if cfg.Status.Addr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /status", d.handleStatus)
+		d.server = &http.Server{Addr: cfg.Status.Addr, Handler: mux}
+	}
+	return d, nil
+}
+
+// Manage 任务使用的 tdx.Manage
+func (this *Daemon) Manage() *tdx.Manage {
+	return this.manage
+}
+
+// Run 启动定时任务和状态接口,阻塞到 ctx 结束后优雅退出:
+// 不再触发新任务,等待运行中的任务 ShutdownTimeout,超时后取消任务的上下文
+func (this *Daemon) Run(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	if this.server != nil {
+		go func() {
+			if err := this.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+	this.cron.Start()
+	for _, j := range this.jobs {
+		if j.OnStart {
+			go this.runJob(j)
+		}
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+	this.shutdown()
+	return err
+}
+
+func (this *Daemon) shutdown() {
+	this.mu.Lock()
+	this.closed = true
+	this.mu.Unlock()
+	//不等待 cron 里运行中的任务(cron.Stop 返回的 ctx 要等任务结束),由 wg 和超时取消处理
+	this.cron.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		this.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(this.cfg.ShutdownTimeout):
+		logs.Warn("等待任务结束超时,取消运行中的任务")
+		this.cancel()
+		<-done
+	}
+	this.cancel()
+
+	if this.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		this.server.Shutdown(ctx)
+	}
+}
+
+// runJob 执行任务,非工作日(workday)或上次还没结束时跳过,失败按 retry 重试
+func (this *Daemon) runJob(j *job) {
+	this.mu.Lock()
+	if this.closed {
+		this.mu.Unlock()
+		return
+	}
+	this.wg.Add(1)
+	this.mu.Unlock()
+	defer this.wg.Done()
+
+	j.mu.Lock()
+	if j.status.Running || (j.Workday && !this.isWorkday()) {
+		j.status.Skipped++
+		j.mu.Unlock()
+		return
+	}
+	j.status.Running = true
+	j.mu.Unlock()
+
+	start := time.Now()
+	err := j.run(this.ctx)
+	for i := 0; err != nil && i < j.Retry && this.ctx.Err() == nil; i++ {
+		logs.Errf("任务[%s] 执行失败: %v, %s后重试\n", j.Name, err, j.RetryInterval)
+		select {
+		case <-this.ctx.Done():
+		case <-time.After(j.RetryInterval):
+			err = j.run(this.ctx)
+		}
+	}
+	if err != nil {
+		logs.Errf("任务[%s] 执行失败: %v\n", j.Name, err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = start
+	j.status.LastCost = time.Since(start)
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Fails++
+		j.status.LastError = err.Error()
+	}
+}
+
+// Status 全部任务的状态
+func (this *Daemon) Status() []*JobStatus {
+	ls := make([]*JobStatus, 0, len(this.jobs))
+	for _, j := range this.jobs {
+		j.mu.Lock()
+		s := j.status
+		j.mu.Unlock()
+		s.NextRun = this.cron.Entry(j.entry).Next
+		ls = append(ls, &s)
+	}
+	return ls
+}
+
+func (this *Daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(this.Status())
+}
@@ -0,0 +1,190 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx"
+)
+
+func TestLoadConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "daemon.yaml")
+	err := os.WriteFile(filename, []byte(`
+source:
+  pool: 2
+jobs:
+  - {type: codes}
+  - {name: day, type: kline, spec: "0 10 15 * * *", workday: true, types: [day]}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Source.Pool != 2 || cfg.Source.DataDir != tdx.DefaultDatabaseDir || cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("source = %+v", cfg.Source)
+	}
+	if j := cfg.Jobs[0]; j.Name != JobCodes || j.Spec != tdx.DefaultCodesSpec || j.RetryInterval != 5*time.Minute {
+		t.Errorf("codes = %+v", j)
+	}
+	if j := cfg.Jobs[1]; !j.Workday || len(j.Types) != 1 || !cfg.has(JobKline) || cfg.has(JobGbbq) {
+		t.Errorf("kline = %+v", j)
+	}
+
+	for _, jobs := range [][]*JobConfig{
+		nil,
+		{{Type: "xx", Spec: "@daily"}},
+		{{Type: JobZHB}},
+		{{Type: JobZHB, Spec: "0 0 9 * *"}},
+		{{Type: JobCodes}, {Type: JobCodes}},
+	} {
+		if err := (&Config{Jobs: jobs}).check(); err == nil {
+			t.Errorf("%v 应返回错误", jobs)
+		}
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	workday := false
+	runs := 0
+	cfg := &Config{Jobs: []*JobConfig{
+		{Name: "a", Type: JobZHB, Spec: "@every 1h", Workday: true, Retry: 2, RetryInterval: time.Millisecond},
+	}}
+	if err := cfg.check(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := newDaemon(cfg, []Runner{func(ctx context.Context) error {
+		runs++
+		if runs < 3 {
+			return errors.New("失败")
+		}
+		return nil
+	}}, func() bool { return workday })
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := d.jobs[0]
+
+	//非工作日跳过
+	d.runJob(j)
+	if s := d.Status()[0]; runs != 0 || s.Skipped != 1 || s.Runs != 0 {
+		t.Errorf("status = %+v", s)
+	}
+
+	//失败后重试成功
+	workday = true
+	d.runJob(j)
+	if s := d.Status()[0]; runs != 3 || s.Runs != 1 || s.Fails != 0 || s.LastError != "" || s.LastRun.IsZero() {
+		t.Errorf("status = %+v", s)
+	}
+
+	//重试后仍失败
+	runs = -10
+	d.runJob(j)
+	if s := d.Status()[0]; s.Runs != 2 || s.Fails != 1 || s.LastError != "失败" {
+		t.Errorf("status = %+v", s)
+	}
+
+	w := httptest.NewRecorder()
+	d.handleStatus(w, httptest.NewRequest("GET", "/status", nil))
+	ls := []*JobStatus(nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &ls); err != nil || len(ls) != 1 || ls[0].Name != "a" || ls[0].Fails != 1 {
+		t.Errorf("GET /status = %s", w.Body.String())
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	cfg := &Config{ShutdownTimeout: 20 * time.Millisecond, Jobs: []*JobConfig{
+		{Name: "slow", Type: JobZHB, Spec: "@every 1h", OnStart: true},
+	}}
+	if err := cfg.check(); err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	d, err := newDaemon(cfg, []Runner{func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}, func() bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	<-started
+	if s := d.Status()[0]; !s.Running || s.NextRun.IsZero() {
+		t.Errorf("status = %+v", s)
+	}
+	cancel()
+
+	//等待超时后取消任务,Run 返回
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有退出")
+	}
+	if s := d.Status()[0]; s.Running || s.LastError != context.Canceled.Error() {
+		t.Errorf("status = %+v", s)
+	}
+	//退出后不再执行
+	d.runJob(d.jobs[0])
+	if s := d.Status()[0]; s.Runs != 1 {
+		t.Errorf("runs = %d", s.Runs)
+	}
+}
+
+// TestShutdownCron cron 触发的任务同样在超时后取消,不会阻塞退出
+func TestShutdownCron(t *testing.T) {
+	cfg := &Config{ShutdownTimeout: 20 * time.Millisecond, Jobs: []*JobConfig{
+		{Name: "slow", Type: JobZHB, Spec: "@every 10ms"},
+	}}
+	if err := cfg.check(); err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	var once sync.Once
+	d, err := newDaemon(cfg, []Runner{func(ctx context.Context) error {
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		return ctx.Err()
+	}}, func() bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("cron 没有触发任务")
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有退出")
+	}
+	if s := d.Status()[0]; s.Running || s.Runs != 1 || s.LastError != context.Canceled.Error() {
+		t.Errorf("status = %+v", s)
+	}
+}
@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend"
+)
+
+// Runner 任务的执行函数,ctx 在退出超时后取消
+type Runner func(ctx context.Context) error
+
+// newRunner 按任务类型创建执行函数
+func newRunner(m *tdx.Manage, dataDir string, job *JobConfig) (Runner, error) {
+	dir := func(def string) string {
+		if job.Dir != "" {
+			return job.Dir
+		}
+		return filepath.Join(dataDir, def)
+	}
+	codes := func() []string {
+		if len(job.Codes) > 0 {
+			return job.Codes
+		}
+		ls := m.Codes.GetStockCodes()
+		ls = append(ls, m.Codes.GetETFCodes()...)
+		return append(ls, m.Codes.GetIndexCodes()...)
+	}
+
+	switch job.Type {
+	case JobCodes:
+		return updater(m.Codes)
+	case JobWorkday:
+		return updater(m.Workday)
+	case JobGbbq:
+		return updater(m.Gbbq)
+
+	case JobKline:
+		pk, err := extend.NewPullKline(extend.PullKlineConfig{
+			Codes:      job.Codes,
+			Types:      job.Types,
+			Dir:        dir(""),
+			Goroutines: job.Goroutines,
+		})
+		if err != nil {
+			return nil, err
+		}
+		//是否工作日由任务的 workday 决定
+		return func(ctx context.Context) error { return pk.UpdateContext(ctx, m, true) }, nil
+
+	case JobTrade:
+		pt := extend.NewPullTrade(dir("trade"))
+		//某个代码失败时跳过,继续后面的代码,最后返回所有失败的代码
+		return func(ctx context.Context) error {
+			var errs []error
+			for _, code := range codes() {
+				if err := ctx.Err(); err != nil {
+					return errors.Join(append(errs, err)...)
+				}
+				if err := pt.PullYear(ctx, m, time.Now().Year(), code); err != nil {
+					logs.Errf("[%s] 拉取分时成交失败: %v\n", code, err)
+					errs = append(errs, errors.New(code+": "+err.Error()))
+				}
+			}
+			return errors.Join(errs...)
+		}, nil
+
+	case JobTick:
+		ta := extend.NewTickArchive(dir("tick"))
+		return func(ctx context.Context) error {
+			now := time.Now()
+			start := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+			return ta.Backfill(ctx, m, codes(), start, now.AddDate(0, 0, 1))
+		}, nil
+
+	case JobZHB:
+		return func(ctx context.Context) error {
+			var files map[string][]byte
+			err := m.Do(func(c *tdx.Client) (err error) {
+				files, err = c.GetZHBFiles()
+				return
+			})
+			if err != nil {
+				return err
+			}
+			if err = os.MkdirAll(dir("zhb"), 0o755); err != nil {
+				return err
+			}
+			for name, bs := range files {
+				if err = os.WriteFile(filepath.Join(dir("zhb"), filepath.Base(name)), bs, 0o644); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+
+	case JobVipdoc:
+		vs := extend.NewVipdocSync(dir("vipdoc"), job.Subs...)
+		return func(ctx context.Context) error { return vs.Sync(ctx, m, codes()...) }, nil
+	}
+	return nil, errors.New("未知的任务类型: " + job.Type)
+}
+
+// updater codes/workday/gbbq 的更新
+func updater(v any) (Runner, error) {
+	up, ok := v.(tdx.Updater)
+	if !ok {
+		return nil, errors.New("不支持更新")
+	}
+	return func(ctx context.Context) error { return up.Update() }, nil
+}
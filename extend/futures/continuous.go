@@ -0,0 +1,60 @@
+package futures
+
+import (
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Adjust 主力连续的复权方式
+type Adjust string
+
+const (
+	AdjustNone  Adjust = ""      //不复权,直接拼接各主力合约的日线
+	AdjustRatio Adjust = "ratio" //比例后复权,以最新主力为基准,换月前的价格乘以 新/旧 收盘价之比
+	AdjustDiff  Adjust = "diff"  //差值后复权,以最新主力为基准,换月前的价格加上 新-旧 收盘价之差
+)
+
+// Continuous 按主力合约拼接连续K线,bars 为 合约→日线,ds 为每天的主力,rolls 为换月记录。
+// 复权以最新的主力合约为基准,历史价格(开高低收和结算价)按之后每次换月调整,成交量/持仓/成交额不变
+func Continuous(bars map[string][]protocol.ExKline, ds []*Dominant, rolls []*Roll, adjust Adjust) []protocol.ExKline {
+	cal := newCalendar(bars)
+	ls := make([]protocol.ExKline, 0, len(ds))
+	for _, d := range ds {
+		k := cal.bars[d.Date][d.Code]
+		if k == nil {
+			continue
+		}
+		ls = append(ls, *k)
+	}
+	if adjust == AdjustNone {
+		return ls
+	}
+
+	//从后往前,每经过一次换月累计一次调整
+	factor, diff := 1.0, 0.0
+	r := len(rolls) - 1
+	for i := len(ls) - 1; i >= 0; i-- {
+		for ; r >= 0 && barDate(ls[i].Datetime) < rolls[r].Date; r-- {
+			if rolls[r].FromPrice > 0 && rolls[r].ToPrice > 0 {
+				factor *= rolls[r].ToPrice / rolls[r].FromPrice
+				diff += rolls[r].ToPrice - rolls[r].FromPrice
+			}
+		}
+		switch adjust {
+		case AdjustRatio:
+			ls[i].Open *= factor
+			ls[i].High *= factor
+			ls[i].Low *= factor
+			ls[i].Close *= factor
+			ls[i].Price *= factor
+		case AdjustDiff:
+			ls[i].Open += diff
+			ls[i].High += diff
+			ls[i].Low += diff
+			ls[i].Close += diff
+			if ls[i].Price != 0 {
+				ls[i].Price += diff
+			}
+		}
+	}
+	return ls
+}
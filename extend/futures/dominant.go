@@ -0,0 +1,210 @@
+// Package futures 期货主力合约和主力连续.
+//
+// 按每天各合约的持仓(或成交量)选出每个品种的主力合约,记录换月日期,
+// 再用各合约的日线拼接成不复权、比例后复权、差值后复权的连续K线(protocol.ExKline)。
+// 数据来自扩展行情(7727)的 ExBars 日线和 ExQuoteList,见 Manager。
+package futures
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+const (
+	ByOI     = "oi"     //按持仓选主力
+	ByVolume = "volume" //按成交量选主力
+)
+
+// Rule 主力合约的选择规则,收盘后判断,下一交易日生效(不用未来数据)
+type Rule struct {
+	By    string  //oi(默认) 或 volume
+	Ratio float64 //新合约超过当前主力的倍数才切换,默认1,例 1.1 减少来回切换
+	Days  int     //连续满足的天数,默认1
+	Back  bool    //是否允许切换到更早到期的合约,默认不允许
+}
+
+func (this Rule) fill() Rule {
+	if this.By == "" {
+		this.By = ByOI
+	}
+	if this.Ratio <= 0 {
+		this.Ratio = 1
+	}
+	if this.Days <= 0 {
+		this.Days = 1
+	}
+	return this
+}
+
+func (this Rule) metric(k *protocol.ExKline) float64 {
+	if this.By == ByVolume {
+		return float64(k.Trade)
+	}
+	return float64(k.Position)
+}
+
+// best 当天持仓(成交量)最大的合约,相同时取较晚到期的;不允许回退时只在比 cur 晚到期的合约中选
+func (this Rule) best(day map[string]*protocol.ExKline, cur string, date time.Time) string {
+	res, max, month := "", -1.0, 0
+	curMonth := ContractMonth(cur, date)
+	for code, k := range day {
+		m := ContractMonth(code, date)
+		if !this.Back && cur != "" && m <= curMonth {
+			continue
+		}
+		if v := this.metric(k); v > max || (v == max && m > month) {
+			res, max, month = code, v, m
+		}
+	}
+	return res
+}
+
+// Dominant 某天的主力合约
+type Dominant struct {
+	Date string //YYYY-MM-DD
+	Code string
+}
+
+// Roll 换月,Date 为新合约成为主力的第一天,价格为换月前一交易日的收盘价,用于复权
+type Roll struct {
+	Date      string
+	From      string
+	To        string
+	FromPrice float64
+	ToPrice   float64
+}
+
+// calendar 按日期整理的各合约日线
+type calendar struct {
+	dates []string                                //升序
+	bars  map[string]map[string]*protocol.ExKline //日期→合约→日线
+}
+
+// newCalendar bars 为 合约→日线
+func newCalendar(bars map[string][]protocol.ExKline) *calendar {
+	c := &calendar{bars: map[string]map[string]*protocol.ExKline{}}
+	for code, ls := range bars {
+		for i := range ls {
+			date := barDate(ls[i].Datetime)
+			if c.bars[date] == nil {
+				c.bars[date] = map[string]*protocol.ExKline{}
+				c.dates = append(c.dates, date)
+			}
+			c.bars[date][code] = &ls[i]
+		}
+	}
+	sort.Strings(c.dates)
+	return c
+}
+
+// index 第一个不早于 date 的位置
+func (this *calendar) index(date string) int {
+	return sort.SearchStrings(this.dates, date)
+}
+
+// bar 合约在 dates[i] 的日线,越界返回 nil
+func (this *calendar) bar(i int, code string) *protocol.ExKline {
+	if i < 0 || i >= len(this.dates) {
+		return nil
+	}
+	return this.bars[this.dates[i]][code]
+}
+
+// SelectDominants 逐日选出主力合约和换月,bars 为一个品种各合约的日线
+func SelectDominants(bars map[string][]protocol.ExKline, rule Rule) ([]*Dominant, []*Roll) {
+	return selectDominants(newCalendar(bars), rule, 0, "")
+}
+
+// selectDominants 从 dates[start] 开始选主力,cur 为当天的主力,空时取当天最大的
+func selectDominants(cal *calendar, rule Rule, start int, cur string) ([]*Dominant, []*Roll) {
+	rule = rule.fill()
+	var ds []*Dominant
+	var rolls []*Roll
+	pending, count := "", 0
+	for i := start; i < len(cal.dates); i++ {
+		date := cal.dates[i]
+		t := parseDate(date)
+		day := cal.bars[date]
+
+		//没有主力或主力已经没有行情(到期),当天切换
+		if cur == "" || day[cur] == nil {
+			next := rule.best(day, cur, t)
+			if next == "" {
+				next = Rule{By: rule.By, Back: true}.best(day, "", t)
+			}
+			if cur != "" && next != "" {
+				r := &Roll{Date: date, From: cur, To: next}
+				if k := cal.bar(i-1, cur); k != nil {
+					r.FromPrice = k.Close
+				}
+				if k := cal.bar(i-1, next); k != nil {
+					r.ToPrice = k.Close
+				} else {
+					r.ToPrice = day[next].Open
+				}
+				rolls = append(rolls, r)
+			}
+			cur, pending, count = next, "", 0
+		}
+		if cur == "" {
+			continue
+		}
+		ds = append(ds, &Dominant{Date: date, Code: cur})
+
+		//收盘后判断,下一交易日生效
+		best := rule.best(day, cur, t)
+		if best == "" || best == cur || rule.metric(day[best]) < rule.metric(day[cur])*rule.Ratio {
+			pending, count = "", 0
+			continue
+		}
+		if best == pending {
+			count++
+		} else {
+			pending, count = best, 1
+		}
+		if count >= rule.Days && i+1 < len(cal.dates) {
+			rolls = append(rolls, &Roll{Date: cal.dates[i+1], From: cur, To: best, FromPrice: day[cur].Close, ToPrice: day[best].Close})
+			cur, pending, count = best, "", 0
+		}
+	}
+	return ds, rolls
+}
+
+// ContractMonth 合约的到期年月 YYYYMM,例 IF2506→202506;郑商所3位年月(SR509)取离 date 最近的年份,
+// 不是合约代码时返回0
+func ContractMonth(code string, date time.Time) int {
+	root := tdx.FuturesRoot(code)
+	if root == "" || len(code)-len(root) < 3 {
+		return 0
+	}
+	n, err := strconv.Atoi(code[len(root):])
+	if err != nil {
+		return 0
+	}
+	if len(code)-len(root) == 4 {
+		return 200000 + n
+	}
+	year := date.Year()/10*10 + n/100
+	if year > date.Year()+5 {
+		year -= 10
+	} else if year < date.Year()-5 {
+		year += 10
+	}
+	return year*100 + n%100
+}
+
+func barDate(datetime string) string {
+	if len(datetime) >= 10 {
+		return datetime[:10]
+	}
+	return datetime
+}
+
+func parseDate(date string) time.Time {
+	t, _ := time.ParseInLocation(time.DateOnly, date, time.Local)
+	return t
+}
@@ -0,0 +1,139 @@
+package futures
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// bars 生成测试日线,oi 为每天的持仓,0 表示当天没有行情,收盘价为 close+天数
+func bars(close float64, oi ...uint32) []protocol.ExKline {
+	var ls []protocol.ExKline
+	for i, v := range oi {
+		if v == 0 {
+			continue
+		}
+		c := close + float64(i)
+		ls = append(ls, protocol.ExKline{
+			Datetime: time.Date(2025, 6, 2+i, 15, 0, 0, 0, time.Local).Format("2006-01-02 15:04"),
+			Open:     c, High: c + 1, Low: c - 1, Close: c, Price: c, Position: v, Trade: v,
+		})
+	}
+	return ls
+}
+
+func codes(ds []*Dominant) []string {
+	ls := make([]string, len(ds))
+	for i, v := range ds {
+		ls[i] = v.Code
+	}
+	return ls
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSelectDominants(t *testing.T) {
+	m := map[string][]protocol.ExKline{
+		"IF2506": bars(100, 50, 50, 40, 30, 20, 0),
+		"IF2507": bars(110, 10, 60, 60, 70, 80, 90),
+		"IF2509": bars(120, 5, 5, 70, 5, 5, 5),
+	}
+
+	//第2天收盘 IF2507 超过,第3天生效;不回退到更早的合约
+	ds, rolls := SelectDominants(m, Rule{})
+	if want := []string{"IF2506", "IF2506", "IF2507", "IF2509", "IF2509", "IF2509"}; !equal(codes(ds), want) {
+		t.Errorf("默认规则 = %v, want %v", codes(ds), want)
+	}
+	if len(rolls) != 2 || rolls[0].Date != "2025-06-04" || rolls[0].FromPrice != 101 || rolls[0].ToPrice != 111 {
+		t.Errorf("rolls = %+v", rolls)
+	}
+
+	//连续2天是同一个合约满足才切换
+	ds, rolls = SelectDominants(m, Rule{Days: 2})
+	if want := []string{"IF2506", "IF2506", "IF2506", "IF2506", "IF2506", "IF2507"}; !equal(codes(ds), want) {
+		t.Errorf("Days=2 = %v, want %v", codes(ds), want)
+	}
+	if len(rolls) != 1 || rolls[0].Date != "2025-06-07" || rolls[0].FromPrice != 104 || rolls[0].ToPrice != 114 {
+		t.Errorf("rolls = %+v", rolls)
+	}
+
+	//超过2倍才切换
+	ds, rolls = SelectDominants(m, Rule{Ratio: 2})
+	if want := []string{"IF2506", "IF2506", "IF2506", "IF2506", "IF2507", "IF2507"}; !equal(codes(ds), want) {
+		t.Errorf("Ratio=2 = %v, want %v", codes(ds), want)
+	}
+	if len(rolls) != 1 || rolls[0].Date != "2025-06-06" {
+		t.Errorf("rolls = %+v", rolls)
+	}
+
+	//没满足条件前 IF2506 到期,当天切换,价格取前一天收盘
+	ds, rolls = SelectDominants(m, Rule{Days: 3})
+	if want := []string{"IF2506", "IF2506", "IF2506", "IF2506", "IF2506", "IF2507"}; !equal(codes(ds), want) {
+		t.Errorf("Days=3 = %v, want %v", codes(ds), want)
+	}
+	if len(rolls) != 1 || rolls[0].Date != "2025-06-07" || rolls[0].FromPrice != 104 || rolls[0].ToPrice != 114 {
+		t.Errorf("rolls = %+v", rolls)
+	}
+
+	//允许回退到更早的合约
+	ds, _ = SelectDominants(m, Rule{Back: true})
+	if want := []string{"IF2506", "IF2506", "IF2507", "IF2509", "IF2507", "IF2507"}; !equal(codes(ds), want) {
+		t.Errorf("Back = %v, want %v", codes(ds), want)
+	}
+
+	//从换月处继续计算,结果和全量一致
+	cal := newCalendar(m)
+	all, _ := selectDominants(cal, Rule{}, 0, "")
+	part, _ := selectDominants(cal, Rule{}, cal.index("2025-06-04"), "IF2507")
+	if !equal(codes(all[2:]), codes(part)) {
+		t.Errorf("增量 = %v, want %v", codes(part), codes(all[2:]))
+	}
+}
+
+func TestContinuous(t *testing.T) {
+	m := map[string][]protocol.ExKline{
+		"IF2506": bars(100, 50, 50, 0),
+		"IF2507": bars(110, 10, 60, 60),
+	}
+	ds, rolls := SelectDominants(m, Rule{})
+
+	ls := Continuous(m, ds, rolls, AdjustNone)
+	if len(ls) != 3 || ls[0].Close != 100 || ls[1].Close != 101 || ls[2].Close != 112 {
+		t.Errorf("不复权 = %+v", ls)
+	}
+
+	//换月前一天 IF2506=101 IF2507=111
+	ls = Continuous(m, ds, rolls, AdjustDiff)
+	if ls[0].Close != 110 || ls[1].Close != 111 || ls[1].High != 112 || ls[2].Close != 112 {
+		t.Errorf("差值复权 = %+v", ls)
+	}
+	ls = Continuous(m, ds, rolls, AdjustRatio)
+	if math.Abs(ls[0].Close-100*111.0/101) > 1e-9 || math.Abs(ls[1].Close-111) > 1e-9 || ls[2].Close != 112 {
+		t.Errorf("比例复权 = %+v", ls)
+	}
+}
+
+func TestContractMonth(t *testing.T) {
+	date := time.Date(2025, 12, 1, 0, 0, 0, 0, time.Local)
+	for code, month := range map[string]int{
+		"IF2506": 202506, "rb2601": 202601, "SR509": 202509, "SR601": 202601, "SR312": 202312,
+		"SR912": 202912,
+		"IFL8":  0, "AAPL": 0, "00700": 0,
+	} {
+		if got := ContractMonth(code, date); got != month {
+			t.Errorf("ContractMonth(%s) = %d, want %d", code, got, month)
+		}
+	}
+}
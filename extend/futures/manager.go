@@ -0,0 +1,445 @@
+package futures
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/lib/xorms"
+	"github.com/injoyai/tdx/protocol"
+	"xorm.io/xorm"
+)
+
+const (
+	barsPage  = 700 //ExBars 每页数量
+	quotePage = 80  //ExQuoteList 每页数量
+	dayKline  = 4   //日线
+	category  = 3   //期货
+	batch     = 500 //批量写入的数量
+)
+
+type Option func(*Manager)
+
+// WithClient 扩展行情客户端,见 tdx.DialExHqDefault
+func WithClient(c *tdx.Client) Option {
+	return func(m *Manager) {
+		m.c = c
+	}
+}
+
+func WithDialClient(dial tdx.DialClientFunc) Option {
+	return func(m *Manager) {
+		m.dialClient = dial
+	}
+}
+
+// WithCodes 扩展代码表,用于列出品种的全部合约,默认 tdx.NewExCodes
+func WithCodes(codes tdx.IExCodes) Option {
+	return func(m *Manager) {
+		m.codes = codes
+	}
+}
+
+func WithDB(db *xorms.Engine) Option {
+	return func(m *Manager) {
+		m.db = db
+	}
+}
+
+func WithDialDB(dial tdx.DialDBFunc) Option {
+	return func(m *Manager) {
+		m.dialDB = dial
+	}
+}
+
+func WithRule(rule Rule) Option {
+	return func(m *Manager) {
+		m.rule = rule
+	}
+}
+
+// New 主力合约管理,合约日线、每天的主力和换月记录持久化到数据库(默认 futures.db),Update 增量更新
+func New(op ...Option) (*Manager, error) {
+	m := &Manager{}
+	for _, v := range op {
+		if v != nil {
+			v(m)
+		}
+	}
+	m.rule = m.rule.fill()
+
+	var err error
+	if m.c == nil {
+		if m.dialClient == nil {
+			m.dialClient = func() (*tdx.Client, error) { return tdx.DialExHqDefault() }
+		}
+		m.c, err = m.dialClient()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if m.codes == nil {
+		m.codes, err = tdx.NewExCodes(tdx.WithExCodesClient(m.c))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if m.db == nil {
+		if m.dialDB == nil {
+			m.dialDB = func() (*xorms.Engine, error) {
+				return xorms.NewSqlite(filepath.Join(tdx.DefaultDatabaseDir, "futures.db"))
+			}
+		}
+		m.db, err = m.dialDB()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = m.db.Sync2(new(FuturesBar), new(FuturesDominant), new(FuturesRoll)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type Manager struct {
+	rule       Rule
+	dialClient tdx.DialClientFunc
+	dialDB     tdx.DialDBFunc
+
+	c     *tdx.Client
+	codes tdx.IExCodes
+	db    *xorms.Engine
+}
+
+// Contracts 品种在交易的全部合约,不含主连/指数(L8/L9),按到期月份升序。
+//
+// 注意: 只来自当前的扩展代码表,已到期的合约不在里面。Update 只拉取这些合约,
+// 之前拉过的合约日线会留在数据库里继续参与主力计算,所以完整的历史需要长期运行积累,
+// 第一次运行之前的历史要用 UpdateContracts 传入到期的合约回补(服务器不一定还有到期合约的日线)
+func (this *Manager) Contracts(market protocol.Exchange, root string) []string {
+	var ls []string
+	for _, v := range this.codes.GetRoot(root) {
+		if protocol.Exchange(v.Market) == market && !isIndex(v.Code) {
+			ls = append(ls, v.Code)
+		}
+	}
+	return ls
+}
+
+// Update 增量更新品种在交易的合约(见 Contracts)的日线,从最后一次换月开始重新计算主力和换月记录
+func (this *Manager) Update(market protocol.Exchange, root string) error {
+	codes := this.Contracts(market, root)
+	if len(codes) == 0 {
+		return errors.New("没有找到品种的合约: " + root)
+	}
+	return this.UpdateContracts(market, root, codes...)
+}
+
+// UpdateContracts 同 Update,合约由调用方指定,用于回补代码表里已经没有的到期合约,例 rb2301 rb2305,
+// 主连/指数代码会被忽略。新合约的日线早于最后一次换月时,从头重新计算主力和换月记录
+func (this *Manager) UpdateContracts(market protocol.Exchange, root string, codes ...string) error {
+	first := "" //新合约最早的日期
+	for _, code := range codes {
+		if isIndex(code) {
+			continue
+		}
+		date, err := this.updateBars(market, root, code)
+		if err != nil {
+			return err
+		}
+		if date != "" && (first == "" || date < first) {
+			first = date
+		}
+	}
+
+	bars, err := this.bars(market, root)
+	if err != nil {
+		return err
+	}
+	cal := newCalendar(bars)
+	if len(cal.dates) == 0 {
+		return nil
+	}
+
+	//从最后一次换月开始,之前的主力不会再变
+	start, cur := 0, ""
+	last := new(FuturesRoll)
+	has, err := this.db.Where("Market=? AND Root=?", market, root).Desc("Date").Get(last)
+	if err != nil {
+		return err
+	}
+	if has && (first == "" || first >= last.Date) {
+		start, cur = cal.index(last.Date), last.To
+	}
+	ds, rolls := selectDominants(cal, this.rule, start, cur)
+	from := cal.dates[min(start, len(cal.dates)-1)]
+
+	return this.db.SessionFunc(func(session *xorm.Session) error {
+		if _, err := session.Where("Market=? AND Root=? AND Date>=?", market, root, from).Delete(new(FuturesDominant)); err != nil {
+			return err
+		}
+		if _, err := session.Where("Market=? AND Root=? AND Date>?", market, root, from).Delete(new(FuturesRoll)); err != nil {
+			return err
+		}
+		insert := make([]*FuturesDominant, 0, len(ds))
+		for _, v := range ds {
+			insert = append(insert, &FuturesDominant{Market: uint8(market), Root: root, Date: v.Date, Code: v.Code})
+		}
+		for i := 0; i < len(insert); i += batch {
+			if _, err := session.Insert(insert[i:min(i+batch, len(insert))]); err != nil {
+				return err
+			}
+		}
+		for _, v := range rolls {
+			if v.Date <= from {
+				continue
+			}
+			_, err := session.Insert(&FuturesRoll{
+				Market: uint8(market), Root: root, Date: v.Date,
+				From: v.From, To: v.To, FromPrice: v.FromPrice, ToPrice: v.ToPrice,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// updateBars 从最新往前分页拉取日线,直到数据库里最后一天,最后一天重新写入(可能是盘中数据),
+// 数据库里原来没有这个合约时返回写入的第一天
+func (this *Manager) updateBars(market protocol.Exchange, root, code string) (string, error) {
+	last := new(FuturesBar)
+	has, err := this.db.Where("Market=? AND Code=?", market, code).Desc("Date").Get(last)
+	if err != nil {
+		return "", err
+	}
+
+	var ls []protocol.ExKline
+	for start := 0; ; start += barsPage {
+		page, err := this.c.ExBars(dayKline, uint8(market), code, uint16(start), barsPage)
+		if err != nil {
+			return "", err
+		}
+		ls = append(page, ls...)
+		if len(page) < barsPage || (len(page) > 0 && barDate(page[0].Datetime) <= last.Date) || start+barsPage > 0xFFFF-barsPage {
+			break
+		}
+	}
+
+	insert := make([]*FuturesBar, 0, len(ls))
+	for _, v := range ls {
+		if date := barDate(v.Datetime); date >= last.Date {
+			insert = append(insert, newFuturesBar(market, root, code, v))
+		}
+	}
+	if len(insert) == 0 {
+		return "", nil
+	}
+	err = this.db.SessionFunc(func(session *xorm.Session) error {
+		if _, err := session.Where("Market=? AND Code=? AND Date>=?", market, code, insert[0].Date).Delete(new(FuturesBar)); err != nil {
+			return err
+		}
+		for i := 0; i < len(insert); i += batch {
+			if _, err := session.Insert(insert[i:min(i+batch, len(insert))]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || has {
+		return "", err
+	}
+	return insert[0].Date, nil
+}
+
+// bars 数据库里品种各合约的日线,合约→日线
+func (this *Manager) bars(market protocol.Exchange, root string) (map[string][]protocol.ExKline, error) {
+	var ls []*FuturesBar
+	if err := this.db.Where("Market=? AND Root=?", market, root).Asc("Date").Find(&ls); err != nil {
+		return nil, err
+	}
+	m := map[string][]protocol.ExKline{}
+	for _, v := range ls {
+		m[v.Code] = append(m[v.Code], v.ExKline())
+	}
+	return m, nil
+}
+
+// Dominants 每天的主力合约,日期升序
+func (this *Manager) Dominants(market protocol.Exchange, root string) ([]*Dominant, error) {
+	var ls []*FuturesDominant
+	if err := this.db.Where("Market=? AND Root=?", market, root).Asc("Date").Find(&ls); err != nil {
+		return nil, err
+	}
+	res := make([]*Dominant, 0, len(ls))
+	for _, v := range ls {
+		res = append(res, &Dominant{Date: v.Date, Code: v.Code})
+	}
+	return res, nil
+}
+
+// Rolls 换月记录,日期升序
+func (this *Manager) Rolls(market protocol.Exchange, root string) ([]*Roll, error) {
+	var ls []*FuturesRoll
+	if err := this.db.Where("Market=? AND Root=?", market, root).Asc("Date").Find(&ls); err != nil {
+		return nil, err
+	}
+	res := make([]*Roll, 0, len(ls))
+	for _, v := range ls {
+		res = append(res, &Roll{Date: v.Date, From: v.From, To: v.To, FromPrice: v.FromPrice, ToPrice: v.ToPrice})
+	}
+	return res, nil
+}
+
+// Series 主力连续日线,需要先 Update
+func (this *Manager) Series(market protocol.Exchange, root string, adjust Adjust) ([]protocol.ExKline, error) {
+	bars, err := this.bars(market, root)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := this.Dominants(market, root)
+	if err != nil {
+		return nil, err
+	}
+	rolls, err := this.Rolls(market, root)
+	if err != nil {
+		return nil, err
+	}
+	return Continuous(bars, ds, rolls, adjust), nil
+}
+
+// DominantNow 按实时行情的持仓(或成交量)取市场每个品种当前最大的合约,品种→合约,
+// 不经过换月规则,用于盘中查看
+func (this *Manager) DominantNow(market protocol.Exchange) (map[string]string, error) {
+	best := map[string]*protocol.ExQuoteListItem{}
+	for start := 0; ; start += quotePage {
+		ls, err := this.c.ExQuoteList(uint8(market), category, uint16(start), quotePage)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ls {
+			v := &ls[i]
+			root := tdx.FuturesRoot(v.Code)
+			if root == "" || isIndex(v.Code) {
+				continue
+			}
+			if old := best[root]; old == nil || this.quoteMetric(v) > this.quoteMetric(old) {
+				best[root] = v
+			}
+		}
+		if len(ls) < quotePage {
+			break
+		}
+	}
+	res := make(map[string]string, len(best))
+	for k, v := range best {
+		res[k] = v.Code
+	}
+	return res, nil
+}
+
+func (this *Manager) quoteMetric(v *protocol.ExQuoteListItem) uint32 {
+	if this.rule.By == ByVolume {
+		return v.ZongLiang
+	}
+	return v.ChiCang
+}
+
+// isIndex 是否主连/指数代码,例 IFL8 IFL9
+func isIndex(code string) bool {
+	root := tdx.FuturesRoot(code)
+	return root != "" && strings.HasPrefix(code[len(root):], "L")
+}
+
+/*
+
+
+
+ */
+
+// FuturesBar 合约日线
+type FuturesBar struct {
+	ID       int64   `json:"id"`
+	Market   uint8   `json:"market" xorm:"index"`
+	Root     string  `json:"root" xorm:"index"` //品种,例 IF
+	Code     string  `json:"code" xorm:"index"` //合约,例 IF2506
+	Date     string  `json:"date" xorm:"index"` //YYYY-MM-DD
+	Datetime string  `json:"datetime"`          //原始时间
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	Settle   float64 `json:"settle"` //结算价
+	Volume   uint32  `json:"volume"` //成交量
+	OI       uint32  `json:"oi"`     //持仓
+	Amount   float64 `json:"amount"`
+}
+
+func (*FuturesBar) TableName() string {
+	return "futures_bar"
+}
+
+func newFuturesBar(market protocol.Exchange, root, code string, k protocol.ExKline) *FuturesBar {
+	return &FuturesBar{
+		Market:   uint8(market),
+		Root:     root,
+		Code:     code,
+		Date:     barDate(k.Datetime),
+		Datetime: k.Datetime,
+		Open:     k.Open,
+		High:     k.High,
+		Low:      k.Low,
+		Close:    k.Close,
+		Settle:   k.Price,
+		Volume:   k.Trade,
+		OI:       k.Position,
+		Amount:   k.Amount,
+	}
+}
+
+func (this *FuturesBar) ExKline() protocol.ExKline {
+	return protocol.ExKline{
+		Datetime: this.Datetime,
+		Open:     this.Open,
+		High:     this.High,
+		Low:      this.Low,
+		Close:    this.Close,
+		Position: this.OI,
+		Trade:    this.Volume,
+		Price:    this.Settle,
+		Amount:   this.Amount,
+	}
+}
+
+// FuturesDominant 某天的主力合约
+type FuturesDominant struct {
+	ID     int64  `json:"id"`
+	Market uint8  `json:"market" xorm:"index"`
+	Root   string `json:"root" xorm:"index"`
+	Date   string `json:"date" xorm:"index"`
+	Code   string `json:"code"`
+}
+
+func (*FuturesDominant) TableName() string {
+	return "futures_dominant"
+}
+
+// FuturesRoll 换月记录
+type FuturesRoll struct {
+	ID        int64   `json:"id"`
+	Market    uint8   `json:"market" xorm:"index"`
+	Root      string  `json:"root" xorm:"index"`
+	Date      string  `json:"date" xorm:"index"` //新合约成为主力的第一天
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	FromPrice float64 `json:"fromPrice"`
+	ToPrice   float64 `json:"toPrice"`
+}
+
+func (*FuturesRoll) TableName() string {
+	return "futures_roll"
+}
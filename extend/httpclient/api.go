@@ -0,0 +1,104 @@
+package httpclient
+
+import (
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// API tdx.Client 和 Client 共有的方法
+type API interface {
+	// 代码/数量
+	GetCount(exchange protocol.Exchange) (*protocol.CountResp, error)
+	GetCode(exchange protocol.Exchange, start uint16) (*protocol.CodeResp, error)
+	GetCodeAll(exchange protocol.Exchange) (*protocol.CodeResp, error)
+	GetStockCodeAll() ([]string, error)
+	GetETFCodeAll() ([]string, error)
+	GetIndexCodeAll() ([]string, error)
+
+	// 行情/财务
+	GetQuote(codes ...string) (protocol.QuotesResp, error)
+	GetCallAuction(code string) (*protocol.CallAuctionResp, error)
+	GetGbbq(code string) (*protocol.GbbqResp, error)
+	GetFinanceInfo(exchange protocol.Exchange, code string) (*protocol.FinanceInfo, error)
+	GetCompanyCategory(exchange protocol.Exchange, code string) ([]protocol.CompanyCategory, error)
+	GetCompanyContent(exchange protocol.Exchange, code, filename string, start, length uint32) (string, error)
+	GetCompanyF10(exchange protocol.Exchange, code string, cache tdx.CompanyCache) (*protocol.CompanyF10, error)
+
+	// 分时/成交
+	GetMinute(code string) (*protocol.MinuteResp, error)
+	GetHistoryMinute(date, code string) (*protocol.MinuteResp, error)
+	GetMinuteTrade(code string, start, count uint16) (*protocol.TradeResp, error)
+	GetMinuteTradeAll(code string) (*protocol.TradeResp, error)
+	GetHistoryMinuteTrade(date, code string, start, count uint16) (*protocol.TradeResp, error)
+	GetHistoryMinuteTradeDay(date, code string) (*protocol.TradeResp, error)
+
+	// K线
+	GetKline(Type uint8, code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKlineAll(Type uint8, code string) (*protocol.KlineResp, error)
+	GetKlineMinute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKlineMinuteAll(code string) (*protocol.KlineResp, error)
+	GetKline5Minute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKline5MinuteAll(code string) (*protocol.KlineResp, error)
+	GetKline15Minute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKline15MinuteAll(code string) (*protocol.KlineResp, error)
+	GetKline30Minute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKline30MinuteAll(code string) (*protocol.KlineResp, error)
+	GetKline60Minute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKline60MinuteAll(code string) (*protocol.KlineResp, error)
+	GetKlineDay(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKlineDayAll(code string) (*protocol.KlineResp, error)
+	GetKlineWeek(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKlineWeekAll(code string) (*protocol.KlineResp, error)
+	GetKlineMonth(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKlineMonthAll(code string) (*protocol.KlineResp, error)
+	GetKlineQuarter(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKlineQuarterAll(code string) (*protocol.KlineResp, error)
+	GetKlineYear(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetKlineYearAll(code string) (*protocol.KlineResp, error)
+
+	// 指数K线
+	GetIndex(Type uint8, code string, start, count uint16) (*protocol.KlineResp, error)
+	GetIndexAll(Type uint8, code string) (*protocol.KlineResp, error)
+	GetIndexMinute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetIndex5Minute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetIndex15Minute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetIndex30Minute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetIndex60Minute(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetIndexDay(code string, start, count uint16) (*protocol.KlineResp, error)
+	GetIndexDayAll(code string) (*protocol.KlineResp, error)
+	GetIndexWeekAll(code string) (*protocol.KlineResp, error)
+	GetIndexMonthAll(code string) (*protocol.KlineResp, error)
+	GetIndexQuarterAll(code string) (*protocol.KlineResp, error)
+	GetIndexYearAll(code string) (*protocol.KlineResp, error)
+
+	// 板块/报表
+	GetBlockData(file string) ([]*protocol.Block, error)
+	GetBlockDataWithIndex(file string) ([]*protocol.Block, error)
+	GetBlockFileRaw(file string) ([]byte, error)
+	GetReportFile(file string) ([]byte, error)
+	GetZHBFiles() (map[string][]byte, error)
+	GetTdxZs() ([]*protocol.TdxZs, error)
+	GetTdxBk() ([]*protocol.TdxBk, error)
+	GetTdxStat() ([]*protocol.TdxStat, error)
+	GetTdxStat2() ([]*protocol.TdxStat2, error)
+	GetXgsg() ([]*protocol.TdxXgsg, error)
+	GetTdxHy() ([]*protocol.TdxHy, error)
+	GetSpBlock() ([]*protocol.SpBlock, error)
+
+	// 扩展行情,需要连接扩展行情服务器/网关启用扩展行情
+	ExMarkets() ([]protocol.ExMarket, error)
+	ExCount() (int, error)
+	ExInstruments(start uint32, count uint16) ([]protocol.ExInstrument, error)
+	ExQuote(market uint8, code string) (*protocol.ExQuote, error)
+	ExQuoteList(market, category uint8, start, count uint16) ([]protocol.ExQuoteListItem, error)
+	ExBars(category, market uint8, code string, start, count uint16) ([]protocol.ExKline, error)
+	ExMinute(market uint8, code string) ([]protocol.ExMinuteTick, error)
+	ExHistMinute(market uint8, code string, date uint32) ([]protocol.ExMinuteTick, error)
+	ExTrade(market uint8, code string, start, count uint16) ([]protocol.ExTradeTick, error)
+	ExHistTrade(market uint8, code string, date uint32, start, count uint16) ([]protocol.ExTradeTick, error)
+	ExBarsRange(market uint8, code string, date, date2 uint32) ([]protocol.ExRangeKline, error)
+}
+
+// 编译期检查两个客户端都实现了 API,任意一边改了方法签名都会编译失败
+var _ API = (*tdx.Client)(nil)
+var _ API = (*Client)(nil)
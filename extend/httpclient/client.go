@@ -0,0 +1,560 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 通过 httpserver 网关获取数据的客户端,方法签名和 tdx.Client 一致,
+// 业务代码依赖 API 接口即可在直连通达信和共用网关之间切换:
+//
+//	var api httpclient.API = tdxClient
+//	api = httpclient.New("http://gateway:8080")
+
+// Option 客户端配置选项
+type Option func(*Client)
+
+// WithHTTPClient 设置 http.Client,默认超时10秒
+func WithHTTPClient(c *http.Client) Option {
+	return func(this *Client) { this.client = c }
+}
+
+// WithTimeout 设置请求超时
+func WithTimeout(d time.Duration) Option {
+	return func(this *Client) { this.client.Timeout = d }
+}
+
+// WithHeader 设置每个请求都带上的请求头,如鉴权
+func WithHeader(key, value string) Option {
+	return func(this *Client) { this.header.Set(key, value) }
+}
+
+// WithAPIKey 设置网关的 API key 或令牌,网关启用鉴权时需要
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// New 创建客户端,addr 为网关地址,例 http://127.0.0.1:8080
+func New(addr string, opts ...Option) *Client {
+	c := &Client{
+		addr:   strings.TrimRight(addr, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+		header: http.Header{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Client 网关客户端
+type Client struct {
+	addr   string
+	client *http.Client
+	header http.Header
+}
+
+// Error 网关返回的错误,Status 为400时 Param 为校验不通过的参数,
+// 401 为缺少或无效的 API key,429 为超出限流或每日请求数
+type Error struct {
+	Status int    //HTTP状态码
+	Msg    string //错误信息
+	Param  string //出错的参数
+	Reason string //required/format/enum
+}
+
+func (this *Error) Error() string {
+	return this.Msg
+}
+
+type response struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// get 请求并解析 data 到 v
+func (this *Client) get(path string, query url.Values, v any) error {
+	u := this.addr + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	for k, vs := range this.header {
+		req.Header[k] = vs
+	}
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	r := new(response)
+	if err = json.Unmarshal(bs, r); err != nil {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(bs)))
+	}
+	if r.Code != 0 {
+		e := &Error{Status: resp.StatusCode, Msg: r.Msg}
+		if resp.StatusCode == http.StatusBadRequest && len(r.Data) > 0 {
+			p := struct {
+				Param  string `json:"param"`
+				Reason string `json:"reason"`
+			}{}
+			if json.Unmarshal(r.Data, &p) == nil {
+				e.Param, e.Reason = p.Param, p.Reason
+			}
+		}
+		return e
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Data, v)
+}
+
+// ---- 参数 ----
+
+type values url.Values
+
+func query() values { return values{} }
+
+func (this values) str(key, v string) values {
+	url.Values(this).Set(key, v)
+	return this
+}
+
+func (this values) uint(key string, v uint64) values {
+	url.Values(this).Set(key, strconv.FormatUint(v, 10))
+	return this
+}
+
+func (this values) exchange(ex protocol.Exchange) values { return this.str("exchange", ex.String()) }
+
+func (this values) code(code string) values { return this.str("code", code) }
+
+func (this values) page(start, count uint16) values {
+	return this.uint("start", uint64(start)).uint("count", uint64(count))
+}
+
+func (this values) typ(t uint8) values { return this.uint("type", uint64(t)) }
+
+func (this values) date(date string) values { return this.str("date", date) }
+
+func (this values) market(market uint8) values { return this.uint("market", uint64(market)) }
+
+// getKline K线类的接口
+func (this *Client) getKline(path string, q values) (resp *protocol.KlineResp, err error) {
+	err = this.get(path, url.Values(q), &resp)
+	return
+}
+
+// ---- 代码/数量 ----
+
+func (this *Client) GetCount(exchange protocol.Exchange) (resp *protocol.CountResp, err error) {
+	err = this.get("/count", url.Values(query().exchange(exchange)), &resp)
+	return
+}
+
+func (this *Client) GetCode(exchange protocol.Exchange, start uint16) (resp *protocol.CodeResp, err error) {
+	err = this.get("/code", url.Values(query().exchange(exchange).uint("start", uint64(start))), &resp)
+	return
+}
+
+func (this *Client) GetCodeAll(exchange protocol.Exchange) (resp *protocol.CodeResp, err error) {
+	err = this.get("/code/all", url.Values(query().exchange(exchange)), &resp)
+	return
+}
+
+func (this *Client) GetStockCodeAll() (resp []string, err error) {
+	err = this.get("/code/stocks", nil, &resp)
+	return
+}
+
+func (this *Client) GetETFCodeAll() (resp []string, err error) {
+	err = this.get("/code/etfs", nil, &resp)
+	return
+}
+
+func (this *Client) GetIndexCodeAll() (resp []string, err error) {
+	err = this.get("/code/indexes", nil, &resp)
+	return
+}
+
+// ---- 行情/财务 ----
+
+func (this *Client) GetQuote(codes ...string) (resp protocol.QuotesResp, err error) {
+	err = this.get("/quote", url.Values(query().str("codes", strings.Join(codes, ","))), &resp)
+	return
+}
+
+func (this *Client) GetCallAuction(code string) (resp *protocol.CallAuctionResp, err error) {
+	err = this.get("/call_auction", url.Values(query().code(code)), &resp)
+	return
+}
+
+func (this *Client) GetGbbq(code string) (resp *protocol.GbbqResp, err error) {
+	err = this.get("/gbbq", url.Values(query().code(code)), &resp)
+	return
+}
+
+func (this *Client) GetFinanceInfo(exchange protocol.Exchange, code string) (resp *protocol.FinanceInfo, err error) {
+	err = this.get("/finance", url.Values(query().exchange(exchange).code(code)), &resp)
+	return
+}
+
+func (this *Client) GetCompanyCategory(exchange protocol.Exchange, code string) (resp []protocol.CompanyCategory, err error) {
+	err = this.get("/company/category", url.Values(query().exchange(exchange).code(code)), &resp)
+	return
+}
+
+func (this *Client) GetCompanyContent(exchange protocol.Exchange, code, filename string, start, length uint32) (resp string, err error) {
+	q := query().exchange(exchange).code(code).str("filename", filename).uint("start", uint64(start)).uint("length", uint64(length))
+	err = this.get("/company/content", url.Values(q), &resp)
+	return
+}
+
+// GetCompanyF10 cache 为nil时由网关解析(网关有缓存),否则同 tdx.Client,逐个分类读取缓存或请求原文
+func (this *Client) GetCompanyF10(exchange protocol.Exchange, code string, cache tdx.CompanyCache) (*protocol.CompanyF10, error) {
+	if cache == nil {
+		var resp *protocol.CompanyF10
+		err := this.get("/company/f10", url.Values(query().exchange(exchange).code(code)), &resp)
+		return resp, err
+	}
+	cats, err := this.GetCompanyCategory(exchange, code)
+	if err != nil {
+		return nil, err
+	}
+	f := &protocol.CompanyF10{Code: exchange.String() + code}
+	for _, cat := range cats {
+		text, ok := cache.Get(exchange, code, cat)
+		if !ok {
+			text, err = this.GetCompanyContent(exchange, code, cat.Filename, cat.Start, cat.Length)
+			if err != nil {
+				return nil, err
+			}
+			if err = cache.Set(exchange, code, cat, text); err != nil {
+				return nil, err
+			}
+		}
+		f.Add(cat.Name, text)
+	}
+	return f, nil
+}
+
+// ---- 分时/成交 ----
+
+func (this *Client) GetMinute(code string) (resp *protocol.MinuteResp, err error) {
+	err = this.get("/minute", url.Values(query().code(code)), &resp)
+	return
+}
+
+func (this *Client) GetHistoryMinute(date, code string) (resp *protocol.MinuteResp, err error) {
+	err = this.get("/minute/history", url.Values(query().date(date).code(code)), &resp)
+	return
+}
+
+func (this *Client) GetMinuteTrade(code string, start, count uint16) (resp *protocol.TradeResp, err error) {
+	err = this.get("/trade", url.Values(query().code(code).page(start, count)), &resp)
+	return
+}
+
+func (this *Client) GetMinuteTradeAll(code string) (resp *protocol.TradeResp, err error) {
+	err = this.get("/trade/all", url.Values(query().code(code)), &resp)
+	return
+}
+
+func (this *Client) GetHistoryMinuteTrade(date, code string, start, count uint16) (resp *protocol.TradeResp, err error) {
+	err = this.get("/trade/history", url.Values(query().date(date).code(code).page(start, count)), &resp)
+	return
+}
+
+func (this *Client) GetHistoryMinuteTradeDay(date, code string) (resp *protocol.TradeResp, err error) {
+	err = this.get("/trade/history/day", url.Values(query().date(date).code(code)), &resp)
+	return
+}
+
+// ---- K线 ----
+
+func (this *Client) GetKline(Type uint8, code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline", query().typ(Type).code(code).page(start, count))
+}
+
+func (this *Client) GetKlineAll(Type uint8, code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/all", query().typ(Type).code(code))
+}
+
+func (this *Client) GetKlineMinute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKlineMinuteAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/minute/all", query().code(code))
+}
+
+func (this *Client) GetKline5Minute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/5minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKline5MinuteAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/5minute/all", query().code(code))
+}
+
+func (this *Client) GetKline15Minute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/15minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKline15MinuteAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/15minute/all", query().code(code))
+}
+
+func (this *Client) GetKline30Minute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/30minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKline30MinuteAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/30minute/all", query().code(code))
+}
+
+func (this *Client) GetKline60Minute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/60minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKline60MinuteAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/60minute/all", query().code(code))
+}
+
+func (this *Client) GetKlineDay(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/day", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKlineDayAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/day/all", query().code(code))
+}
+
+func (this *Client) GetKlineWeek(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/week", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKlineWeekAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/week/all", query().code(code))
+}
+
+func (this *Client) GetKlineMonth(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/month", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKlineMonthAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/month/all", query().code(code))
+}
+
+func (this *Client) GetKlineQuarter(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/quarter", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKlineQuarterAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/quarter/all", query().code(code))
+}
+
+func (this *Client) GetKlineYear(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/year", query().code(code).page(start, count))
+}
+
+func (this *Client) GetKlineYearAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/kline/year/all", query().code(code))
+}
+
+// ---- 指数K线 ----
+
+func (this *Client) GetIndex(Type uint8, code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/index", query().typ(Type).code(code).page(start, count))
+}
+
+func (this *Client) GetIndexAll(Type uint8, code string) (*protocol.KlineResp, error) {
+	return this.getKline("/index/all", query().typ(Type).code(code))
+}
+
+func (this *Client) GetIndexMinute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/index/minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetIndex5Minute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/index/5minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetIndex15Minute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/index/15minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetIndex30Minute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/index/30minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetIndex60Minute(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/index/60minute", query().code(code).page(start, count))
+}
+
+func (this *Client) GetIndexDay(code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline("/index/day", query().code(code).page(start, count))
+}
+
+func (this *Client) GetIndexDayAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/index/day/all", query().code(code))
+}
+
+func (this *Client) GetIndexWeekAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/index/week/all", query().code(code))
+}
+
+func (this *Client) GetIndexMonthAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/index/month/all", query().code(code))
+}
+
+func (this *Client) GetIndexQuarterAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/index/quarter/all", query().code(code))
+}
+
+func (this *Client) GetIndexYearAll(code string) (*protocol.KlineResp, error) {
+	return this.getKline("/index/year/all", query().code(code))
+}
+
+// ---- 板块/报表 ----
+
+func (this *Client) GetBlockData(file string) (resp []*protocol.Block, err error) {
+	err = this.get("/block/data", url.Values(query().str("file", file)), &resp)
+	return
+}
+
+func (this *Client) GetBlockDataWithIndex(file string) (resp []*protocol.Block, err error) {
+	err = this.get("/block/data/index", url.Values(query().str("file", file)), &resp)
+	return
+}
+
+func (this *Client) GetBlockFileRaw(file string) (resp []byte, err error) {
+	err = this.get("/block/file", url.Values(query().str("file", file)), &resp)
+	return
+}
+
+func (this *Client) GetReportFile(file string) (resp []byte, err error) {
+	err = this.get("/report/file", url.Values(query().str("file", file)), &resp)
+	return
+}
+
+func (this *Client) GetZHBFiles() (resp map[string][]byte, err error) {
+	err = this.get("/zhb/files", nil, &resp)
+	return
+}
+
+func (this *Client) GetTdxZs() (resp []*protocol.TdxZs, err error) {
+	err = this.get("/tdx/zs", nil, &resp)
+	return
+}
+
+func (this *Client) GetTdxBk() (resp []*protocol.TdxBk, err error) {
+	err = this.get("/tdx/bk", nil, &resp)
+	return
+}
+
+func (this *Client) GetTdxStat() (resp []*protocol.TdxStat, err error) {
+	err = this.get("/tdx/stat", nil, &resp)
+	return
+}
+
+func (this *Client) GetTdxStat2() (resp []*protocol.TdxStat2, err error) {
+	err = this.get("/tdx/stat2", nil, &resp)
+	return
+}
+
+func (this *Client) GetXgsg() (resp []*protocol.TdxXgsg, err error) {
+	err = this.get("/tdx/xgsg", nil, &resp)
+	return
+}
+
+func (this *Client) GetTdxHy() (resp []*protocol.TdxHy, err error) {
+	err = this.get("/tdx/hy", nil, &resp)
+	return
+}
+
+func (this *Client) GetSpBlock() (resp []*protocol.SpBlock, err error) {
+	err = this.get("/spblock", nil, &resp)
+	return
+}
+
+// ---- 扩展行情 ----
+
+func (this *Client) ExMarkets() (resp []protocol.ExMarket, err error) {
+	err = this.get("/ex/markets", nil, &resp)
+	return
+}
+
+func (this *Client) ExCount() (resp int, err error) {
+	err = this.get("/ex/count", nil, &resp)
+	return
+}
+
+func (this *Client) ExInstruments(start uint32, count uint16) (resp []protocol.ExInstrument, err error) {
+	q := query().uint("start", uint64(start)).uint("count", uint64(count))
+	err = this.get("/ex/instruments", url.Values(q), &resp)
+	return
+}
+
+func (this *Client) ExQuote(market uint8, code string) (resp *protocol.ExQuote, err error) {
+	err = this.get("/ex/quote", url.Values(query().market(market).code(code)), &resp)
+	return
+}
+
+func (this *Client) ExQuoteList(market, category uint8, start, count uint16) (resp []protocol.ExQuoteListItem, err error) {
+	q := query().market(market).uint("category", uint64(category)).page(start, count)
+	err = this.get("/ex/quote_list", url.Values(q), &resp)
+	return
+}
+
+func (this *Client) ExBars(category, market uint8, code string, start, count uint16) (resp []protocol.ExKline, err error) {
+	q := query().uint("category", uint64(category)).market(market).code(code).page(start, count)
+	err = this.get("/ex/bars", url.Values(q), &resp)
+	return
+}
+
+func (this *Client) ExMinute(market uint8, code string) (resp []protocol.ExMinuteTick, err error) {
+	err = this.get("/ex/minute", url.Values(query().market(market).code(code)), &resp)
+	return
+}
+
+func (this *Client) ExHistMinute(market uint8, code string, date uint32) (resp []protocol.ExMinuteTick, err error) {
+	q := query().market(market).code(code).uint("date", uint64(date))
+	err = this.get("/ex/minute/hist", url.Values(q), &resp)
+	return
+}
+
+func (this *Client) ExTrade(market uint8, code string, start, count uint16) (resp []protocol.ExTradeTick, err error) {
+	q := query().market(market).code(code).page(start, count)
+	err = this.get("/ex/trade", url.Values(q), &resp)
+	return
+}
+
+func (this *Client) ExHistTrade(market uint8, code string, date uint32, start, count uint16) (resp []protocol.ExTradeTick, err error) {
+	q := query().market(market).code(code).uint("date", uint64(date)).page(start, count)
+	err = this.get("/ex/trade/hist", url.Values(q), &resp)
+	return
+}
+
+func (this *Client) ExBarsRange(market uint8, code string, date, date2 uint32) (resp []protocol.ExRangeKline, err error) {
+	q := query().market(market).code(code).uint("date", uint64(date)).uint("date2", uint64(date2))
+	err = this.get("/ex/bars/range", url.Values(q), &resp)
+	return
+}
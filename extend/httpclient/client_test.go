@@ -0,0 +1,78 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /kline/day", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code") != "sz000001" || q.Get("start") != "0" || q.Get("count") != "2" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"code":0,"msg":"ok","data":{"Count":2,"List":[
+			{"Open":10000,"Close":10500,"Volume":100,"Time":"2024-01-02T15:00:00+08:00"},
+			{"Open":10500,"Close":10200,"Volume":200,"Time":"2024-01-03T15:00:00+08:00"}]}}`))
+	})
+	mux.HandleFunc("GET /quote", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("codes") != "sz000001,sh600000" {
+			t.Errorf("codes = %s", r.URL.Query().Get("codes"))
+		}
+		if r.Header.Get("X-Token") != "abc" {
+			t.Errorf("header = %v", r.Header)
+		}
+		w.Write([]byte(`{"code":0,"msg":"ok","data":[{"Exchange":0,"Code":"000001"},{"Exchange":1,"Code":"600000"}]}`))
+	})
+	mux.HandleFunc("GET /count", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":1,"msg":"参数 exchange 取值错误: nq (可选: sh, sz, bj)","data":{"param":"exchange","reason":"enum"}}`))
+	})
+	mux.HandleFunc("GET /gbbq", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":1,"msg":"连接超时","data":null}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClient(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	c := New(ts.URL+"/", WithHeader("X-Token", "abc"))
+
+	k, err := c.GetKlineDay("sz000001", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Count != 2 || len(k.List) != 2 || k.List[0].Close != 10500 || k.List[1].Time.Day() != 3 {
+		t.Errorf("kline = %+v", k.List)
+	}
+
+	q, err := c.GetQuote("sz000001", "sh600000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q) != 2 || q[1].Exchange != protocol.ExchangeSH || q[1].Code != "600000" {
+		t.Errorf("quote = %v", q)
+	}
+
+	_, err = c.GetCount(protocol.ExchangeNQ)
+	e := (*Error)(nil)
+	if !errors.As(err, &e) || e.Status != http.StatusBadRequest || e.Param != "exchange" || e.Reason != "enum" {
+		t.Errorf("err = %#v", err)
+	}
+
+	g, err := c.GetGbbq("sz000001")
+	if !errors.As(err, &e) || e.Status != http.StatusOK || e.Msg != "连接超时" || g != nil {
+		t.Errorf("err = %#v, resp = %v", err, g)
+	}
+
+	//未注册的路由,ServeMux 返回的不是JSON
+	if _, err = c.GetTdxHy(); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,343 @@
+package httpserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Arrow IPC 流格式(https://arrow.apache.org/docs/format/Columnar.html#ipc-streaming-format)
+//
+// 只实现输出表格需要的部分: 一个 Schema 消息、一个 RecordBatch 消息和结束标记,
+// 列类型为 Int64/Float64/Utf8/Bool/Timestamp(毫秒),不压缩,不用字典。
+// 元数据是 FlatBuffers,这里手写了一个只支持表、字符串、表向量、结构体向量的编码器,避免引入 arrow 依赖。
+
+const (
+	arrowMetadataV5 = 4
+
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeUtf8          = 5
+	arrowTypeBool          = 6
+	arrowTypeTimestamp     = 10
+
+	arrowPrecisionDouble = 2
+	arrowUnitMillisecond = 1
+)
+
+// ---- FlatBuffers ----
+
+// fbObject flatbuffers 中的对象,写入后返回起始位置
+type fbObject interface {
+	fbWrite(b *fbBuilder) int
+}
+
+// fbBuilder 从前往后写,被引用的对象总在引用之后(偏移为无符号数)
+type fbBuilder struct {
+	buf []byte
+}
+
+func (this *fbBuilder) pad(n int) {
+	for len(this.buf)%n != 0 {
+		this.buf = append(this.buf, 0)
+	}
+}
+
+func (this *fbBuilder) offsetAt(pos, target int) {
+	binary.LittleEndian.PutUint32(this.buf[pos:], uint32(target-pos))
+}
+
+// fbFinish 写入根对象,返回8字节对齐的数据
+func fbFinish(root fbObject) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 512)}
+	b.offsetAt(0, root.fbWrite(b))
+	b.pad(8)
+	return b.buf
+}
+
+// fbField 表的字段,child 不为nil时为引用,否则为 size 字节的标量
+type fbField struct {
+	id    int
+	size  int
+	value uint64
+	child fbObject
+}
+
+func (this fbField) width() int {
+	if this.child != nil {
+		return 4
+	}
+	return this.size
+}
+
+// fbTable 表
+type fbTable []fbField
+
+func (this fbTable) fbWrite(b *fbBuilder) int {
+	//宽的字段在前,表从8字节对齐的位置开始,字段都能自然对齐
+	fields := append(fbTable(nil), this...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].width() > fields[j].width() })
+	n, size := 0, 4
+	offsets := make([]int, len(fields))
+	for i, f := range fields {
+		n = max(n, f.id+1)
+		w := f.width()
+		size = (size + w - 1) / w * w
+		offsets[i] = size
+		size += w
+	}
+
+	//vtable: vtable大小,表大小,每个字段在表中的偏移
+	b.pad(2)
+	vt := len(b.buf)
+	slots := make([]uint16, n)
+	for i, f := range fields {
+		slots[f.id] = uint16(offsets[i])
+	}
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*n))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+	for _, v := range slots {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, v)
+	}
+
+	b.pad(8)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vt)))
+	for i, f := range fields {
+		p := b.buf[pos+offsets[i]:]
+		switch {
+		case f.child != nil:
+		case f.size == 1:
+			p[0] = byte(f.value)
+		case f.size == 2:
+			binary.LittleEndian.PutUint16(p, uint16(f.value))
+		case f.size == 4:
+			binary.LittleEndian.PutUint32(p, uint32(f.value))
+		case f.size == 8:
+			binary.LittleEndian.PutUint64(p, f.value)
+		}
+	}
+	for i, f := range fields {
+		if f.child != nil {
+			b.offsetAt(pos+offsets[i], f.child.fbWrite(b))
+		}
+	}
+	return pos
+}
+
+// fbString 字符串
+type fbString string
+
+func (this fbString) fbWrite(b *fbBuilder) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(this)))
+	b.buf = append(b.buf, this...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+// fbVector 表的向量
+type fbVector []fbObject
+
+func (this fbVector) fbWrite(b *fbBuilder) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(this)))
+	b.buf = append(b.buf, make([]byte, 4*len(this))...)
+	for i, v := range this {
+		b.offsetAt(pos+4+4*i, v.fbWrite(b))
+	}
+	return pos
+}
+
+// fbStructs 结构体向量,元素按8字节对齐
+type fbStructs struct {
+	n    int
+	data []byte
+}
+
+func (this fbStructs) fbWrite(b *fbBuilder) int {
+	for (len(b.buf)+4)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(this.n))
+	b.buf = append(b.buf, this.data...)
+	return pos
+}
+
+// ---- Arrow ----
+
+// arrowBody RecordBatch 的消息体
+type arrowBody struct {
+	data    []byte
+	nodes   []byte //FieldNode{length, null_count}
+	buffers []byte //Buffer{offset, length}
+	count   int    //buffer 数量
+}
+
+func (this *arrowBody) buffer(bs []byte) {
+	this.buffers = binary.LittleEndian.AppendUint64(this.buffers, uint64(len(this.data)))
+	this.buffers = binary.LittleEndian.AppendUint64(this.buffers, uint64(len(bs)))
+	this.count++
+	this.data = append(this.data, bs...)
+	for len(this.data)%8 != 0 {
+		this.data = append(this.data, 0)
+	}
+}
+
+func (this *arrowBody) node(length, nulls int) {
+	this.nodes = binary.LittleEndian.AppendUint64(this.nodes, uint64(length))
+	this.nodes = binary.LittleEndian.AppendUint64(this.nodes, uint64(nulls))
+}
+
+// arrowColumn 一列的值和 Schema 中的字段
+func arrowColumn(t *table, c *column, body *arrowBody) fbTable {
+	n := t.Len()
+	valid := make([]byte, (n+7)/8)
+	nulls := 0
+	values := make([]any, n)
+	for i := 0; i < n; i++ {
+		v, ok := c.value(t.Row(i))
+		if !ok {
+			nulls++
+			continue
+		}
+		values[i] = v
+		valid[i/8] |= 1 << (i % 8)
+	}
+	body.node(n, nulls)
+	if nulls > 0 {
+		body.buffer(valid)
+	} else {
+		body.buffer(nil)
+	}
+
+	var typ uint64
+	var typeTable fbTable
+	switch c.Kind {
+	case columnInt:
+		typ, typeTable = arrowTypeInt, fbTable{{id: 0, size: 4, value: 64}, {id: 1, size: 1, value: 1}}
+		bs := make([]byte, 8*n)
+		for i, v := range values {
+			if v != nil {
+				binary.LittleEndian.PutUint64(bs[8*i:], uint64(v.(int64)))
+			}
+		}
+		body.buffer(bs)
+	case columnFloat:
+		typ, typeTable = arrowTypeFloatingPoint, fbTable{{id: 0, size: 2, value: arrowPrecisionDouble}}
+		bs := make([]byte, 8*n)
+		for i, v := range values {
+			if v != nil {
+				binary.LittleEndian.PutUint64(bs[8*i:], math.Float64bits(v.(float64)))
+			}
+		}
+		body.buffer(bs)
+	case columnBool:
+		typ, typeTable = arrowTypeBool, fbTable{}
+		bs := make([]byte, (n+7)/8)
+		for i, v := range values {
+			if v != nil && v.(bool) {
+				bs[i/8] |= 1 << (i % 8)
+			}
+		}
+		body.buffer(bs)
+	case columnTime:
+		typ, typeTable = arrowTypeTimestamp, fbTable{{id: 0, size: 2, value: arrowUnitMillisecond}}
+		bs := make([]byte, 8*n)
+		zone := ""
+		for i, v := range values {
+			if v != nil {
+				t := v.(time.Time)
+				binary.LittleEndian.PutUint64(bs[8*i:], uint64(t.UnixMilli()))
+				if zone == "" {
+					zone = arrowTimezone(t)
+				}
+			}
+		}
+		if zone != "" {
+			typeTable = append(typeTable, fbField{id: 1, child: fbString(zone)})
+		}
+		body.buffer(bs)
+	default:
+		typ, typeTable = arrowTypeUtf8, fbTable{}
+		offsets := make([]byte, 4, 4*(n+1))
+		var data []byte
+		for _, v := range values {
+			if v != nil {
+				data = append(data, v.(string)...)
+			}
+			offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+		}
+		body.buffer(offsets)
+		body.buffer(data)
+	}
+
+	return fbTable{
+		{id: 0, child: fbString(c.Name)},
+		{id: 1, size: 1, value: 1}, //nullable
+		{id: 2, size: 1, value: typ},
+		{id: 3, child: typeTable},
+		{id: 5, child: fbVector{}}, //children,部分实现要求不能为空
+	}
+}
+
+// arrowTimezone 时区写成 +08:00 的形式,不依赖时区数据库
+func arrowTimezone(t time.Time) string {
+	_, off := t.Zone()
+	sign := '+'
+	if off < 0 {
+		sign, off = '-', -off
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, off/3600, off%3600/60)
+}
+
+// writeArrowMessage 写入一条封装的消息: 0xFFFFFFFF, 元数据长度, 元数据, 消息体
+func writeArrowMessage(w io.Writer, headerType uint64, header fbTable, body []byte) error {
+	meta := fbFinish(fbTable{
+		{id: 0, size: 2, value: arrowMetadataV5},
+		{id: 1, size: 1, value: headerType},
+		{id: 2, child: header},
+		{id: 3, size: 8, value: uint64(len(body))},
+	})
+	prefix := make([]byte, 8)
+	binary.LittleEndian.PutUint32(prefix, 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(prefix[4:], uint32(len(meta)))
+	for _, bs := range [][]byte{prefix, meta, body} {
+		if _, err := w.Write(bs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArrow 以 Arrow IPC 流格式写入表格
+func writeArrow(w io.Writer, t *table) error {
+	body := &arrowBody{}
+	fields := make(fbVector, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		fields = append(fields, arrowColumn(t, c, body))
+	}
+	if err := writeArrowMessage(w, arrowHeaderSchema, fbTable{{id: 1, child: fields}}, nil); err != nil {
+		return err
+	}
+	batch := fbTable{
+		{id: 0, size: 8, value: uint64(t.Len())},
+		{id: 1, child: fbStructs{n: len(t.Columns), data: body.nodes}},
+		{id: 2, child: fbStructs{n: body.count, data: body.buffers}},
+	}
+	if err := writeArrowMessage(w, arrowHeaderRecordBatch, batch, body.data); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+	return err
+}
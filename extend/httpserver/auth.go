@@ -0,0 +1,436 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 鉴权、限流和审计
+//
+// 配置了 API key(WithAPIKeys/WithAPIKeyFile)或令牌密钥(WithTokenSecret)后,
+// 数据接口和推送接口都需要带上凭证,按以下顺序查找:
+//
+//	Authorization: Bearer <key或令牌>
+//	X-API-Key: <key或令牌>
+//	?api_key=<key或令牌>          浏览器的 WebSocket/EventSource 不能设置请求头时使用
+//
+// 凭证先按静态 key 查找,找不到时按 HMAC 令牌(见 NewToken)校验。
+// 每个 key(令牌按 sub)单独限流(令牌桶)和限制每日请求数,在缓存和连接池之前检查,
+// 超出时返回 429。健康检查和 /openapi.json 不需要鉴权。
+// 设置 WithAuditLog 后每个请求写一行 JSON 审计日志,未启用鉴权时 key 为空。
+
+// APIKey 一个 API key 及其限制
+type APIKey struct {
+	Key   string  `json:"key"`
+	Name  string  `json:"name"`            //名称,用于审计和统计,为空时为 key 的前4位
+	Rate  float64 `json:"rate,omitempty"`  //每秒请求数,0 使用 WithRateLimit 的默认值,小于0不限
+	Burst int     `json:"burst,omitempty"` //突发请求数,0 时为 rate 向上取整
+	Quota int     `json:"quota,omitempty"` //每日请求数,0 使用 WithDailyQuota 的默认值,小于0不限
+	Admin bool    `json:"admin,omitempty"` //是否可以访问 /admin/*
+}
+
+// TokenClaims HMAC 令牌的内容,限制字段的含义同 APIKey
+type TokenClaims struct {
+	Name   string  `json:"sub"`
+	Expire int64   `json:"exp,omitempty"` //过期时间(unix秒),0 不过期
+	Rate   float64 `json:"rate,omitempty"`
+	Burst  int     `json:"burst,omitempty"`
+	Quota  int     `json:"quota,omitempty"`
+	Admin  bool    `json:"admin,omitempty"`
+}
+
+// NewToken 用 secret 签发令牌,格式为 base64url(claims).base64url(HMAC-SHA256(claims)),
+// 服务端用 WithTokenSecret 设置相同的 secret 校验
+func NewToken(secret string, c TokenClaims) string {
+	payload, _ := json.Marshal(c)
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return p + "." + base64.RawURLEncoding.EncodeToString(tokenSign(secret, p))
+}
+
+func tokenSign(secret, payload string) []byte {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
+
+// parseToken 校验令牌,返回其中的内容
+func parseToken(secret, token string, now time.Time) (*TokenClaims, error) {
+	p, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errors.New("无效的凭证")
+	}
+	bs, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(bs, tokenSign(secret, p)) {
+		return nil, errors.New("无效的凭证")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return nil, errors.New("无效的凭证")
+	}
+	c := new(TokenClaims)
+	if err := json.Unmarshal(payload, c); err != nil || c.Name == "" {
+		return nil, errors.New("无效的凭证")
+	}
+	if c.Expire > 0 && now.Unix() >= c.Expire {
+		return nil, errors.New("凭证已过期")
+	}
+	return c, nil
+}
+
+// LoadAPIKeys 读取 key 文件,文件为 APIKey 的 JSON 数组
+func LoadAPIKeys(filename string) ([]APIKey, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(bs, &keys); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", filename, err)
+	}
+	return keys, nil
+}
+
+// KeyUsage 一个 key 的使用情况,today/limited/routes 为当天的统计
+type KeyUsage struct {
+	Name    string         `json:"name"`
+	Date    string         `json:"date"`    //统计日期 YYYYMMDD
+	Today   int            `json:"today"`   //当天请求数
+	Quota   int            `json:"quota"`   //每日请求数限制,小于等于0不限
+	Rate    float64        `json:"rate"`    //每秒请求数限制,小于等于0不限
+	Limited int            `json:"limited"` //当天被限流或超额拒绝的次数
+	Total   int64          `json:"total"`   //启动以来的请求数
+	Routes  map[string]int `json:"routes"`  //当天各路由的请求数
+	Last    time.Time      `json:"last"`    //最后一次请求的时间
+}
+
+// identity 请求的身份
+type identity struct {
+	Name  string
+	Rate  float64
+	Burst int
+	Quota int
+	Admin bool
+}
+
+type identityKey struct{}
+
+// keyUsage 一个 key 的限流状态和统计
+type keyUsage struct {
+	KeyUsage
+	tokens float64   //令牌桶剩余
+	last   time.Time //令牌桶上次更新时间
+}
+
+// allow 令牌桶,rate 小于等于0不限
+func (this *keyUsage) allow(now time.Time, rate float64, burst int) bool {
+	if rate <= 0 {
+		return true
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	if this.last.IsZero() {
+		this.tokens = float64(burst)
+	} else {
+		this.tokens = math.Min(float64(burst), this.tokens+now.Sub(this.last).Seconds()*rate)
+	}
+	this.last = now
+	if this.tokens < 1 {
+		return false
+	}
+	this.tokens--
+	return true
+}
+
+// authenticator 鉴权、限流和审计
+type authenticator struct {
+	keys   map[string]*APIKey
+	secret string
+	rate   float64
+	burst  int
+	quota  int
+
+	auditMu sync.Mutex
+	audit   io.Writer
+
+	mu    sync.Mutex
+	usage map[string]*keyUsage
+}
+
+func newAuthenticator(cfg *serverConfig) (*authenticator, error) {
+	keys := cfg.apiKeys
+	if cfg.apiKeyFile != "" {
+		ls, err := LoadAPIKeys(cfg.apiKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(ls, keys...)
+	}
+	if len(keys) == 0 && cfg.tokenSecret == "" && cfg.audit == nil {
+		return nil, nil
+	}
+	a := &authenticator{
+		keys:   map[string]*APIKey{},
+		secret: cfg.tokenSecret,
+		rate:   cfg.rate,
+		burst:  cfg.burst,
+		quota:  cfg.quota,
+		audit:  cfg.audit,
+		usage:  map[string]*keyUsage{},
+	}
+	for i := range keys {
+		k := keys[i]
+		if k.Key == "" {
+			return nil, errors.New("API key 不能为空")
+		}
+		if k.Name == "" {
+			k.Name = k.Key[:min(4, len(k.Key))] + "****"
+		}
+		a.keys[k.Key] = &k
+	}
+	return a, nil
+}
+
+// enabled 是否需要鉴权,只设置了审计日志时不需要
+func (this *authenticator) enabled() bool {
+	return len(this.keys) > 0 || this.secret != ""
+}
+
+// credential 请求中的凭证
+func credential(r *http.Request) string {
+	if v := r.Header.Get("Authorization"); len(v) > 7 && strings.EqualFold(v[:7], "Bearer ") {
+		return strings.TrimSpace(v[7:])
+	}
+	if v := r.Header.Get("X-API-Key"); v != "" {
+		return v
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// identify 校验凭证
+func (this *authenticator) identify(r *http.Request, now time.Time) (*identity, error) {
+	cred := credential(r)
+	if cred == "" {
+		return nil, errors.New("缺少 API key")
+	}
+	if k, ok := this.keys[cred]; ok {
+		return this.limits(&identity{Name: k.Name, Rate: k.Rate, Burst: k.Burst, Quota: k.Quota, Admin: k.Admin}), nil
+	}
+	if this.secret == "" {
+		return nil, errors.New("无效的凭证")
+	}
+	c, err := parseToken(this.secret, cred, now)
+	if err != nil {
+		return nil, err
+	}
+	return this.limits(&identity{Name: c.Name, Rate: c.Rate, Burst: c.Burst, Quota: c.Quota, Admin: c.Admin}), nil
+}
+
+// limits 未设置的限制使用默认值
+func (this *authenticator) limits(id *identity) *identity {
+	if id.Rate == 0 {
+		id.Rate, id.Burst = this.rate, this.burst
+	}
+	if id.Quota == 0 {
+		id.Quota = this.quota
+	}
+	return id
+}
+
+// limitError 超出限流或每日请求数,retry 为建议的重试秒数
+type limitError struct {
+	msg   string
+	retry int
+}
+
+func (this *limitError) Error() string { return this.msg }
+
+// take 记录一次请求,超出限流或每日请求数时返回错误
+func (this *authenticator) take(id *identity, route string, now time.Time) *limitError {
+	return this.use(id, route, 1, true, now)
+}
+
+// charge 额外记录 n 次请求,只检查每日请求数,批量接口按代码数计数
+func (this *authenticator) charge(id *identity, route string, n int, now time.Time) *limitError {
+	return this.use(id, route, n, false, now)
+}
+
+func (this *authenticator) use(id *identity, route string, n int, limit bool, now time.Time) *limitError {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	u := this.usage[id.Name]
+	if u == nil {
+		u = &keyUsage{KeyUsage: KeyUsage{Name: id.Name}}
+		this.usage[id.Name] = u
+	}
+	if date := now.Format("20060102"); u.Date != date {
+		u.Date, u.Today, u.Limited, u.Routes = date, 0, 0, map[string]int{}
+	}
+	u.Rate, u.Quota = id.Rate, id.Quota
+	if id.Quota > 0 && u.Today+n > id.Quota {
+		u.Limited++
+		next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+		return &limitError{fmt.Sprintf("超出每日请求数限制: %d", id.Quota), int(next.Sub(now).Seconds()) + 1}
+	}
+	if limit && !u.allow(now, id.Rate, id.Burst) {
+		u.Limited++
+		return &limitError{fmt.Sprintf("请求过于频繁,限制每秒 %g 次", id.Rate), 1}
+	}
+	u.Today += n
+	u.Total += int64(n)
+	u.Routes[route] += n
+	u.Last = now
+	return nil
+}
+
+// usages 全部 key 的使用情况,按名称排序
+func (this *authenticator) usages() []KeyUsage {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	ls := make([]KeyUsage, 0, len(this.usage))
+	for _, u := range this.usage {
+		v := u.KeyUsage
+		v.Routes = make(map[string]int, len(u.Routes))
+		for k, n := range u.Routes {
+			v.Routes[k] = n
+		}
+		ls = append(ls, v)
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+	return ls
+}
+
+// auditEntry 一行审计日志
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Key    string    `json:"key"`
+	Method string    `json:"method"`
+	Route  string    `json:"route"`
+	Code   string    `json:"code,omitempty"` //请求的证券代码(code/codes参数)
+	Status int       `json:"status"`
+	Ms     int64     `json:"ms"`
+	IP     string    `json:"ip"`
+}
+
+func (this *authenticator) log(e *auditEntry) {
+	if this.audit == nil {
+		return
+	}
+	bs, _ := json.Marshal(e)
+	bs = append(bs, '\n')
+	this.auditMu.Lock()
+	defer this.auditMu.Unlock()
+	this.audit.Write(bs)
+}
+
+// statusWriter 记录响应状态码,推送接口需要 Flush/Hijack
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (this *statusWriter) WriteHeader(code int) {
+	if this.status == 0 {
+		this.status = code
+	}
+	this.ResponseWriter.WriteHeader(code)
+}
+
+func (this *statusWriter) Write(p []byte) (int, error) {
+	if this.status == 0 {
+		this.status = http.StatusOK
+	}
+	return this.ResponseWriter.Write(p)
+}
+
+func (this *statusWriter) Flush() {
+	if f, ok := this.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (this *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := this.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("不支持 Hijack")
+	}
+	if this.status == 0 {
+		this.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+func (this *statusWriter) Unwrap() http.ResponseWriter {
+	return this.ResponseWriter
+}
+
+// authed 给路由加上鉴权、限流和审计,未配置时原样返回
+func (s *Server) authed(h http.HandlerFunc) http.HandlerFunc {
+	a := s.auth
+	if a == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		e := &auditEntry{Time: now, Method: r.Method, Route: r.URL.Path, IP: remoteIP(r)}
+		if e.Code = r.URL.Query().Get("code"); e.Code == "" {
+			e.Code = r.URL.Query().Get("codes")
+		}
+		defer func() {
+			e.Status, e.Ms = sw.status, time.Since(now).Milliseconds()
+			a.log(e)
+		}()
+
+		if a.enabled() {
+			id, err := a.identify(r, now)
+			if err != nil {
+				respondErr(sw, http.StatusUnauthorized, err.Error())
+				return
+			}
+			e.Key = id.Name
+			if err := a.take(id, r.URL.Path, now); err != nil {
+				sw.Header().Set("Retry-After", strconv.Itoa(err.retry))
+				respondErr(sw, http.StatusTooManyRequests, err.Error())
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+		}
+		h(sw, r)
+	}
+}
+
+// remoteIP 请求方IP,不信任 X-Forwarded-For
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handleAdminUsage 各 key 的使用情况,需要 admin 权限
+func (s *Server) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	id, _ := r.Context().Value(identityKey{}).(*identity)
+	if id == nil || !id.Admin {
+		respondErr(w, http.StatusForbidden, "需要管理员权限")
+		return
+	}
+	respondOK(w, s.auth.usages())
+}
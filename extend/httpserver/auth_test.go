@@ -0,0 +1,159 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newAuthServer(t *testing.T, cfg *serverConfig) (*Server, *http.ServeMux) {
+	a, err := newAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{pool: &fakePool{}, auth: a}
+	return s, newTestMux(s)
+}
+
+func doGet(mux *http.ServeMux, url string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuth(t *testing.T) {
+	_, mux := newAuthServer(t, &serverConfig{
+		apiKeys:     []APIKey{{Key: "k1", Name: "team-a"}},
+		tokenSecret: "secret",
+	})
+
+	for _, c := range []struct {
+		name   string
+		url    string
+		header []string
+		status int
+	}{
+		{"无凭证", "/count?exchange=sh", nil, http.StatusUnauthorized},
+		{"错误的key", "/count?exchange=sh", []string{"X-API-Key", "k2"}, http.StatusUnauthorized},
+		{"X-API-Key", "/count?exchange=sh", []string{"X-API-Key", "k1"}, 0},
+		{"Bearer", "/count?exchange=sh", []string{"Authorization", "Bearer k1"}, 0},
+		{"api_key", "/count?exchange=sh&api_key=k1", nil, 0},
+		{"令牌", "/count?exchange=sh", []string{"Authorization", "Bearer " + NewToken("secret", TokenClaims{Name: "team-b"})}, 0},
+		{"错误的签名", "/count?exchange=sh", []string{"Authorization", "Bearer " + NewToken("other", TokenClaims{Name: "team-b"})}, http.StatusUnauthorized},
+		{"过期的令牌", "/count?exchange=sh", []string{"Authorization", "Bearer " + NewToken("secret", TokenClaims{Name: "team-b", Expire: time.Now().Unix() - 1})}, http.StatusUnauthorized},
+		{"健康检查", "/", nil, http.StatusOK},
+		{"文档", "/openapi.json", nil, http.StatusOK},
+	} {
+		w := doGet(mux, c.url, c.header...)
+		if c.status == 0 && w.Code == http.StatusUnauthorized || c.status != 0 && w.Code != c.status {
+			t.Errorf("%s: %d %s", c.name, w.Code, w.Body.String())
+		}
+	}
+
+	//鉴权在参数校验之前
+	if w := doGet(mux, "/count?exchange=xx"); w.Code != http.StatusUnauthorized {
+		t.Errorf("未鉴权的错误参数: %d", w.Code)
+	}
+	if w := doGet(mux, "/count?exchange=xx", "X-API-Key", "k1"); w.Code != http.StatusBadRequest {
+		t.Errorf("错误参数: %d", w.Code)
+	}
+}
+
+func TestAuthLimit(t *testing.T) {
+	s, mux := newAuthServer(t, &serverConfig{
+		apiKeys: []APIKey{
+			{Key: "quota", Name: "quota", Quota: 2},
+			{Key: "rate", Name: "rate", Rate: 1, Burst: 2},
+			{Key: "admin", Name: "admin", Admin: true, Quota: -1},
+			{Key: "user", Name: "user"},
+		},
+		quota: 100,
+	})
+
+	for i, want := range []int{0, 0, http.StatusTooManyRequests} {
+		w := doGet(mux, "/count?exchange=sh", "X-API-Key", "quota")
+		if want == 0 && w.Code == http.StatusTooManyRequests || want != 0 && w.Code != want {
+			t.Fatalf("quota %d: %d", i, w.Code)
+		}
+		if want != 0 && w.Header().Get("Retry-After") == "" {
+			t.Error("缺少 Retry-After")
+		}
+	}
+	for i, want := range []int{0, 0, http.StatusTooManyRequests} {
+		w := doGet(mux, "/count?exchange=sh", "X-API-Key", "rate")
+		if want == 0 && w.Code == http.StatusTooManyRequests || want != 0 && w.Code != want {
+			t.Fatalf("rate %d: %d", i, w.Code)
+		}
+	}
+
+	if w := doGet(mux, "/admin/usage", "X-API-Key", "user"); w.Code != http.StatusForbidden {
+		t.Errorf("非管理员: %d", w.Code)
+	}
+	w := doGet(mux, "/admin/usage", "X-API-Key", "admin")
+	resp := struct {
+		Data []KeyUsage `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	usage := map[string]KeyUsage{}
+	for _, u := range resp.Data {
+		usage[u.Name] = u
+	}
+	if u := usage["quota"]; u.Today != 2 || u.Limited != 1 || u.Quota != 2 || u.Routes["/count"] != 2 {
+		t.Errorf("quota usage = %+v", u)
+	}
+	if u := usage["rate"]; u.Today != 2 || u.Limited != 1 || u.Quota != 100 {
+		t.Errorf("rate usage = %+v", u)
+	}
+
+	//新的一天重新计数
+	if err := s.auth.take(&identity{Name: "quota", Quota: 2}, "/count", time.Now().AddDate(0, 0, 1)); err != nil {
+		t.Errorf("next day: %v", err)
+	}
+	if u := s.auth.usages()[1]; u.Name != "quota" || u.Today != 1 || u.Limited != 0 || u.Total != 3 {
+		t.Errorf("next day = %+v", u)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	_, mux := newAuthServer(t, &serverConfig{apiKeys: []APIKey{{Key: "k1", Name: "team-a"}}, audit: buf})
+	doGet(mux, "/count?exchange=sh")
+	doGet(mux, "/kline/day?code=sz000001&start=0&count=10", "X-API-Key", "k1")
+
+	var ls []auditEntry
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		ls = append(ls, e)
+	}
+	if len(ls) != 2 {
+		t.Fatalf("audit = %d", len(ls))
+	}
+	if ls[0].Key != "" || ls[0].Status != http.StatusUnauthorized || ls[0].Route != "/count" {
+		t.Errorf("audit[0] = %+v", ls[0])
+	}
+	if ls[1].Key != "team-a" || ls[1].Code != "sz000001" || ls[1].Route != "/kline/day" || ls[1].Status == 0 {
+		t.Errorf("audit[1] = %+v", ls[1])
+	}
+}
+
+func TestCacheKeyAPIKey(t *testing.T) {
+	a := cacheKey(httptest.NewRequest("GET", "/quote?codes=sz000001&api_key=k1", nil))
+	b := cacheKey(httptest.NewRequest("GET", "/quote?codes=sz000001", nil))
+	if a != b {
+		t.Errorf("%s != %s", a, b)
+	}
+}
@@ -0,0 +1,233 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 批量接口
+//
+// POST /batch/kline、/batch/minute、/batch/finance、/batch/gbbq,请求体为 JSON,包含代码列表和参数,
+// 每个代码单独从连接池获取数据,最多同时 WithBatchConcurrency 个。
+// 默认以 NDJSON 流式返回,每完成一个代码输出一行(顺序为完成顺序):
+//
+//	{"code":"sz000001","data":{...}}
+//	{"code":"sh600000","error":"..."}
+//
+// 单个代码出错不影响其他代码。?format=json 时等全部完成后按请求顺序返回统一响应结构,data 为上面的数组。
+// 启用鉴权时每个代码计一次请求。
+
+const (
+	defaultBatchConcurrency = 4
+	defaultBatchMaxCodes    = 1000
+	batchMaxBody            = 1 << 20
+)
+
+// batchItem 一个代码的结果
+type batchItem struct {
+	Code  string `json:"code"`
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// batchFunc 获取一个代码的数据,code 已带交易所前缀
+type batchFunc func(c *tdx.Client, code string) (any, error)
+
+// batchKlineReq POST /batch/kline
+type batchKlineReq struct {
+	Codes []string `json:"codes"`
+	Type  *uint8   `json:"type"`  //K线类型,同 /kline 的 type,默认9(日线)
+	Start uint16   `json:"start"` //起始位置,0为最新
+	Count uint16   `json:"count"` //获取数量,all 为 false 时必填
+	All   bool     `json:"all"`   //获取全部K线
+	Index bool     `json:"index"` //指数K线
+}
+
+// batchMinuteReq POST /batch/minute
+type batchMinuteReq struct {
+	Codes []string `json:"codes"`
+	Date  string   `json:"date"` //YYYYMMDD,为空时为当日分时
+}
+
+// batchCodesReq POST /batch/finance、/batch/gbbq
+type batchCodesReq struct {
+	Codes []string `json:"codes"`
+}
+
+// decodeBatch 解析请求体,出错时已响应
+func decodeBatch(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, batchMaxBody)).Decode(v); err != nil {
+		respondErr(w, http.StatusBadRequest, "请求体格式错误: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleBatchKline(w http.ResponseWriter, r *http.Request) {
+	req := &batchKlineReq{}
+	if !decodeBatch(w, r, req) {
+		return
+	}
+	typ := protocol.TypeKlineDay
+	if req.Type != nil {
+		typ = *req.Type
+	}
+	if typ > protocol.TypeKlineYear {
+		respondErr(w, http.StatusBadRequest, fmt.Sprintf("参数 type 取值错误: %d", typ))
+		return
+	}
+	if !req.All && req.Count == 0 {
+		respondErr(w, http.StatusBadRequest, "参数 count 不能为空")
+		return
+	}
+	s.batch(w, r, req.Codes, func(c *tdx.Client, code string) (any, error) {
+		switch {
+		case req.Index && req.All:
+			return c.GetIndexAll(typ, code)
+		case req.Index:
+			return c.GetIndex(typ, code, req.Start, req.Count)
+		case req.All:
+			return c.GetKlineAll(typ, code)
+		default:
+			return c.GetKline(typ, code, req.Start, req.Count)
+		}
+	})
+}
+
+func (s *Server) handleBatchMinute(w http.ResponseWriter, r *http.Request) {
+	req := &batchMinuteReq{}
+	if !decodeBatch(w, r, req) {
+		return
+	}
+	if req.Date != "" && !regDate.MatchString(req.Date) {
+		respondErr(w, http.StatusBadRequest, "参数 date 格式错误: "+req.Date)
+		return
+	}
+	s.batch(w, r, req.Codes, func(c *tdx.Client, code string) (any, error) {
+		if req.Date != "" {
+			return c.GetHistoryMinute(req.Date, code)
+		}
+		return c.GetMinute(code)
+	})
+}
+
+func (s *Server) handleBatchFinance(w http.ResponseWriter, r *http.Request) {
+	req := &batchCodesReq{}
+	if !decodeBatch(w, r, req) {
+		return
+	}
+	s.batch(w, r, req.Codes, func(c *tdx.Client, code string) (any, error) {
+		ex, body, err := protocol.DecodeCode(code)
+		if err != nil {
+			return nil, err
+		}
+		return c.GetFinanceInfo(ex, body)
+	})
+}
+
+func (s *Server) handleBatchGbbq(w http.ResponseWriter, r *http.Request) {
+	req := &batchCodesReq{}
+	if !decodeBatch(w, r, req) {
+		return
+	}
+	s.batch(w, r, req.Codes, func(c *tdx.Client, code string) (any, error) {
+		return c.GetGbbq(code)
+	})
+}
+
+// batch 并发获取每个代码的数据并输出,重复的代码只获取一次
+func (s *Server) batch(w http.ResponseWriter, r *http.Request, codes []string, fetch batchFunc) {
+	if len(codes) == 0 {
+		respondErr(w, http.StatusBadRequest, "参数 codes 不能为空")
+		return
+	}
+	maxCodes, concurrency := s.batchMaxCodes, s.batchConcurrency
+	if maxCodes <= 0 {
+		maxCodes = defaultBatchMaxCodes
+	}
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if len(codes) > maxCodes {
+		respondErr(w, http.StatusBadRequest, fmt.Sprintf("参数 codes 数量超过限制: %d > %d", len(codes), maxCodes))
+		return
+	}
+
+	//规范化并去重,无效的代码直接作为错误输出
+	items := make([]*batchItem, 0, len(codes))
+	seen := map[string]bool{}
+	for _, code := range codes {
+		c, err := normalizeCode(code)
+		switch {
+		case err != nil:
+			items = append(items, &batchItem{Code: code, Error: err.Error()})
+		case !seen[c]:
+			seen[c] = true
+			items = append(items, &batchItem{Code: c})
+		}
+	}
+
+	//每个代码计一次请求,authed 已经计了一次
+	if id, _ := r.Context().Value(identityKey{}).(*identity); id != nil && len(seen) > 1 {
+		if err := s.auth.charge(id, r.URL.Path, len(seen)-1, time.Now()); err != nil {
+			w.Header().Set("Retry-After", fmt.Sprint(err.retry))
+			respondErr(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+	}
+
+	done := make(chan *batchItem)
+	go func() {
+		wg := sync.WaitGroup{}
+		limit := make(chan struct{}, concurrency)
+		for _, item := range items {
+			if item.Error != "" {
+				done <- item
+				continue
+			}
+			select {
+			case <-r.Context().Done():
+				//客户端已断开,剩下的不再请求
+				item.Error = r.Context().Err().Error()
+				done <- item
+				continue
+			case limit <- struct{}{}:
+			}
+			wg.Add(1)
+			go func() {
+				defer func() { <-limit; wg.Done() }()
+				err := tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) (err error) {
+					item.Data, err = fetch(c, item.Code)
+					return
+				})
+				if err != nil {
+					item.Data, item.Error = nil, err.Error()
+				}
+				done <- item
+			}()
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	if r.URL.Query().Get("format") == formatJSON {
+		for range done {
+		}
+		respondOK(w, items)
+		return
+	}
+	w.Header().Set("Content-Type", formatTypes[formatNDJSON])
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for item := range done {
+		if enc.Encode(item) == nil && flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
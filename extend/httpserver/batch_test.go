@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx"
+)
+
+// callPool 直接执行,fetch 不使用连接
+type callPool struct{ fakePool }
+
+func (callPool) Do(fn func(c *tdx.Client) error) error { return fn(nil) }
+
+func readBatch(t *testing.T, w *httptest.ResponseRecorder) map[string]batchItem {
+	if w.Header().Get("Content-Type") != formatTypes[formatNDJSON] {
+		t.Fatalf("Content-Type = %s, %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+	m := map[string]batchItem{}
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var item batchItem
+		if err := json.Unmarshal(sc.Bytes(), &item); err != nil {
+			t.Fatal(err)
+		}
+		m[item.Code] = item
+	}
+	return m
+}
+
+func TestBatch(t *testing.T) {
+	s := &Server{pool: callPool{}, batchConcurrency: 2, batchMaxCodes: 10}
+	var running, peak atomic.Int32
+	fetch := func(c *tdx.Client, code string) (any, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		if code == "sh600519" {
+			return nil, errors.New("fake")
+		}
+		return map[string]string{"code": code}, nil
+	}
+	post := func(url string, codes ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.batch(w, httptest.NewRequest("POST", url, nil), codes, fetch)
+		return w
+	}
+
+	m := readBatch(t, post("/batch/test", "sz000001", "600519", "bad!", "000001", "sz000002", "sz000004", "sz000005"))
+	if len(m) != 6 {
+		t.Fatalf("items = %v", m)
+	}
+	if m["sz000001"].Error != "" || m["sz000001"].Data == nil {
+		t.Errorf("sz000001 = %+v", m["sz000001"])
+	}
+	if m["sh600519"].Error != "fake" || m["sh600519"].Data != nil {
+		t.Errorf("sh600519 = %+v", m["sh600519"])
+	}
+	if m["bad!"].Error == "" {
+		t.Errorf("bad! = %+v", m["bad!"])
+	}
+	if p := peak.Load(); p > 2 || p < 1 {
+		t.Errorf("并发数 = %d", p)
+	}
+
+	//format=json 按请求顺序
+	w := post("/batch/test?format=json", "sz000002", "sh600519", "sz000001")
+	resp := struct {
+		Data []batchItem `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 3 || resp.Data[0].Code != "sz000002" || resp.Data[1].Error != "fake" || resp.Data[2].Code != "sz000001" {
+		t.Errorf("json = %s", w.Body.String())
+	}
+
+	if w := post("/batch/test"); w.Code != http.StatusBadRequest {
+		t.Errorf("空代码: %d", w.Code)
+	}
+	if w := post("/batch/test", strings.Split(strings.Repeat("sz000001,", 11), ",")[:11]...); w.Code != http.StatusBadRequest {
+		t.Errorf("超过限制: %d", w.Code)
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	a, err := newAuthenticator(&serverConfig{apiKeys: []APIKey{{Key: "k1"}, {Key: "k2", Quota: 3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := newTestMux(&Server{pool: &fakePool{}, auth: a})
+	post := func(path, body string, key ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", path, strings.NewReader(body))
+		r.Header.Set("X-API-Key", append(key, "k1")[0])
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, c := range []struct{ path, body string }{
+		{"/batch/kline", `{"codes":["sz000001"],"type":12,"count":10}`},
+		{"/batch/kline", `{"codes":["sz000001"]}`},
+		{"/batch/minute", `{"codes":["sz000001"],"date":"2024-01-02"}`},
+		{"/batch/finance", `{"codes":`},
+	} {
+		if w := post(c.path, c.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: %d", c.path, c.body, w.Code)
+		}
+	}
+
+	//连接池出错时每个代码都有错误,整体仍是200
+	m := readBatch(t, post("/batch/gbbq", `{"codes":["sz000001","sh600000"]}`, "k2"))
+	if w := m["sh600000"]; len(m) != 2 || w.Error != "fake" {
+		t.Errorf("gbbq = %v", m)
+	}
+	//每个代码计一次请求,已用2次,剩1次
+	if w := post("/batch/finance", `{"codes":["sz000001","sh600000"]}`, "k2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("超出每日请求数: %d", w.Code)
+	}
+}
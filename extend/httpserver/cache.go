@@ -0,0 +1,306 @@
+package httpserver
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 响应缓存
+//
+// 以 路径+排序后的参数 为键缓存成功的响应(code=0),过期时间由每个路由的 cachePolicy 决定。
+// 默认关闭,WithCache 设置内存条数后启用。内存中按 LRU 淘汰,设置了目录时同时写入磁盘,重启后仍可命中。
+// 同一个键的并发请求只会有一个真正请求通达信服务器,其余等待结果。
+// 支持 ETag/If-None-Match 和 Last-Modified/If-Modified-Since,未变化返回 304。
+
+// cacheEntry 一条缓存的响应
+type cacheEntry struct {
+	Key         string      `json:"key"`
+	ContentType string      `json:"contentType"`
+	Header      http.Header `json:"header"` //handler 设置的其他响应头,例 Vary
+	Body        []byte      `json:"body"`
+	ETag        string      `json:"etag"`
+	Modified    time.Time   `json:"modified"` //生成时间
+	Expire      time.Time   `json:"expire"`   //过期时间
+}
+
+// responseCache LRU 内存缓存,可选磁盘持久化
+type responseCache struct {
+	size int    //内存最大条数
+	dir  string //磁盘目录,为空不持久化
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+
+	group flightGroup
+}
+
+func newResponseCache(size int, dir string) *responseCache {
+	return &responseCache{
+		size:  size,
+		dir:   dir,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// get 获取未过期的缓存,内存未命中时尝试磁盘
+func (this *responseCache) get(key string, now time.Time) *cacheEntry {
+	this.mu.Lock()
+	if el, ok := this.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if now.Before(e.Expire) {
+			this.ll.MoveToFront(el)
+			this.mu.Unlock()
+			return e
+		}
+		this.ll.Remove(el)
+		delete(this.items, key)
+	}
+	this.mu.Unlock()
+
+	if this.dir == "" {
+		return nil
+	}
+	filename := this.filename(key)
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	e := new(cacheEntry)
+	if err = json.Unmarshal(bs, e); err != nil || e.Key != key || !now.Before(e.Expire) {
+		os.Remove(filename)
+		return nil
+	}
+	this.setMemory(e)
+	return e
+}
+
+// set 写入缓存
+func (this *responseCache) set(e *cacheEntry) {
+	this.setMemory(e)
+	if this.dir == "" {
+		return
+	}
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(this.dir, 0777); err != nil {
+		return
+	}
+	//先写临时文件再改名,避免并发读到不完整的文件
+	filename := this.filename(e.Key)
+	tmp := filename + ".tmp"
+	if err = os.WriteFile(tmp, bs, 0666); err == nil {
+		os.Rename(tmp, filename)
+	}
+}
+
+func (this *responseCache) setMemory(e *cacheEntry) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if el, ok := this.items[e.Key]; ok {
+		el.Value = e
+		this.ll.MoveToFront(el)
+		return
+	}
+	this.items[e.Key] = this.ll.PushFront(e)
+	for this.size > 0 && this.ll.Len() > this.size {
+		last := this.ll.Back()
+		this.ll.Remove(last)
+		delete(this.items, last.Value.(*cacheEntry).Key)
+	}
+}
+
+func (this *responseCache) filename(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(this.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// cacheKey 请求的缓存键,参数排序后拼接(不含 api_key),非 JSON 格式加上格式(可能来自 Accept)
+func cacheKey(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("api_key")
+	key := r.URL.Path + "?" + query.Encode()
+	if f := negotiate(r); f != formatJSON {
+		key += "#" + f
+	}
+	return key
+}
+
+// cachePolicy 返回响应的过期时间,零值表示不缓存
+type cachePolicy func(r *http.Request, now time.Time) time.Time
+
+// cached 给路由加上缓存,未启用缓存时原样返回
+func (s *Server) cached(policy cachePolicy, h http.HandlerFunc) http.HandlerFunc {
+	if s.cache == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		expire := policy(r, now)
+		if expire.IsZero() || !expire.After(now) {
+			h(w, r)
+			return
+		}
+		key := cacheKey(r)
+		e, hit := s.cache.get(key, now), true
+		if e == nil {
+			hit = false
+			v, shared := s.cache.group.do(key, func() any {
+				rec := newBufferedResponse()
+				h(rec, r)
+				body := rec.body.Bytes()
+				//只缓存成功的响应,错误也在这里返回给所有等待的请求。
+				//非 JSON 格式(CSV等)出错时仍是 JSON 的统一响应结构
+				ct := rec.header.Get("Content-Type")
+				if rec.code != http.StatusOK || (ct == "application/json" && !bytes.HasPrefix(body, []byte(`{"code":0,`))) {
+					return rec
+				}
+				header := rec.header.Clone()
+				header.Del("Content-Type")
+				header.Del("Content-Length")
+				sum := sha1.Sum(body)
+				e := &cacheEntry{
+					Key:         key,
+					ContentType: ct,
+					Header:      header,
+					Body:        body,
+					ETag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+					Modified:    now.Truncate(time.Second),
+					Expire:      expire,
+				}
+				s.cache.set(e)
+				return e
+			})
+			if shared {
+				hit = true
+			}
+			switch v := v.(type) {
+			case *cacheEntry:
+				e = v
+			case *bufferedResponse:
+				v.writeTo(w)
+				return
+			default:
+				//fn 异常退出
+				h(w, r)
+				return
+			}
+		}
+		s.writeCached(w, r, e, hit, now)
+	}
+}
+
+// writeCached 写入缓存的响应,处理 If-None-Match/If-Modified-Since
+func (s *Server) writeCached(w http.ResponseWriter, r *http.Request, e *cacheEntry, hit bool, now time.Time) {
+	h := w.Header()
+	for k, vs := range e.Header {
+		h[k] = append([]string(nil), vs...)
+	}
+	h.Set("ETag", e.ETag)
+	h.Set("Last-Modified", e.Modified.UTC().Format(http.TimeFormat))
+	h.Set("Cache-Control", "max-age="+strconv.Itoa(int(e.Expire.Sub(now).Seconds())))
+	if hit {
+		h.Set("X-Cache", "HIT")
+	} else {
+		h.Set("X-Cache", "MISS")
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if inm == e.ETag || inm == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !e.Modified.After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	h.Set("Content-Type", e.ContentType)
+	h.Set("Content-Length", fmt.Sprint(len(e.Body)))
+	w.Write(e.Body)
+}
+
+// bufferedResponse 先把 handler 的响应写到内存,判断能否缓存后再写给客户端
+type bufferedResponse struct {
+	header http.Header
+	code   int
+	wrote  bool
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}, code: http.StatusOK}
+}
+
+func (this *bufferedResponse) Header() http.Header { return this.header }
+
+func (this *bufferedResponse) WriteHeader(code int) {
+	if !this.wrote {
+		this.code, this.wrote = code, true
+	}
+}
+
+func (this *bufferedResponse) Write(p []byte) (int, error) {
+	this.wrote = true
+	return this.body.Write(p)
+}
+
+// writeTo 原样写给客户端,合并的请求共用,不修改自身
+func (this *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for k, vs := range this.header {
+		w.Header()[k] = append([]string(nil), vs...)
+	}
+	w.WriteHeader(this.code)
+	w.Write(this.body.Bytes())
+}
+
+// flightGroup 合并相同键的并发调用,同 golang.org/x/sync/singleflight
+type flightGroup struct {
+	mu sync.Mutex
+	m  map[string]*flightCall
+}
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	val any
+}
+
+// do 执行fn,同一时间相同key只执行一次,shared 表示结果来自其他调用
+func (this *flightGroup) do(key string, fn func() any) (v any, shared bool) {
+	this.mu.Lock()
+	if this.m == nil {
+		this.m = map[string]*flightCall{}
+	}
+	if c, ok := this.m[key]; ok {
+		this.mu.Unlock()
+		c.wg.Wait()
+		return c.val, true
+	}
+	c := new(flightCall)
+	c.wg.Add(1)
+	this.m[key] = c
+	this.mu.Unlock()
+
+	defer func() {
+		this.mu.Lock()
+		delete(this.m, key)
+		this.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.val = fn()
+	return c.val, false
+}
@@ -0,0 +1,169 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 各路由的缓存策略:
+//
+//	行情(quote)              1秒
+//	分时/成交/分钟K线        到当前K线收盘(盘中),盘后到下个交易日开盘
+//	日线及以上               盘后到下个交易日开盘,盘中当天K线还在变,按1分钟处理
+//	股本变迁/财务            到下个交易日
+//	历史(带date参数)         日期早于今天的到下个交易日开盘,否则同分时
+//	板块/配置文件(zhb等)     到第二天0点
+//	扩展行情(/ex/*)          港股/美股/期货夜盘的交易时间和A股不同,实时数据1秒,
+//	                         历史(带date参数)早于该市场当前交易日的到第二天0点,否则1秒
+//
+// 交易日由 WithWorkday 设置的 tdx.Workday 判断,未设置时按周一至周五处理。
+
+const (
+	sessionMorning   = 9*60 + 30 //上午开盘 09:30
+	sessionMorningN  = 120       //上午交易分钟数
+	sessionAfternoon = 13 * 60   //下午开盘 13:00
+	sessionMinutes   = 240       //全天交易分钟数
+	sessionSettle    = 5         //收盘后数据可能还在更新的分钟数
+)
+
+// isTradingDay 是否是交易日
+func (s *Server) isTradingDay(t time.Time) bool {
+	if s.workday != nil {
+		return s.workday.Is(t)
+	}
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// nextOpen 下一个交易日(不含当天)的开盘时间
+func (s *Server) nextOpen(now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for i := 1; i <= 30; i++ {
+		if d := day.AddDate(0, 0, i); s.isTradingDay(d) {
+			return d.Add(sessionMorning * time.Minute)
+		}
+	}
+	return day.AddDate(0, 0, 1).Add(sessionMorning * time.Minute)
+}
+
+// barClose 周期为 minutes 分钟的K线,当前这根的收盘时间。
+// 非交易时段返回下一个有数据变化的时间(开盘/午后开盘/下个交易日开盘)
+func (s *Server) barClose(now time.Time, minutes int) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !s.isTradingDay(now) {
+		return s.nextOpen(now)
+	}
+	m := now.Hour()*60 + now.Minute()
+	var n int //已经过的交易分钟数
+	switch {
+	case m < sessionMorning:
+		return day.Add(sessionMorning * time.Minute)
+	case m < sessionMorning+sessionMorningN:
+		n = m - sessionMorning
+	case m < sessionMorning+sessionMorningN+sessionSettle:
+		return now.Truncate(time.Minute).Add(time.Minute)
+	case m < sessionAfternoon:
+		return day.Add(sessionAfternoon * time.Minute)
+	case m < sessionAfternoon+sessionMinutes-sessionMorningN:
+		n = sessionMorningN + m - sessionAfternoon
+	case m < sessionAfternoon+sessionMinutes-sessionMorningN+sessionSettle:
+		return now.Truncate(time.Minute).Add(time.Minute)
+	default:
+		return s.nextOpen(now)
+	}
+	next := (n/minutes + 1) * minutes
+	if next > sessionMinutes {
+		next = sessionMinutes
+	}
+	if next <= sessionMorningN {
+		return day.Add(time.Duration(sessionMorning+next) * time.Minute)
+	}
+	return day.Add(time.Duration(sessionAfternoon+next-sessionMorningN) * time.Minute)
+}
+
+// inSession 是否在交易时段(含集合竞价)
+func (s *Server) inSession(now time.Time) bool {
+	m := now.Hour()*60 + now.Minute()
+	return s.isTradingDay(now) && m >= 9*60+15 && m < 15*60
+}
+
+// policyTTL 固定时长
+func policyTTL(d time.Duration) cachePolicy {
+	return func(r *http.Request, now time.Time) time.Time {
+		return now.Add(d)
+	}
+}
+
+// policyDaily 到第二天0点
+func policyDaily(r *http.Request, now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+}
+
+// policyBar 周期为 minutes 分钟的K线(分时/成交为1)
+func (s *Server) policyBar(minutes int) cachePolicy {
+	return func(r *http.Request, now time.Time) time.Time {
+		return s.barClose(now, minutes)
+	}
+}
+
+// policyHistory 日线及以上,盘中按1分钟,盘后到下个交易日开盘
+func (s *Server) policyHistory(r *http.Request, now time.Time) time.Time {
+	if s.inSession(now) {
+		return s.barClose(now, 1)
+	}
+	return s.barClose(now, sessionMinutes)
+}
+
+// policyTradingDay 到下一个交易日(股本变迁/财务),交易日盘前到当天开盘,盘中到当天收盘
+func (s *Server) policyTradingDay(r *http.Request, now time.Time) time.Time {
+	return s.barClose(now, sessionMinutes)
+}
+
+// policyDate 带date参数(YYYYMMDD)的历史数据,早于今天的不会再变
+func (s *Server) policyDate(r *http.Request, now time.Time) time.Time {
+	if date := r.URL.Query().Get("date"); date != "" && date < now.Format("20060102") {
+		return s.nextOpen(now)
+	}
+	return s.barClose(now, 1)
+}
+
+// policyExDate 扩展行情的历史数据,param 为日期参数(YYYYMMDD),早于市场当前交易日的不会再变。
+// 交易日只按周一到周五(A股的节假日港股/美股/期货不一定休市)
+func policyExDate(param string) cachePolicy {
+	return func(r *http.Request, now time.Time) time.Time {
+		q := r.URL.Query()
+		market, err := strconv.ParseUint(q.Get("market"), 10, 8)
+		if err != nil {
+			return time.Time{}
+		}
+		day := protocol.ExTradingDay(uint8(market), now, nil).Format("20060102")
+		if date := q.Get(param); date != "" && date < day {
+			return policyDaily(r, now)
+		}
+		return now.Add(time.Second)
+	}
+}
+
+// policyKline 按 type 参数选择K线周期
+func (s *Server) policyKline(r *http.Request, now time.Time) time.Time {
+	t, err := requestType(r)
+	if err != nil {
+		return time.Time{}
+	}
+	switch t {
+	case protocol.TypeKlineMinute, protocol.TypeKlineMinute2:
+		return s.barClose(now, 1)
+	case protocol.TypeKline5Minute:
+		return s.barClose(now, 5)
+	case protocol.TypeKline15Minute:
+		return s.barClose(now, 15)
+	case protocol.TypeKline30Minute:
+		return s.barClose(now, 30)
+	case protocol.TypeKline60Minute:
+		return s.barClose(now, 60)
+	default:
+		return s.policyHistory(r, now)
+	}
+}
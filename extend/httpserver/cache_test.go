@@ -0,0 +1,148 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheLRU(t *testing.T) {
+	now := time.Now()
+	c := newResponseCache(2, t.TempDir())
+	for _, key := range []string{"a", "b", "c"} {
+		c.set(&cacheEntry{Key: key, Body: []byte(key), Expire: now.Add(time.Minute)})
+	}
+	if c.ll.Len() != 2 {
+		t.Fatalf("len: %d", c.ll.Len())
+	}
+	//内存中已淘汰,从磁盘读回
+	if e := c.get("a", now); e == nil || string(e.Body) != "a" {
+		t.Fatalf("disk: %v", e)
+	}
+	if e := c.get("a", now.Add(2*time.Minute)); e != nil {
+		t.Fatal("expired")
+	}
+}
+
+func TestCached(t *testing.T) {
+	var calls atomic.Int32
+	s := &Server{cache: newResponseCache(10, "")}
+	h := s.cached(policyTTL(time.Minute), func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Add("Vary", "Accept")
+		if r.URL.Query().Get("err") != "" {
+			respondErr(w, http.StatusOK, "error")
+			return
+		}
+		respondOK(w, r.URL.Query().Get("code"))
+	})
+
+	//并发的相同请求只执行一次
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest("GET", "/x?code=1&b=2", nil))
+			if w.Code != http.StatusOK || w.Header().Get("ETag") == "" || w.Header().Get("Vary") != "Accept" {
+				t.Errorf("code: %d %v", w.Code, w.Header())
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("calls: %d", n)
+	}
+
+	//参数顺序不影响命中
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/x?b=2&code=1", nil))
+	if calls.Load() != 1 || w.Header().Get("X-Cache") != "HIT" || w.Header().Get("Vary") != "Accept" {
+		t.Fatalf("calls: %d %s", calls.Load(), w.Header().Get("X-Cache"))
+	}
+
+	//ETag
+	req := httptest.NewRequest("GET", "/x?b=2&code=1", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w2 := httptest.NewRecorder()
+	h(w2, req)
+	if w2.Code != http.StatusNotModified || w2.Body.Len() != 0 || w2.Header().Get("Vary") != "Accept" {
+		t.Fatalf("etag: %d", w2.Code)
+	}
+
+	//Last-Modified
+	req = httptest.NewRequest("GET", "/x?b=2&code=1", nil)
+	req.Header.Set("If-Modified-Since", w.Header().Get("Last-Modified"))
+	w2 = httptest.NewRecorder()
+	h(w2, req)
+	if w2.Code != http.StatusNotModified {
+		t.Fatalf("last-modified: %d", w2.Code)
+	}
+
+	//错误不缓存
+	for i := 0; i < 2; i++ {
+		h(httptest.NewRecorder(), httptest.NewRequest("GET", "/x?err=1", nil))
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("calls: %d", n)
+	}
+}
+
+func TestPolicyExDate(t *testing.T) {
+	//北京时间周六凌晨3点,美股还是周五(6月6日)的交易日,期货夜盘已经是周一的交易日
+	now := time.Date(2025, 6, 7, 3, 0, 0, 0, time.FixedZone("CST", 8*60*60))
+	daily := policyDaily(nil, now)
+	for _, v := range []struct {
+		url  string
+		want time.Time
+	}{
+		{"/ex/minute/hist?market=74&date=20250606", now.Add(time.Second)},
+		{"/ex/minute/hist?market=74&date=20250605", daily},
+		{"/ex/minute/hist?market=30&date=20250606", daily},
+		{"/ex/bars/range?market=30&date=20250601&date2=20250609", now.Add(time.Second)},
+		{"/ex/minute/hist?market=x&date=20250605", time.Time{}},
+	} {
+		param := "date"
+		if strings.HasPrefix(v.url, "/ex/bars/range") {
+			param = "date2"
+		}
+		if got := policyExDate(param)(httptest.NewRequest("GET", v.url, nil), now); !got.Equal(v.want) {
+			t.Errorf("%s = %v, want %v", v.url, got, v.want)
+		}
+	}
+}
+
+func TestBarClose(t *testing.T) {
+	s := &Server{}
+	at := func(day, h, m int) time.Time { return time.Date(2024, 1, day, h, m, 10, 0, time.Local) }
+	cases := []struct {
+		now     time.Time
+		minutes int
+		want    time.Time
+	}{
+		{at(2, 9, 31), 1, at(2, 9, 32)},
+		{at(2, 9, 31), 5, at(2, 9, 35)},
+		{at(2, 10, 31), 60, at(2, 11, 30)},
+		{at(2, 11, 31), 60, at(2, 11, 32)}, //收盘后几分钟内数据可能还在更新
+		{at(2, 12, 0), 60, at(2, 13, 0)},
+		{at(2, 13, 1), 60, at(2, 14, 0)},
+		{at(2, 14, 59), 30, at(2, 15, 0)},
+		{at(2, 8, 0), 1, at(2, 9, 30)},
+		{at(2, 16, 0), 1, at(3, 9, 30)},
+		{at(5, 16, 0), 1, at(8, 9, 30)}, //周五盘后到周一
+		{at(6, 10, 0), 1, at(8, 9, 30)}, //周六
+	}
+	for _, c := range cases {
+		got := s.barClose(c.now, c.minutes)
+		want := c.want.Truncate(time.Minute)
+		if !got.Equal(want) {
+			t.Errorf("%s %d: %s != %s", c.now, c.minutes, got, want)
+		}
+	}
+}
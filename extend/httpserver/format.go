@@ -0,0 +1,256 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 响应格式
+//
+// 表格类接口(K线/分时/成交/行情/代码/统计等,见 tabular)支持按 format 参数或 Accept 请求头选择格式,
+// format 参数优先:
+//
+//	json    application/json                       默认,统一响应结构
+//	csv     text/csv                               第一行为列名
+//	ndjson  application/x-ndjson                   每行一个 JSON 对象,边转换边输出
+//	arrow   application/vnd.apache.arrow.stream    Arrow IPC 流格式,可直接被 pandas/polars/duckdb 读取
+//
+// 非 JSON 格式只输出 data 部分(表格化见 table.go),出错时仍返回 JSON 的统一响应结构。
+
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+	formatArrow  = "arrow"
+	formatTable  = "table" //对齐的文本,只用于 WriteTable
+)
+
+var formatTypes = map[string]string{
+	formatJSON:   "application/json",
+	formatCSV:    "text/csv; charset=utf-8",
+	formatNDJSON: "application/x-ndjson",
+	formatArrow:  "application/vnd.apache.arrow.stream",
+}
+
+// pFormat 表格类接口的 format 参数
+var pFormat = param{Name: "format", Kind: kindString, Desc: "响应格式,未设置时按 Accept 请求头,默认 json", Example: "csv",
+	Optional: true, Enum: []string{formatJSON, formatCSV, formatNDJSON, formatArrow}}
+
+// ndjsonFlush NDJSON 每多少行刷新一次
+const ndjsonFlush = 500
+
+// negotiate 请求的响应格式
+func negotiate(r *http.Request) string {
+	if f := strings.ToLower(r.URL.Query().Get("format")); f != "" {
+		return f
+	}
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		t, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		switch t {
+		case "application/json":
+			return formatJSON
+		case "text/csv":
+			return formatCSV
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return formatNDJSON
+		case "application/vnd.apache.arrow.stream":
+			return formatArrow
+		}
+	}
+	return formatJSON
+}
+
+// formatWriter 带响应格式的 ResponseWriter,respondOK 据此输出
+type formatWriter struct {
+	http.ResponseWriter
+	format string
+}
+
+func (this *formatWriter) Flush() {
+	if f, ok := this.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// withFormat 表格类接口按请求选择响应格式
+func withFormat(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if f := negotiate(r); f != formatJSON {
+			w = &formatWriter{ResponseWriter: w, format: f}
+		}
+		h(w, r)
+	}
+}
+
+// respond 按格式输出表格,不能表格化时返回false
+func (this *formatWriter) respond(data any) bool {
+	t, ok := newTable(data)
+	if !ok {
+		return false
+	}
+	this.Header().Set("Content-Type", formatTypes[this.format])
+	switch this.format {
+	case formatCSV:
+		writeCSV(this, t)
+	case formatNDJSON:
+		writeNDJSON(this, t)
+	case formatArrow:
+		//先写到内存,出错时还能返回错误
+		buf := new(bytes.Buffer)
+		if err := writeArrow(buf, t); err != nil {
+			this.Header().Del("Content-Type")
+			respondErr(this.ResponseWriter, http.StatusInternalServerError, err.Error())
+			return true
+		}
+		this.Write(buf.Bytes())
+	default:
+		return false
+	}
+	return true
+}
+
+// cellString CSV 的单元格,数字不带单位,时间为 RFC3339
+func cellString(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
+
+// WriteTable 把数据按 format(csv/ndjson/arrow/table)写到 w,列和 format 参数的输出相同,
+// table 为对齐的文本。不能表格化或不支持的格式返回 false,供命令行等非 HTTP 场景使用
+func WriteTable(w io.Writer, format string, data any) (bool, error) {
+	t, ok := newTable(data)
+	if !ok {
+		return false, nil
+	}
+	switch format {
+	case formatCSV:
+		writeCSV(w, t)
+	case formatNDJSON:
+		writeNDJSON(w, t)
+	case formatArrow:
+		return true, writeArrow(w, t)
+	case formatTable:
+		writeText(w, t)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// writeText 对齐的文本,价格保留2位小数
+func writeText(w io.Writer, t *table) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, c := range t.Columns {
+		if i > 0 {
+			tw.Write([]byte{'\t'})
+		}
+		io.WriteString(tw, c.Name)
+	}
+	tw.Write([]byte{'\n'})
+	for i := 0; i < t.Len(); i++ {
+		row := t.Row(i)
+		for j, c := range t.Columns {
+			if j > 0 {
+				tw.Write([]byte{'\t'})
+			}
+			v, _ := c.value(row)
+			switch v := v.(type) {
+			case float64:
+				fmt.Fprintf(tw, "%.2f", v)
+			case time.Time:
+				io.WriteString(tw, v.Format(time.DateTime))
+			default:
+				io.WriteString(tw, cellString(v))
+			}
+		}
+		tw.Write([]byte{'\n'})
+	}
+	tw.Flush()
+}
+
+func writeCSV(w io.Writer, t *table) {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		record[i] = c.Name
+	}
+	cw.Write(record)
+	for i := 0; i < t.Len(); i++ {
+		row := t.Row(i)
+		for j, c := range t.Columns {
+			v, _ := c.value(row)
+			record[j] = cellString(v)
+		}
+		if cw.Write(record) != nil {
+			return
+		}
+	}
+	cw.Flush()
+}
+
+func writeNDJSON(w io.Writer, t *table) {
+	names := make([][]byte, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i], _ = json.Marshal(c.Name)
+	}
+	flusher, _ := w.(http.Flusher)
+	buf := new(bytes.Buffer)
+	for i := 0; i < t.Len(); i++ {
+		row := t.Row(i)
+		buf.WriteByte('{')
+		for j, c := range t.Columns {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(names[j])
+			buf.WriteByte(':')
+			v, ok := c.value(row)
+			if f, isFloat := v.(float64); isFloat && (math.IsNaN(f) || math.IsInf(f, 0)) {
+				ok = false //NaN/Inf 不能编码为 JSON
+			}
+			if !ok {
+				buf.WriteString("null")
+				continue
+			}
+			bs, _ := json.Marshal(v)
+			buf.Write(bs)
+		}
+		buf.WriteString("}\n")
+		if (i+1)%ndjsonFlush == 0 {
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return
+			}
+			buf.Reset()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,482 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+var testKlines = &protocol.KlineResp{Count: 2, List: []*protocol.Kline{
+	{Last: 9800, Open: 10000, High: 10500, Low: 9900, Close: 10230, Volume: 1200, Amount: 12345678,
+		Time: time.Date(2024, 1, 2, 15, 0, 0, 0, time.FixedZone("CST", 8*3600))},
+	{Last: 10230, Open: 10230, High: 10300, Low: 10010, Close: 10100, Volume: 800, Amount: 8000000,
+		Time: time.Date(2024, 1, 3, 15, 0, 0, 0, time.FixedZone("CST", 8*3600))},
+}}
+
+func columnNames(t *table) []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func TestTable(t *testing.T) {
+	tb, ok := newTable(testKlines)
+	if !ok || tb.Len() != 2 {
+		t.Fatal("KlineResp 应按 List 表格化")
+	}
+	if s := strings.Join(columnNames(tb), ","); s != "last,open,high,low,close,order,volume,amount,time,up_count,down_count" {
+		t.Fatalf("columns = %s", s)
+	}
+	if v, _ := tb.Columns[4].value(tb.Row(0)); v != 10.23 {
+		t.Errorf("close = %v", v)
+	}
+
+	quotes := protocol.QuotesResp{{Exchange: protocol.ExchangeSH, Code: "600519", Kline: testKlines.List[0]}}
+	tb, _ = newTable(quotes)
+	cols := map[string]*column{}
+	for _, c := range tb.Columns {
+		cols[c.Name] = c
+	}
+	for _, name := range []string{"exchange", "code", "kline_open", "kline_time", "buy_level_1_price", "sell_level_5_number"} {
+		if cols[name] == nil {
+			t.Fatalf("缺少列 %s: %v", name, columnNames(tb))
+		}
+	}
+	if v, _ := cols["exchange"].value(tb.Row(0)); v != "sh" {
+		t.Errorf("exchange = %v", v)
+	}
+	if v, _ := cols["kline_open"].value(tb.Row(0)); v != 10.0 {
+		t.Errorf("kline_open = %v", v)
+	}
+	//Kline 为nil时为空值
+	tb, _ = newTable(protocol.QuotesResp{{Code: "000001"}})
+	for _, c := range tb.Columns {
+		if c.Name == "kline_open" {
+			if _, ok := c.value(tb.Row(0)); ok {
+				t.Error("kline_open 应为空")
+			}
+		}
+	}
+
+	ex := &protocol.ExQuote{Bid: [5]float64{1.5}}
+	tb, _ = newTable(ex)
+	if tb.Len() != 1 || !strings.Contains(strings.Join(columnNames(tb), ","), "bid_1,bid_2") {
+		t.Errorf("ExQuote columns = %v", columnNames(tb))
+	}
+
+	if tabular([]byte{}) || tabular(map[string][]byte{}) {
+		t.Error("[]byte/map 不应表格化")
+	}
+	if tb, ok := newTable(42); !ok || tb.Columns[0].Name != "value" {
+		t.Error("数字应为一列 value")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for s, want := range map[string]string{
+		"UpCount":      "up_count",
+		"preClose":     "pre_close",
+		"LiuTongGuBen": "liu_tong_gu_ben",
+		"ETFCode":      "etf_code",
+		"Code":         "code",
+		"bid":          "bid",
+	} {
+		if got := snakeCase(s); got != want {
+			t.Errorf("snakeCase(%s) = %s, want %s", s, got, want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, c := range []struct {
+		url, accept, want string
+	}{
+		{"/kline", "", formatJSON},
+		{"/kline", "text/csv", formatCSV},
+		{"/kline", "text/html, application/x-ndjson;q=0.9", formatNDJSON},
+		{"/kline", "application/vnd.apache.arrow.stream", formatArrow},
+		{"/kline?format=CSV", "application/x-ndjson", formatCSV},
+		{"/kline", "*/*", formatJSON},
+	} {
+		r := httptest.NewRequest("GET", c.url, nil)
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		if got := negotiate(r); got != c.want {
+			t.Errorf("%s %q: %s, want %s", c.url, c.accept, got, c.want)
+		}
+	}
+}
+
+func newFormatMux(s *Server, calls *atomic.Int32) *http.ServeMux {
+	mux := http.NewServeMux()
+	p := []param{pCode, pFormat}
+	h := withFormat(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Query().Get("code") == "err" {
+			respondErr(w, http.StatusOK, "error")
+			return
+		}
+		respondOK(w, testKlines)
+	})
+	if s.cache != nil {
+		h = s.cached(policyTTL(time.Minute), h)
+	}
+	mux.HandleFunc("GET /kline", validate(p, h))
+	return mux
+}
+
+func TestFormatResponse(t *testing.T) {
+	var calls atomic.Int32
+	mux := newFormatMux(&Server{}, &calls)
+	get := func(url, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", url, nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/kline?code=sz000001", "")
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") || w.Header().Get("Vary") != "Accept" {
+		t.Fatalf("json: %v", w.Header())
+	}
+
+	w = get("/kline?code=sz000001&format=csv", "")
+	if w.Header().Get("Content-Type") != formatTypes[formatCSV] {
+		t.Fatalf("csv Content-Type = %s", w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][4] != "close" || records[1][4] != "10.23" || records[1][8] != "2024-01-02T15:00:00+08:00" {
+		t.Errorf("csv = %v", records)
+	}
+
+	w = get("/kline?code=sz000001", "application/x-ndjson")
+	sc := bufio.NewScanner(w.Body)
+	var rows []map[string]any
+	for sc.Scan() {
+		m := map[string]any{}
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, m)
+	}
+	if len(rows) != 2 || rows[1]["close"] != 10.1 || rows[0]["volume"] != 1200.0 {
+		t.Errorf("ndjson = %v", rows)
+	}
+
+	//出错时仍是 JSON
+	w = get("/kline?code=err&format=csv", "")
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") || !strings.Contains(w.Body.String(), `"code":1`) {
+		t.Errorf("error: %s %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = get("/kline?code=sz000001&format=xml", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("format=xml: %d", w.Code)
+	}
+}
+
+func TestFormatCache(t *testing.T) {
+	var calls atomic.Int32
+	mux := newFormatMux(&Server{cache: newResponseCache(10, "")}, &calls)
+	bodies := map[string]string{}
+	for _, accept := range []string{"", "text/csv", "", "text/csv", "application/vnd.apache.arrow.stream"} {
+		r := httptest.NewRequest("GET", "/kline?code=sz000001", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if b, ok := bodies[accept]; ok && b != w.Body.String() {
+			t.Errorf("%q: 缓存内容不一致", accept)
+		}
+		bodies[accept] = w.Body.String()
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+// ---- 读取 Arrow IPC 流,只用于测试 ----
+
+type fbReader []byte
+
+func (this fbReader) u16(p int) int { return int(binary.LittleEndian.Uint16(this[p:])) }
+
+func (this fbReader) u32(p int) int { return int(binary.LittleEndian.Uint32(this[p:])) }
+
+func (this fbReader) deref(p int) int { return p + this.u32(p) }
+
+// field 表 pos 中字段 id 的位置,不存在时返回-1
+func (this fbReader) field(pos, id int) int {
+	vt := pos - int(int32(this.u32(pos)))
+	if 4+2*id >= this.u16(vt) {
+		return -1
+	}
+	if off := this.u16(vt + 4 + 2*id); off != 0 {
+		return pos + off
+	}
+	return -1
+}
+
+func (this fbReader) str(p int) string {
+	p = this.deref(p)
+	return string(this[p+4 : p+4+this.u32(p)])
+}
+
+// vector 向量的长度和第一个元素的位置
+func (this fbReader) vector(p int) (int, int) {
+	p = this.deref(p)
+	return this.u32(p), p + 4
+}
+
+type arrowMessage struct {
+	meta       fbReader
+	msg        int //Message 表的位置
+	headerType int
+	header     int
+	body       []byte
+}
+
+func readArrowMessages(t *testing.T, bs []byte) []arrowMessage {
+	var ls []arrowMessage
+	for {
+		if len(bs) < 8 || binary.LittleEndian.Uint32(bs) != 0xFFFFFFFF {
+			t.Fatalf("缺少 continuation: % x", bs[:min(8, len(bs))])
+		}
+		n := int(binary.LittleEndian.Uint32(bs[4:]))
+		if n == 0 {
+			if len(bs) != 8 {
+				t.Fatalf("结束标记后还有 %d 字节", len(bs)-8)
+			}
+			return ls
+		}
+		if (8+n)%8 != 0 {
+			t.Fatalf("元数据未对齐: %d", n)
+		}
+		meta := fbReader(bs[8 : 8+n])
+		m := arrowMessage{meta: meta, msg: meta.u32(0)}
+		if v := meta.field(m.msg, 0); v < 0 || meta.u16(v) != arrowMetadataV5 {
+			t.Fatal("version")
+		}
+		m.headerType = int(meta[meta.field(m.msg, 1)])
+		m.header = meta.deref(meta.field(m.msg, 2))
+		bodyLen := 0
+		if p := meta.field(m.msg, 3); p >= 0 {
+			bodyLen = int(binary.LittleEndian.Uint64(meta[p:]))
+		}
+		m.body = bs[8+n : 8+n+bodyLen]
+		ls = append(ls, m)
+		bs = bs[8+n+bodyLen:]
+	}
+}
+
+func TestArrow(t *testing.T) {
+	tb, _ := newTable(protocol.QuotesResp{
+		{Exchange: protocol.ExchangeSH, Code: "600519", Kline: testKlines.List[0]},
+		{Exchange: protocol.ExchangeSZ, Code: "000001"},
+	})
+	buf := new(bytes.Buffer)
+	if err := writeArrow(buf, tb); err != nil {
+		t.Fatal(err)
+	}
+	ms := readArrowMessages(t, buf.Bytes())
+	if len(ms) != 2 || ms[0].headerType != arrowHeaderSchema || ms[1].headerType != arrowHeaderRecordBatch {
+		t.Fatalf("messages = %d", len(ms))
+	}
+
+	//Schema
+	schema := ms[0]
+	n, first := schema.meta.vector(schema.meta.field(schema.header, 1))
+	if n != len(tb.Columns) {
+		t.Fatalf("fields = %d, columns = %d", n, len(tb.Columns))
+	}
+	types := map[string]int{}
+	index := map[string]int{}
+	for i := 0; i < n; i++ {
+		f := schema.meta.deref(first + 4*i)
+		name := schema.meta.str(schema.meta.field(f, 0))
+		types[name] = int(schema.meta[schema.meta.field(f, 2)])
+		index[name] = i
+		if name != tb.Columns[i].Name {
+			t.Fatalf("field %d = %s, want %s", i, name, tb.Columns[i].Name)
+		}
+		if name == "kline_time" {
+			typ := schema.meta.deref(schema.meta.field(f, 3))
+			if tz := schema.meta.str(schema.meta.field(typ, 1)); tz != "+08:00" {
+				t.Errorf("timezone = %s", tz)
+			}
+		}
+	}
+	for name, want := range map[string]int{
+		"exchange":          arrowTypeUtf8,
+		"code":              arrowTypeUtf8,
+		"kline_close":       arrowTypeFloatingPoint,
+		"kline_volume":      arrowTypeInt,
+		"kline_time":        arrowTypeTimestamp,
+		"buy_level_1_buy":   arrowTypeBool,
+		"buy_level_1_price": arrowTypeFloatingPoint,
+	} {
+		if types[name] != want {
+			t.Errorf("%s type = %d, want %d", name, types[name], want)
+		}
+	}
+
+	//RecordBatch
+	batch := ms[1]
+	if l := binary.LittleEndian.Uint64(batch.meta[batch.meta.field(batch.header, 0):]); l != 2 {
+		t.Fatalf("length = %d", l)
+	}
+	nodeN, nodes := batch.meta.vector(batch.meta.field(batch.header, 1))
+	bufN, bufs := batch.meta.vector(batch.meta.field(batch.header, 2))
+	if nodeN != n || nodes%8 != 0 || bufs%8 != 0 {
+		t.Fatalf("nodes = %d at %d, buffers at %d", nodeN, nodes, bufs)
+	}
+	buffer := func(i int) []byte {
+		off := int(binary.LittleEndian.Uint64(batch.meta[bufs+16*i:]))
+		l := int(binary.LittleEndian.Uint64(batch.meta[bufs+16*i+8:]))
+		if off%8 != 0 || off+l > len(batch.body) {
+			t.Fatalf("buffer %d: %d+%d", i, off, l)
+		}
+		return batch.body[off : off+l]
+	}
+	//每列 validity+values,utf8 多一个 offsets
+	bufIndex := map[string]int{}
+	bi := 0
+	for i := 0; i < n; i++ {
+		bufIndex[tb.Columns[i].Name] = bi
+		bi += 2
+		if tb.Columns[i].Kind == columnString {
+			bi++
+		}
+	}
+	if bi != bufN {
+		t.Fatalf("buffers = %d, want %d", bufN, bi)
+	}
+
+	i := bufIndex["kline_close"]
+	if nulls := binary.LittleEndian.Uint64(batch.meta[nodes+16*index["kline_close"]+8:]); nulls != 1 {
+		t.Errorf("kline_close nulls = %d", nulls)
+	}
+	if v := buffer(i); len(v) != 1 || v[0] != 1 {
+		t.Errorf("kline_close validity = % x", v)
+	}
+	if v := math.Float64frombits(binary.LittleEndian.Uint64(buffer(i + 1))); v != 10.23 {
+		t.Errorf("kline_close = %v", v)
+	}
+	if v := binary.LittleEndian.Uint64(buffer(bufIndex["kline_time"] + 1)); int64(v) != testKlines.List[0].Time.UnixMilli() {
+		t.Errorf("kline_time = %d", v)
+	}
+	i = bufIndex["code"]
+	if len(buffer(i)) != 0 {
+		t.Error("code 没有空值,不需要 validity")
+	}
+	if offsets := buffer(i + 1); binary.LittleEndian.Uint32(offsets[4:]) != 6 || binary.LittleEndian.Uint32(offsets[8:]) != 12 {
+		t.Errorf("code offsets = % x", offsets)
+	}
+	if s := string(buffer(i + 2)); s != "600519000001" {
+		t.Errorf("code = %s", s)
+	}
+}
+
+// arrowPyarrowScript 用 pyarrow 读取标准输入的 Arrow IPC 流,输出列类型和数据
+const arrowPyarrowScript = `
+import json, sys
+import pyarrow as pa
+t = pa.ipc.open_stream(sys.stdin.buffer).read_all()
+print(json.dumps({"types": {f.name: str(f.type) for f in t.schema}, "rows": t.to_pylist()}, default=str))
+`
+
+// TestArrowPyarrow 用 pyarrow 读取 writeArrow 的输出,核对本地没有实现的读取端也能识别。
+// 需要 python3 和 pyarrow(pip install pyarrow),没有时跳过
+func TestArrowPyarrow(t *testing.T) {
+	if err := exec.Command("python3", "-c", "import pyarrow").Run(); err != nil {
+		t.Skip("需要 python3 和 pyarrow:", err)
+	}
+	tb, _ := newTable(protocol.QuotesResp{
+		{Exchange: protocol.ExchangeSH, Code: "600519", Kline: testKlines.List[0]},
+		{Exchange: protocol.ExchangeSZ, Code: "000001"},
+	})
+	buf := new(bytes.Buffer)
+	if err := writeArrow(buf, tb); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("python3", "-c", arrowPyarrowScript)
+	cmd.Stdin = buf
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("pyarrow: %v %s", err, out)
+	}
+	res := struct {
+		Types map[string]string
+		Rows  []map[string]any
+	}{}
+	if err := json.Unmarshal(out, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"exchange":        "string",
+		"kline_close":     "double",
+		"kline_volume":    "int64",
+		"kline_time":      "timestamp[ms, tz=+08:00]",
+		"buy_level_1_buy": "bool",
+	} {
+		if res.Types[name] != want {
+			t.Errorf("%s type = %s, want %s", name, res.Types[name], want)
+		}
+	}
+	if len(res.Types) != len(tb.Columns) || len(res.Rows) != 2 {
+		t.Fatalf("columns = %d, rows = %d", len(res.Types), len(res.Rows))
+	}
+	a, b := res.Rows[0], res.Rows[1]
+	if a["exchange"] != "sh" || a["code"] != "600519" || a["kline_close"] != 10.23 || a["kline_volume"] != float64(1200) ||
+		a["kline_time"] != "2024-01-02 15:00:00+08:00" || a["buy_level_1_buy"] != false {
+		t.Errorf("row 0 = %v", a)
+	}
+	if b["code"] != "000001" || b["kline_close"] != nil || b["kline_time"] != nil {
+		t.Errorf("row 1 = %v", b)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if ok, err := WriteTable(buf, formatTable, testKlines); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "last ") || !strings.Contains(lines[1], "10.23") ||
+		!strings.Contains(lines[1], "2024-01-02 15:00:00") {
+		t.Errorf("table:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if ok, _ := WriteTable(buf, formatCSV, testKlines); !ok || !strings.HasPrefix(buf.String(), "last,open,") {
+		t.Errorf("csv:\n%s", buf.String())
+	}
+	//不能表格化或未知格式
+	if ok, _ := WriteTable(buf, formatTable, map[string]int{}); ok {
+		t.Error("map 不能表格化")
+	}
+	if ok, _ := WriteTable(buf, "xml", testKlines); ok {
+		t.Error("未知格式")
+	}
+}
@@ -1,8 +1,10 @@
 package httpserver
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/injoyai/tdx"
 	"github.com/injoyai/tdx/protocol"
@@ -22,7 +24,7 @@ func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.CountResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetCount(ex)
 		return err
 	})
@@ -50,7 +52,7 @@ func (s *Server) handleCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.CodeResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetCode(ex, start)
 		return err
 	})
@@ -73,7 +75,7 @@ func (s *Server) handleCodeAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.CodeResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetCodeAll(ex)
 		return err
 	})
@@ -87,7 +89,7 @@ func (s *Server) handleCodeAll(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleStockCodeAll(w http.ResponseWriter, r *http.Request) {
 	var resp []string
 	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetStockCodeAll()
 		return err
 	})
@@ -101,7 +103,7 @@ func (s *Server) handleStockCodeAll(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleETFCodeAll(w http.ResponseWriter, r *http.Request) {
 	var resp []string
 	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetETFCodeAll()
 		return err
 	})
@@ -115,7 +117,7 @@ func (s *Server) handleETFCodeAll(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleIndexCodeAll(w http.ResponseWriter, r *http.Request) {
 	var resp []string
 	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexCodeAll()
 		return err
 	})
@@ -136,7 +138,7 @@ func (s *Server) handleQuote(w http.ResponseWriter, r *http.Request) {
 	}
 	codes := strings.Split(codesStr, ",")
 	var resp protocol.QuotesResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetQuote(codes...)
 		return err
 	})
@@ -154,7 +156,7 @@ func (s *Server) handleCallAuction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.CallAuctionResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetCallAuction(code)
 		return err
 	})
@@ -172,7 +174,7 @@ func (s *Server) handleGbbq(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.GbbqResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetGbbq(code)
 		return err
 	})
@@ -200,7 +202,7 @@ func (s *Server) handleFinanceInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.FinanceInfo
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetFinanceInfo(ex, code)
 		return err
 	})
@@ -228,7 +230,7 @@ func (s *Server) handleCompanyCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.CompanyCategory
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetCompanyCategory(ex, code)
 		return err
 	})
@@ -271,7 +273,7 @@ func (s *Server) handleCompanyContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp string
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetCompanyContent(ex, code, filename, start, length)
 		return err
 	})
@@ -282,6 +284,34 @@ func (s *Server) handleCompanyContent(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, resp)
 }
 
+func (s *Server) handleCompanyF10(w http.ResponseWriter, r *http.Request) {
+	exStr, err := queryStr(r, "exchange")
+	if err != nil {
+		respondErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ex, err := parseExchange(exStr)
+	if err != nil {
+		respondErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	code, err := queryStr(r, "code")
+	if err != nil {
+		respondErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var resp *protocol.CompanyF10
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
+		resp, err = c.GetCompanyF10(ex, code, nil)
+		return err
+	})
+	if err != nil {
+		respondErr(w, http.StatusOK, err.Error())
+		return
+	}
+	respondOK(w, resp)
+}
+
 // ---- 分时/成交 ----
 
 func (s *Server) handleMinute(w http.ResponseWriter, r *http.Request) {
@@ -291,7 +321,7 @@ func (s *Server) handleMinute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.MinuteResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetMinute(code)
 		return err
 	})
@@ -314,7 +344,7 @@ func (s *Server) handleHistoryMinute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.MinuteResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetHistoryMinute(date, code)
 		return err
 	})
@@ -342,7 +372,7 @@ func (s *Server) handleTrade(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.TradeResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetMinuteTrade(code, start, count)
 		return err
 	})
@@ -360,7 +390,7 @@ func (s *Server) handleTradeAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.TradeResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetMinuteTradeAll(code)
 		return err
 	})
@@ -393,7 +423,7 @@ func (s *Server) handleHistoryTrade(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.TradeResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetHistoryMinuteTrade(date, code, start, count)
 		return err
 	})
@@ -416,7 +446,7 @@ func (s *Server) handleHistoryTradeDay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.TradeResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetHistoryMinuteTradeDay(date, code)
 		return err
 	})
@@ -451,7 +481,7 @@ func (s *Server) handleKline(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline(typ, code, start, count)
 		return err
 	})
@@ -474,7 +504,7 @@ func (s *Server) handleKlineAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineAll(typ, code)
 		return err
 	})
@@ -504,7 +534,7 @@ func (s *Server) handleKlineMinute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineMinute(code, start, count)
 		return err
 	})
@@ -522,7 +552,7 @@ func (s *Server) handleKlineMinuteAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineMinuteAll(code)
 		return err
 	})
@@ -550,7 +580,7 @@ func (s *Server) handleKline5Minute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline5Minute(code, start, count)
 		return err
 	})
@@ -568,7 +598,7 @@ func (s *Server) handleKline5MinuteAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline5MinuteAll(code)
 		return err
 	})
@@ -596,7 +626,7 @@ func (s *Server) handleKline15Minute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline15Minute(code, start, count)
 		return err
 	})
@@ -614,7 +644,7 @@ func (s *Server) handleKline15MinuteAll(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline15MinuteAll(code)
 		return err
 	})
@@ -642,7 +672,7 @@ func (s *Server) handleKline30Minute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline30Minute(code, start, count)
 		return err
 	})
@@ -660,7 +690,7 @@ func (s *Server) handleKline30MinuteAll(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline30MinuteAll(code)
 		return err
 	})
@@ -688,7 +718,7 @@ func (s *Server) handleKline60Minute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline60Minute(code, start, count)
 		return err
 	})
@@ -706,7 +736,7 @@ func (s *Server) handleKline60MinuteAll(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKline60MinuteAll(code)
 		return err
 	})
@@ -734,7 +764,7 @@ func (s *Server) handleKlineDay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineDay(code, start, count)
 		return err
 	})
@@ -752,7 +782,7 @@ func (s *Server) handleKlineDayAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineDayAll(code)
 		return err
 	})
@@ -780,7 +810,7 @@ func (s *Server) handleKlineWeek(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineWeek(code, start, count)
 		return err
 	})
@@ -798,7 +828,7 @@ func (s *Server) handleKlineWeekAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineWeekAll(code)
 		return err
 	})
@@ -826,7 +856,7 @@ func (s *Server) handleKlineMonth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineMonth(code, start, count)
 		return err
 	})
@@ -844,7 +874,7 @@ func (s *Server) handleKlineMonthAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineMonthAll(code)
 		return err
 	})
@@ -872,7 +902,7 @@ func (s *Server) handleKlineQuarter(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineQuarter(code, start, count)
 		return err
 	})
@@ -890,7 +920,7 @@ func (s *Server) handleKlineQuarterAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineQuarterAll(code)
 		return err
 	})
@@ -918,7 +948,7 @@ func (s *Server) handleKlineYear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineYear(code, start, count)
 		return err
 	})
@@ -936,7 +966,7 @@ func (s *Server) handleKlineYearAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetKlineYearAll(code)
 		return err
 	})
@@ -971,7 +1001,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndex(typ, code, start, count)
 		return err
 	})
@@ -994,7 +1024,7 @@ func (s *Server) handleIndexAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexAll(typ, code)
 		return err
 	})
@@ -1024,7 +1054,7 @@ func (s *Server) handleIndexMinute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexMinute(code, start, count)
 		return err
 	})
@@ -1052,7 +1082,7 @@ func (s *Server) handleIndex5Minute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndex5Minute(code, start, count)
 		return err
 	})
@@ -1080,7 +1110,7 @@ func (s *Server) handleIndex15Minute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndex15Minute(code, start, count)
 		return err
 	})
@@ -1108,7 +1138,7 @@ func (s *Server) handleIndex30Minute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndex30Minute(code, start, count)
 		return err
 	})
@@ -1136,7 +1166,7 @@ func (s *Server) handleIndex60Minute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndex60Minute(code, start, count)
 		return err
 	})
@@ -1164,7 +1194,7 @@ func (s *Server) handleIndexDay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexDay(code, start, count)
 		return err
 	})
@@ -1184,7 +1214,7 @@ func (s *Server) handleIndexDayAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexDayAll(code)
 		return err
 	})
@@ -1202,7 +1232,7 @@ func (s *Server) handleIndexWeekAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexWeekAll(code)
 		return err
 	})
@@ -1220,7 +1250,7 @@ func (s *Server) handleIndexMonthAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexMonthAll(code)
 		return err
 	})
@@ -1238,7 +1268,7 @@ func (s *Server) handleIndexQuarterAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexQuarterAll(code)
 		return err
 	})
@@ -1256,7 +1286,7 @@ func (s *Server) handleIndexYearAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.KlineResp
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetIndexYearAll(code)
 		return err
 	})
@@ -1276,7 +1306,7 @@ func (s *Server) handleBlockData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []*protocol.Block
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetBlockData(file)
 		return err
 	})
@@ -1294,14 +1324,23 @@ func (s *Server) handleBlockDataWithIndex(w http.ResponseWriter, r *http.Request
 		return
 	}
 	var resp []*protocol.Block
-	err = s.pool.Do(func(c *tdx.Client) error {
-		resp, err = c.GetBlockDataWithIndex(file)
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
+		resp, err = c.GetBlockData(file)
 		return err
 	})
 	if err != nil {
 		respondErr(w, http.StatusOK, err.Error())
 		return
 	}
+	//同 Client.GetBlockDataWithIndex,zhb.zip 用共享的缓存
+	files, err := s.zhbFiles()
+	if err != nil {
+		respondErr(w, http.StatusOK, err.Error())
+		return
+	}
+	zs := protocol.ParseTdxZs(files[protocol.FileTdxZs])
+	bk := protocol.ParseTdxBk(files[protocol.FileTdxBk])
+	protocol.FillBlockIndexAlias(resp, zs, bk)
 	respondOK(w, resp)
 }
 
@@ -1312,7 +1351,7 @@ func (s *Server) handleBlockFileRaw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []byte
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetBlockFileRaw(file)
 		return err
 	})
@@ -1330,7 +1369,7 @@ func (s *Server) handleReportFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []byte
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetReportFile(file)
 		return err
 	})
@@ -1341,13 +1380,60 @@ func (s *Server) handleReportFile(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, resp)
 }
 
-func (s *Server) handleZHBFiles(w http.ResponseWriter, r *http.Request) {
-	var resp map[string][]byte
-	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
-		resp, err = c.GetZHBFiles()
-		return err
+// zhbFiles zhb.zip 内的文件。启用缓存时多个路由共用一份,按天过期,
+// 避免 /tdx/zs、/tdx/bk、/tdx/stat 等各自重新下载整个 zhb.zip
+func (s *Server) zhbFiles() (map[string][]byte, error) {
+	download := func() (files map[string][]byte, err error) {
+		err = s.pool.Do(func(c *tdx.Client) error {
+			files, err = c.GetZHBFiles()
+			return err
+		})
+		return
+	}
+	if s.cache == nil {
+		return download()
+	}
+
+	now := time.Now()
+	s.zhbMu.Lock()
+	files, expire := s.zhb, s.zhbExpire
+	s.zhbMu.Unlock()
+	if files != nil && now.Before(expire) {
+		return files, nil
+	}
+
+	type result struct {
+		files map[string][]byte
+		err   error
+	}
+	v, _ := s.cache.group.do("zhb", func() any {
+		files, err := download()
+		if err == nil {
+			s.zhbMu.Lock()
+			s.zhb, s.zhbExpire = files, policyDaily(nil, now)
+			s.zhbMu.Unlock()
+		}
+		return &result{files: files, err: err}
 	})
+	r := v.(*result)
+	return r.files, r.err
+}
+
+// zhbFile zhb.zip 内的单个文件
+func (s *Server) zhbFile(name string) ([]byte, error) {
+	files, err := s.zhbFiles()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s 中缺少 %s", protocol.ReportZHB, name)
+	}
+	return data, nil
+}
+
+func (s *Server) handleZHBFiles(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.zhbFiles()
 	if err != nil {
 		respondErr(w, http.StatusOK, err.Error())
 		return
@@ -1356,79 +1442,54 @@ func (s *Server) handleZHBFiles(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleTdxZs(w http.ResponseWriter, r *http.Request) {
-	var resp []*protocol.TdxZs
-	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
-		resp, err = c.GetTdxZs()
-		return err
-	})
+	data, err := s.zhbFile(protocol.FileTdxZs)
 	if err != nil {
 		respondErr(w, http.StatusOK, err.Error())
 		return
 	}
-	respondOK(w, resp)
+	respondOK(w, protocol.ParseTdxZs(data))
 }
 
 func (s *Server) handleTdxBk(w http.ResponseWriter, r *http.Request) {
-	var resp []*protocol.TdxBk
-	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
-		resp, err = c.GetTdxBk()
-		return err
-	})
+	data, err := s.zhbFile(protocol.FileTdxBk)
 	if err != nil {
 		respondErr(w, http.StatusOK, err.Error())
 		return
 	}
-	respondOK(w, resp)
+	respondOK(w, protocol.ParseTdxBk(data))
 }
 
 func (s *Server) handleTdxStat(w http.ResponseWriter, r *http.Request) {
-	var resp []*protocol.TdxStat
-	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
-		resp, err = c.GetTdxStat()
-		return err
-	})
+	data, err := s.zhbFile(protocol.FileTdxStat)
 	if err != nil {
 		respondErr(w, http.StatusOK, err.Error())
 		return
 	}
-	respondOK(w, resp)
+	respondOK(w, protocol.ParseTdxStat(data))
 }
 
 func (s *Server) handleTdxStat2(w http.ResponseWriter, r *http.Request) {
-	var resp []*protocol.TdxStat2
-	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
-		resp, err = c.GetTdxStat2()
-		return err
-	})
+	data, err := s.zhbFile(protocol.FileTdxStat2)
 	if err != nil {
 		respondErr(w, http.StatusOK, err.Error())
 		return
 	}
-	respondOK(w, resp)
+	respondOK(w, protocol.ParseTdxStat2(data))
 }
 
 func (s *Server) handleTdxXgsg(w http.ResponseWriter, r *http.Request) {
-	var resp []*protocol.TdxXgsg
-	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
-		resp, err = c.GetXgsg()
-		return err
-	})
+	data, err := s.zhbFile(protocol.FileXgsg)
 	if err != nil {
 		respondErr(w, http.StatusOK, err.Error())
 		return
 	}
-	respondOK(w, resp)
+	respondOK(w, protocol.ParseXgsg(data))
 }
 
 func (s *Server) handleTdxHy(w http.ResponseWriter, r *http.Request) {
 	var resp []*protocol.TdxHy
 	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) error {
 		resp, err = c.GetTdxHy()
 		return err
 	})
@@ -1440,15 +1501,10 @@ func (s *Server) handleTdxHy(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleSpBlock(w http.ResponseWriter, r *http.Request) {
-	var resp []*protocol.SpBlock
-	var err error
-	err = s.pool.Do(func(c *tdx.Client) error {
-		resp, err = c.GetSpBlock()
-		return err
-	})
+	data, err := s.zhbFile(protocol.FileSpBlock)
 	if err != nil {
 		respondErr(w, http.StatusOK, err.Error())
 		return
 	}
-	respondOK(w, resp)
+	respondOK(w, protocol.ParseSpBlock(data))
 }
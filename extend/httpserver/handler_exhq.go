@@ -16,7 +16,7 @@ func (s *Server) handleExMarkets(w http.ResponseWriter, r *http.Request) {
 	}
 	var resp []protocol.ExMarket
 	var err error
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExMarkets()
 		return err
 	})
@@ -34,7 +34,7 @@ func (s *Server) handleExCount(w http.ResponseWriter, r *http.Request) {
 	}
 	var resp int
 	var err error
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExCount()
 		return err
 	})
@@ -61,7 +61,7 @@ func (s *Server) handleExInstruments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.ExInstrument
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExInstruments(start, count)
 		return err
 	})
@@ -88,7 +88,7 @@ func (s *Server) handleExQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp *protocol.ExQuote
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExQuote(market, code)
 		return err
 	})
@@ -125,7 +125,7 @@ func (s *Server) handleExQuoteList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.ExQuoteListItem
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExQuoteList(market, category, start, count)
 		return err
 	})
@@ -167,7 +167,7 @@ func (s *Server) handleExBars(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.ExKline
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExBars(category, market, code, start, count)
 		return err
 	})
@@ -194,7 +194,7 @@ func (s *Server) handleExMinute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.ExMinuteTick
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExMinute(market, code)
 		return err
 	})
@@ -226,7 +226,7 @@ func (s *Server) handleExHistMinute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.ExMinuteTick
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExHistMinute(market, code, date)
 		return err
 	})
@@ -263,7 +263,7 @@ func (s *Server) handleExTrade(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.ExTradeTick
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExTrade(market, code, start, count)
 		return err
 	})
@@ -305,7 +305,7 @@ func (s *Server) handleExHistTrade(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.ExTradeTick
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExHistTrade(market, code, date, start, count)
 		return err
 	})
@@ -342,7 +342,7 @@ func (s *Server) handleExBarsRange(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var resp []protocol.ExRangeKline
-	err = s.exPool.Do(func(c *tdx.Client) error {
+	err = tdx.DoContext(r.Context(), s.exPool, func(c *tdx.Client) error {
 		resp, err = c.ExBarsRange(market, code, date, date2)
 		return err
 	})
@@ -0,0 +1,356 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	quoteBatch = 80  //单次请求的行情数量
+	tradeCount = 100 //每次轮询的最新成交条数
+)
+
+// ---- 轮询与比较 ----
+
+// normalizeCode 标准行情只支持A股6位代码,统一加上交易所前缀
+func normalizeCode(code string) (string, error) {
+	code = protocol.AddPrefix(strings.TrimSpace(code))
+	ex, c, err := protocol.DecodeCode(code)
+	if err != nil {
+		return "", err
+	}
+	switch ex {
+	case protocol.ExchangeSH, protocol.ExchangeSZ, protocol.ExchangeBJ:
+		if len(c) == 6 {
+			return ex.String() + c, nil
+		}
+	}
+	return "", fmt.Errorf("不支持的代码: %s (扩展行情使用 /ws/ex/quote)", code)
+}
+
+// normalizeExCode 扩展行情代码,格式 市场:代码,例 47:IF2609
+func normalizeExCode(code string) (string, error) {
+	_, _, err := decodeExCode(code)
+	return strings.TrimSpace(code), err
+}
+
+func decodeExCode(s string) (uint8, string, error) {
+	m, code, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || code == "" {
+		return 0, "", fmt.Errorf("扩展行情代码格式错误: %s (例 47:IF2609)", s)
+	}
+	market, err := strconv.ParseUint(m, 10, 8)
+	if err != nil {
+		return 0, "", fmt.Errorf("扩展行情代码格式错误: %s (例 47:IF2609)", s)
+	}
+	return uint8(market), code, nil
+}
+
+// pollQuote 按批获取行情,一批失败时逐个重新获取,找出失败的代码,其他代码照常推送
+func (s *Server) pollQuote(codes []string) (map[string]any, error) {
+	out := make(map[string]any, len(codes))
+	errs := pollErrors{}
+	get := func(ls []string) error {
+		var resp protocol.QuotesResp
+		err := s.pool.Do(func(c *tdx.Client) (err error) {
+			resp, err = c.GetQuote(append([]string(nil), ls...)...)
+			return
+		})
+		if err != nil {
+			return err
+		}
+		for _, q := range resp {
+			out[q.Exchange.String()+q.Code] = q
+		}
+		return nil
+	}
+	for i := 0; i < len(codes); i += quoteBatch {
+		ls := codes[i:min(i+quoteBatch, len(codes))]
+		switch err := get(ls); {
+		case err == nil:
+		case len(ls) == 1:
+			errs[ls[0]] = err
+		default:
+			for _, code := range ls {
+				if err := get([]string{code}); err != nil {
+					errs[code] = err
+				}
+			}
+		}
+	}
+	return out, errs.err()
+}
+
+func (s *Server) pollMinute(codes []string) (map[string]any, error) {
+	out := make(map[string]any, len(codes))
+	errs := pollErrors{}
+	for _, code := range codes {
+		var resp *protocol.MinuteResp
+		err := s.pool.Do(func(c *tdx.Client) (err error) {
+			resp, err = c.GetMinute(code)
+			return
+		})
+		if err != nil {
+			errs[code] = err
+			continue
+		}
+		out[code] = resp.List
+	}
+	return out, errs.err()
+}
+
+func (s *Server) pollTrade(codes []string) (map[string]any, error) {
+	out := make(map[string]any, len(codes))
+	errs := pollErrors{}
+	for _, code := range codes {
+		var resp *protocol.TradeResp
+		err := s.pool.Do(func(c *tdx.Client) (err error) {
+			resp, err = c.GetMinuteTrade(code, 0, tradeCount)
+			return
+		})
+		if err != nil {
+			errs[code] = err
+			continue
+		}
+		out[code] = resp.List
+	}
+	return out, errs.err()
+}
+
+func (s *Server) pollExQuote(codes []string) (map[string]any, error) {
+	out := make(map[string]any, len(codes))
+	errs := pollErrors{}
+	for _, code := range codes {
+		market, c2, err := decodeExCode(code)
+		if err != nil {
+			errs[code] = err
+			continue
+		}
+		var resp *protocol.ExQuote
+		err = s.exPool.Do(func(c *tdx.Client) (err error) {
+			resp, err = c.ExQuote(market, c2)
+			return
+		})
+		if err != nil {
+			errs[code] = err
+			continue
+		}
+		out[code] = resp
+	}
+	return out, errs.err()
+}
+
+// diffSnapshot 整体比较(行情),有变化推送最新值
+func diffSnapshot(old, new any) any {
+	if old == nil {
+		return new
+	}
+	a, _ := json.Marshal(old)
+	b, _ := json.Marshal(new)
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	return new
+}
+
+// minuteDiff 分时变化,Start 为 List 第一条在当天分时中的序号
+type minuteDiff struct {
+	Start int                    `json:"start"`
+	List  []protocol.PriceNumber `json:"list"`
+}
+
+// diffMinute 从第一条不同的分时开始推送(最后一分钟会持续变化)
+func diffMinute(old, new any) any {
+	n := new.([]protocol.PriceNumber)
+	if old == nil {
+		return &minuteDiff{List: n}
+	}
+	o := old.([]protocol.PriceNumber)
+	i := 0
+	for i < len(o) && i < len(n) && o[i] == n[i] {
+		i++
+	}
+	if i == len(n) && len(o) == len(n) {
+		return nil
+	}
+	return &minuteDiff{Start: i, List: n[i:]}
+}
+
+// diffTrade 推送新增的成交。每次取最新的N条,按上一次结果的尾部和这一次的头部对齐找出新增部分,
+// 对不齐(间隔内成交超过N条)时推送全部N条
+func diffTrade(old, new any) any {
+	n := new.(protocol.Trades)
+	if old == nil {
+		return n
+	}
+	o := old.(protocol.Trades)
+	for k := min(len(o), len(n)); k > 0; k-- {
+		if equalTrades(o[len(o)-k:], n[:k]) {
+			if k == len(n) {
+				return nil
+			}
+			return n[k:]
+		}
+	}
+	if len(n) == 0 {
+		return nil
+	}
+	return n
+}
+
+func equalTrades(a, b protocol.Trades) bool {
+	for i := range a {
+		if !a[i].Time.Equal(b[i].Time) || a[i].Price != b[i].Price || a[i].Volume != b[i].Volume ||
+			a[i].Status != b[i].Status || a[i].Number != b[i].Number {
+			return false
+		}
+	}
+	return true
+}
+
+// ---- WebSocket/SSE ----
+
+// splitCodes 拆分逗号分隔的代码
+func splitCodes(s string) []string {
+	ls := []string(nil)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ls = append(ls, v)
+		}
+	}
+	return ls
+}
+
+// handleWS WebSocket 推送,连接参数 codes 为初始订阅,
+// 之后可发送 {"action":"subscribe|unsubscribe","codes":[...]} 调整订阅
+func (s *Server) handleWS(hub *pushHub) http.Handler {
+	//不用 websocket.Handler,它会拒绝没有 Origin 的非浏览器客户端
+	return websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		sub := hub.add()
+		defer hub.remove(sub)
+		if err := hub.subscribe(sub, splitCodes(ws.Request().URL.Query().Get("codes"))...); err != nil {
+			_ = websocket.JSON.Send(ws, &pushMessage{Type: "error", Data: err.Error()})
+			return
+		}
+
+		go func() {
+			defer hub.remove(sub)
+			for {
+				req := new(pushRequest)
+				if err := websocket.JSON.Receive(ws, req); err != nil {
+					return
+				}
+				var err error
+				switch req.Action {
+				case "subscribe":
+					err = hub.subscribe(sub, req.Codes...)
+				case "unsubscribe":
+					hub.unsubscribe(sub, req.Codes...)
+				default:
+					err = fmt.Errorf("未知的操作: %s (可选: subscribe, unsubscribe)", req.Action)
+				}
+				if err != nil && !hub.notify(sub, &pushMessage{Type: "error", Data: err.Error()}) {
+					return
+				}
+			}
+		}()
+
+		for msg := range sub.C {
+			if err := websocket.JSON.Send(ws, msg); err != nil {
+				return
+			}
+		}
+	}}
+}
+
+// handleSSE SSE 推送,连接参数 codes 为初始订阅,第一条消息(event: id)为订阅id,
+// 之后用 POST 同一路径 ?id=&action=subscribe|unsubscribe&codes= 调整订阅
+func (s *Server) handleSSE(hub *pushHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			s.handleSSESubscribe(hub, w, r)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			respondErr(w, http.StatusInternalServerError, "不支持SSE")
+			return
+		}
+		sub := hub.add()
+		defer hub.remove(sub)
+		if err := hub.subscribe(sub, splitCodes(r.URL.Query().Get("codes"))...); err != nil {
+			respondErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		fmt.Fprintf(w, "event: id\ndata: %s\n\n", sub.id)
+		flusher.Flush()
+
+		t := time.NewTicker(pushKeepalive)
+		defer t.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-t.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case msg, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				bs, _ := json.Marshal(msg)
+				if _, err := fmt.Fprintf(w, "data: %s\n\n", bs); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func (s *Server) handleSSESubscribe(hub *pushHub, w http.ResponseWriter, r *http.Request) {
+	id, err := queryStr(r, "id")
+	if err != nil {
+		respondErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	codesStr, err := queryStr(r, "codes")
+	if err != nil {
+		respondErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sub := hub.get(id)
+	if sub == nil {
+		respondErr(w, http.StatusNotFound, "订阅不存在或已断开: "+id)
+		return
+	}
+	switch action := r.URL.Query().Get("action"); action {
+	case "", "subscribe":
+		err = hub.subscribe(sub, splitCodes(codesStr)...)
+	case "unsubscribe":
+		hub.unsubscribe(sub, splitCodes(codesStr)...)
+	default:
+		err = fmt.Errorf("未知的操作: %s (可选: subscribe, unsubscribe)", action)
+	}
+	if err != nil {
+		respondErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondOK(w, nil)
+}
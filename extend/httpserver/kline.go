@@ -0,0 +1,293 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 复权、日期范围和周期
+//
+// K线和指数K线路由支持以下可选参数,都不带时和原来一样直接返回通达信的数据:
+//
+//	fq=qfq|hfq|none     复权(仅股票K线),默认 none,对齐通达信,见 protocol.PreKlines.Factors
+//	from=YYYYMMDD       起始日期(含),带 from/to 时不需要 start/count
+//	to=YYYYMMDD         结束日期(含),默认到最新
+//	period=day          /kline、/kline/all、/index、/index/all 用来代替 type,见 protocol.KlinePeriods
+//
+// 复权因子由服务端的 tdx.Gbbq(每天更新,见 WithGbbq)和全部日线计算,每个代码每天算一次。
+// 分钟线按所在交易日的因子复权;周/月/季/年线由复权后的日线合成,和通达信一致。
+
+const (
+	fqNone = "none"
+	fqQFQ  = "qfq"
+	fqHFQ  = "hfq"
+)
+
+var (
+	pFq     = param{Name: "fq", Kind: kindString, Desc: "复权方式,qfq=前复权 hfq=后复权 none=不复权", Example: fqQFQ, Optional: true, Enum: []string{fqNone, fqQFQ, fqHFQ}}
+	pFrom   = param{Name: "from", Kind: kindDate, Desc: "起始日期 YYYYMMDD(含)", Example: "20240102", Optional: true}
+	pTo     = param{Name: "to", Kind: kindDate, Desc: "结束日期 YYYYMMDD(含),默认到最新", Example: "20241231", Optional: true}
+	pPeriod = param{Name: "period", Kind: kindString, Desc: "K线周期,代替 type", Example: "day", Optional: true,
+		Enum: []string{"1m", "5m", "15m", "30m", "60m", "day", "week", "month", "quarter", "year"}}
+
+	// 带 from/to 时分页参数可以不带
+	pRangeStart = param{Name: "start", Kind: kindUint16, Desc: "起始位置,0为最新,带 from/to 时不需要", Example: "0", Unless: []string{"from", "to"}}
+	pRangeCount = param{Name: "count", Kind: kindUint16, Desc: "获取数量,带 from/to 时不需要", Example: "100", Unless: []string{"from", "to"}}
+	// 带 period 时可以不带 type
+	pPeriodType = param{Name: "type", Kind: pType.Kind, Desc: pType.Desc + ",带 period 时不需要", Example: pType.Example, Enum: pType.Enum, Unless: []string{"period"}}
+)
+
+// klineRoute K线路由的类型,typ 为 0 且 byType 时按请求的 type/period
+type klineRoute struct {
+	typ    uint8
+	byType bool //按 type/period 参数
+	index  bool //指数
+	all    bool //全部,没有分页参数
+}
+
+// klineQuery 一次请求的参数
+type klineQuery struct {
+	code   string
+	typ    uint8
+	index  bool
+	fq     string
+	from   time.Time //为零时不限
+	to     time.Time //不含,为零时不限
+	ranged bool      //带 from/to
+	all    bool
+	start  uint16
+	count  uint16
+}
+
+// requestType 请求的K线类型,period 优先
+func requestType(r *http.Request) (uint8, error) {
+	q := r.URL.Query()
+	if p := q.Get("period"); p != "" {
+		typ, ok := protocol.KlinePeriods[strings.ToLower(p)]
+		if !ok {
+			return 0, fmt.Errorf("参数 period 取值错误: %s", p)
+		}
+		return typ, nil
+	}
+	return queryUint8(r, "type")
+}
+
+// parseDate YYYYMMDD
+func parseDate(s string) (time.Time, error) {
+	return time.ParseInLocation("20060102", s, time.Local)
+}
+
+// withKline 带复权、日期范围或周期参数时由 serveKline 处理,否则使用原来的 h
+func (s *Server) withKline(rt klineRoute, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		fq := strings.ToLower(q.Get("fq"))
+		if rt.index {
+			fq = "" //指数没有复权
+		}
+		if (fq == "" || fq == fqNone) && q.Get("from") == "" && q.Get("to") == "" && q.Get("period") == "" {
+			h(w, r)
+			return
+		}
+
+		kq := &klineQuery{typ: rt.typ, index: rt.index, fq: fq, all: rt.all}
+		var err error
+		if rt.byType {
+			if kq.typ, err = requestType(r); err != nil {
+				respondErr(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if kq.typ > protocol.TypeKlineYear {
+				respondErr(w, http.StatusBadRequest, fmt.Sprintf("参数 type 取值错误: %d", kq.typ))
+				return
+			}
+		}
+		if kq.code, err = queryStr(r, "code"); err != nil {
+			respondErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if v := q.Get("from"); v != "" {
+			kq.from, _ = parseDate(v) //已校验
+			kq.ranged = true
+		}
+		if v := q.Get("to"); v != "" {
+			to, _ := parseDate(v)
+			kq.to = to.AddDate(0, 0, 1)
+			kq.ranged = true
+		}
+		if !kq.from.IsZero() && !kq.to.IsZero() && !kq.from.Before(kq.to) {
+			respondErr(w, http.StatusBadRequest, "参数 from 不能晚于 to")
+			return
+		}
+		if !kq.ranged && !kq.all {
+			if kq.start, err = queryUint16(r, "start"); err != nil {
+				respondErr(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if kq.count, err = queryUint16(r, "count"); err != nil {
+				respondErr(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		s.serveKline(w, r, kq)
+	}
+}
+
+// serveKline 获取K线,按需复权和截取日期范围
+func (s *Server) serveKline(w http.ResponseWriter, r *http.Request, kq *klineQuery) {
+	var fs []*protocol.Factor
+	var ks protocol.Klines
+	adjust := kq.fq == fqQFQ || kq.fq == fqHFQ
+	//周及以上的复权K线由复权后的日线合成
+	merge := adjust && protocol.KlineGroup(kq.typ) != nil
+	err := tdx.DoContext(r.Context(), s.pool, func(c *tdx.Client) (err error) {
+		if adjust {
+			if fs, err = s.factors(c, kq.code); err != nil {
+				return err
+			}
+		}
+		switch {
+		case merge:
+			//分页时不知道需要多少日线,获取全部;有 from 时从 from 所在周期的第一天开始
+			group := protocol.KlineGroup(kq.typ)
+			ks, err = s.fetchKline(c, kq, protocol.TypeKlineDay, func(k *protocol.Kline) bool {
+				return kq.ranged && kq.before(k) && group(k.Time) != group(kq.from)
+			})
+		case kq.ranged || kq.all:
+			ks, err = s.fetchKline(c, kq, kq.typ, kq.before)
+		default:
+			ks, err = s.fetchKline(c, kq, kq.typ, nil)
+		}
+		return
+	})
+	if err != nil {
+		respondErr(w, http.StatusOK, err.Error())
+		return
+	}
+
+	if adjust {
+		ks = protocol.ApplyFactors(ks, fs, kq.fq == fqQFQ)
+	}
+	if merge {
+		ks = protocol.MergeKlines(ks, protocol.KlineGroup(kq.typ))
+		if !kq.ranged && !kq.all {
+			ks = pageKlines(ks, kq.start, kq.count)
+		}
+	}
+	if kq.ranged {
+		ks = rangeKlines(ks, kq.from, kq.to)
+	}
+	respondOK(w, &protocol.KlineResp{Count: uint16(len(ks)), List: ks})
+}
+
+// fetchKline 获取不复权的K线,until 不为 nil 时多次获取直到 until 返回 true,否则按分页
+func (s *Server) fetchKline(c *tdx.Client, kq *klineQuery, typ uint8, until func(k *protocol.Kline) bool) (protocol.Klines, error) {
+	var resp *protocol.KlineResp
+	var err error
+	switch {
+	case until != nil && kq.index:
+		resp, err = c.GetIndexUntil(typ, kq.code, until)
+	case until != nil:
+		resp, err = c.GetKlineUntil(typ, kq.code, until)
+	case kq.index:
+		resp, err = c.GetIndex(typ, kq.code, kq.start, kq.count)
+	default:
+		resp, err = c.GetKline(typ, kq.code, kq.start, kq.count)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.List, nil
+}
+
+// before 早于 from,用于 GetKlineUntil 停止
+func (this *klineQuery) before(k *protocol.Kline) bool {
+	return !this.from.IsZero() && k.Time.Before(this.from)
+}
+
+// rangeKlines 截取 [from,to) 的K线,ks 按时间升序
+func rangeKlines(ks protocol.Klines, from, to time.Time) protocol.Klines {
+	i := sort.Search(len(ks), func(i int) bool { return from.IsZero() || !ks[i].Time.Before(from) })
+	j := sort.Search(len(ks), func(j int) bool { return !to.IsZero() && !ks[j].Time.Before(to) })
+	if i >= j {
+		return protocol.Klines{}
+	}
+	return ks[i:j]
+}
+
+// pageKlines 同通达信的分页,start 为从最新往前的偏移
+func pageKlines(ks protocol.Klines, start, count uint16) protocol.Klines {
+	end := len(ks) - int(start)
+	if end <= 0 {
+		return protocol.Klines{}
+	}
+	return ks[max(end-int(count), 0):end]
+}
+
+// compactFactors 只保留因子变化的交易日(除权除息日),protocol.ApplyFactors 按交易日查找
+func compactFactors(fs []*protocol.Factor) []*protocol.Factor {
+	res := []*protocol.Factor(nil)
+	for _, f := range fs {
+		if n := len(res); n > 0 && res[n-1].QFQMul == f.QFQMul && res[n-1].QFQAdd == f.QFQAdd {
+			continue
+		}
+		res = append(res, f)
+	}
+	return res
+}
+
+// factors code 的复权因子,每个代码每天计算一次
+func (s *Server) factors(c *tdx.Client, code string) ([]*protocol.Factor, error) {
+	code = protocol.AddPrefix(code)
+	today := time.Now().Format(time.DateOnly)
+	s.factorMu.Lock()
+	if s.factorDate != today {
+		s.factorDate, s.factorMap = today, map[string][]*protocol.Factor{}
+	}
+	fs, ok := s.factorMap[code]
+	s.factorMu.Unlock()
+	if ok {
+		return fs, nil
+	}
+
+	g, err := s.loadGbbq()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.GetKlineDayAll(code)
+	if err != nil {
+		return nil, err
+	}
+	fs = compactFactors(g.GetFactors(code, resp.List))
+
+	s.factorMu.Lock()
+	if s.factorDate == today {
+		s.factorMap[code] = fs
+	}
+	s.factorMu.Unlock()
+	return fs, nil
+}
+
+// loadGbbq 服务端的除权除息数据,未通过 WithGbbq 设置时第一次复权请求时创建,失败下次重试
+func (s *Server) loadGbbq() (tdx.IGbbq, error) {
+	s.gbbqMu.Lock()
+	defer s.gbbqMu.Unlock()
+	if s.gbbq != nil {
+		return s.gbbq, nil
+	}
+	if s.dialGbbq == nil {
+		return nil, fmt.Errorf("未设置除权除息数据,不支持复权")
+	}
+	g, err := s.dialGbbq()
+	if err != nil {
+		return nil, err
+	}
+	s.gbbq = g
+	return g, nil
+}
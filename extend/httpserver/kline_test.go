@@ -0,0 +1,86 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+func testDay(date string, open, close float64) *protocol.Kline {
+	t, _ := time.ParseInLocation("20060102 15:04", date+" 15:00", time.Local)
+	return &protocol.Kline{Time: t, Open: protocol.Yuan(open), High: protocol.Yuan(max(open, close)),
+		Low: protocol.Yuan(min(open, close)), Close: protocol.Yuan(close), Volume: 100}
+}
+
+func TestKlineRange(t *testing.T) {
+	ks := protocol.Klines{
+		testDay("20240102", 10, 11), testDay("20240103", 11, 12), testDay("20240104", 12, 13),
+		testDay("20240108", 13, 14), testDay("20240109", 14, 15),
+	}
+	from, _ := parseDate("20240103")
+	to, _ := parseDate("20240108")
+	if r := rangeKlines(ks, from, to.AddDate(0, 0, 1)); len(r) != 3 || r[0] != ks[1] || r[2] != ks[3] {
+		t.Errorf("range = %v", r)
+	}
+	if r := rangeKlines(ks, time.Time{}, from); len(r) != 1 {
+		t.Errorf("range to = %v", r)
+	}
+	if r := pageKlines(ks, 1, 2); len(r) != 2 || r[0] != ks[2] || r[1] != ks[3] {
+		t.Errorf("page = %v", r)
+	}
+	if r := pageKlines(ks, 4, 10); len(r) != 1 || r[0] != ks[0] {
+		t.Errorf("page = %v", r)
+	}
+
+}
+
+func TestKlineFactors(t *testing.T) {
+	ks := protocol.Klines{testDay("20240102", 10, 10), testDay("20240103", 10, 10), testDay("20240104", 9, 9), testDay("20240105", 9, 9)}
+	for i := 1; i < len(ks); i++ {
+		ks[i].Last = ks[i-1].Close
+	}
+	//20240104 10股派10元
+	ex, _ := parseDate("20240104")
+	fs := protocol.XRXDs{{Time: ex, Fenhong: 10}}.Pre(ks).Factors()
+	cfs := compactFactors(fs)
+	if len(cfs) != 2 {
+		t.Fatalf("factors = %d", len(cfs))
+	}
+
+	if cfs[0] != fs[0] || !cfs[1].Time.Equal(ks[2].Time) {
+		t.Errorf("compact = %v %v", cfs[0].Time, cfs[1].Time)
+	}
+}
+
+func TestKlineParams(t *testing.T) {
+	mux := newTestMux(&Server{pool: &fakePool{}})
+	for _, c := range []struct {
+		url  string
+		code int //响应中的 code,1 为连接池的错误,400 为参数错误
+	}{
+		{"/kline/day?code=sz000001", http.StatusBadRequest},
+		{"/kline/day?code=sz000001&from=20240101", 1},
+		{"/kline/day?code=sz000001&start=0&count=10&fq=qfq", 1},
+		{"/kline/day?code=sz000001&start=0&count=10&fq=xx", http.StatusBadRequest},
+		{"/kline/day?code=sz000001&from=20240201&to=20240101", http.StatusBadRequest},
+		{"/kline?code=sz000001&start=0&count=10", http.StatusBadRequest},
+		{"/kline?code=sz000001&start=0&count=10&period=week", 1},
+		{"/kline?code=sz000001&start=0&count=10&period=2h", http.StatusBadRequest},
+		{"/index/all?code=sh000001&period=day&from=20240101", 1},
+	} {
+		w := doGet(mux, c.url)
+		if c.code == http.StatusBadRequest {
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: %d %s", c.url, w.Code, w.Body.String())
+			}
+			continue
+		}
+		resp := Response{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || resp.Code != c.code {
+			t.Errorf("%s: %d %s", c.url, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 监控和链路追踪
+//
+// GET /metrics 为 Prometheus 文本格式(不依赖 client_golang),默认开启,WithMetrics(false) 关闭:
+//
+//	tdx_requests_total{pool,type,result}          通达信请求数,result 为 ok/error/timeout
+//	tdx_request_duration_seconds{pool,type}       通达信请求耗时
+//	tdx_bytes_total{pool,type,direction}          发送(sent)/接收(received)字节数
+//	tdx_decode_errors_total{pool,type}            响应解析失败次数
+//	tdx_connects_total{pool,reconnect}            连接次数,reconnect=true 为断线重连
+//	tdx_disconnects_total{pool}                   断开次数
+//	tdx_pool_wait_seconds{pool}                   从连接池获取连接的等待时间
+//	http_requests_total{route,method,status}      HTTP 请求数
+//	http_request_duration_seconds{route}          HTTP 请求耗时
+//	http_cache_total{route,result}                响应缓存命中(hit)/未命中(miss)
+//
+// pool 为 std(标准行情)或 ex(扩展行情)。WithTracer 设置后,每个 HTTP 请求创建一个 span,
+// 请求中的每个通达信请求创建一个子 span(通过 tdx.DoContext 传递上下文)。
+
+// Tracer 链路追踪,按 OpenTelemetry 的 trace.Tracer 简化,可以用几行代码适配
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span 一个 span
+type Span interface {
+	SetAttribute(key string, value any)
+	End(err error)
+}
+
+var (
+	durationBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	waitBuckets     = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+)
+
+// metricVec 一个指标,按标签值区分序列
+type metricVec struct {
+	name    string
+	help    string
+	typ     string //counter/histogram
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	values  []string
+	value   float64  //counter 的值,histogram 的 sum
+	count   uint64   //histogram 的数量
+	buckets []uint64 //histogram 各桶(非累计)
+}
+
+func newCounter(name, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, typ: "counter", labels: labels, series: map[string]*metricSeries{}}
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, typ: "histogram", labels: labels, buckets: buckets, series: map[string]*metricSeries{}}
+}
+
+func (this *metricVec) get(values []string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	s := this.series[key]
+	if s == nil {
+		s = &metricSeries{values: append([]string(nil), values...), buckets: make([]uint64, len(this.buckets))}
+		this.series[key] = s
+	}
+	return s
+}
+
+// add counter 加 v
+func (this *metricVec) add(v float64, values ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.get(values).value += v
+}
+
+func (this *metricVec) inc(values ...string) {
+	this.add(1, values...)
+}
+
+// observe histogram 记录一个值
+func (this *metricVec) observe(v float64, values ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	s := this.get(values)
+	s.value += v
+	s.count++
+	if i := sort.SearchFloat64s(this.buckets, v); i < len(this.buckets) {
+		s.buckets[i]++
+	}
+}
+
+// labelString {a="1",b="2"},extra 为额外的标签(如 le)
+func (this *metricVec) labelString(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	b := strings.Builder{}
+	b.WriteByte('{')
+	write := func(k, v string) {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v))
+		b.WriteByte('"')
+	}
+	for i, k := range this.labels {
+		write(k, values[i])
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		write(extra[i], extra[i+1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// write 按 Prometheus 文本格式输出
+func (this *metricVec) write(w io.Writer) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", this.name, this.help, this.name, this.typ)
+	keys := make([]string, 0, len(this.series))
+	for k := range this.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := this.series[k]
+		if this.typ == "counter" {
+			fmt.Fprintf(w, "%s%s %s\n", this.name, this.labelString(s.values), formatFloat(s.value))
+			continue
+		}
+		var n uint64
+		for i, le := range this.buckets {
+			n += s.buckets[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", this.name, this.labelString(s.values, "le", formatFloat(le)), n)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", this.name, this.labelString(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", this.name, this.labelString(s.values), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", this.name, this.labelString(s.values), s.count)
+	}
+}
+
+// metrics 全部指标
+type metrics struct {
+	tdxRequests     *metricVec
+	tdxDuration     *metricVec
+	tdxBytes        *metricVec
+	tdxDecodeErrors *metricVec
+	tdxConnects     *metricVec
+	tdxDisconnects  *metricVec
+	poolWait        *metricVec
+	httpRequests    *metricVec
+	httpDuration    *metricVec
+	httpCache       *metricVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		tdxRequests:     newCounter("tdx_requests_total", "通达信请求数", "pool", "type", "result"),
+		tdxDuration:     newHistogram("tdx_request_duration_seconds", "通达信请求耗时", durationBuckets, "pool", "type"),
+		tdxBytes:        newCounter("tdx_bytes_total", "通达信请求的字节数", "pool", "type", "direction"),
+		tdxDecodeErrors: newCounter("tdx_decode_errors_total", "通达信响应解析失败次数", "pool", "type"),
+		tdxConnects:     newCounter("tdx_connects_total", "通达信连接次数", "pool", "reconnect"),
+		tdxDisconnects:  newCounter("tdx_disconnects_total", "通达信断开次数", "pool"),
+		poolWait:        newHistogram("tdx_pool_wait_seconds", "从连接池获取连接的等待时间", waitBuckets, "pool"),
+		httpRequests:    newCounter("http_requests_total", "HTTP 请求数", "route", "method", "status"),
+		httpDuration:    newHistogram("http_request_duration_seconds", "HTTP 请求耗时", durationBuckets, "route"),
+		httpCache:       newCounter("http_cache_total", "响应缓存命中次数", "route", "result"),
+	}
+}
+
+func (this *metrics) write(w io.Writer) {
+	for _, m := range []*metricVec{
+		this.tdxRequests, this.tdxDuration, this.tdxBytes, this.tdxDecodeErrors, this.tdxConnects,
+		this.tdxDisconnects, this.poolWait, this.httpRequests, this.httpDuration, this.httpCache,
+	} {
+		m.write(w)
+	}
+}
+
+// spanKey 上下文中的 span
+type spanKey struct{}
+
+// serverHook 连接和连接池的回调,记录指标和创建 span
+type serverHook struct {
+	pool    string
+	metrics *metrics
+	tracer  Tracer
+}
+
+func (this *serverHook) RequestStart(ctx context.Context, info *tdx.RequestInfo) context.Context {
+	if this.tracer == nil {
+		return ctx
+	}
+	ctx, span := this.tracer.Start(ctx, "tdx "+protocol.TypeName(info.Type))
+	span.SetAttribute("tdx.pool", this.pool)
+	span.SetAttribute("tdx.type", protocol.TypeName(info.Type))
+	span.SetAttribute("tdx.msg_id", info.MsgID)
+	span.SetAttribute("tdx.addr", info.Addr)
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (this *serverHook) RequestFinish(ctx context.Context, info *tdx.RequestInfo) {
+	typ := protocol.TypeName(info.Type)
+	if m := this.metrics; m != nil {
+		result := "ok"
+		switch {
+		case info.Timeout:
+			result = "timeout"
+		case info.Err != nil:
+			result = "error"
+		}
+		m.tdxRequests.inc(this.pool, typ, result)
+		m.tdxDuration.observe(info.Duration.Seconds(), this.pool, typ)
+		m.tdxBytes.add(float64(info.Sent), this.pool, typ, "sent")
+		m.tdxBytes.add(float64(info.Received), this.pool, typ, "received")
+	}
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		span.SetAttribute("tdx.sent", info.Sent)
+		span.SetAttribute("tdx.received", info.Received)
+		span.End(info.Err)
+	}
+}
+
+func (this *serverHook) DecodeError(typ uint16, err error) {
+	if this.metrics != nil {
+		this.metrics.tdxDecodeErrors.inc(this.pool, protocol.TypeName(typ))
+	}
+}
+
+func (this *serverHook) Connected(addr string, reconnect bool) {
+	if this.metrics != nil {
+		this.metrics.tdxConnects.inc(this.pool, strconv.FormatBool(reconnect))
+	}
+}
+
+func (this *serverHook) Disconnected(addr string, err error) {
+	if this.metrics != nil {
+		this.metrics.tdxDisconnects.inc(this.pool)
+	}
+}
+
+func (this *serverHook) PoolWait(d time.Duration, err error) {
+	if this.metrics != nil {
+		this.metrics.poolWait.observe(d.Seconds(), this.pool)
+	}
+}
+
+// observed 给路由加上 HTTP 指标和 span,都未启用时原样返回
+func (s *Server) observed(route string, h http.HandlerFunc) http.HandlerFunc {
+	if s.metrics == nil && s.tracer == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var span Span
+		if s.tracer != nil {
+			var ctx context.Context
+			ctx, span = s.tracer.Start(r.Context(), r.Method+" "+route)
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.route", route)
+			span.SetAttribute("http.target", r.URL.RequestURI())
+			r = r.WithContext(ctx)
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		h(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		if m := s.metrics; m != nil {
+			m.httpRequests.inc(route, r.Method, strconv.Itoa(sw.status))
+			m.httpDuration.observe(time.Since(start).Seconds(), route)
+			if c := sw.Header().Get("X-Cache"); c != "" {
+				m.httpCache.inc(route, strings.ToLower(c))
+			}
+		}
+		if span != nil {
+			span.SetAttribute("http.status_code", sw.status)
+			var err error
+			if sw.status >= http.StatusInternalServerError {
+				err = fmt.Errorf("HTTP %d", sw.status)
+			}
+			span.End(err)
+		}
+	}
+}
+
+// handleMetrics Prometheus 指标
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	s.metrics.write(bw)
+	bw.Flush()
+}
@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+type fakeSpanKey struct{}
+
+type fakeSpan struct {
+	name   string
+	parent *fakeSpan
+	attrs  map[string]any
+	ended  bool
+	err    error
+}
+
+func (this *fakeSpan) SetAttribute(key string, value any) { this.attrs[key] = value }
+
+func (this *fakeSpan) End(err error) { this.ended, this.err = true, err }
+
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (this *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	parent, _ := ctx.Value(fakeSpanKey{}).(*fakeSpan)
+	span := &fakeSpan{name: name, parent: parent, attrs: map[string]any{}}
+	this.spans = append(this.spans, span)
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func TestMetricVec(t *testing.T) {
+	c := newCounter("test_total", "测试", "a")
+	c.inc(`x"y`)
+	c.add(2, `x"y`)
+	h := newHistogram("test_seconds", "测试", []float64{.1, 1}, "a")
+	h.observe(.05, "x")
+	h.observe(.5, "x")
+	h.observe(5, "x")
+
+	buf := new(bytes.Buffer)
+	c.write(buf)
+	h.write(buf)
+	want := `# HELP test_total 测试
+# TYPE test_total counter
+test_total{a="x\"y"} 3
+# HELP test_seconds 测试
+# TYPE test_seconds histogram
+test_seconds_bucket{a="x",le="0.1"} 1
+test_seconds_bucket{a="x",le="1"} 2
+test_seconds_bucket{a="x",le="+Inf"} 3
+test_seconds_sum{a="x"} 5.55
+test_seconds_count{a="x"} 3
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s", buf.String())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	s := &Server{pool: &fakePool{}, metrics: newMetrics()}
+	mux := newTestMux(s)
+	doGet(mux, "/count?exchange=sh")
+	doGet(mux, "/count?exchange=xx")
+
+	hook := &serverHook{pool: "std", metrics: s.metrics}
+	hook.RequestFinish(context.Background(), &tdx.RequestInfo{Type: protocol.TypeKline, Sent: 40, Received: 1000, Duration: time.Millisecond})
+	hook.RequestFinish(context.Background(), &tdx.RequestInfo{Type: protocol.TypeKline, Timeout: true, Err: errors.New("超时")})
+	hook.Connected("127.0.0.1:7709", true)
+
+	w := doGet(mux, "/metrics")
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Content-Type = %s", w.Header().Get("Content-Type"))
+	}
+	for _, line := range []string{
+		`http_requests_total{route="/count",method="GET",status="200"} 1`,
+		`http_requests_total{route="/count",method="GET",status="400"} 1`,
+		`http_request_duration_seconds_count{route="/count"} 2`,
+		`tdx_requests_total{pool="std",type="kline",result="ok"} 1`,
+		`tdx_requests_total{pool="std",type="kline",result="timeout"} 1`,
+		`tdx_bytes_total{pool="std",type="kline",direction="received"} 1000`,
+		`tdx_connects_total{pool="std",reconnect="true"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("缺少 %s", line)
+		}
+	}
+	//指标接口本身不统计
+	if strings.Contains(w.Body.String(), `route="/metrics"`) {
+		t.Error("不应统计 /metrics")
+	}
+}
+
+func TestTracing(t *testing.T) {
+	tracer := &fakeTracer{}
+	s := &Server{pool: &fakePool{}, tracer: tracer}
+	doGet(newTestMux(s), "/count?exchange=sh")
+	if len(tracer.spans) != 1 {
+		t.Fatalf("spans = %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.name != "GET /count" || !span.ended || span.attrs["http.status_code"] != 200 {
+		t.Errorf("http span = %+v", span)
+	}
+
+	//通达信请求的 span 是 HTTP 请求 span 的子 span
+	ctx, parent := tracer.Start(context.Background(), "GET /kline")
+	hook := &serverHook{pool: "std", tracer: tracer}
+	info := &tdx.RequestInfo{Type: protocol.TypeKline}
+	ctx = hook.RequestStart(ctx, info)
+	info.Err = errors.New("超时")
+	hook.RequestFinish(ctx, info)
+	child := tracer.spans[len(tracer.spans)-1]
+	if child.name != "tdx kline" || child.parent != parent || !child.ended || child.err == nil {
+		t.Errorf("tdx span = %+v", child)
+	}
+}
@@ -0,0 +1,245 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPI 3 文档
+//
+// 由 routeTable 生成,响应 data 的 schema 通过反射 protocol 中的类型得到,
+// 有 json 标签的用标签名,没有的用字段名(和 encoding/json 一致)。
+// 命名结构体放在 components/schemas 下,用 $ref 引用。
+
+const openAPIVersion = "3.0.3"
+
+// schemaGen 反射生成 JSON Schema
+type schemaGen struct {
+	defs map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (this *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			//[]byte 由 encoding/json 编码为 base64
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": this.schema(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": this.schema(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": this.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return this.object(t)
+		}
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := this.defs[t.Name()]; !ok {
+			this.defs[t.Name()] = nil //先占位,避免递归类型死循环
+			this.defs[t.Name()] = this.object(t)
+		}
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
+
+// object 结构体的 schema,匿名嵌入的结构体字段展开
+func (this *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	this.fields(t, props)
+	return map[string]any{"type": "object", "properties": props}
+}
+
+func (this *schemaGen) fields(t reflect.Type, props map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				this.fields(ft, props)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = this.schema(f.Type)
+	}
+}
+
+// openAPI 生成文档
+func (s *Server) openAPI() map[string]any {
+	g := &schemaGen{defs: map[string]any{}}
+	paramErr := g.object(reflect.TypeOf(paramError{}))
+	paramErr["nullable"] = true
+	g.defs["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code": map[string]any{"type": "integer", "example": 1},
+			"msg":  map[string]any{"type": "string"},
+			"data": paramErr,
+		},
+	}
+	errContent := map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}}}
+	errResp := map[string]any{"description": "参数错误", "content": errContent}
+	authed := s.auth != nil && s.auth.enabled()
+
+	paths := map[string]any{}
+	for _, rt := range s.routes {
+		params := make([]any, 0, len(rt.Params))
+		for _, p := range rt.Params {
+			params = append(params, p.openAPI())
+		}
+		content := map[string]any{"application/json": map[string]any{"schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"code": map[string]any{"type": "integer"},
+				"msg":  map[string]any{"type": "string"},
+				"data": g.schema(reflect.TypeOf(rt.Data)),
+			},
+		}}}
+		if tabular(rt.Data) {
+			//表格格式,见 format.go
+			content["text/csv"] = map[string]any{"schema": map[string]any{"type": "string"}}
+			content[formatTypes[formatNDJSON]] = map[string]any{"schema": map[string]any{"type": "string"}}
+			content[formatTypes[formatArrow]] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+		}
+		responses := map[string]any{
+			"200": map[string]any{
+				"description": "code=0 成功,code=1 时 msg 为通达信返回的错误",
+				"content":     content,
+			},
+			"400": errResp,
+		}
+		if authed {
+			responses["401"] = map[string]any{"description": "缺少或无效的凭证", "content": errContent}
+			responses["429"] = map[string]any{"description": "超出限流或每日请求数", "content": errContent}
+		}
+		op := map[string]any{
+			"tags":        []string{rt.Tag},
+			"summary":     rt.Summary,
+			"operationId": operationID(rt.Method, rt.Path),
+			"parameters":  params,
+			"responses":   responses,
+		}
+		item, _ := paths[rt.Path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	components := map[string]any{"schemas": g.defs}
+	doc := map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":       "tdx HTTP API",
+			"version":     "1.0",
+			"description": "通达信行情 HTTP 接口。推送接口(/ws/*、/sse/*)见 README。",
+		},
+		"paths":      paths,
+		"components": components,
+	}
+	if authed {
+		components["securitySchemes"] = map[string]any{
+			"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+		}
+		doc["security"] = []any{map[string]any{"bearer": []string{}}, map[string]any{"apiKey": []string{}}}
+	}
+	return doc
+}
+
+// openAPI 参数的文档
+func (this param) openAPI() map[string]any {
+	schema := map[string]any{}
+	switch this.Kind {
+	case kindUint8, kindUint16, kindUint32:
+		bits, _ := strconv.Atoi(strings.TrimPrefix(string(this.Kind), "uint"))
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+		schema["maximum"] = uint64(1)<<bits - 1
+		if len(this.Enum) > 0 {
+			enum := make([]int, 0, len(this.Enum))
+			for _, e := range this.Enum {
+				n, _ := strconv.Atoi(e)
+				enum = append(enum, n)
+			}
+			schema["enum"] = enum
+		}
+	case kindDate:
+		schema["type"] = "string"
+		schema["pattern"] = regDate.String()
+	default:
+		schema["type"] = "string"
+		if len(this.Enum) > 0 {
+			schema["enum"] = this.Enum
+		}
+	}
+	m := map[string]any{
+		"name":        this.Name,
+		"in":          "query",
+		"required":    !this.Optional && len(this.Unless) == 0,
+		"description": this.Desc,
+		"schema":      schema,
+	}
+	if this.Example != "" {
+		m["example"] = this.Example
+	}
+	return m
+}
+
+// operationID 例 GET /kline/day/all → getKlineDayAll
+func operationID(method, path string) string {
+	b := strings.Builder{}
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '_' }) {
+		b.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+	return b.String()
+}
+
+// handleOpenAPI OpenAPI 3 文档
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	s.specOnce.Do(func() {
+		s.spec, _ = json.Marshal(s.openAPI())
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.spec)
+}
@@ -0,0 +1,160 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/injoyai/tdx"
+)
+
+// fakePool 不连接服务器的连接池,用于只测试路由的场景
+type fakePool struct{}
+
+func (fakePool) Get() (*tdx.Client, error)             { return nil, errors.New("fake") }
+func (fakePool) Put(c *tdx.Client)                     {}
+func (fakePool) Do(fn func(c *tdx.Client) error) error { return errors.New("fake") }
+func (fakePool) Go(fn func(c *tdx.Client)) error       { return errors.New("fake") }
+
+func newTestMux(s *Server) *http.ServeMux {
+	mux := http.NewServeMux()
+	s.registerRoutes(mux)
+	return mux
+}
+
+func TestOpenAPI(t *testing.T) {
+	s := &Server{}
+	mux := newTestMux(s)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	doc := struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name     string `json:"name"`
+				Required bool   `json:"required"`
+				Schema   struct {
+					Type    string `json:"type"`
+					Maximum uint64 `json:"maximum"`
+				} `json:"schema"`
+			} `json:"parameters"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openAPIVersion {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	if len(doc.Paths) != len(s.routes) {
+		t.Errorf("paths = %d, routes = %d", len(doc.Paths), len(s.routes))
+	}
+	if _, ok := doc.Paths["/ex/quote"]; ok {
+		t.Error("扩展行情未启用,不应包含 /ex/quote")
+	}
+
+	op := doc.Paths["/kline/day"]["get"]
+	if op.OperationID != "getKlineDay" {
+		t.Errorf("operationId = %q", op.OperationID)
+	}
+	//带 from/to 时不需要 start/count,所以不是必填
+	if len(op.Parameters) != 7 || op.Parameters[6].Name != "format" || op.Parameters[6].Required || op.Parameters[3].Name != "fq" ||
+		op.Parameters[1].Name != "start" || op.Parameters[1].Schema.Type != "integer" || op.Parameters[1].Schema.Maximum != 65535 || op.Parameters[1].Required {
+		t.Errorf("parameters = %+v", op.Parameters)
+	}
+
+	kline := doc.Components.Schemas["Kline"]
+	if kline.Properties["Close"]["type"] != "integer" || kline.Properties["Time"]["format"] != "date-time" {
+		t.Errorf("Kline = %+v", kline.Properties)
+	}
+	quote := doc.Components.Schemas["Quote"]
+	if quote.Properties["Kline"]["$ref"] != "#/components/schemas/Kline" {
+		t.Errorf("Quote.Kline = %+v", quote.Properties["Kline"])
+	}
+	if _, ok := doc.Components.Schemas["Error"]; !ok {
+		t.Error("缺少 Error")
+	}
+
+	ids := map[string]string{}
+	for path, item := range doc.Paths {
+		for _, op := range item {
+			if p, ok := ids[op.OperationID]; ok {
+				t.Errorf("operationId %s 重复: %s, %s", op.OperationID, p, path)
+			}
+			ids[op.OperationID] = path
+		}
+	}
+}
+
+func TestOpenAPISchemaEx(t *testing.T) {
+	s := &Server{exPool: &fakePool{}}
+	mux := newTestMux(s)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	doc := struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	//有json标签的用标签名
+	if _, ok := doc.Components.Schemas["ExQuote"].Properties["preClose"]; !ok {
+		t.Errorf("ExQuote = %+v", doc.Components.Schemas["ExQuote"].Properties)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	mux := newTestMux(&Server{})
+	cases := []struct {
+		url    string
+		param  string
+		reason string
+	}{
+		{"/kline/day?start=0&count=10", "code", "required"},
+		{"/kline/day?code=sz000001&start=abc&count=10", "start", "format"},
+		{"/kline/day?code=sz000001&start=0&count=70000", "count", "format"},
+		{"/kline?type=12&code=sz000001&start=0&count=10", "type", "enum"},
+		{"/count?exchange=xx", "exchange", "enum"},
+		{"/trade/history/day?date=2024-01-02&code=sz000001", "date", "format"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", c.url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", c.url, w.Code)
+			continue
+		}
+		resp := struct {
+			Code int        `json:"code"`
+			Msg  string     `json:"msg"`
+			Data paramError `json:"data"`
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != 1 || resp.Msg == "" || resp.Data.Param != c.param || resp.Data.Reason != c.reason {
+			t.Errorf("%s: %s", c.url, w.Body.String())
+		}
+	}
+
+	//大小写不敏感,和 parseExchange 一致
+	if err := pExchange.check(httptest.NewRequest("GET", "/count?exchange=SH", nil)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,323 @@
+package httpserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 推送(WebSocket/SSE)
+//
+// 每类推送一个 pushHub,所有订阅者的代码合并去重后,每个周期只通过连接池轮询一次,
+// 和上一次的结果比较,只把有变化的代码按订阅关系分发给订阅者。
+// 没有订阅者时停止轮询。
+
+const (
+	pushBuffer       = 256              //每个订阅者的消息缓存,写满说明消费太慢,直接断开
+	pushKeepalive    = 15 * time.Second //SSE 心跳
+	defaultPushEvery = time.Second      //默认轮询周期
+)
+
+// pushMessage 推送的消息
+type pushMessage struct {
+	Type string `json:"type"`           //消息类型 quote/minute/trade/ex_quote/error
+	Code string `json:"code,omitempty"` //代码
+	Data any    `json:"data"`           //数据,首次为全量,之后为变化部分
+}
+
+// pollErrors 部分代码轮询失败(代码错误、停牌等),代码→错误,其他代码的结果照常推送
+type pollErrors map[string]error
+
+func (this pollErrors) Error() string {
+	ls := make([]string, 0, len(this))
+	for code, err := range this {
+		ls = append(ls, code+": "+err.Error())
+	}
+	sort.Strings(ls)
+	return strings.Join(ls, "; ")
+}
+
+// err 没有失败的代码时返回nil
+func (this pollErrors) err() error {
+	if len(this) == 0 {
+		return nil
+	}
+	return this
+}
+
+// pushRequest 客户端订阅/取消订阅
+type pushRequest struct {
+	Action string   `json:"action"` //subscribe/unsubscribe
+	Codes  []string `json:"codes"`
+}
+
+// pushHub 一类推送的订阅中心
+type pushHub struct {
+	name      string
+	interval  time.Duration
+	normalize func(code string) (string, error)            //校验并统一代码格式
+	poll      func(codes []string) (map[string]any, error) //轮询最新数据,代码→数据,部分代码失败时返回 pollErrors
+	diff      func(old, new any) any                       //比较变化,old为nil时返回全量,无变化返回nil
+
+	mu      sync.Mutex
+	subs    map[string]*subscriber
+	last    map[string]any
+	errs    map[string]string //失败的代码→错误,相同的错误只推送一次
+	running bool
+	closed  bool
+}
+
+func newPushHub(name string, interval time.Duration, normalize func(string) (string, error),
+	poll func([]string) (map[string]any, error), diff func(old, new any) any) *pushHub {
+	if interval <= 0 {
+		interval = defaultPushEvery
+	}
+	return &pushHub{
+		name:      name,
+		interval:  interval,
+		normalize: normalize,
+		poll:      poll,
+		diff:      diff,
+		subs:      map[string]*subscriber{},
+		last:      map[string]any{},
+		errs:      map[string]string{},
+	}
+}
+
+// subscriber 一个订阅者(一个 WebSocket 连接或 SSE 连接)
+type subscriber struct {
+	id    string
+	codes map[string]struct{}
+	C     chan *pushMessage
+}
+
+// send 非阻塞发送,缓存满返回false,需持有 pushHub 的锁(通道由 pushHub 关闭)
+func (this *subscriber) send(msg *pushMessage) bool {
+	select {
+	case this.C <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// add 新增订阅者
+func (this *pushHub) add() *subscriber {
+	bs := make([]byte, 8)
+	_, _ = rand.Read(bs)
+	sub := &subscriber{
+		id:    hex.EncodeToString(bs),
+		codes: map[string]struct{}{},
+		C:     make(chan *pushMessage, pushBuffer),
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.closed {
+		close(sub.C)
+		return sub
+	}
+	this.subs[sub.id] = sub
+	return sub
+}
+
+// get 按id获取订阅者
+func (this *pushHub) get(id string) *subscriber {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.subs[id]
+}
+
+// remove 移除订阅者并关闭其消息通道,可重复调用
+func (this *pushHub) remove(sub *subscriber) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.removeLocked(sub)
+}
+
+func (this *pushHub) removeLocked(sub *subscriber) {
+	if _, ok := this.subs[sub.id]; ok {
+		delete(this.subs, sub.id)
+		close(sub.C)
+	}
+}
+
+// notify 给订阅者发送消息(如错误提示),订阅者已移除或缓存满返回false
+func (this *pushHub) notify(sub *subscriber, msg *pushMessage) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if _, ok := this.subs[sub.id]; !ok {
+		return false
+	}
+	if !sub.send(msg) {
+		this.removeLocked(sub)
+		return false
+	}
+	return true
+}
+
+// subscribe 订阅代码,已有的数据立即推送全量
+func (this *pushHub) subscribe(sub *subscriber, codes ...string) error {
+	ls := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code, err := this.normalize(code)
+		if err != nil {
+			return err
+		}
+		ls = append(ls, code)
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if _, ok := this.subs[sub.id]; !ok {
+		return nil
+	}
+	for _, code := range ls {
+		if _, ok := sub.codes[code]; ok {
+			continue
+		}
+		sub.codes[code] = struct{}{}
+		if v, ok := this.last[code]; ok {
+			if !sub.send(&pushMessage{Type: this.name, Code: code, Data: this.diff(nil, v)}) {
+				this.removeLocked(sub)
+				return nil
+			}
+		}
+	}
+	if !this.running && len(ls) > 0 {
+		this.running = true
+		go this.run()
+	}
+	return nil
+}
+
+// unsubscribe 取消订阅代码
+func (this *pushHub) unsubscribe(sub *subscriber, codes ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, code := range codes {
+		if code, err := this.normalize(code); err == nil {
+			delete(sub.codes, code)
+		}
+	}
+}
+
+// codes 全部订阅者的代码,去重
+func (this *pushHub) codes() []string {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	m := map[string]struct{}{}
+	for _, sub := range this.subs {
+		for code := range sub.codes {
+			m[code] = struct{}{}
+		}
+	}
+	ls := make([]string, 0, len(m))
+	for code := range m {
+		ls = append(ls, code)
+	}
+	return ls
+}
+
+// run 轮询并分发,没有订阅代码时退出
+func (this *pushHub) run() {
+	t := time.NewTicker(this.interval)
+	defer t.Stop()
+	for {
+		codes := this.codes()
+		if len(codes) == 0 {
+			this.mu.Lock()
+			//加锁后再确认一次,避免和 subscribe 竞争
+			if len(this.subs) == 0 || this.closed || this.emptyLocked() {
+				this.running = false
+				this.last = map[string]any{}
+				this.errs = map[string]string{}
+				this.mu.Unlock()
+				return
+			}
+			this.mu.Unlock()
+			continue
+		}
+
+		result, err := this.poll(codes)
+		this.publish(result, err)
+		<-t.C
+	}
+}
+
+func (this *pushHub) emptyLocked() bool {
+	for _, sub := range this.subs {
+		if len(sub.codes) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// publish 比较变化并分发,部分代码失败(pollErrors)时只把错误发给订阅了这些代码的订阅者
+func (this *pushHub) publish(result map[string]any, err error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	var errs pollErrors
+	if err != nil && !errors.As(err, &errs) {
+		msg := &pushMessage{Type: "error", Data: err.Error()}
+		for _, sub := range this.subs {
+			if len(sub.codes) > 0 && !sub.send(msg) {
+				this.removeLocked(sub)
+			}
+		}
+		return
+	}
+	changed := map[string]any{}
+	for code, v := range result {
+		if d := this.diff(this.last[code], v); d != nil {
+			changed[code] = d
+		}
+		this.last[code] = v
+	}
+	//不再订阅的代码不保留,重新订阅时推全量,失败的代码保留上一次的数据
+	for code := range this.last {
+		if _, ok := result[code]; !ok && errs[code] == nil {
+			delete(this.last, code)
+		}
+	}
+	failed := map[string]string{}
+	for code, e := range errs {
+		if msg := e.Error(); this.errs[code] != msg {
+			failed[code] = msg
+		}
+	}
+	this.errs = map[string]string{}
+	for code, e := range errs {
+		this.errs[code] = e.Error()
+	}
+	for _, sub := range this.subs {
+		for code := range sub.codes {
+			msg := (*pushMessage)(nil)
+			if d, ok := changed[code]; ok {
+				msg = &pushMessage{Type: this.name, Code: code, Data: d}
+			} else if e, ok := failed[code]; ok {
+				msg = &pushMessage{Type: "error", Code: code, Data: e}
+			} else {
+				continue
+			}
+			if !sub.send(msg) {
+				this.removeLocked(sub)
+				break
+			}
+		}
+	}
+}
+
+// close 关闭全部订阅者
+func (this *pushHub) close() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.closed = true
+	for _, sub := range this.subs {
+		this.removeLocked(sub)
+	}
+}
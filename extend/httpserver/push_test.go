@@ -0,0 +1,195 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+	"golang.org/x/net/websocket"
+)
+
+func TestPushHub(t *testing.T) {
+	var polls atomic.Int32
+	data := map[string]any{"sz000001": 1, "sh600000": 2}
+	hub := newPushHub("quote", 10*time.Millisecond, normalizeCode, func(codes []string) (map[string]any, error) {
+		polls.Add(1)
+		out := map[string]any{}
+		for _, code := range codes {
+			out[code] = data[code]
+		}
+		return out, nil
+	}, diffSnapshot)
+
+	a, b := hub.add(), hub.add()
+	if err := hub.subscribe(a, "000001", "600000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.subscribe(b, "sz000001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.subscribe(b, "xx"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	recv := func(sub *subscriber) *pushMessage {
+		select {
+		case msg := <-sub.C:
+			return msg
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+			return nil
+		}
+	}
+	got := map[string]any{}
+	for i := 0; i < 2; i++ {
+		msg := recv(a)
+		got[msg.Code] = msg.Data
+	}
+	if got["sz000001"] != 1 || got["sh600000"] != 2 {
+		t.Fatalf("a: %v", got)
+	}
+	if msg := recv(b); msg.Code != "sz000001" {
+		t.Fatalf("b: %+v", msg)
+	}
+
+	//数据不变不推送,也不会因为两个订阅者重复轮询
+	time.Sleep(50 * time.Millisecond)
+	if len(a.C) != 0 || len(b.C) != 0 {
+		t.Fatal("unexpected message")
+	}
+	if n := polls.Load(); n < 3 || n > 10 {
+		t.Fatalf("polls: %d", n)
+	}
+
+	hub.remove(a)
+	hub.remove(b)
+	time.Sleep(50 * time.Millisecond)
+	hub.mu.Lock()
+	running := hub.running
+	hub.mu.Unlock()
+	if running {
+		t.Fatal("hub should stop without subscribers")
+	}
+	if _, ok := <-a.C; ok {
+		t.Fatal("channel should be closed")
+	}
+}
+
+// TestPushHubPartial 一个代码失败时其他代码照常推送,错误只发给订阅了该代码的订阅者,相同的错误只发一次
+func TestPushHubPartial(t *testing.T) {
+	hub := newPushHub("quote", 10*time.Millisecond, normalizeCode, func(codes []string) (map[string]any, error) {
+		out := map[string]any{}
+		errs := pollErrors{}
+		for _, code := range codes {
+			if code == "sz000002" {
+				errs[code] = errors.New("停牌")
+				continue
+			}
+			out[code] = code
+		}
+		return out, errs.err()
+	}, diffSnapshot)
+
+	a, b := hub.add(), hub.add()
+	if err := hub.subscribe(a, "sz000001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.subscribe(b, "sz000001", "sz000002"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	defer hub.close()
+
+	drain := func(sub *subscriber) []*pushMessage {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		var ls []*pushMessage
+		for len(sub.C) > 0 {
+			ls = append(ls, <-sub.C)
+		}
+		return ls
+	}
+	if ls := drain(a); len(ls) != 1 || ls[0].Type != "quote" || ls[0].Code != "sz000001" {
+		t.Fatalf("a: %v", ls)
+	}
+	ls := drain(b)
+	types := map[string]string{}
+	for _, v := range ls {
+		types[v.Code] = v.Type
+	}
+	if len(ls) != 2 || types["sz000001"] != "quote" || types["sz000002"] != "error" {
+		t.Fatalf("b: %v", ls)
+	}
+}
+
+func TestDiffTrade(t *testing.T) {
+	now := time.Date(2024, 1, 2, 9, 31, 0, 0, time.Local)
+	trade := func(price, volume int) *protocol.Trade {
+		return &protocol.Trade{Time: now, Price: protocol.Price(price), Volume: volume}
+	}
+	old := protocol.Trades{trade(1, 1), trade(2, 1), trade(3, 1)}
+	if d := diffTrade(old, protocol.Trades{trade(1, 1), trade(2, 1), trade(3, 1)}); d != nil {
+		t.Fatalf("unchanged: %v", d)
+	}
+	d := diffTrade(old, protocol.Trades{trade(2, 1), trade(3, 1), trade(4, 1)})
+	if ls, _ := d.(protocol.Trades); len(ls) != 1 || ls[0].Price != 4 {
+		t.Fatalf("append: %v", d)
+	}
+	d = diffTrade(old, protocol.Trades{trade(5, 1), trade(6, 1)})
+	if ls, _ := d.(protocol.Trades); len(ls) != 2 {
+		t.Fatalf("gap: %v", d)
+	}
+}
+
+func TestDiffMinute(t *testing.T) {
+	old := []protocol.PriceNumber{{Time: "09:31", Price: 1}, {Time: "09:32", Price: 2}}
+	if d := diffMinute(old, []protocol.PriceNumber{{Time: "09:31", Price: 1}, {Time: "09:32", Price: 2}}); d != nil {
+		t.Fatalf("unchanged: %v", d)
+	}
+	d := diffMinute(old, []protocol.PriceNumber{{Time: "09:31", Price: 1}, {Time: "09:32", Price: 3}, {Time: "09:33", Price: 4}})
+	if m, _ := d.(*minuteDiff); m == nil || m.Start != 1 || len(m.List) != 2 {
+		t.Fatalf("changed: %+v", d)
+	}
+}
+
+func TestHandleWS(t *testing.T) {
+	hub := newPushHub("quote", 10*time.Millisecond, normalizeCode, func(codes []string) (map[string]any, error) {
+		out := map[string]any{}
+		for _, code := range codes {
+			out[code] = code
+		}
+		return out, nil
+	}, diffSnapshot)
+	s := &Server{}
+	ts := httptest.NewServer(s.handleWS(hub))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/?codes=000001"
+	ws, err := websocket.Dial(url, "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	_ = ws.SetDeadline(time.Now().Add(time.Second))
+
+	msg := new(pushMessage)
+	if err = websocket.JSON.Receive(ws, msg); err != nil || msg.Code != "sz000001" {
+		t.Fatalf("%v %+v", err, msg)
+	}
+	if err = websocket.JSON.Send(ws, &pushRequest{Action: "subscribe", Codes: []string{"600000"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = websocket.JSON.Receive(ws, msg); err != nil || msg.Code != "sh600000" {
+		t.Fatalf("%v %+v", err, msg)
+	}
+	if err = websocket.JSON.Send(ws, &pushRequest{Action: "xx"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = websocket.JSON.Receive(ws, msg); err != nil || msg.Type != "error" {
+		t.Fatalf("%v %+v", err, msg)
+	}
+}
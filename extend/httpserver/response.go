@@ -18,6 +18,9 @@ type Response struct {
 
 // respondOK 成功响应
 func respondOK(w http.ResponseWriter, data any) {
+	if fw, ok := w.(*formatWriter); ok && fw.respond(data) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(Response{Code: 0, Msg: "ok", Data: data})
 }
@@ -29,6 +32,13 @@ func respondErr(w http.ResponseWriter, httpCode int, msg string) {
 	_ = json.NewEncoder(w).Encode(Response{Code: 1, Msg: msg, Data: nil})
 }
 
+// respondInvalid 参数校验失败,400,data 为出错的参数和原因
+func respondInvalid(w http.ResponseWriter, err *paramError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(Response{Code: 1, Msg: err.Error(), Data: err})
+}
+
 // ---- 参数解析辅助 ----
 
 func queryStr(r *http.Request, key string) (string, error) {
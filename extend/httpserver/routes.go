@@ -0,0 +1,272 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 路由表
+//
+// 每个路由的路径、参数、响应类型和缓存策略都定义在 routeTable 里,
+// registerRoutes 按表注册并统一校验参数,/openapi.json 也由这张表生成,
+// 新增路由只需要在表里加一行,文档和校验不会和实现脱节。
+
+// paramKind 参数类型
+type paramKind string
+
+const (
+	kindString paramKind = "string"
+	kindUint8  paramKind = "uint8"
+	kindUint16 paramKind = "uint16"
+	kindUint32 paramKind = "uint32"
+	kindDate   paramKind = "date" //YYYYMMDD 字符串
+)
+
+var regDate = regexp.MustCompile(`^\d{8}$`)
+
+// param 查询参数
+type param struct {
+	Name     string
+	Kind     paramKind
+	Desc     string
+	Example  string
+	Optional bool
+	Enum     []string //可选值,不区分大小写
+	Unless   []string //带其中任一参数时可以不带
+}
+
+// route 一个路由
+type route struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	Params  []param
+	Data    any         //响应中 data 的类型,用于生成文档
+	Policy  cachePolicy //缓存策略,nil 不缓存
+	Handler http.HandlerFunc
+}
+
+// 常用参数
+var (
+	pExchange = param{Name: "exchange", Kind: kindString, Desc: "交易所", Example: "sh", Enum: []string{"sh", "sz", "bj"}}
+	pCode     = param{Name: "code", Kind: kindString, Desc: "证券代码,可带交易所前缀", Example: "sh600519"}
+	pCodes    = param{Name: "codes", Kind: kindString, Desc: "多个证券代码,逗号分隔", Example: "sz000001,sh600008"}
+	pStart    = param{Name: "start", Kind: kindUint16, Desc: "起始位置,0为最新", Example: "0"}
+	pCount    = param{Name: "count", Kind: kindUint16, Desc: "获取数量", Example: "100"}
+	pDate     = param{Name: "date", Kind: kindDate, Desc: "日期 YYYYMMDD", Example: "20240102"}
+	pFile     = param{Name: "file", Kind: kindString, Desc: "板块/报表文件名", Example: "block_gn.dat"}
+	pType     = param{Name: "type", Kind: kindUint8, Desc: "K线类型 0=5分钟 1=15分钟 2=30分钟 3=60分钟 4=日(变体) 5=周 6=月 7=1分钟 8=1分钟(变体) 9=日 10=季 11=年",
+		Example: "9", Enum: []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"}}
+
+	pMarket   = param{Name: "market", Kind: kindUint8, Desc: "扩展行情市场代码", Example: "47"}
+	pCategory = param{Name: "category", Kind: kindUint8, Desc: "扩展行情类别", Example: "1"}
+	pExDate   = param{Name: "date", Kind: kindUint32, Desc: "日期 YYYYMMDD", Example: "20240102"}
+	pExStart  = param{Name: "start", Kind: kindUint32, Desc: "起始位置", Example: "0"}
+)
+
+// routeTable 全部路由,扩展行情未启用时不包含 /ex/*
+func (s *Server) routeTable() []route {
+	kline := func(path, summary string, typ uint8, policy cachePolicy, h http.HandlerFunc) route {
+		return route{"GET", path, "K线", summary, []param{pCode, pRangeStart, pRangeCount, pFq, pFrom, pTo}, &protocol.KlineResp{}, policy,
+			s.withKline(klineRoute{typ: typ}, h)}
+	}
+	klineAll := func(path, summary string, typ uint8, policy cachePolicy, h http.HandlerFunc) route {
+		return route{"GET", path, "K线", summary, []param{pCode, pFq, pFrom, pTo}, &protocol.KlineResp{}, policy,
+			s.withKline(klineRoute{typ: typ, all: true}, h)}
+	}
+	index := func(path, summary string, typ uint8, policy cachePolicy, h http.HandlerFunc) route {
+		return route{"GET", path, "指数K线", summary, []param{pCode, pRangeStart, pRangeCount, pFrom, pTo}, &protocol.KlineResp{}, policy,
+			s.withKline(klineRoute{typ: typ, index: true}, h)}
+	}
+	indexAll := func(path, summary string, typ uint8, policy cachePolicy, h http.HandlerFunc) route {
+		return route{"GET", path, "指数K线", summary, []param{pCode, pFrom, pTo}, &protocol.KlineResp{}, policy,
+			s.withKline(klineRoute{typ: typ, index: true, all: true}, h)}
+	}
+
+	ls := []route{
+		// 代码/数量
+		{"GET", "/count", "代码", "获取指定交易所的证券数量", []param{pExchange}, &protocol.CountResp{}, policyDaily, s.handleCount},
+		{"GET", "/code", "代码", "获取指定交易所的证券代码(分页)", []param{pExchange, pStart}, &protocol.CodeResp{}, policyDaily, s.handleCode},
+		{"GET", "/code/all", "代码", "获取指定交易所的全部证券代码", []param{pExchange}, &protocol.CodeResp{}, policyDaily, s.handleCodeAll},
+		{"GET", "/code/stocks", "代码", "获取全部股票代码", nil, []string{}, policyDaily, s.handleStockCodeAll},
+		{"GET", "/code/etfs", "代码", "获取全部 ETF 代码", nil, []string{}, policyDaily, s.handleETFCodeAll},
+		{"GET", "/code/indexes", "代码", "获取全部指数代码", nil, []string{}, policyDaily, s.handleIndexCodeAll},
+
+		// 行情/财务
+		{"GET", "/quote", "行情", "获取实时行情报价(支持多个代码)", []param{pCodes}, protocol.QuotesResp{}, policyTTL(time.Second), s.handleQuote},
+		{"GET", "/call_auction", "行情", "获取集合竞价数据", []param{pCode}, &protocol.CallAuctionResp{}, policyTTL(time.Second), s.handleCallAuction},
+		{"GET", "/gbbq", "财务", "获取除权除息(股本变更)数据", []param{pCode}, &protocol.GbbqResp{}, s.policyTradingDay, s.handleGbbq},
+		{"GET", "/finance", "财务", "获取财务信息", []param{pExchange, pCode}, &protocol.FinanceInfo{}, s.policyTradingDay, s.handleFinanceInfo},
+		{"GET", "/company/category", "财务", "获取公司信息(F10)文件目录", []param{pExchange, pCode}, []protocol.CompanyCategory{}, policyDaily, s.handleCompanyCategory},
+		{"GET", "/company/content", "财务", "获取公司信息(F10)文件内容", []param{pExchange, pCode,
+			{Name: "filename", Kind: kindString, Desc: "F10 文件名", Example: "600519.txt"},
+			{Name: "start", Kind: kindUint32, Desc: "文件内偏移", Example: "0"},
+			{Name: "length", Kind: kindUint32, Desc: "长度", Example: "5000"},
+		}, "", policyDaily, s.handleCompanyContent},
+		{"GET", "/company/f10", "财务", "获取全部 F10 分类并解析", []param{pExchange, pCode}, &protocol.CompanyF10{}, policyDaily, s.handleCompanyF10},
+
+		// 分时/成交
+		{"GET", "/minute", "分时成交", "获取当日分时数据", []param{pCode}, &protocol.MinuteResp{}, s.policyBar(1), s.handleMinute},
+		{"GET", "/minute/history", "分时成交", "获取历史分时数据", []param{pDate, pCode}, &protocol.MinuteResp{}, s.policyDate, s.handleHistoryMinute},
+		{"GET", "/trade", "分时成交", "获取当日分笔成交明细(分页)", []param{pCode, pStart, pCount}, &protocol.TradeResp{}, s.policyBar(1), s.handleTrade},
+		{"GET", "/trade/all", "分时成交", "获取当日全部分笔成交明细", []param{pCode}, &protocol.TradeResp{}, s.policyBar(1), s.handleTradeAll},
+		{"GET", "/trade/history", "分时成交", "获取历史分笔成交明细(分页)", []param{pDate, pCode, pStart, pCount}, &protocol.TradeResp{}, s.policyDate, s.handleHistoryTrade},
+		{"GET", "/trade/history/day", "分时成交", "获取指定日期全部分笔成交明细", []param{pDate, pCode}, &protocol.TradeResp{}, s.policyDate, s.handleHistoryTradeDay},
+
+		// K线(股票)
+		{"GET", "/kline", "K线", "获取指定类型的 K 线(分页)", []param{pPeriodType, pCode, pRangeStart, pRangeCount, pPeriod, pFq, pFrom, pTo}, &protocol.KlineResp{}, s.policyKline,
+			s.withKline(klineRoute{byType: true}, s.handleKline)},
+		{"GET", "/kline/all", "K线", "获取指定类型的全部 K 线", []param{pPeriodType, pCode, pPeriod, pFq, pFrom, pTo}, &protocol.KlineResp{}, s.policyKline,
+			s.withKline(klineRoute{byType: true, all: true}, s.handleKlineAll)},
+		kline("/kline/minute", "获取 1 分钟 K 线(分页)", protocol.TypeKlineMinute, s.policyBar(1), s.handleKlineMinute),
+		klineAll("/kline/minute/all", "获取全部 1 分钟 K 线", protocol.TypeKlineMinute, s.policyBar(1), s.handleKlineMinuteAll),
+		kline("/kline/5minute", "获取 5 分钟 K 线(分页)", protocol.TypeKline5Minute, s.policyBar(5), s.handleKline5Minute),
+		klineAll("/kline/5minute/all", "获取全部 5 分钟 K 线", protocol.TypeKline5Minute, s.policyBar(5), s.handleKline5MinuteAll),
+		kline("/kline/15minute", "获取 15 分钟 K 线(分页)", protocol.TypeKline15Minute, s.policyBar(15), s.handleKline15Minute),
+		klineAll("/kline/15minute/all", "获取全部 15 分钟 K 线", protocol.TypeKline15Minute, s.policyBar(15), s.handleKline15MinuteAll),
+		kline("/kline/30minute", "获取 30 分钟 K 线(分页)", protocol.TypeKline30Minute, s.policyBar(30), s.handleKline30Minute),
+		klineAll("/kline/30minute/all", "获取全部 30 分钟 K 线", protocol.TypeKline30Minute, s.policyBar(30), s.handleKline30MinuteAll),
+		kline("/kline/60minute", "获取 60 分钟 K 线(分页)", protocol.TypeKline60Minute, s.policyBar(60), s.handleKline60Minute),
+		klineAll("/kline/60minute/all", "获取全部 60 分钟 K 线", protocol.TypeKline60Minute, s.policyBar(60), s.handleKline60MinuteAll),
+		kline("/kline/day", "获取日 K 线(分页)", protocol.TypeKlineDay, s.policyHistory, s.handleKlineDay),
+		klineAll("/kline/day/all", "获取全部日 K 线", protocol.TypeKlineDay, s.policyHistory, s.handleKlineDayAll),
+		kline("/kline/week", "获取周 K 线(分页)", protocol.TypeKlineWeek, s.policyHistory, s.handleKlineWeek),
+		klineAll("/kline/week/all", "获取全部周 K 线", protocol.TypeKlineWeek, s.policyHistory, s.handleKlineWeekAll),
+		kline("/kline/month", "获取月 K 线(分页)", protocol.TypeKlineMonth, s.policyHistory, s.handleKlineMonth),
+		klineAll("/kline/month/all", "获取全部月 K 线", protocol.TypeKlineMonth, s.policyHistory, s.handleKlineMonthAll),
+		kline("/kline/quarter", "获取季 K 线(分页)", protocol.TypeKlineQuarter, s.policyHistory, s.handleKlineQuarter),
+		klineAll("/kline/quarter/all", "获取全部季 K 线", protocol.TypeKlineQuarter, s.policyHistory, s.handleKlineQuarterAll),
+		kline("/kline/year", "获取年 K 线(分页)", protocol.TypeKlineYear, s.policyHistory, s.handleKlineYear),
+		klineAll("/kline/year/all", "获取全部年 K 线", protocol.TypeKlineYear, s.policyHistory, s.handleKlineYearAll),
+
+		// 指数K线
+		{"GET", "/index", "指数K线", "获取指定类型的指数 K 线(分页)", []param{pPeriodType, pCode, pRangeStart, pRangeCount, pPeriod, pFrom, pTo}, &protocol.KlineResp{}, s.policyKline,
+			s.withKline(klineRoute{byType: true, index: true}, s.handleIndex)},
+		{"GET", "/index/all", "指数K线", "获取指定类型的全部指数 K 线", []param{pPeriodType, pCode, pPeriod, pFrom, pTo}, &protocol.KlineResp{}, s.policyKline,
+			s.withKline(klineRoute{byType: true, index: true, all: true}, s.handleIndexAll)},
+		index("/index/minute", "获取指数 1 分钟 K 线(分页)", protocol.TypeKlineMinute, s.policyBar(1), s.handleIndexMinute),
+		index("/index/5minute", "获取指数 5 分钟 K 线(分页)", protocol.TypeKline5Minute, s.policyBar(5), s.handleIndex5Minute),
+		index("/index/15minute", "获取指数 15 分钟 K 线(分页)", protocol.TypeKline15Minute, s.policyBar(15), s.handleIndex15Minute),
+		index("/index/30minute", "获取指数 30 分钟 K 线(分页)", protocol.TypeKline30Minute, s.policyBar(30), s.handleIndex30Minute),
+		index("/index/60minute", "获取指数 60 分钟 K 线(分页)", protocol.TypeKline60Minute, s.policyBar(60), s.handleIndex60Minute),
+		index("/index/day", "获取指数日 K 线(分页)", protocol.TypeKlineDay, s.policyHistory, s.handleIndexDay),
+		indexAll("/index/day/all", "获取全部指数日 K 线", protocol.TypeKlineDay, s.policyHistory, s.handleIndexDayAll),
+		indexAll("/index/week/all", "获取全部指数周 K 线", protocol.TypeKlineWeek, s.policyHistory, s.handleIndexWeekAll),
+		indexAll("/index/month/all", "获取全部指数月 K 线", protocol.TypeKlineMonth, s.policyHistory, s.handleIndexMonthAll),
+		indexAll("/index/quarter/all", "获取全部指数季 K 线", protocol.TypeKlineQuarter, s.policyHistory, s.handleIndexQuarterAll),
+		indexAll("/index/year/all", "获取全部指数年 K 线", protocol.TypeKlineYear, s.policyHistory, s.handleIndexYearAll),
+
+		// 板块/报表
+		{"GET", "/block/data", "板块", "获取板块数据(解析后)", []param{pFile}, []*protocol.Block{}, policyDaily, s.handleBlockData},
+		{"GET", "/block/data/index", "板块", "获取带板块指数代码的板块数据", []param{pFile}, []*protocol.Block{}, policyDaily, s.handleBlockDataWithIndex},
+		{"GET", "/block/file", "板块", "获取板块原始文件内容(base64)", []param{pFile}, []byte{}, policyDaily, s.handleBlockFileRaw},
+		{"GET", "/report/file", "板块", "获取报表文件内容(base64)", []param{pFile}, []byte{}, policyDaily, s.handleReportFile},
+		{"GET", "/zhb/files", "板块", "获取 zhb.zip 内全部文件(文件名→base64)", nil, map[string][]byte{}, policyDaily, s.handleZHBFiles},
+		{"GET", "/tdx/zs", "板块", "获取通达信指数信息", nil, []*protocol.TdxZs{}, policyDaily, s.handleTdxZs},
+		{"GET", "/tdx/bk", "板块", "获取通达信板块信息", nil, []*protocol.TdxBk{}, policyDaily, s.handleTdxBk},
+		{"GET", "/tdx/stat", "板块", "获取通达信统计信息", nil, []*protocol.TdxStat{}, policyDaily, s.handleTdxStat},
+		{"GET", "/tdx/stat2", "板块", "获取通达信统计信息(二)", nil, []*protocol.TdxStat2{}, policyDaily, s.handleTdxStat2},
+		{"GET", "/tdx/xgsg", "板块", "获取新股申购信息", nil, []*protocol.TdxXgsg{}, policyDaily, s.handleTdxXgsg},
+		{"GET", "/tdx/hy", "板块", "获取通达信行业信息", nil, []*protocol.TdxHy{}, policyDaily, s.handleTdxHy},
+		{"GET", "/spblock", "板块", "获取特殊板块信息", nil, []*protocol.SpBlock{}, policyDaily, s.handleSpBlock},
+	}
+
+	if s.exPool != nil {
+		ls = append(ls, []route{
+			{"GET", "/ex/markets", "扩展行情", "获取扩展行情市场列表", nil, []protocol.ExMarket{}, policyDaily, s.handleExMarkets},
+			{"GET", "/ex/count", "扩展行情", "获取扩展行情证券数量", nil, 0, policyDaily, s.handleExCount},
+			{"GET", "/ex/instruments", "扩展行情", "获取扩展行情证券列表(分页)", []param{pExStart, pCount}, []protocol.ExInstrument{}, policyDaily, s.handleExInstruments},
+			{"GET", "/ex/quote", "扩展行情", "获取扩展行情实时报价", []param{pMarket, pCode}, &protocol.ExQuote{}, policyTTL(time.Second), s.handleExQuote},
+			{"GET", "/ex/quote_list", "扩展行情", "获取扩展行情报价列表(分页)", []param{pMarket, pCategory, pStart, pCount}, []protocol.ExQuoteListItem{}, policyTTL(time.Second), s.handleExQuoteList},
+			{"GET", "/ex/bars", "扩展行情", "获取扩展行情 K 线(分页)", []param{pCategory, pMarket, pCode, pStart, pCount}, []protocol.ExKline{}, policyTTL(time.Second), s.handleExBars},
+			{"GET", "/ex/minute", "扩展行情", "获取扩展行情分时数据", []param{pMarket, pCode}, []protocol.ExMinuteTick{}, policyTTL(time.Second), s.handleExMinute},
+			{"GET", "/ex/minute/hist", "扩展行情", "获取扩展行情历史分时数据", []param{pMarket, pCode, pExDate}, []protocol.ExMinuteTick{}, policyExDate("date"), s.handleExHistMinute},
+			{"GET", "/ex/trade", "扩展行情", "获取扩展行情分笔成交(分页)", []param{pMarket, pCode, pStart, pCount}, []protocol.ExTradeTick{}, policyTTL(time.Second), s.handleExTrade},
+			{"GET", "/ex/trade/hist", "扩展行情", "获取扩展行情历史分笔成交(分页)", []param{pMarket, pCode, pExDate, pStart, pCount}, []protocol.ExTradeTick{}, policyExDate("date"), s.handleExHistTrade},
+			{"GET", "/ex/bars/range", "扩展行情", "获取扩展行情指定日期区间 K 线", []param{pMarket, pCode, pExDate,
+				{Name: "date2", Kind: kindUint32, Desc: "结束日期 YYYYMMDD", Example: "20240601"},
+			}, []protocol.ExRangeKline{}, policyExDate("date2"), s.handleExBarsRange},
+		}...)
+	}
+
+	for i := range ls {
+		if tabular(ls[i].Data) {
+			ls[i].Params = append(ls[i].Params[:len(ls[i].Params):len(ls[i].Params)], pFormat)
+		}
+	}
+	return ls
+}
+
+// paramError 参数校验错误,作为400响应的 data 返回
+type paramError struct {
+	Param  string `json:"param"`
+	Reason string `json:"reason"` //required/format/enum
+	msg    string
+}
+
+func (this *paramError) Error() string { return this.msg }
+
+// check 校验请求中的参数
+func (this param) check(r *http.Request) *paramError {
+	v := r.URL.Query().Get(this.Name)
+	if v == "" {
+		if this.Optional {
+			return nil
+		}
+		for _, name := range this.Unless {
+			if r.URL.Query().Get(name) != "" {
+				return nil
+			}
+		}
+		return &paramError{Param: this.Name, Reason: "required", msg: fmt.Sprintf("参数 %s 不能为空", this.Name)}
+	}
+	var err error
+	switch this.Kind {
+	case kindUint8:
+		_, err = strconv.ParseUint(v, 10, 8)
+	case kindUint16:
+		_, err = strconv.ParseUint(v, 10, 16)
+	case kindUint32:
+		_, err = strconv.ParseUint(v, 10, 32)
+	case kindDate:
+		if !regDate.MatchString(v) {
+			err = fmt.Errorf("invalid date")
+		}
+	}
+	if err != nil {
+		return &paramError{Param: this.Name, Reason: "format", msg: fmt.Sprintf("参数 %s 格式错误: %s", this.Name, v)}
+	}
+	if len(this.Enum) > 0 {
+		for _, e := range this.Enum {
+			if strings.EqualFold(e, v) {
+				return nil
+			}
+		}
+		return &paramError{Param: this.Name, Reason: "enum",
+			msg: fmt.Sprintf("参数 %s 取值错误: %s (可选: %s)", this.Name, v, strings.Join(this.Enum, ", "))}
+	}
+	return nil
+}
+
+// validate 按路由定义校验参数,不通过返回400,不会进入缓存和连接池
+func validate(params []param, h http.HandlerFunc) http.HandlerFunc {
+	if len(params) == 0 {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range params {
+			if err := p.check(r); err != nil {
+				respondInvalid(w, err)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
@@ -1,10 +1,14 @@
 package httpserver
 
 import (
+	"io"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/injoyai/ios/client"
 	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
 )
 
 // Option HTTP 服务配置选项
@@ -17,6 +21,27 @@ type serverConfig struct {
 	exHqHosts  []string
 	exPoolSize int
 	options    []client.Option
+	pushEvery  time.Duration
+	cacheSize  int
+	cacheDir   string
+	workday    *tdx.Workday
+
+	apiKeys     []APIKey
+	apiKeyFile  string
+	tokenSecret string
+	rate        float64
+	burst       int
+	quota       int
+	audit       io.Writer
+
+	batchConcurrency int
+	batchMaxCodes    int
+
+	metrics bool
+	tracer  Tracer
+	hook    tdx.Hook
+
+	gbbq tdx.IGbbq
 }
 
 // WithAddr 设置监听地址
@@ -44,6 +69,88 @@ func WithExPoolSize(n int) Option {
 	return func(c *serverConfig) { c.exPoolSize = n }
 }
 
+// WithPushInterval 设置推送(/ws/*、/sse/*)的轮询周期,默认1秒
+func WithPushInterval(d time.Duration) Option {
+	return func(c *serverConfig) { c.pushEvery = d }
+}
+
+// WithCache 启用响应缓存并设置内存条数,例 1000,默认不缓存,小于等于0时关闭
+func WithCache(size int) Option {
+	return func(c *serverConfig) { c.cacheSize = size }
+}
+
+// WithCacheDir 设置响应缓存的磁盘目录,重启后仍可命中,默认不持久化
+func WithCacheDir(dir string) Option {
+	return func(c *serverConfig) { c.cacheDir = dir }
+}
+
+// WithWorkday 设置交易日,用于计算缓存过期时间,未设置时按周一至周五处理
+func WithWorkday(w *tdx.Workday) Option {
+	return func(c *serverConfig) { c.workday = w }
+}
+
+// WithAPIKeys 设置 API key,设置后请求需要带上 key,见 auth.go
+func WithAPIKeys(keys ...APIKey) Option {
+	return func(c *serverConfig) { c.apiKeys = append(c.apiKeys, keys...) }
+}
+
+// WithAPIKeyFile 从文件读取 API key,文件为 APIKey 的 JSON 数组
+func WithAPIKeyFile(filename string) Option {
+	return func(c *serverConfig) { c.apiKeyFile = filename }
+}
+
+// WithTokenSecret 设置 HMAC 令牌的密钥,启用令牌鉴权,令牌由 NewToken 签发
+func WithTokenSecret(secret string) Option {
+	return func(c *serverConfig) { c.tokenSecret = secret }
+}
+
+// WithRateLimit 设置每个 key 默认的每秒请求数和突发请求数,默认不限
+func WithRateLimit(rate float64, burst int) Option {
+	return func(c *serverConfig) { c.rate, c.burst = rate, burst }
+}
+
+// WithDailyQuota 设置每个 key 默认的每日请求数,默认不限
+func WithDailyQuota(n int) Option {
+	return func(c *serverConfig) { c.quota = n }
+}
+
+// WithAuditLog 设置审计日志,每个请求写一行 JSON(时间、key、路由、代码、状态码、耗时、IP)
+func WithAuditLog(w io.Writer) Option {
+	return func(c *serverConfig) { c.audit = w }
+}
+
+// WithBatchConcurrency 设置批量接口(/batch/*)每个请求同时获取的代码数,默认4
+func WithBatchConcurrency(n int) Option {
+	return func(c *serverConfig) { c.batchConcurrency = n }
+}
+
+// WithBatchMaxCodes 设置批量接口每个请求最多的代码数,默认1000
+func WithBatchMaxCodes(n int) Option {
+	return func(c *serverConfig) { c.batchMaxCodes = n }
+}
+
+// WithMetrics 设置是否开启 Prometheus 指标 GET /metrics,默认开启,见 metrics.go
+func WithMetrics(b ...bool) Option {
+	return func(c *serverConfig) { c.metrics = len(b) == 0 || b[0] }
+}
+
+// WithTracer 设置链路追踪,每个 HTTP 请求及其中的通达信请求各创建一个 span
+func WithTracer(t Tracer) Option {
+	return func(c *serverConfig) { c.tracer = t }
+}
+
+// WithHook 设置连接和连接池的观测回调,和内置的指标一起生效,
+// 连接选项中的 tdx.WithHook 会被覆盖,需要用这个
+func WithHook(h tdx.Hook) Option {
+	return func(c *serverConfig) { c.hook = h }
+}
+
+// WithGbbq 设置复权(fq=qfq|hfq)使用的除权除息数据,未设置时在第一次复权请求时
+// 用 tdx.NewGbbq 创建(数据库在 tdx.DefaultDatabaseDir,每天更新)
+func WithGbbq(g tdx.IGbbq) Option {
+	return func(c *serverConfig) { c.gbbq = g }
+}
+
 // WithOptions 设置通达信连接选项,如 tdx.WithDebug()、tdx.WithRedial()
 func WithOptions(opts ...client.Option) Option {
 	return func(c *serverConfig) {
@@ -56,6 +163,35 @@ type Server struct {
 	pool   tdx.IPool
 	exPool tdx.IPool
 	server *http.Server
+
+	cache     *responseCache
+	auth      *authenticator
+	workday   *tdx.Workday
+	zhbMu     sync.Mutex
+	zhb       map[string][]byte
+	zhbExpire time.Time
+
+	quoteHub   *pushHub
+	minuteHub  *pushHub
+	tradeHub   *pushHub
+	exQuoteHub *pushHub
+
+	batchConcurrency int
+	batchMaxCodes    int
+
+	metrics *metrics
+	tracer  Tracer
+
+	gbbqMu     sync.Mutex
+	gbbq       tdx.IGbbq
+	dialGbbq   func() (tdx.IGbbq, error)
+	factorMu   sync.Mutex
+	factorDate string
+	factorMap  map[string][]*protocol.Factor
+
+	routes   []route
+	specOnce sync.Once
+	spec     []byte
 }
 
 // New 创建并初始化 HTTP 服务
@@ -64,17 +200,37 @@ func New(opts ...Option) (*Server, error) {
 		addr:     ":8080",
 		hosts:    tdx.Hosts,
 		poolSize: 1,
+		metrics:  true,
 	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
 
+	var m *metrics
+	if cfg.metrics {
+		m = newMetrics()
+	}
+	//连接和连接池的回调,pool 为 std/ex
+	hookFor := func(pool string) tdx.Hook {
+		if m == nil && cfg.tracer == nil {
+			return cfg.hook
+		}
+		return tdx.MultiHook(&serverHook{pool: pool, metrics: m, tracer: cfg.tracer}, cfg.hook)
+	}
+	stdHook := hookFor("std")
+
+	auth, err := newAuthenticator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	pool, err := tdx.NewPool(func() (*tdx.Client, error) {
-		return tdx.DialHostsRange(cfg.hosts, cfg.options...)
+		return tdx.DialHostsRange(cfg.hosts, withHook(cfg.options, stdHook)...)
 	}, cfg.poolSize)
 	if err != nil {
 		return nil, err
 	}
+	pool.SetHook(stdHook)
 
 	// 初始化 DefaultCodes(ETF/可转债等非股票行情需要查 Decimal 修正价格, 见 Client.GetQuote)
 	if tdx.DefaultCodes == nil {
@@ -88,21 +244,47 @@ func New(opts ...Option) (*Server, error) {
 		}
 	}
 
-	s := &Server{pool: pool}
+	s := &Server{
+		pool:             pool,
+		auth:             auth,
+		workday:          cfg.workday,
+		batchConcurrency: cfg.batchConcurrency,
+		batchMaxCodes:    cfg.batchMaxCodes,
+		metrics:          m,
+		tracer:           cfg.tracer,
+		gbbq:             cfg.gbbq,
+		dialGbbq: func() (tdx.IGbbq, error) {
+			return tdx.NewGbbq(tdx.WithGbbqDialClient(func() (*tdx.Client, error) {
+				return tdx.DialHostsRange(cfg.hosts, cfg.options...)
+			}))
+		},
+	}
+	if cfg.cacheSize > 0 {
+		s.cache = newResponseCache(cfg.cacheSize, cfg.cacheDir)
+	}
 
 	if len(cfg.exHqHosts) > 0 {
 		if cfg.exPoolSize <= 0 {
 			cfg.exPoolSize = 1
 		}
+		exHook := hookFor("ex")
 		exPool, err := tdx.NewPool(func() (*tdx.Client, error) {
-			return tdx.DialExHqHosts(cfg.exHqHosts, cfg.options...)
+			return tdx.DialExHqHosts(cfg.exHqHosts, withHook(cfg.options, exHook)...)
 		}, cfg.exPoolSize)
 		if err != nil {
 			return nil, err
 		}
+		exPool.SetHook(exHook)
 		s.exPool = exPool
 	}
 
+	s.quoteHub = newPushHub("quote", cfg.pushEvery, normalizeCode, s.pollQuote, diffSnapshot)
+	s.minuteHub = newPushHub("minute", cfg.pushEvery, normalizeCode, s.pollMinute, diffMinute)
+	s.tradeHub = newPushHub("trade", cfg.pushEvery, normalizeCode, s.pollTrade, diffTrade)
+	if s.exPool != nil {
+		s.exQuoteHub = newPushHub("ex_quote", cfg.pushEvery, normalizeExCode, s.pollExQuote, diffSnapshot)
+	}
+
 	mux := http.NewServeMux()
 	s.registerRoutes(mux)
 
@@ -113,6 +295,14 @@ func New(opts ...Option) (*Server, error) {
 	return s, nil
 }
 
+// withHook 连接选项加上观测回调,h 为 nil 时原样返回
+func withHook(options []client.Option, h tdx.Hook) []client.Option {
+	if h == nil {
+		return options
+	}
+	return append(append([]client.Option(nil), options...), tdx.WithHook(h))
+}
+
 // Default 使用默认配置创建 HTTP 服务(开启断线重连)
 func Default(opts ...Option) (*Server, error) {
 	opts = append([]Option{WithOptions(tdx.WithRedial())}, opts...)
@@ -126,6 +316,11 @@ func (s *Server) Run() error {
 
 // Close 关闭 HTTP 服务
 func (s *Server) Close() error {
+	for _, hub := range []*pushHub{s.quoteHub, s.minuteHub, s.tradeHub, s.exQuoteHub} {
+		if hub != nil {
+			hub.close()
+		}
+	}
 	return s.server.Close()
 }
 
@@ -133,97 +328,45 @@ func (s *Server) Close() error {
 func (s *Server) registerRoutes(mux *http.ServeMux) {
 	// 健康检查
 	mux.HandleFunc("GET /", s.handleHealth)
+	mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	if s.metrics != nil {
+		mux.HandleFunc("GET /metrics", s.handleMetrics)
+	}
+
+	// 数据接口,见 routeTable,鉴权在参数校验和缓存之前
+	s.routes = s.routeTable()
+	for _, rt := range s.routes {
+		h := rt.Handler
+		if tabular(rt.Data) {
+			h = withFormat(h)
+		}
+		if rt.Policy != nil {
+			h = s.cached(rt.Policy, h)
+		}
+		mux.HandleFunc(rt.Method+" "+rt.Path, s.observed(rt.Path, s.authed(validate(rt.Params, h))))
+	}
+
+	// 批量,见 batch.go
+	mux.HandleFunc("POST /batch/kline", s.observed("/batch/kline", s.authed(s.handleBatchKline)))
+	mux.HandleFunc("POST /batch/minute", s.observed("/batch/minute", s.authed(s.handleBatchMinute)))
+	mux.HandleFunc("POST /batch/finance", s.observed("/batch/finance", s.authed(s.handleBatchFinance)))
+	mux.HandleFunc("POST /batch/gbbq", s.observed("/batch/gbbq", s.authed(s.handleBatchGbbq)))
 
-	// 代码/数量
-	mux.HandleFunc("GET /count", s.handleCount)
-	mux.HandleFunc("GET /code", s.handleCode)
-	mux.HandleFunc("GET /code/all", s.handleCodeAll)
-	mux.HandleFunc("GET /code/stocks", s.handleStockCodeAll)
-	mux.HandleFunc("GET /code/etfs", s.handleETFCodeAll)
-	mux.HandleFunc("GET /code/indexes", s.handleIndexCodeAll)
-
-	// 行情/财务
-	mux.HandleFunc("GET /quote", s.handleQuote)
-	mux.HandleFunc("GET /call_auction", s.handleCallAuction)
-	mux.HandleFunc("GET /gbbq", s.handleGbbq)
-	mux.HandleFunc("GET /finance", s.handleFinanceInfo)
-	mux.HandleFunc("GET /company/category", s.handleCompanyCategory)
-	mux.HandleFunc("GET /company/content", s.handleCompanyContent)
-
-	// 分时/成交
-	mux.HandleFunc("GET /minute", s.handleMinute)
-	mux.HandleFunc("GET /minute/history", s.handleHistoryMinute)
-	mux.HandleFunc("GET /trade", s.handleTrade)
-	mux.HandleFunc("GET /trade/all", s.handleTradeAll)
-	mux.HandleFunc("GET /trade/history", s.handleHistoryTrade)
-	mux.HandleFunc("GET /trade/history/day", s.handleHistoryTradeDay)
-
-	// K线(股票)
-	mux.HandleFunc("GET /kline", s.handleKline)
-	mux.HandleFunc("GET /kline/all", s.handleKlineAll)
-	mux.HandleFunc("GET /kline/minute", s.handleKlineMinute)
-	mux.HandleFunc("GET /kline/minute/all", s.handleKlineMinuteAll)
-	mux.HandleFunc("GET /kline/5minute", s.handleKline5Minute)
-	mux.HandleFunc("GET /kline/5minute/all", s.handleKline5MinuteAll)
-	mux.HandleFunc("GET /kline/15minute", s.handleKline15Minute)
-	mux.HandleFunc("GET /kline/15minute/all", s.handleKline15MinuteAll)
-	mux.HandleFunc("GET /kline/30minute", s.handleKline30Minute)
-	mux.HandleFunc("GET /kline/30minute/all", s.handleKline30MinuteAll)
-	mux.HandleFunc("GET /kline/60minute", s.handleKline60Minute)
-	mux.HandleFunc("GET /kline/60minute/all", s.handleKline60MinuteAll)
-	mux.HandleFunc("GET /kline/day", s.handleKlineDay)
-	mux.HandleFunc("GET /kline/day/all", s.handleKlineDayAll)
-	mux.HandleFunc("GET /kline/week", s.handleKlineWeek)
-	mux.HandleFunc("GET /kline/week/all", s.handleKlineWeekAll)
-	mux.HandleFunc("GET /kline/month", s.handleKlineMonth)
-	mux.HandleFunc("GET /kline/month/all", s.handleKlineMonthAll)
-	mux.HandleFunc("GET /kline/quarter", s.handleKlineQuarter)
-	mux.HandleFunc("GET /kline/quarter/all", s.handleKlineQuarterAll)
-	mux.HandleFunc("GET /kline/year", s.handleKlineYear)
-	mux.HandleFunc("GET /kline/year/all", s.handleKlineYearAll)
-
-	// 指数K线
-	mux.HandleFunc("GET /index", s.handleIndex)
-	mux.HandleFunc("GET /index/all", s.handleIndexAll)
-	mux.HandleFunc("GET /index/minute", s.handleIndexMinute)
-	mux.HandleFunc("GET /index/5minute", s.handleIndex5Minute)
-	mux.HandleFunc("GET /index/15minute", s.handleIndex15Minute)
-	mux.HandleFunc("GET /index/30minute", s.handleIndex30Minute)
-	mux.HandleFunc("GET /index/60minute", s.handleIndex60Minute)
-	mux.HandleFunc("GET /index/day", s.handleIndexDay)
-	mux.HandleFunc("GET /index/day/all", s.handleIndexDayAll)
-	mux.HandleFunc("GET /index/week/all", s.handleIndexWeekAll)
-	mux.HandleFunc("GET /index/month/all", s.handleIndexMonthAll)
-	mux.HandleFunc("GET /index/quarter/all", s.handleIndexQuarterAll)
-	mux.HandleFunc("GET /index/year/all", s.handleIndexYearAll)
-
-	// 板块/报表
-	mux.HandleFunc("GET /block/data", s.handleBlockData)
-	mux.HandleFunc("GET /block/data/index", s.handleBlockDataWithIndex)
-	mux.HandleFunc("GET /block/file", s.handleBlockFileRaw)
-	mux.HandleFunc("GET /report/file", s.handleReportFile)
-	mux.HandleFunc("GET /zhb/files", s.handleZHBFiles)
-	mux.HandleFunc("GET /tdx/zs", s.handleTdxZs)
-	mux.HandleFunc("GET /tdx/bk", s.handleTdxBk)
-	mux.HandleFunc("GET /tdx/stat", s.handleTdxStat)
-	mux.HandleFunc("GET /tdx/stat2", s.handleTdxStat2)
-	mux.HandleFunc("GET /tdx/xgsg", s.handleTdxXgsg)
-	mux.HandleFunc("GET /tdx/hy", s.handleTdxHy)
-	mux.HandleFunc("GET /spblock", s.handleSpBlock)
-
-	// 扩展行情
+	// 推送
+	mux.HandleFunc("GET /ws/quote", s.authed(s.handleWS(s.quoteHub).ServeHTTP))
+	mux.HandleFunc("GET /ws/minute", s.authed(s.handleWS(s.minuteHub).ServeHTTP))
+	mux.HandleFunc("GET /ws/trade", s.authed(s.handleWS(s.tradeHub).ServeHTTP))
+	mux.HandleFunc("GET /sse/quote", s.authed(s.handleSSE(s.quoteHub)))
+	mux.HandleFunc("POST /sse/quote", s.authed(s.handleSSE(s.quoteHub)))
 	if s.exPool != nil {
-		mux.HandleFunc("GET /ex/markets", s.handleExMarkets)
-		mux.HandleFunc("GET /ex/count", s.handleExCount)
-		mux.HandleFunc("GET /ex/instruments", s.handleExInstruments)
-		mux.HandleFunc("GET /ex/quote", s.handleExQuote)
-		mux.HandleFunc("GET /ex/quote_list", s.handleExQuoteList)
-		mux.HandleFunc("GET /ex/bars", s.handleExBars)
-		mux.HandleFunc("GET /ex/minute", s.handleExMinute)
-		mux.HandleFunc("GET /ex/minute/hist", s.handleExHistMinute)
-		mux.HandleFunc("GET /ex/trade", s.handleExTrade)
-		mux.HandleFunc("GET /ex/trade/hist", s.handleExHistTrade)
-		mux.HandleFunc("GET /ex/bars/range", s.handleExBarsRange)
+		mux.HandleFunc("GET /ws/ex/quote", s.authed(s.handleWS(s.exQuoteHub).ServeHTTP))
+		mux.HandleFunc("GET /sse/ex/quote", s.authed(s.handleSSE(s.exQuoteHub)))
+		mux.HandleFunc("POST /sse/ex/quote", s.authed(s.handleSSE(s.exQuoteHub)))
+	}
+
+	// 管理
+	if s.auth != nil && s.auth.enabled() {
+		mux.HandleFunc("GET /admin/usage", s.authed(s.handleAdminUsage))
 	}
 }
 
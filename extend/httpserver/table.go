@@ -0,0 +1,268 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 表格化
+//
+// 把响应数据转成 行×列 的表格,供 CSV/NDJSON/Arrow 输出:
+//
+//	带 List 字段的结构体(KlineResp/TradeResp/CodeResp...)  List 的每个元素一行
+//	切片(QuotesResp/[]ExKline...)                         每个元素一行
+//	结构体(FinanceInfo/ExQuote...)                        一行
+//	数字/字符串(ExCount)                                  一行,列名 value
+//
+// 列名为 json 标签或字段名的蛇形命名(preClose→pre_close, UpCount→up_count),
+// 嵌套结构体加前缀(kline_open),数组按序号展开(bid_1..bid_5, buy_level_1_price)。
+// protocol.Price 输出为元(float64),protocol.Exchange 输出为 sh/sz/bj,
+// 其他切片、map 等输出为 JSON 字符串。
+
+// columnKind 列类型
+type columnKind int
+
+const (
+	columnInt columnKind = iota
+	columnFloat
+	columnString
+	columnBool
+	columnTime
+)
+
+var (
+	priceType    = reflect.TypeOf(protocol.Price(0))
+	exchangeType = reflect.TypeOf(protocol.Exchange(0))
+)
+
+// column 一列,path 为从行到字段的访问路径,非负为字段序号,负数为数组下标(-1-i)
+type column struct {
+	Name string
+	Kind columnKind
+	path []int
+}
+
+// value 取一行中该列的值,返回 int64/float64/string/bool/time.Time,指针为nil时返回false
+func (this *column) value(row reflect.Value) (any, bool) {
+	v := row
+	for _, i := range this.path {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, false
+			}
+			v = v.Elem()
+		}
+		if i >= 0 {
+			v = v.Field(i)
+		} else {
+			v = v.Index(-1 - i)
+		}
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == priceType:
+		return protocol.Price(v.Int()).Float64(), true
+	case v.Type() == exchangeType:
+		return protocol.Exchange(v.Uint()).String(), true
+	case v.Type() == timeType:
+		return v.Interface().(time.Time), true
+	}
+	switch this.Kind {
+	case columnInt:
+		if v.CanInt() {
+			return v.Int(), true
+		}
+		return int64(v.Uint()), true
+	case columnFloat:
+		return v.Float(), true
+	case columnBool:
+		return v.Bool(), true
+	default:
+		if v.Kind() == reflect.String {
+			return v.String(), true
+		}
+		bs, _ := json.Marshal(v.Interface())
+		return string(bs), true
+	}
+}
+
+// table 表格
+type table struct {
+	Columns []*column
+	rows    reflect.Value //切片
+}
+
+func (this *table) Len() int { return this.rows.Len() }
+
+func (this *table) Row(i int) reflect.Value { return this.rows.Index(i) }
+
+// rowsOf 数据对应的行类型,不能表格化时返回false
+func rowsOf(t reflect.Type) (reflect.Type, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nil, false
+		}
+		return t.Elem(), true
+	case reflect.Struct:
+		if t == timeType {
+			return t, true
+		}
+		if f, ok := t.FieldByName("List"); ok && f.Type.Kind() == reflect.Slice {
+			return f.Type.Elem(), true
+		}
+		return t, true
+	case reflect.Map, reflect.Interface, reflect.Func, reflect.Chan, reflect.Invalid:
+		return nil, false
+	default:
+		return t, true
+	}
+}
+
+// tabular 是否可以表格化
+func tabular(data any) bool {
+	if data == nil {
+		return false
+	}
+	_, ok := rowsOf(reflect.TypeOf(data))
+	return ok
+}
+
+// newTable 把数据转成表格
+func newTable(data any) (*table, bool) {
+	if data == nil {
+		return nil, false
+	}
+	rowType, ok := rowsOf(reflect.TypeOf(data))
+	if !ok {
+		return nil, false
+	}
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return &table{Columns: columnsOf(rowType), rows: reflect.MakeSlice(reflect.SliceOf(rowType), 0, 0)}, true
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Slice:
+	case v.Kind() == reflect.Struct && v.Type() != rowType:
+		v = v.FieldByName("List")
+	default:
+		s := reflect.MakeSlice(reflect.SliceOf(rowType), 1, 1)
+		s.Index(0).Set(v)
+		v = s
+	}
+	return &table{Columns: columnsOf(rowType), rows: v}, true
+}
+
+// columnsOf 行类型的列
+func columnsOf(t reflect.Type) []*column {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return []*column{leafColumn("value", nil, t)}
+	}
+	var ls []*column
+	appendColumns(&ls, "", nil, t)
+	return ls
+}
+
+func appendColumns(ls *[]*column, prefix string, path []int, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	join := func(i int) []int {
+		return append(append([]int(nil), path...), i)
+	}
+	switch {
+	case t == priceType, t == exchangeType, t == timeType:
+		//作为一列,不展开
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if tag == "-" {
+				continue
+			}
+			name := tag
+			if name == "" {
+				name = f.Name
+			}
+			if f.Anonymous && tag == "" {
+				appendColumns(ls, prefix, join(i), f.Type)
+				continue
+			}
+			appendColumns(ls, prefix+snakeCase(name)+"_", join(i), f.Type)
+		}
+		return
+	case t.Kind() == reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			appendColumns(ls, prefix+strconv.Itoa(i+1)+"_", join(-1-i), t.Elem())
+		}
+		return
+	}
+	*ls = append(*ls, leafColumn(strings.TrimSuffix(prefix, "_"), path, t))
+}
+
+// leafColumn 非结构体字段
+func leafColumn(name string, path []int, t reflect.Type) *column {
+	c := &column{Name: name, path: path}
+	switch {
+	case t == priceType:
+		c.Kind = columnFloat
+	case t == exchangeType:
+		c.Kind = columnString
+	case t == timeType:
+		c.Kind = columnTime
+	default:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			c.Kind = columnInt
+		case reflect.Float32, reflect.Float64:
+			c.Kind = columnFloat
+		case reflect.Bool:
+			c.Kind = columnBool
+		default:
+			c.Kind = columnString
+		}
+	}
+	return c
+}
+
+// snakeCase UpCount→up_count, preClose→pre_close, LiuTongGuBen→liu_tong_gu_ben
+func snakeCase(s string) string {
+	rs := []rune(s)
+	b := strings.Builder{}
+	for i, r := range rs {
+		if unicode.IsUpper(r) {
+			//连续大写(如 ETF)只在开头和接下来是小写时断开
+			if i > 0 && (unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1]) ||
+				(i+1 < len(rs) && unicode.IsLower(rs[i+1]) && unicode.IsUpper(rs[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,295 @@
+package extend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/injoyai/tdx/lib/gbbq"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 通达信安装目录下的其他本地数据文件,与网络接口的数据结构保持一致,
+// 使离线的通达信安装目录可以作为完整的数据源:
+//
+//	T0002/hq_cache/block_*.dat   板块文件,同 GetBlockData
+//	T0002/hq_cache/tdxhy.cfg     行业归属,同 GetTdxHy
+//	T0002/hq_cache/tdxzs.cfg     指数板块,同 GetTdxZs
+//	T0002/hq_cache/gbbq          股本变迁(加密),同 GetGbbq
+//	T0002/hq_cache/base.dbf      基本财务,同 GetFinanceInfo
+//	T0002/blocknew/*.blk         自定义板块,blocknew.cfg 为板块名称
+//	vipdoc/cw/gpcw*.zip|dat      专业财务数据(部分版本在 tdxfin/ 下)
+
+// readHqCache 读取 T0002/hq_cache 下的文件
+func readHqCache(dir, name string) ([]byte, error) {
+	filename := filepath.Join(dir, "T0002", "hq_cache", name)
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("读取本地文件失败 %s: %w", filename, err)
+	}
+	return bs, nil
+}
+
+// ReadBlockFile 读取本地板块文件,file 如 block_gn.dat/block_fg.dat/block_zs.dat
+func ReadBlockFile(dir, file string) ([]*protocol.Block, error) {
+	bs, err := readHqCache(dir, file)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.ParseBlockFile(bs), nil
+}
+
+// ReadTdxHy 读取本地行业归属(tdxhy.cfg)
+func ReadTdxHy(dir string) ([]*protocol.TdxHy, error) {
+	bs, err := readHqCache(dir, "tdxhy.cfg")
+	if err != nil {
+		return nil, err
+	}
+	return protocol.ParseTdxHy(bs), nil
+}
+
+// ReadTdxZs 读取本地指数板块(tdxzs.cfg)
+func ReadTdxZs(dir string) ([]*protocol.TdxZs, error) {
+	bs, err := readHqCache(dir, "tdxzs.cfg")
+	if err != nil {
+		return nil, err
+	}
+	return protocol.ParseTdxZs(bs), nil
+}
+
+// ReadGbbq 读取本地股本变迁(hq_cache/gbbq),数值单位与 GetGbbq 一致(股本为股)
+func ReadGbbq(dir string) ([]*protocol.Gbbq, error) {
+	bs, err := readHqCache(dir, "gbbq")
+	if err != nil {
+		return nil, err
+	}
+	ls, err := gbbq.Decode(bs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*protocol.Gbbq, 0, len(ls))
+	for _, v := range ls {
+		g := &protocol.Gbbq{
+			Code:     protocol.Exchange(v.Market).String() + v.Code,
+			Time:     time.Date(v.Date.Year(), v.Date.Month(), v.Date.Day(), 15, 0, 0, 0, time.Local),
+			Category: v.Category,
+		}
+		switch g.Category {
+		case 1:
+			g.C1, g.C2, g.C3, g.C4 = v.C1, v.C2, v.C3, v.C4
+		case 11, 12:
+			g.C3 = v.C3
+		case 13, 14:
+			g.C1, g.C3 = v.C1, v.C3
+		default:
+			//本地文件股本单位为万股
+			g.C1, g.C2, g.C3, g.C4 = v.C1*1e4, v.C2*1e4, v.C3*1e4, v.C4*1e4
+		}
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+// ReadBaseDBF 读取本地基本财务(hq_cache/base.dbf),数值单位与 GetFinanceInfo 一致
+// base.dbf 无应收账款/现金流/存货字段,对应值为0
+func ReadBaseDBF(dir string) ([]*protocol.FinanceInfo, error) {
+	bs, err := readHqCache(dir, "base.dbf")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ParseDBF(bs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*protocol.FinanceInfo, 0, len(rows))
+	for _, row := range rows {
+		f := func(key string) float64 {
+			v, _ := strconv.ParseFloat(row[key], 64)
+			return v
+		}
+		w := func(key string) float64 { return f(key) * 1e4 }
+		out = append(out, &protocol.FinanceInfo{
+			Market:         uint8(f("SC")),
+			Code:           row["GPDM"],
+			LiuTongGuBen:   w("LTAG"),
+			Province:       uint16(f("DY")),
+			Industry:       uint16(f("HY")),
+			UpdatedDate:    uint32(f("GXRQ")),
+			IPODate:        uint32(f("SSDATE")),
+			ZongGuBen:      w("ZGB"),
+			GuoJiaGu:       w("GJG"),
+			FaQiRenFaRenGu: w("FQRFRG"),
+			FaRenGu:        w("FRG"),
+			BGu:            w("BG"),
+			HGu:            w("HG"),
+			ZhiGongGu:      w("ZGG"),
+			ZongZiChan:     w("ZZC"),
+			LiuDongZiChan:  w("LDZC"),
+			GuDingZiChan:   w("GDZC"),
+			WuXingZiChan:   w("WXZC"),
+			GuDongRenShu:   f("GDRS"),
+			LiuDongFuZhai:  w("LDFZ"),
+			ChangQiFuZhai:  w("CQFZ"),
+			ZiBenGongJiJin: w("ZBGJJ"),
+			JingZiChan:     w("JZC"),
+			ZhuYingShouRu:  w("ZYSY"),
+			ZhuYingLiRun:   w("ZYLY"),
+			YingYeLiRun:    w("YYLY"),
+			TouZiShouYi:    w("TZSY"),
+			LiRunZongHe:    w("LYZE"),
+			ShuiHouLiRun:   w("SHLY"),
+			JingLiRun:      w("JLY"),
+			WeiFenLiRun:    w("WFPLY"),
+		})
+	}
+	return out, nil
+}
+
+// ParseDBF 解析 dBase III(.dbf) 文件,返回每条记录(字段名→去掉首尾空格的文本),跳过已删除的记录
+//
+//	头部 32 字节: 04~07 uint32 记录数, 08~09 uint16 头部长度, 10~11 uint16 记录长度
+//	字段描述 每个 32 字节,以 0x0D 结束: 00~10 字段名, 11 类型, 16 长度
+//	记录 首字节为删除标记('*'为已删除),之后按字段长度依次排列
+func ParseDBF(bs []byte) ([]map[string]string, error) {
+	if len(bs) < 32 {
+		return nil, errors.New("dbf 数据长度不足")
+	}
+	count := int(binary.LittleEndian.Uint32(bs[4:8]))
+	headerLen := int(binary.LittleEndian.Uint16(bs[8:10]))
+	recordLen := int(binary.LittleEndian.Uint16(bs[10:12]))
+	if recordLen < 1 || headerLen < 32 || headerLen > len(bs) {
+		return nil, fmt.Errorf("dbf 头部无效: 头部长度 %d, 记录长度 %d", headerLen, recordLen)
+	}
+	//记录数来自头部,按实际数据长度限制
+	count = max(min(count, (len(bs)-headerLen)/recordLen), 0)
+
+	type field struct {
+		name   string
+		length int
+	}
+	fields := []field(nil)
+	for pos := 32; pos+32 <= len(bs) && pos < headerLen && bs[pos] != 0x0D; pos += 32 {
+		name := string(bytes.TrimRight(bs[pos:pos+11], "\x00"))
+		fields = append(fields, field{name: name, length: int(bs[pos+16])})
+	}
+
+	out := make([]map[string]string, 0, count)
+	for i := 0; i < count; i++ {
+		pos := headerLen + i*recordLen
+		if pos+recordLen > len(bs) {
+			break
+		}
+		rec := bs[pos : pos+recordLen]
+		if rec[0] == '*' {
+			continue
+		}
+		row := make(map[string]string, len(fields))
+		offset := 1
+		for _, f := range fields {
+			if offset+f.length > len(rec) {
+				break
+			}
+			row[f.name] = strings.TrimSpace(string(protocol.UTF8ToGBK(rec[offset : offset+f.length])))
+			offset += f.length
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+// ReadBlk 读取自定义板块文件(.blk),每行为 市场(0深,1沪,2北)+6位代码,返回带交易所前缀的代码
+func ReadBlk(filename string) ([]string, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBlk(bs), nil
+}
+
+// ParseBlk 解析自定义板块文件(.blk)内容
+func ParseBlk(bs []byte) []string {
+	codes := []string(nil)
+	for _, line := range strings.Split(string(bs), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) != 7 || line[0] < '0' || line[0] > '9' {
+			continue
+		}
+		codes = append(codes, protocol.Exchange(line[0]-'0').String()+line[1:])
+	}
+	return codes
+}
+
+// ReadCustomBlocks 读取全部自定义板块(T0002/blocknew),板块名称取自 blocknew.cfg,
+// 未登记的 .blk 以文件名作为板块名称。注意: Codes 带交易所前缀
+func ReadCustomBlocks(dir string) ([]*protocol.Block, error) {
+	blockDir := filepath.Join(dir, "T0002", "blocknew")
+	names := map[string]string{} //文件名(小写,不含后缀)→板块名称
+	if bs, err := os.ReadFile(filepath.Join(blockDir, "blocknew.cfg")); err == nil {
+		//每条 120 字节: 00~49 名称(GBK), 50~119 文件名
+		for i := 0; i+120 <= len(bs); i += 120 {
+			name, _, _ := bytes.Cut(bs[i:i+50], []byte{0})
+			file, _, _ := bytes.Cut(bs[i+50:i+120], []byte{0})
+			if len(file) > 0 {
+				names[strings.ToLower(string(file))] = strings.TrimSpace(string(protocol.UTF8ToGBK(name)))
+			}
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(blockDir, "*.blk"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	out := make([]*protocol.Block, 0, len(files))
+	for _, filename := range files {
+		codes, err := ReadBlk(filename)
+		if err != nil {
+			return nil, err
+		}
+		file := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		name := names[strings.ToLower(file)]
+		if len(name) == 0 {
+			name = file
+		}
+		out = append(out, &protocol.Block{Name: name, Codes: codes})
+	}
+	return out, nil
+}
+
+// ListGpcw 列出本地专业财务数据文件(vipdoc/cw 和 tdxfin 下的 gpcw*.zip/gpcw*.dat),按文件名排序
+func ListGpcw(dir string) ([]string, error) {
+	ls := []string(nil)
+	for _, sub := range []string{filepath.Join("vipdoc", "cw"), "tdxfin"} {
+		files, err := filepath.Glob(filepath.Join(dir, sub, "gpcw*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range files {
+			switch strings.ToLower(filepath.Ext(v)) {
+			case ".zip", ".dat":
+				ls = append(ls, v)
+			}
+		}
+	}
+	sort.Slice(ls, func(i, j int) bool { return filepath.Base(ls[i]) < filepath.Base(ls[j]) })
+	return ls, nil
+}
+
+// ReadGpcw 读取专业财务数据文件,支持 gpcwYYYYMMDD.zip 和解压后的 gpcwYYYYMMDD.dat
+func ReadGpcw(filename string) ([]*protocol.FinancialReport, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(filename), ".zip") {
+		return protocol.DecodeFinancialReportZip(bs)
+	}
+	return protocol.ParseFinancialReport(bs)
+}
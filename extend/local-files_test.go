@@ -0,0 +1,143 @@
+package extend
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDBF(t *testing.T) {
+	// 2 个字段: GPDM(C,6) ZGB(N,10), 3 条记录,第 2 条已删除
+	fields := []struct {
+		name   string
+		length int
+	}{{"GPDM", 6}, {"ZGB", 10}}
+	headerLen := 32 + 32*len(fields) + 1
+	recordLen := 1 + 6 + 10
+	bs := make([]byte, headerLen)
+	binary.LittleEndian.PutUint32(bs[4:8], 3)
+	binary.LittleEndian.PutUint16(bs[8:10], uint16(headerLen))
+	binary.LittleEndian.PutUint16(bs[10:12], uint16(recordLen))
+	for i, f := range fields {
+		copy(bs[32+i*32:], f.name)
+		bs[32+i*32+16] = byte(f.length)
+	}
+	bs[headerLen-1] = 0x0D
+	bs = append(bs, " 600000  12345.67"...)
+	bs = append(bs, "*000001      1.00"...)
+	bs = append(bs, " 000002     88.50"...)
+
+	rows, err := ParseDBF(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("记录数不一致: got %d want 2", len(rows))
+	}
+	if rows[0]["GPDM"] != "600000" || rows[0]["ZGB"] != "12345.67" || rows[1]["GPDM"] != "000002" {
+		t.Errorf("记录不一致: %v", rows)
+	}
+
+	//记录数大于实际数据时按数据长度截断
+	binary.LittleEndian.PutUint32(bs[4:8], 1<<31)
+	if rows, err = ParseDBF(bs); err != nil || len(rows) != 2 {
+		t.Errorf("记录数过大: %d %v", len(rows), err)
+	}
+	//记录长度为0或头部长度超出数据时返回错误
+	for _, v := range [][2]uint16{{uint16(headerLen), 0}, {uint16(len(bs) + 1), uint16(recordLen)}} {
+		bad := append([]byte(nil), bs...)
+		binary.LittleEndian.PutUint16(bad[8:10], v[0])
+		binary.LittleEndian.PutUint16(bad[10:12], v[1])
+		if _, err := ParseDBF(bad); err == nil {
+			t.Errorf("头部长度 %d 记录长度 %d 应返回错误", v[0], v[1])
+		}
+	}
+}
+
+func TestParseBlk(t *testing.T) {
+	codes := ParseBlk([]byte("\r\n1600000\r\n0000001\r\n2920000\r\n"))
+	want := []string{"sh600000", "sz000001", "bj920000"}
+	if len(codes) != len(want) {
+		t.Fatalf("got %v want %v", codes, want)
+	}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("got %v want %v", codes, want)
+		}
+	}
+}
+
+func TestReadGpcw(t *testing.T) {
+	// 2 只股票,每只 3 列
+	data := make([]byte, 20+2*11)
+	binary.LittleEndian.PutUint32(data[2:6], 20240930)
+	binary.LittleEndian.PutUint16(data[6:8], 2)
+	binary.LittleEndian.PutUint32(data[12:16], 3*4)
+	for i, code := range []string{"000001", "600000"} {
+		pos := 20 + i*11
+		copy(data[pos:], code)
+		binary.LittleEndian.PutUint32(data[pos+7:], uint32(len(data)+i*12))
+	}
+	for _, v := range []float32{1.5, 2, 3, 4, 5.25, 6} {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("gpcw20240930.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	zw.Close()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "vipdoc", "cw", "gpcw20240930.zip")
+	if err = newFile(filename, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ListGpcw(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("文件数不一致: %v", files)
+	}
+	ls, err := ReadGpcw(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 2 || ls[1].Code != "600000" || ls[1].Date != 20240930 || ls[1].Values[1] != 5.25 || ls[0].Values[0] != 1.5 {
+		t.Errorf("解析不一致: %+v %+v", ls[0], ls[1])
+	}
+}
+
+func TestReadMinuteLegacy(t *testing.T) {
+	rec := make([]byte, 32)
+	binary.LittleEndian.PutUint16(rec[0:2], uint16((2024-2004)*2048+3*100+15))
+	binary.LittleEndian.PutUint16(rec[2:4], 9*60+35)
+	for i, v := range []uint32{1050, 1080, 1040, 1075} {
+		binary.LittleEndian.PutUint32(rec[4+i*4:], v)
+	}
+	binary.LittleEndian.PutUint32(rec[20:24], math.Float32bits(107500))
+	binary.LittleEndian.PutUint32(rec[24:28], 10000)
+
+	dir := t.TempDir()
+	if err := newFile(filepath.Join(dir, "vipdoc", "sz", "fzline", "sz000001.5"), rec); err != nil {
+		t.Fatal(err)
+	}
+	ks, err := ReadMinute5Legacy(dir, "sz000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != 1 {
+		t.Fatalf("数量不一致: %d", len(ks))
+	}
+	k := ks[0]
+	if k.Time.Format("2006-01-02 15:04") != "2024-03-15 09:35" || k.Open != 10500 || k.Close != 10750 || k.Volume != 100 || k.Amount != 107500000 {
+		t.Errorf("解析不一致: %v", k)
+	}
+}
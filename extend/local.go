@@ -129,6 +129,59 @@ func readMinute(dir string, code string, sub, ext string, skipTailPlaceholder bo
 	return ks, nil
 }
 
+// ReadMinute1Legacy 读取旧版本地 1 分钟线数据(minline/*.1),dir: 通达信安装目录
+// 旧版格式与 .lc1 的区别是价格为 uint32(价格×100),参考 pytdx TdxMinBarReader。
+func ReadMinute1Legacy(dir string, code string) (protocol.Klines, error) {
+	return readMinuteLegacy(dir, code, "minline", ".1")
+}
+
+// ReadMinute5Legacy 读取旧版本地 5 分钟线数据(fzline/*.5),dir: 通达信安装目录
+// 旧版格式与 .lc5 的区别是价格为 uint32(价格×100),参考 pytdx TdxMinBarReader。
+func ReadMinute5Legacy(dir string, code string) (protocol.Klines, error) {
+	return readMinuteLegacy(dir, code, "fzline", ".5")
+}
+
+// readMinuteLegacy 旧版分钟线(.1/.5)读取通用实现,每 32 字节一条
+//
+//	00~01 uint16 日期(同 .lc1)
+//	02~03 uint16 当日分钟数
+//	04~19 4×uint32 开盘/最高/最低/收盘(价格×100, 整数)
+//	20~23 float32 成交额(元)
+//	24~27 uint32 成交量(股/手)
+//	28~31 保留
+func readMinuteLegacy(dir string, code string, sub, ext string) (protocol.Klines, error) {
+	ex, c, err := decodeCode(code)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := readLocal(dir, ex, sub, c+ext)
+	if err != nil {
+		return nil, err
+	}
+	index := protocol.IsIndex(c)
+	ks := protocol.Klines{}
+	for i := 0; i+32 <= len(bs); i += 32 {
+		rec := bs[i : i+32]
+		d := binary.LittleEndian.Uint16(rec[0:2])
+		m := binary.LittleEndian.Uint16(rec[2:4])
+		t := time.Date(int(d/2048)+2004, time.Month(d%2048/100), int(d%2048%100), int(m/60), int(m%60), 0, 0, time.Local)
+		vol := int64(binary.LittleEndian.Uint32(rec[24:28]))
+		if !index {
+			vol /= 100 //股票成交量单位"股"转"手"
+		}
+		ks = append(ks, &protocol.Kline{
+			Open:   priceFromInt(binary.LittleEndian.Uint32(rec[4:8])),
+			High:   priceFromInt(binary.LittleEndian.Uint32(rec[8:12])),
+			Low:    priceFromInt(binary.LittleEndian.Uint32(rec[12:16])),
+			Close:  priceFromInt(binary.LittleEndian.Uint32(rec[16:20])),
+			Amount: protocol.Price(int64(math.Round(float64(math.Float32frombits(binary.LittleEndian.Uint32(rec[20:24]))) * 1000))), //元转厘
+			Volume: vol,
+			Time:   t,
+		})
+	}
+	return ks, nil
+}
+
 // decodeCode 解析股票代码,带交易所前缀(如 sz000001),返回交易所和带前缀的完整代码
 func decodeCode(code string) (protocol.Exchange, string, error) {
 	ex, c, err := protocol.DecodeCode(code)
@@ -0,0 +1,181 @@
+package moneyflow
+
+import (
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Bucket 单子大小
+type Bucket int
+
+const (
+	Small  Bucket = iota //小单
+	Medium               //中单
+	Large                //大单
+	Huge                 //超大单
+)
+
+func (this Bucket) String() string {
+	switch this {
+	case Huge:
+		return "超大单"
+	case Large:
+		return "大单"
+	case Medium:
+		return "中单"
+	default:
+		return "小单"
+	}
+}
+
+// Thresholds 按成交金额(元)划分单子大小,不小于 Huge 为超大单,不小于 Large 为大单,
+// 不小于 Medium 为中单,其余为小单
+type Thresholds struct {
+	Huge   float64 `json:"huge"`
+	Large  float64 `json:"large"`
+	Medium float64 `json:"medium"`
+}
+
+// DefaultThresholds 常见的划分: 超大单>=100万,大单>=20万,中单>=4万
+var DefaultThresholds = Thresholds{
+	Huge:   1000000,
+	Large:  200000,
+	Medium: 40000,
+}
+
+// Bucket 金额(元)对应的单子大小
+func (this Thresholds) Bucket(amount float64) Bucket {
+	switch {
+	case amount >= this.Huge:
+		return Huge
+	case amount >= this.Large:
+		return Large
+	case amount >= this.Medium:
+		return Medium
+	default:
+		return Small
+	}
+}
+
+// Flow 资金流向,金额(元),主动买为流入,主动卖为流出,中性盘单独统计不算净流入
+type Flow struct {
+	Code      string    `json:"code"` //代码或板块
+	Time      time.Time `json:"time"` //分钟为结束时间(同分钟K线),日为当天15:00
+	HugeIn    float64   `json:"hugeIn"`
+	HugeOut   float64   `json:"hugeOut"`
+	LargeIn   float64   `json:"largeIn"`
+	LargeOut  float64   `json:"largeOut"`
+	MediumIn  float64   `json:"mediumIn"`
+	MediumOut float64   `json:"mediumOut"`
+	SmallIn   float64   `json:"smallIn"`
+	SmallOut  float64   `json:"smallOut"`
+	Neutral   float64   `json:"neutral"` //中性盘金额
+	Trades    int       `json:"trades"`  //成交笔数
+}
+
+// Add 加入一笔成交,status 同 protocol.Trade.Status(0买 1卖 2中性)
+func (this *Flow) Add(b Bucket, status int, amount float64) {
+	this.Trades++
+	if status != 0 && status != 1 {
+		this.Neutral += amount
+		return
+	}
+	in, out := this.bucket(b)
+	if status == 0 {
+		*in += amount
+	} else {
+		*out += amount
+	}
+}
+
+func (this *Flow) bucket(b Bucket) (in, out *float64) {
+	switch b {
+	case Huge:
+		return &this.HugeIn, &this.HugeOut
+	case Large:
+		return &this.LargeIn, &this.LargeOut
+	case Medium:
+		return &this.MediumIn, &this.MediumOut
+	default:
+		return &this.SmallIn, &this.SmallOut
+	}
+}
+
+// Merge 累加另一个资金流向,用于板块汇总
+func (this *Flow) Merge(f *Flow) {
+	if f == nil {
+		return
+	}
+	this.HugeIn += f.HugeIn
+	this.HugeOut += f.HugeOut
+	this.LargeIn += f.LargeIn
+	this.LargeOut += f.LargeOut
+	this.MediumIn += f.MediumIn
+	this.MediumOut += f.MediumOut
+	this.SmallIn += f.SmallIn
+	this.SmallOut += f.SmallOut
+	this.Neutral += f.Neutral
+	this.Trades += f.Trades
+}
+
+// Net 某类单子的净流入
+func (this *Flow) Net(b Bucket) float64 {
+	in, out := this.bucket(b)
+	return *in - *out
+}
+
+// In 总流入
+func (this *Flow) In() float64 {
+	return this.HugeIn + this.LargeIn + this.MediumIn + this.SmallIn
+}
+
+// Out 总流出
+func (this *Flow) Out() float64 {
+	return this.HugeOut + this.LargeOut + this.MediumOut + this.SmallOut
+}
+
+// Amount 总成交额,包括中性盘
+func (this *Flow) Amount() float64 {
+	return this.In() + this.Out() + this.Neutral
+}
+
+// MainNet 主力净流入,超大单+大单
+func (this *Flow) MainNet() float64 {
+	return this.Net(Huge) + this.Net(Large)
+}
+
+// RetailNet 散户净流入,中单+小单
+func (this *Flow) RetailNet() float64 {
+	return this.Net(Medium) + this.Net(Small)
+}
+
+// MainRatio 主力净占比,主力净流入/总成交额
+func (this *Flow) MainRatio() float64 {
+	if a := this.Amount(); a > 0 {
+		return this.MainNet() / a
+	}
+	return 0
+}
+
+// minuteTime 成交所属的分钟,同 protocol.Trades.Klines:
+// 09:25集合竞价算到09:30,09:30:xx算到09:31,午休算到11:30,15:00之后算到15:00
+func minuteTime(t time.Time) time.Time {
+	ms := t.Hour()*60 + t.Minute()
+	ms = max(ms, 569) + 1
+	if ms > 690 && ms <= 780 {
+		ms = 690
+	}
+	ms = min(ms, 900)
+	return time.Date(t.Year(), t.Month(), t.Day(), ms/60, ms%60, 0, 0, t.Location())
+}
+
+// dayTime 成交所属的日,取当天15:00
+func dayTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 15, 0, 0, 0, t.Location())
+}
+
+// amount 成交金额(元)
+func amount(t *protocol.Trade) float64 {
+	return t.Amount().Float64()
+}
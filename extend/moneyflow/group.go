@@ -0,0 +1,74 @@
+package moneyflow
+
+import (
+	"sort"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Groups 板块(行业/概念)的成分股,板块 → 带前缀的代码
+type Groups map[string][]string
+
+// IndustryGroups 按通达信行业(tdxhy.cfg,见 tdx.Client.GetTdxHy)分组,
+// n>0 时取行业代码的前n个字符作为更粗的一级行业,例 T1001 取 n=3 为 T10
+func IndustryGroups(ls []*protocol.TdxHy, n int) Groups {
+	g := Groups{}
+	for _, v := range ls {
+		key := v.TdxHy
+		if key == "" {
+			continue
+		}
+		if n > 0 && len(key) > n {
+			key = key[:n]
+		}
+		g[key] = append(g[key], protocol.Exchange(v.Market).String()+v.Code)
+	}
+	return g
+}
+
+// BlockGroups 按板块(概念/风格/指数,见 tdx.Client.GetBlockDataWithIndex)分组,
+// 有板块指数代码时用 名称(880xxx) 作为板块
+func BlockGroups(ls []*protocol.Block) Groups {
+	g := Groups{}
+	for _, v := range ls {
+		key := v.Name
+		if v.Index != "" {
+			key += "(" + v.Index + ")"
+		}
+		for _, code := range v.Codes {
+			g[key] = append(g[key], protocol.AddPrefix(code))
+		}
+	}
+	return g
+}
+
+// Rollup 把个股的资金流向汇总到板块,没有数据的成分股跳过
+func Rollup(flows map[string]*Flow, g Groups) map[string]*Flow {
+	res := make(map[string]*Flow, len(g))
+	for name, codes := range g {
+		f := &Flow{Code: name}
+		for _, code := range codes {
+			if v := flows[code]; v != nil {
+				f.Merge(v)
+				f.Time = v.Time
+			}
+		}
+		res[name] = f
+	}
+	return res
+}
+
+// Rank 按主力净流入从大到小排序
+func Rank(flows map[string]*Flow) []*Flow {
+	ls := make([]*Flow, 0, len(flows))
+	for _, v := range flows {
+		ls = append(ls, v)
+	}
+	sort.Slice(ls, func(i, j int) bool {
+		if a, b := ls[i].MainNet(), ls[j].MainNet(); a != b {
+			return a > b
+		}
+		return ls[i].Code < ls[j].Code
+	})
+	return ls
+}
@@ -0,0 +1,236 @@
+// Package moneyflow 资金流向.
+//
+// 按逐笔成交(protocol.Trade)的金额把每笔成交分成超大单/大单/中单/小单(阈值可配置),
+// 主动买为流入、主动卖为流出,按分钟和按日汇总每个代码的净流入,
+// 再按通达信行业(GetTdxHy)和概念板块(GetBlockDataWithIndex)汇总,得到常见的"主力资金"排行。
+//
+// 成交数据是3秒左右的快照合并,不是真正的逐笔委托,所以结果和各软件的资金流向会有差异。
+package moneyflow
+
+import (
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// DefaultConcurrency Codes 同时获取成交的代码数量
+const DefaultConcurrency = 4
+
+type Option func(*Engine)
+
+// WithPool 连接池,可以传 *tdx.Manage,默认按并发数量 tdx.DialDefault 建立连接池
+func WithPool(p tdx.IPool) Option {
+	return func(e *Engine) {
+		e.pool = p
+	}
+}
+
+func WithDialPool(dial tdx.DialPoolFunc) Option {
+	return func(e *Engine) {
+		e.dialPool = dial
+	}
+}
+
+// WithClient 单个客户端,等同于只有一个连接的连接池,请求会排队
+func WithClient(c *tdx.Client) Option {
+	return WithDialClient(func() (*tdx.Client, error) { return c, nil })
+}
+
+func WithDialClient(dial tdx.DialClientFunc) Option {
+	return WithDialPool(func() (tdx.IPool, error) { return tdx.NewPool(dial, 1) })
+}
+
+// WithConcurrency Codes 同时获取成交的代码数量,默认 DefaultConcurrency,
+// 实际并发还受连接池的连接数量限制
+func WithConcurrency(n int) Option {
+	return func(e *Engine) {
+		e.concurrency = n
+	}
+}
+
+// WithThresholds 单子大小的金额阈值,默认 DefaultThresholds
+func WithThresholds(th Thresholds) Option {
+	return func(e *Engine) {
+		e.th = th
+	}
+}
+
+// WithByOrder 按每单的平均金额(成交金额/单数)划分单子大小,默认按每笔成交的金额,
+// 历史成交没有单数,按每笔成交的金额
+func WithByOrder(b ...bool) Option {
+	return func(e *Engine) {
+		e.byOrder = len(b) == 0 || b[0]
+	}
+}
+
+// New 新建,连接池在第一次获取数据时建立
+func New(op ...Option) *Engine {
+	e := &Engine{th: DefaultThresholds}
+	for _, v := range op {
+		if v != nil {
+			v(e)
+		}
+	}
+	if e.concurrency <= 0 {
+		e.concurrency = DefaultConcurrency
+	}
+	if e.dialPool == nil {
+		e.dialPool = func() (tdx.IPool, error) {
+			return tdx.NewPool(func() (*tdx.Client, error) { return tdx.DialDefault() }, e.concurrency)
+		}
+	}
+	e.fetch = e.trades
+	return e
+}
+
+type Engine struct {
+	th          Thresholds
+	byOrder     bool
+	concurrency int
+	dialPool    tdx.DialPoolFunc
+	fetch       func(c *tdx.Client, code string, date time.Time) (protocol.Trades, error) //测试时替换
+
+	pool tdx.IPool
+	mu   sync.Mutex
+}
+
+// do 从连接池取一个客户端执行 fn
+func (this *Engine) do(fn func(c *tdx.Client) error) error {
+	this.mu.Lock()
+	if this.pool == nil {
+		p, err := this.dialPool()
+		if err != nil {
+			this.mu.Unlock()
+			return err
+		}
+		this.pool = p
+	}
+	p := this.pool
+	this.mu.Unlock()
+	return p.Do(fn)
+}
+
+// Classify 一笔成交的单子大小
+func (this *Engine) Classify(t *protocol.Trade) Bucket {
+	a := amount(t)
+	if this.byOrder && t.Number > 1 {
+		a /= float64(t.Number)
+	}
+	return this.th.Bucket(a)
+}
+
+// Minutes 按分钟汇总,时间同 protocol.Trades.Klines,只返回有成交的分钟,按时间升序
+func (this *Engine) Minutes(code string, ts protocol.Trades) []*Flow {
+	var ls []*Flow
+	for _, v := range ts {
+		t := minuteTime(v.Time)
+		if len(ls) == 0 || !ls[len(ls)-1].Time.Equal(t) {
+			ls = append(ls, &Flow{Code: code, Time: t})
+		}
+		ls[len(ls)-1].Add(this.Classify(v), v.Status, amount(v))
+	}
+	return ls
+}
+
+// Day 按日汇总,成交需要是同一天的,没有成交时为nil
+func (this *Engine) Day(code string, ts protocol.Trades) *Flow {
+	if len(ts) == 0 {
+		return nil
+	}
+	f := &Flow{Code: code, Time: dayTime(ts[0].Time)}
+	for _, v := range ts {
+		f.Add(this.Classify(v), v.Status, amount(v))
+	}
+	return f
+}
+
+// Trades 某天的全部成交,当天的只能盘后获取完整数据,见 tdx.Client.GetTradeAll
+func (this *Engine) Trades(code string, date time.Time) (ts protocol.Trades, err error) {
+	err = this.do(func(c *tdx.Client) (err error) {
+		ts, err = this.fetch(c, code, date)
+		return
+	})
+	return
+}
+
+func (this *Engine) trades(c *tdx.Client, code string, date time.Time) (protocol.Trades, error) {
+	var resp *protocol.TradeResp
+	var err error
+	if now := time.Now(); date.Year() == now.Year() && date.YearDay() == now.YearDay() {
+		resp, err = c.GetTradeAll(code)
+	} else {
+		resp, err = c.GetHistoryTradeDay(date.Format("20060102"), code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.List, nil
+}
+
+// Code 某天一个代码的日资金流向和分钟资金流向
+func (this *Engine) Code(code string, date time.Time) (*Flow, []*Flow, error) {
+	code = protocol.AddPrefix(code)
+	ts, err := this.Trades(code, date)
+	if err != nil {
+		return nil, nil, err
+	}
+	return this.Day(code, ts), this.Minutes(code, ts), nil
+}
+
+// Codes 某天多个代码的日资金流向,按 WithConcurrency 并发获取,
+// 单个代码出错只打印日志,没有成交的代码不返回
+func (this *Engine) Codes(date time.Time, codes ...string) map[string]*Flow {
+	res := make(map[string]*Flow, len(codes))
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	limit := make(chan struct{}, this.concurrency)
+	for _, code := range codes {
+		code = protocol.AddPrefix(code)
+		limit <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-limit; wg.Done() }()
+			ts, err := this.Trades(code, date)
+			if err != nil {
+				logs.Err(code, err)
+				return
+			}
+			if f := this.Day(code, ts); f != nil {
+				mu.Lock()
+				res[code] = f
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// Industries 通达信行业分组,见 IndustryGroups
+func (this *Engine) Industries(n int) (Groups, error) {
+	var ls []*protocol.TdxHy
+	err := this.do(func(c *tdx.Client) (err error) {
+		ls, err = c.GetTdxHy()
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return IndustryGroups(ls, n), nil
+}
+
+// Concepts 概念板块分组,见 BlockGroups
+func (this *Engine) Concepts() (Groups, error) {
+	var ls []*protocol.Block
+	err := this.do(func(c *tdx.Client) (err error) {
+		ls, err = c.GetBlockDataWithIndex(protocol.BlockFileGN)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return BlockGroups(ls), nil
+}
@@ -0,0 +1,167 @@
+package moneyflow
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// trade 成交,价格为元,量为手
+func trade(hm string, price float64, volume, status, number int) *protocol.Trade {
+	t, _ := time.ParseInLocation("20060102 15:04:05", "20250102 "+hm, time.Local)
+	return &protocol.Trade{
+		Time:   t,
+		Price:  protocol.Price(math.Round(price * 1000)),
+		Volume: volume,
+		Status: status,
+		Number: number,
+	}
+}
+
+func TestThresholds_Bucket(t *testing.T) {
+	for amount, want := range map[float64]Bucket{
+		1000000: Huge,
+		999999:  Large,
+		200000:  Large,
+		40000:   Medium,
+		39999:   Small,
+		0:       Small,
+	} {
+		if got := DefaultThresholds.Bucket(amount); got != want {
+			t.Errorf("Bucket(%v) = %s, want %s", amount, got, want)
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	ts := protocol.Trades{
+		trade("09:25:00", 10, 2000, 0, 10),  //200万 超大单 买,集合竞价算到09:30
+		trade("09:30:03", 10, 300, 1, 3),    //30万 大单 卖
+		trade("09:30:06", 10, 50, 0, 1),     //5万 中单 买
+		trade("09:31:00", 10, 10, 1, 1),     //1万 小单 卖
+		trade("11:30:00", 10, 100, 2, 1),    //10万 中性,算到11:30
+		trade("14:59:57", 10, 1000, 0, 100), //100万 超大单 买
+	}
+	e := New()
+	d := e.Day("sz000001", ts)
+	if d.Time.Hour() != 15 || d.Trades != 6 {
+		t.Fatalf("Day = %+v", d)
+	}
+	if d.HugeIn != 3000000 || d.LargeOut != 300000 || d.MediumIn != 50000 || d.SmallOut != 10000 || d.Neutral != 100000 {
+		t.Errorf("Day = %+v", d)
+	}
+	if v := d.MainNet(); v != 2700000 {
+		t.Errorf("MainNet = %v", v)
+	}
+	if v := d.RetailNet(); v != 40000 {
+		t.Errorf("RetailNet = %v", v)
+	}
+	if v := d.Amount(); v != 3460000 {
+		t.Errorf("Amount = %v", v)
+	}
+
+	ms := e.Minutes("sz000001", ts)
+	want := []string{"09:30", "09:31", "09:32", "11:30", "15:00"}
+	if len(ms) != len(want) {
+		t.Fatalf("Minutes = %d, want %d", len(ms), len(want))
+	}
+	for i, v := range ms {
+		if s := v.Time.Format("15:04"); s != want[i] {
+			t.Errorf("Minutes[%d] = %s, want %s", i, s, want[i])
+		}
+	}
+	if ms[1].LargeOut != 300000 || ms[1].MediumIn != 50000 {
+		t.Errorf("Minutes[1] = %+v", ms[1])
+	}
+
+	//按单数平均后,200万/10单=20万为大单,100万/100单=1万为小单
+	e = New(WithByOrder())
+	d = e.Day("sz000001", ts)
+	if d.HugeIn != 0 || d.LargeIn != 2000000 || d.SmallIn != 1000000 {
+		t.Errorf("ByOrder Day = %+v", d)
+	}
+}
+
+func TestRollup(t *testing.T) {
+	flows := map[string]*Flow{
+		"sz000001": {Code: "sz000001", HugeIn: 100, LargeOut: 30},
+		"sh600000": {Code: "sh600000", LargeIn: 50},
+		"sh600036": {Code: "sh600036", HugeOut: 200},
+	}
+	hy := IndustryGroups([]*protocol.TdxHy{
+		{Market: 0, Code: "000001", TdxHy: "T1001"},
+		{Market: 1, Code: "600000", TdxHy: "T1001"},
+		{Market: 1, Code: "600036", TdxHy: "T1002"},
+	}, 3)
+	if len(hy) != 1 || len(hy["T10"]) != 3 {
+		t.Fatalf("IndustryGroups = %v", hy)
+	}
+	gn := BlockGroups([]*protocol.Block{
+		{Name: "银行", Index: "880471", Codes: []string{"000001", "600036"}},
+		{Name: "金融", Codes: []string{"600000"}},
+	})
+	if len(gn["银行(880471)"]) != 2 || gn["金融"][0] != "sh600000" {
+		t.Fatalf("BlockGroups = %v", gn)
+	}
+
+	if v := Rollup(flows, hy)["T10"].MainNet(); v != -80 {
+		t.Errorf("Rollup T10 MainNet = %v", v)
+	}
+	ls := Rank(Rollup(flows, gn))
+	if len(ls) != 2 || ls[0].Code != "金融" || ls[1].MainNet() != -130 {
+		t.Errorf("Rank = %v %v", ls[0], ls[1])
+	}
+}
+
+// stubPool 不连接服务器,记录同时执行的数量
+type stubPool struct {
+	mu        sync.Mutex
+	cur, peak int
+}
+
+func (this *stubPool) Get() (*tdx.Client, error) { return nil, nil }
+func (this *stubPool) Put(c *tdx.Client)         {}
+func (this *stubPool) Go(fn func(c *tdx.Client)) error {
+	go fn(nil)
+	return nil
+}
+
+func (this *stubPool) Do(fn func(c *tdx.Client) error) error {
+	this.mu.Lock()
+	this.cur++
+	this.peak = max(this.peak, this.cur)
+	this.mu.Unlock()
+	defer func() {
+		this.mu.Lock()
+		this.cur--
+		this.mu.Unlock()
+	}()
+	return fn(nil)
+}
+
+func TestEngine_Codes(t *testing.T) {
+	p := &stubPool{}
+	e := New(WithPool(p), WithConcurrency(2))
+	e.fetch = func(c *tdx.Client, code string, date time.Time) (protocol.Trades, error) {
+		time.Sleep(10 * time.Millisecond)
+		switch code {
+		case "sz000002":
+			return nil, errors.New("失败")
+		case "sz000003":
+			return nil, nil
+		}
+		return protocol.Trades{trade("09:30:03", 10, 300, 0, 3)}, nil
+	}
+	res := e.Codes(time.Now(), "000001", "000002", "000003", "600000", "600036")
+	if len(res) != 3 || res["sz000001"] == nil || res["sh600036"].LargeIn != 300000 {
+		t.Errorf("Codes = %v", res)
+	}
+	if p.peak != 2 {
+		t.Errorf("并发 = %d, want 2", p.peak)
+	}
+}
@@ -0,0 +1,113 @@
+package options
+
+import (
+	"math"
+)
+
+// Model 定价模型
+type Model string
+
+const (
+	ModelBS      Model = "bs"      //Black-Scholes,标的为现货(ETF/指数)
+	ModelBlack76 Model = "black76" //Black-76,标的为期货
+)
+
+// carry 持有成本,BS 为无风险利率,Black-76 为0
+func (this Model) carry(r float64) float64 {
+	if this == ModelBlack76 {
+		return 0
+	}
+	return r
+}
+
+// Greeks 希腊字母,Vega 和 Rho 为波动率/利率变动1%的价格变动,Theta 为每个自然日的价格变动
+type Greeks struct {
+	Delta float64 `json:"delta"`
+	Gamma float64 `json:"gamma"`
+	Vega  float64 `json:"vega"`
+	Theta float64 `json:"theta"`
+	Rho   float64 `json:"rho"`
+}
+
+// Price 理论价格,s 为标的价格(Black-76 为期货价格),k 行权价,t 剩余年数,r 无风险利率,sigma 波动率
+func Price(model Model, call bool, s, k, t, r, sigma float64) float64 {
+	if t <= 0 || sigma <= 0 {
+		return intrinsic(call, s, k)
+	}
+	b := model.carry(r)
+	d1, d2 := d12(s, k, t, b, sigma)
+	if call {
+		return s*math.Exp((b-r)*t)*normCDF(d1) - k*math.Exp(-r*t)*normCDF(d2)
+	}
+	return k*math.Exp(-r*t)*normCDF(-d2) - s*math.Exp((b-r)*t)*normCDF(-d1)
+}
+
+// GetGreeks 希腊字母
+func GetGreeks(model Model, call bool, s, k, t, r, sigma float64) Greeks {
+	if t <= 0 || sigma <= 0 || s <= 0 || k <= 0 {
+		return Greeks{}
+	}
+	b := model.carry(r)
+	d1, d2 := d12(s, k, t, b, sigma)
+	carry, discount := math.Exp((b-r)*t), math.Exp(-r*t)
+	g := Greeks{
+		Gamma: carry * normPDF(d1) / (s * sigma * math.Sqrt(t)),
+		Vega:  s * carry * normPDF(d1) * math.Sqrt(t) / 100,
+	}
+	decay := -s * carry * normPDF(d1) * sigma / (2 * math.Sqrt(t))
+	if call {
+		g.Delta = carry * normCDF(d1)
+		g.Theta = decay - (b-r)*s*carry*normCDF(d1) - r*k*discount*normCDF(d2)
+		g.Rho = k * t * discount * normCDF(d2)
+	} else {
+		g.Delta = carry * (normCDF(d1) - 1)
+		g.Theta = decay + (b-r)*s*carry*normCDF(-d1) + r*k*discount*normCDF(-d2)
+		g.Rho = -k * t * discount * normCDF(-d2)
+	}
+	if model == ModelBlack76 {
+		g.Rho = -t * Price(model, call, s, k, t, r, sigma)
+	}
+	g.Theta /= 365
+	g.Rho /= 100
+	return g
+}
+
+// ImpliedVol 隐含波动率,二分法,价格不在理论范围内(低于内在价值或高于上限)时返回 false
+func ImpliedVol(model Model, call bool, price, s, k, t, r float64) (float64, bool) {
+	if t <= 0 || price <= 0 || s <= 0 || k <= 0 {
+		return 0, false
+	}
+	lo, hi := 1e-4, 5.0
+	if price < Price(model, call, s, k, t, r, lo) || price > Price(model, call, s, k, t, r, hi) {
+		return 0, false
+	}
+	for i := 0; i < 100 && hi-lo > 1e-8; i++ {
+		mid := (lo + hi) / 2
+		if Price(model, call, s, k, t, r, mid) > price {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
+
+func d12(s, k, t, b, sigma float64) (float64, float64) {
+	d1 := (math.Log(s/k) + (b+sigma*sigma/2)*t) / (sigma * math.Sqrt(t))
+	return d1, d1 - sigma*math.Sqrt(t)
+}
+
+func intrinsic(call bool, s, k float64) float64 {
+	if call {
+		return math.Max(s-k, 0)
+	}
+	return math.Max(k-s, 0)
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
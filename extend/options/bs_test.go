@@ -0,0 +1,70 @@
+package options
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b, eps float64) bool {
+	return math.Abs(a-b) <= eps
+}
+
+func TestPrice(t *testing.T) {
+	//S=100 K=100 T=1 r=5% σ=20%
+	call := Price(ModelBS, true, 100, 100, 1, 0.05, 0.2)
+	put := Price(ModelBS, false, 100, 100, 1, 0.05, 0.2)
+	if !near(call, 10.4506, 1e-4) || !near(put, 5.5735, 1e-4) {
+		t.Errorf("BS call=%v put=%v", call, put)
+	}
+
+	//Black-76 平价: C-P=(F-K)e^(-rT)
+	c := Price(ModelBlack76, true, 3000, 2900, 0.5, 0.03, 0.25)
+	p := Price(ModelBlack76, false, 3000, 2900, 0.5, 0.03, 0.25)
+	if !near(c-p, 100*math.Exp(-0.015), 1e-9) {
+		t.Errorf("Black-76 c-p=%v", c-p)
+	}
+
+	//到期按内在价值
+	if v := Price(ModelBS, false, 2.5, 2.8, 0, 0.02, 0.2); !near(v, 0.3, 1e-12) {
+		t.Errorf("到期 put=%v", v)
+	}
+}
+
+func TestGreeks(t *testing.T) {
+	g := GetGreeks(ModelBS, true, 100, 100, 1, 0.05, 0.2)
+	if !near(g.Delta, 0.6368, 1e-4) || !near(g.Gamma, 0.018762, 1e-6) || !near(g.Vega, 0.37524, 1e-5) ||
+		!near(g.Theta, -6.414/365, 1e-5) || !near(g.Rho, 0.53232, 1e-5) {
+		t.Errorf("call greeks = %+v", g)
+	}
+	p := GetGreeks(ModelBS, false, 100, 100, 1, 0.05, 0.2)
+	if !near(g.Delta-p.Delta, 1, 1e-12) || !near(g.Gamma, p.Gamma, 1e-12) {
+		t.Errorf("put greeks = %+v", p)
+	}
+
+	//差分验证 Black-76
+	const s, k, T, r, sigma, h = 3000.0, 3100.0, 0.3, 0.02, 0.3, 0.01
+	g = GetGreeks(ModelBlack76, false, s, k, T, r, sigma)
+	delta := (Price(ModelBlack76, false, s+h, k, T, r, sigma) - Price(ModelBlack76, false, s-h, k, T, r, sigma)) / (2 * h)
+	vega := (Price(ModelBlack76, false, s, k, T, r, sigma+1e-4) - Price(ModelBlack76, false, s, k, T, r, sigma-1e-4)) / 2e-4 / 100
+	rho := (Price(ModelBlack76, false, s, k, T, r+1e-5, sigma) - Price(ModelBlack76, false, s, k, T, r-1e-5, sigma)) / 2e-5 / 100
+	theta := -(Price(ModelBlack76, false, s, k, T+1e-5, r, sigma) - Price(ModelBlack76, false, s, k, T-1e-5, r, sigma)) / 2e-5 / 365
+	if !near(g.Delta, delta, 1e-6) || !near(g.Vega, vega, 1e-6) || !near(g.Rho, rho, 1e-6) || !near(g.Theta, theta, 1e-6) {
+		t.Errorf("Black-76 greeks = %+v, want delta=%v vega=%v rho=%v theta=%v", g, delta, vega, rho, theta)
+	}
+}
+
+func TestImpliedVol(t *testing.T) {
+	for _, model := range []Model{ModelBS, ModelBlack76} {
+		for _, call := range []bool{true, false} {
+			price := Price(model, call, 2.8, 2.9, 0.2, 0.02, 0.23)
+			iv, ok := ImpliedVol(model, call, price, 2.8, 2.9, 0.2, 0.02)
+			if !ok || !near(iv, 0.23, 1e-6) {
+				t.Errorf("%s call=%v iv=%v %v", model, call, iv, ok)
+			}
+		}
+	}
+	//低于内在价值
+	if _, ok := ImpliedVol(ModelBS, true, 0.1, 3, 2.5, 0.2, 0.02); ok {
+		t.Error("低于内在价值应返回 false")
+	}
+}
@@ -0,0 +1,265 @@
+// Package options 期权链和希腊字母.
+//
+// 从扩展行情(7727)的代码表找出标的(50ETF/300ETF/IO/商品期货等)的全部期权合约,
+// 按名称/代码解析行权价、到期月份和认购认沽,批量获取行情,
+// 用 Black-Scholes(现货标的)或 Black-76(期货标的)计算隐含波动率、希腊字母和每个到期日的波动率微笑。
+package options
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+const (
+	DefaultRate = 0.02 //默认无风险利率
+
+	quotePage = 80 //ExQuoteList 每页数量
+	category  = 3  //期权的批量行情和期货同样格式
+)
+
+// QuoteSource 标的(ETF/指数/期货)行情,例 tdx.Facade
+type QuoteSource interface {
+	Quote(codes ...string) (protocol.QuotesResp, error)
+}
+
+// exQuoter 期权行情,即扩展行情的 tdx.Client
+type exQuoter interface {
+	ExQuote(market uint8, code string) (*protocol.ExQuote, error)
+	ExQuoteList(market, category uint8, start, count uint16) ([]protocol.ExQuoteListItem, error)
+}
+
+type Option func(*Client)
+
+// WithClient 扩展行情客户端,见 tdx.DialExHqDefault
+func WithClient(c *tdx.Client) Option {
+	return func(cli *Client) {
+		cli.c = c
+	}
+}
+
+func WithDialClient(dial tdx.DialClientFunc) Option {
+	return func(cli *Client) {
+		cli.dialClient = dial
+	}
+}
+
+// WithCodes 扩展代码表,默认 tdx.NewExCodes
+func WithCodes(codes tdx.IExCodes) Option {
+	return func(cli *Client) {
+		cli.codes = codes
+	}
+}
+
+// WithFacade 获取标的价格(ETF/指数/期货),默认 tdx.NewFacade,
+// ETF的价格需要代码表修正,见 tdx.WithFacadeCodes
+func WithFacade(f *tdx.Facade) Option {
+	return func(cli *Client) {
+		cli.spot = f
+	}
+}
+
+// WithSpot 标的价格的来源,同 WithFacade
+func WithSpot(s QuoteSource) Option {
+	return func(cli *Client) {
+		cli.spot = s
+	}
+}
+
+// WithRate 无风险利率,默认 DefaultRate
+func WithRate(r float64) Option {
+	return func(cli *Client) {
+		cli.rate = r
+	}
+}
+
+// WithTradingDay 交易日判断,用于推算到期日,默认周一到周五,例 tdx.Workday.Is
+func WithTradingDay(f func(t time.Time) bool) Option {
+	return func(cli *Client) {
+		cli.isTradingDay = f
+	}
+}
+
+// WithExpiry 自定义到期日,默认 Expiry
+func WithExpiry(f func(c *Contract) time.Time) Option {
+	return func(cli *Client) {
+		cli.expiry = f
+	}
+}
+
+func New(op ...Option) (*Client, error) {
+	cli := &Client{
+		rate:         DefaultRate,
+		isTradingDay: IsWeekday,
+	}
+	for _, v := range op {
+		if v != nil {
+			v(cli)
+		}
+	}
+	if cli.expiry == nil {
+		cli.expiry = func(c *Contract) time.Time { return Expiry(c.Market, c.Month, cli.isTradingDay) }
+	}
+
+	var err error
+	if cli.c == nil {
+		if cli.dialClient == nil {
+			cli.dialClient = func() (*tdx.Client, error) { return tdx.DialExHqDefault() }
+		}
+		cli.c, err = cli.dialClient()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cli.codes == nil {
+		cli.codes, err = tdx.NewExCodes(tdx.WithExCodesClient(cli.c))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cli.spot == nil {
+		cli.spot = tdx.NewFacade()
+	}
+	cli.ex = cli.c
+	return cli, nil
+}
+
+type Client struct {
+	rate         float64
+	isTradingDay func(t time.Time) bool
+	expiry       func(c *Contract) time.Time
+	dialClient   tdx.DialClientFunc
+
+	c     *tdx.Client
+	ex    exQuoter
+	codes tdx.IExCodes
+	spot  QuoteSource
+}
+
+// Contracts 标的的全部期权合约,按 到期日/行权价/认购在前 排序,
+// 标的为ETF代码(510050 sh510300 159919)、股指期权品种(IO HO MO)或商品期货品种(m SR cu)
+func (this *Client) Contracts(underlying string) ([]*Contract, error) {
+	u, ok := findUnderlying(underlying)
+	if !ok {
+		return nil, errors.New("不支持的期权标的: " + underlying)
+	}
+	now := time.Now()
+	var ls []*Contract
+	for _, m := range this.codes.GetMarket(u.market) {
+		if c, ok := u.match(m, now); ok {
+			c.Expiry = this.expiry(c)
+			ls = append(ls, c)
+		}
+	}
+	sortContracts(ls)
+	return ls, nil
+}
+
+// Chain 期权链,包含行情、隐含波动率和希腊字母
+func (this *Client) Chain(underlying string) (*Chain, error) {
+	contracts, err := this.Contracts(underlying)
+	if err != nil {
+		return nil, err
+	}
+	if len(contracts) == 0 {
+		return nil, errors.New("没有找到期权合约: " + underlying)
+	}
+
+	quotes, err := this.quotes(contracts)
+	if err != nil {
+		return nil, err
+	}
+
+	//标的价格,商品期权每个月份的标的不同
+	var codes []string
+	spot := map[string]float64{}
+	for _, c := range contracts {
+		if _, ok := spot[c.Underlying]; !ok {
+			spot[c.Underlying] = 0
+			codes = append(codes, c.Underlying)
+		}
+	}
+	qs, err := this.spot.Quote(codes...)
+	if err != nil {
+		return nil, err
+	}
+	for i, q := range qs {
+		if q != nil && q.Kline != nil {
+			spot[codes[i]] = quotePrice(q.Kline.Close.Float64(), q.Kline.Last.Float64())
+		}
+	}
+
+	return NewChain(underlying, contracts, quotes, spot, this.rate, time.Now()), nil
+}
+
+// quotes 分页获取期权市场的批量行情,列表里没有的合约再单独获取
+func (this *Client) quotes(contracts []*Contract) (map[string]*Quote, error) {
+	want := map[string]bool{}
+	markets := map[protocol.Exchange]bool{}
+	for _, c := range contracts {
+		want[c.Code] = true
+		markets[c.Market] = true
+	}
+
+	res := map[string]*Quote{}
+	for market := range markets {
+		for start := 0; ; start += quotePage {
+			ls, err := this.ex.ExQuoteList(uint8(market), category, uint16(start), quotePage)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range ls {
+				if want[v.Code] {
+					res[v.Code] = &Quote{
+						Price: v.Price, PreClose: v.PreClose, Bid: v.Bid[0], Ask: v.Ask[0],
+						Volume: v.ZongLiang, OI: v.ChiCang,
+					}
+				}
+			}
+			if len(ls) < quotePage || start+quotePage > math.MaxUint16-quotePage {
+				break
+			}
+		}
+	}
+
+	for _, c := range contracts {
+		if res[c.Code] != nil {
+			continue
+		}
+		q, err := this.ex.ExQuote(uint8(c.Market), c.Code)
+		if err != nil {
+			return nil, err
+		}
+		res[c.Code] = &Quote{
+			Price: q.Price, PreClose: q.PreClose, Bid: q.Bid[0], Ask: q.Ask[0],
+			Volume: q.ZongLiang, OI: q.ChiCang,
+		}
+	}
+	return res, nil
+}
+
+func sortContracts(ls []*Contract) {
+	sort.Slice(ls, func(i, j int) bool {
+		if !ls[i].Expiry.Equal(ls[j].Expiry) {
+			return ls[i].Expiry.Before(ls[j].Expiry)
+		}
+		if ls[i].Strike != ls[j].Strike {
+			return ls[i].Strike < ls[j].Strike
+		}
+		return ls[i].Call && !ls[j].Call
+	})
+}
+
+// quotePrice 第一个大于0的价格
+func quotePrice(ls ...float64) float64 {
+	for _, v := range ls {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,198 @@
+package options
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Contract 期权合约
+type Contract struct {
+	Market     protocol.Exchange `json:"market"`
+	Code       string            `json:"code"`       //例 10008123 IO2506-C-3800 m2509-C-3000 SR509C5800
+	Name       string            `json:"name"`       //例 50ETF购6月2800
+	Underlying string            `json:"underlying"` //标的代码,可用 protocol.ParseSymbol 解析,例 sh510050 sh000300 m2509
+	Call       bool              `json:"call"`       //认购
+	Strike     float64           `json:"strike"`     //行权价
+	Month      int               `json:"month"`      //到期月份 YYYYMM
+	Expiry     time.Time         `json:"expiry"`     //到期日
+	Adjusted   bool              `json:"adjusted"`   //分红等调整过的合约(名称带A),合约单位不是标准的
+	Model      Model             `json:"model"`
+}
+
+// underlying 标的和期权合约的对应关系
+type underlying struct {
+	market protocol.Exchange
+	prefix string //ETF期权的名称前缀,例 50ETF
+	code   string //ETF/指数的标的代码
+	root   string //指数/商品期权的品种
+}
+
+// etfUnderlyings ETF期权,按名称前缀匹配
+var etfUnderlyings = map[string]underlying{
+	"510050": {market: protocol.ExchangeSHO, prefix: "50ETF", code: "sh510050"},
+	"510300": {market: protocol.ExchangeSHO, prefix: "300ETF", code: "sh510300"},
+	"510500": {market: protocol.ExchangeSHO, prefix: "500ETF", code: "sh510500"},
+	"588000": {market: protocol.ExchangeSHO, prefix: "科创50", code: "sh588000"},
+	"588080": {market: protocol.ExchangeSHO, prefix: "科创板50", code: "sh588080"},
+	"159919": {market: protocol.ExchangeSZO, prefix: "300ETF", code: "sz159919"},
+	"159922": {market: protocol.ExchangeSZO, prefix: "500ETF", code: "sz159922"},
+	"159915": {market: protocol.ExchangeSZO, prefix: "创业板", code: "sz159915"},
+	"159901": {market: protocol.ExchangeSZO, prefix: "深100ETF", code: "sz159901"},
+}
+
+// indexUnderlyings 中金所股指期权
+var indexUnderlyings = map[string]string{
+	"IO": "sh000300",
+	"HO": "sh000016",
+	"MO": "sh000852",
+}
+
+// optionMarkets 期货交易所→期权市场
+var optionMarkets = map[protocol.Exchange]protocol.Exchange{
+	protocol.ExchangeCFF: protocol.ExchangeCFFO,
+	protocol.ExchangeDCE: protocol.ExchangeDCEO,
+	protocol.ExchangeCZC: protocol.ExchangeCZCO,
+	protocol.ExchangeSHF: protocol.ExchangeSHFO,
+	protocol.ExchangeGFE: protocol.ExchangeGFEO,
+}
+
+// findUnderlying 标的,ETF代码(510050,可带前缀)、股指期权品种(IO)或商品期货品种(m SR cu)
+func findUnderlying(s string) (underlying, bool) {
+	s = strings.TrimSpace(s)
+	if sym, err := protocol.ParseSymbol(s); err == nil && !sym.IsEx() {
+		v, ok := etfUnderlyings[sym.Code]
+		return v, ok
+	}
+	if v, ok := indexUnderlyings[strings.ToUpper(s)]; ok {
+		return underlying{market: protocol.ExchangeCFFO, code: v, root: strings.ToUpper(s)}, true
+	}
+	if ex, ok := protocol.FuturesExchange(s); ok {
+		return underlying{market: optionMarkets[ex], root: s}, true
+	}
+	return underlying{}, false
+}
+
+var (
+	etfNameRegexp = regexp.MustCompile(`^(.+?)(购|沽)(\d{1,2})月(\d+(?:\.\d+)?)(A?)$`)
+	codeRegexp    = regexp.MustCompile(`^([A-Za-z]+)(\d{3,4})-?([CP])-?(\d+(?:\.\d+)?)$`)
+)
+
+// match 解析合约,不是该标的的返回 false
+func (this underlying) match(m *tdx.ExCodeModel, now time.Time) (*Contract, bool) {
+	if protocol.Exchange(m.Market) != this.market {
+		return nil, false
+	}
+	c := &Contract{Market: this.market, Code: m.Code, Name: m.Name}
+
+	//ETF期权按名称解析,例 50ETF购6月2800,行权价单位为厘
+	if this.prefix != "" {
+		ls := etfNameRegexp.FindStringSubmatch(m.Name)
+		if ls == nil || ls[1] != this.prefix {
+			return nil, false
+		}
+		month, _ := strconv.Atoi(ls[3])
+		year := now.Year()
+		if month < int(now.Month()) {
+			year++
+		}
+		strike, _ := strconv.ParseFloat(ls[4], 64)
+		if !strings.Contains(ls[4], ".") {
+			strike /= 1000
+		}
+		c.Underlying, c.Call, c.Strike, c.Month, c.Adjusted, c.Model = this.code, ls[2] == "购", strike, year*100+month, ls[5] == "A", ModelBS
+		return c, true
+	}
+
+	//股指/商品期权按代码解析,例 IO2506-C-3800 SR509C5800
+	ls := codeRegexp.FindStringSubmatch(m.Code)
+	if ls == nil || !strings.EqualFold(ls[1], this.root) {
+		return nil, false
+	}
+	c.Month = contractMonth(ls[2], now)
+	c.Call = ls[3] == "C"
+	c.Strike, _ = strconv.ParseFloat(ls[4], 64)
+	if this.code != "" {
+		c.Underlying, c.Model = this.code, ModelBS
+	} else {
+		c.Underlying, c.Model = ls[1]+ls[2], ModelBlack76
+	}
+	return c, true
+}
+
+// contractMonth YYMM 或郑商所的 YMM,郑商所取离 now 最近的年份
+func contractMonth(s string, now time.Time) int {
+	n, _ := strconv.Atoi(s)
+	if len(s) == 4 {
+		return 200000 + n
+	}
+	year := now.Year()/10*10 + n/100
+	if year > now.Year()+5 {
+		year -= 10
+	} else if year < now.Year()-5 {
+		year += 10
+	}
+	return year*100 + n%100
+}
+
+// Expiry 到期日(近似规则,节假日用 isTradingDay 判断):
+//   - ETF期权: 到期月份第四个星期三,遇节假日顺延
+//   - 股指期权: 到期月份第三个星期五,遇节假日顺延
+//   - 大商所/广期所: 标的期货合约月份前一个月的第12个交易日
+//   - 郑商所: 标的期货合约月份前一个月的第15个交易日
+//   - 上期所: 标的期货合约月份前一个月的倒数第5个交易日
+//
+// 交易所规则会调整,不准时用 WithExpiry 指定
+func Expiry(market protocol.Exchange, month int, isTradingDay func(time.Time) bool) time.Time {
+	first := time.Date(month/100, time.Month(month%100), 1, 15, 0, 0, 0, time.Local)
+	switch market {
+	case protocol.ExchangeSHO, protocol.ExchangeSZO:
+		return nextTradingDay(nthWeekday(first, time.Wednesday, 4), isTradingDay)
+	case protocol.ExchangeCFFO:
+		return nextTradingDay(nthWeekday(first, time.Friday, 3), isTradingDay)
+	case protocol.ExchangeCZCO:
+		return nthTradingDay(first.AddDate(0, -1, 0), 15, isTradingDay)
+	case protocol.ExchangeSHFO:
+		return nthTradingDay(first.AddDate(0, -1, 0), -5, isTradingDay)
+	default:
+		return nthTradingDay(first.AddDate(0, -1, 0), 12, isTradingDay)
+	}
+}
+
+// IsWeekday 周一到周五,默认的交易日判断,可用 tdx.Workday.Is 代替
+func IsWeekday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// nthWeekday 当月第n个星期几
+func nthWeekday(first time.Time, w time.Weekday, n int) time.Time {
+	offset := (int(w) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+(n-1)*7)
+}
+
+func nextTradingDay(t time.Time, isTradingDay func(time.Time) bool) time.Time {
+	for i := 0; i < 30 && !isTradingDay(t); i++ {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// nthTradingDay 当月第n个交易日,n<0 为倒数
+func nthTradingDay(first time.Time, n int, isTradingDay func(time.Time) bool) time.Time {
+	t, step := first, 1
+	if n < 0 {
+		t, step, n = first.AddDate(0, 1, -1), -1, -n
+	}
+	for count := 0; t.Month() == first.Month(); t = t.AddDate(0, 0, step) {
+		if isTradingDay(t) {
+			if count++; count == n {
+				return t
+			}
+		}
+	}
+	return t
+}
@@ -0,0 +1,184 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2025, 11, 10, 10, 0, 0, 0, time.Local)
+	model := func(market protocol.Exchange, code, name string) *tdx.ExCodeModel {
+		return &tdx.ExCodeModel{Market: uint8(market), Code: code, Name: name}
+	}
+
+	etf, _ := findUnderlying("510050")
+	c, ok := etf.match(model(protocol.ExchangeSHO, "10009001", "50ETF购12月2800"), now)
+	if !ok || !c.Call || c.Strike != 2.8 || c.Month != 202512 || c.Underlying != "sh510050" || c.Model != ModelBS {
+		t.Errorf("50ETF购12月2800 = %+v", c)
+	}
+	//跨年,调整过的合约
+	c, ok = etf.match(model(protocol.ExchangeSHO, "10009002", "50ETF沽1月2750A"), now)
+	if !ok || c.Call || c.Strike != 2.75 || c.Month != 202601 || !c.Adjusted {
+		t.Errorf("50ETF沽1月2750A = %+v", c)
+	}
+	for _, v := range []*tdx.ExCodeModel{
+		model(protocol.ExchangeSHO, "10009003", "500ETF购12月6000"),
+		model(protocol.ExchangeSZO, "90000001", "50ETF购12月2800"),
+	} {
+		if c, ok := etf.match(v, now); ok {
+			t.Errorf("%s 不是 510050 的期权: %+v", v.Name, c)
+		}
+	}
+
+	io, _ := findUnderlying("IO")
+	c, ok = io.match(model(protocol.ExchangeCFFO, "IO2512-P-4500", ""), now)
+	if !ok || c.Call || c.Strike != 4500 || c.Month != 202512 || c.Underlying != "sh000300" || c.Model != ModelBS {
+		t.Errorf("IO2512-P-4500 = %+v", c)
+	}
+
+	sr, _ := findUnderlying("SR")
+	c, ok = sr.match(model(protocol.ExchangeCZCO, "SR601C5800", ""), now)
+	if !ok || !c.Call || c.Strike != 5800 || c.Month != 202601 || c.Underlying != "SR601" || c.Model != ModelBlack76 {
+		t.Errorf("SR601C5800 = %+v", c)
+	}
+	m, _ := findUnderlying("m")
+	if c, ok = m.match(model(protocol.ExchangeDCEO, "m2601-C-3000", ""), now); !ok || c.Underlying != "m2601" {
+		t.Errorf("m2601-C-3000 = %+v", c)
+	}
+	if c, ok = m.match(model(protocol.ExchangeDCEO, "MA601C3000", ""), now); ok {
+		t.Errorf("MA601C3000 不是 m 的期权: %+v", c)
+	}
+
+	if _, ok := findUnderlying("000001"); ok {
+		t.Error("000001 没有期权")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	for _, v := range []struct {
+		market protocol.Exchange
+		month  int
+		want   string
+	}{
+		{protocol.ExchangeSHO, 202506, "2025-06-25"},
+		{protocol.ExchangeCFFO, 202506, "2025-06-20"},
+		{protocol.ExchangeDCEO, 202509, "2025-08-18"},
+		{protocol.ExchangeCZCO, 202509, "2025-08-21"},
+		{protocol.ExchangeSHFO, 202508, "2025-07-25"},
+	} {
+		if got := Expiry(v.market, v.month, IsWeekday).Format(time.DateOnly); got != v.want {
+			t.Errorf("Expiry(%s, %d) = %s, want %s", v.market, v.month, got, v.want)
+		}
+	}
+	//遇节假日顺延
+	holiday := func(t time.Time) bool { return IsWeekday(t) && t.Day() != 25 }
+	if got := Expiry(protocol.ExchangeSHO, 202506, holiday).Format(time.DateOnly); got != "2025-06-26" {
+		t.Errorf("顺延 = %s", got)
+	}
+}
+
+func TestChain(t *testing.T) {
+	now := time.Date(2025, 6, 3, 10, 0, 0, 0, time.Local)
+	expiry := time.Date(2025, 6, 25, 15, 0, 0, 0, time.Local)
+	years := expiry.Sub(now).Hours() / 24 / 365
+	var contracts []*Contract
+	quotes := map[string]*Quote{}
+	for i, strike := range []float64{2.7, 2.8, 2.9} {
+		for _, call := range []bool{true, false} {
+			c := &Contract{Code: string(rune('a'+i)) + map[bool]string{true: "c", false: "p"}[call], Underlying: "sh510050",
+				Call: call, Strike: strike, Expiry: expiry, Model: ModelBS}
+			contracts = append(contracts, c)
+			//虚值期权的波动率更高
+			iv := 0.2 + 0.5*(strike-2.8)*(strike-2.8)
+			quotes[c.Code] = &Quote{Price: Price(ModelBS, call, 2.8, strike, years, 0.02, iv)}
+		}
+	}
+	quotes["ac"].Bid, quotes["ac"].Ask = 0, 1 //没有买一,用最新价
+
+	chain := NewChain("510050", contracts, quotes, map[string]float64{"sh510050": 2.8}, 0.02, now)
+	if len(chain.Items) != 6 || len(chain.Expiries()) != 1 {
+		t.Fatalf("chain = %+v", chain)
+	}
+	for _, v := range chain.Items {
+		if want := 0.2 + 0.5*(v.Strike-2.8)*(v.Strike-2.8); !near(v.IV, want, 1e-6) || v.Delta == 0 {
+			t.Errorf("%s iv=%v want %v, greeks=%+v", v.Code, v.IV, want, v.Greeks)
+		}
+	}
+	smile := chain.Smiles()["2025-06-25"]
+	if len(smile) != 3 || smile[0].Strike != 2.7 || smile[0].IV != smile[0].PutIV || smile[2].IV != smile[2].CallIV || smile[0].IV <= smile[1].IV {
+		t.Errorf("smile = %+v", smile)
+	}
+}
+
+type stubSpot map[string]protocol.Price
+
+func (this stubSpot) Quote(codes ...string) (protocol.QuotesResp, error) {
+	ls := make(protocol.QuotesResp, len(codes))
+	for i, code := range codes {
+		p, ok := this[code]
+		if !ok {
+			return nil, errors.New("未知的代码: " + code)
+		}
+		ls[i] = &protocol.Quote{Kline: &protocol.Kline{Close: p}}
+	}
+	return ls, nil
+}
+
+type stubEx map[string]float64
+
+func (this stubEx) ExQuote(market uint8, code string) (*protocol.ExQuote, error) {
+	return nil, errors.New("不应单独获取: " + code)
+}
+
+func (this stubEx) ExQuoteList(market, category uint8, start, count uint16) ([]protocol.ExQuoteListItem, error) {
+	if start > 0 {
+		return nil, nil
+	}
+	var ls []protocol.ExQuoteListItem
+	for code, price := range this {
+		ls = append(ls, protocol.ExQuoteListItem{Market: market, Code: code, Price: price})
+	}
+	return ls, nil
+}
+
+// TestClientChain 通过 Client.Chain,ETF标的价格走 QuoteSource
+func TestClientChain(t *testing.T) {
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month()+2, 1, 0, 0, 0, 0, time.Local).Month()
+	codes := tdx.NewExCodesBase()
+	codes.Update([]*tdx.ExCodeModel{
+		{Market: uint8(protocol.ExchangeSHO), Code: "10009001", Name: fmt.Sprintf("50ETF购%d月2800", month)},
+		{Market: uint8(protocol.ExchangeSHO), Code: "10009002", Name: fmt.Sprintf("50ETF沽%d月2800", month)},
+	})
+	cli := &Client{
+		rate:         DefaultRate,
+		isTradingDay: IsWeekday,
+		ex:           stubEx{"10009001": 0.12, "10009002": 0.1},
+		codes:        codes,
+		spot:         stubSpot{"sh510050": 2800},
+	}
+	cli.expiry = func(c *Contract) time.Time { return Expiry(c.Market, c.Month, cli.isTradingDay) }
+
+	chain, err := cli.Chain("510050")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.Items) != 2 {
+		t.Fatalf("items = %d", len(chain.Items))
+	}
+	for _, v := range chain.Items {
+		if v.Spot != 2.8 || v.IV <= 0 || v.Delta == 0 {
+			t.Errorf("%s spot=%v iv=%v greeks=%+v", v.Code, v.Spot, v.IV, v.Greeks)
+		}
+	}
+
+	cli.spot = stubSpot{}
+	if _, err := cli.Chain("510050"); err == nil {
+		t.Error("标的价格获取失败应返回错误")
+	}
+}
@@ -0,0 +1,124 @@
+package options
+
+import (
+	"sort"
+	"time"
+)
+
+// Quote 期权行情
+type Quote struct {
+	Price    float64 `json:"price"` //最新价
+	PreClose float64 `json:"preClose"`
+	Bid      float64 `json:"bid"` //买一
+	Ask      float64 `json:"ask"` //卖一
+	Volume   uint32  `json:"volume"`
+	OI       uint32  `json:"oi"` //持仓
+}
+
+// Mark 计算隐含波动率用的价格,有买卖一时取中间价,否则取最新价,再没有取昨收
+func (this *Quote) Mark() float64 {
+	if this.Bid > 0 && this.Ask > 0 {
+		return (this.Bid + this.Ask) / 2
+	}
+	return quotePrice(this.Price, this.PreClose)
+}
+
+// Item 期权链的一项
+type Item struct {
+	*Contract
+	Quote
+	Spot  float64 `json:"spot"`  //标的价格
+	Years float64 `json:"years"` //剩余年数
+	IV    float64 `json:"iv"`    //隐含波动率,算不出时为0
+	Greeks
+}
+
+// Chain 期权链
+type Chain struct {
+	Underlying string    `json:"underlying"`
+	Time       time.Time `json:"time"`
+	Rate       float64   `json:"rate"`
+	Items      []*Item   `json:"items"` //按 到期日/行权价/认购在前 排序
+}
+
+// NewChain 用行情(合约代码→行情)和标的价格(标的代码→价格)计算隐含波动率和希腊字母,
+// 剩余时间按到期日15:00计算
+func NewChain(underlying string, contracts []*Contract, quotes map[string]*Quote, spot map[string]float64, rate float64, now time.Time) *Chain {
+	chain := &Chain{Underlying: underlying, Time: now, Rate: rate}
+	for _, c := range contracts {
+		item := &Item{Contract: c, Spot: spot[c.Underlying]}
+		if q := quotes[c.Code]; q != nil {
+			item.Quote = *q
+		}
+		expiry := time.Date(c.Expiry.Year(), c.Expiry.Month(), c.Expiry.Day(), 15, 0, 0, 0, c.Expiry.Location())
+		if item.Years = expiry.Sub(now).Hours() / 24 / 365; item.Years < 0 {
+			item.Years = 0
+		}
+		if iv, ok := ImpliedVol(c.Model, c.Call, item.Mark(), item.Spot, c.Strike, item.Years, rate); ok {
+			item.IV = iv
+			item.Greeks = GetGreeks(c.Model, c.Call, item.Spot, c.Strike, item.Years, rate, iv)
+		}
+		chain.Items = append(chain.Items, item)
+	}
+	return chain
+}
+
+// Expiries 全部到期日,升序
+func (this *Chain) Expiries() []time.Time {
+	var ls []time.Time
+	for _, v := range this.Items {
+		if len(ls) == 0 || !ls[len(ls)-1].Equal(v.Expiry) {
+			ls = append(ls, v.Expiry)
+		}
+	}
+	return ls
+}
+
+// SmilePoint 波动率微笑的一个行权价
+type SmilePoint struct {
+	Strike float64 `json:"strike"`
+	CallIV float64 `json:"callIV"`
+	PutIV  float64 `json:"putIV"`
+	IV     float64 `json:"iv"` //虚值期权的隐含波动率,行权价低于标的取认沽,否则取认购,没有时取另一个
+}
+
+// Smile 到期日的波动率微笑,按行权价升序,调整过的合约(A)不参与
+func (this *Chain) Smile(expiry time.Time) []*SmilePoint {
+	m := map[float64]*SmilePoint{}
+	spot := 0.0
+	for _, v := range this.Items {
+		if !v.Expiry.Equal(expiry) || v.Adjusted {
+			continue
+		}
+		p := m[v.Strike]
+		if p == nil {
+			p = &SmilePoint{Strike: v.Strike}
+			m[v.Strike] = p
+		}
+		if v.Call {
+			p.CallIV = v.IV
+		} else {
+			p.PutIV = v.IV
+		}
+		spot = v.Spot
+	}
+	ls := make([]*SmilePoint, 0, len(m))
+	for _, p := range m {
+		p.IV = p.CallIV
+		if (p.Strike < spot && p.PutIV > 0) || p.CallIV == 0 {
+			p.IV = p.PutIV
+		}
+		ls = append(ls, p)
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Strike < ls[j].Strike })
+	return ls
+}
+
+// Smiles 全部到期日的波动率微笑,到期日(YYYY-MM-DD)→微笑
+func (this *Chain) Smiles() map[string][]*SmilePoint {
+	m := map[string][]*SmilePoint{}
+	for _, t := range this.Expiries() {
+		m[t.Format(time.DateOnly)] = this.Smile(t)
+	}
+	return m
+}
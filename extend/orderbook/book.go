@@ -0,0 +1,154 @@
+package orderbook
+
+import (
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Point 一次快照的盘口指标
+type Point struct {
+	Code      string        `json:"code"` //例 sz000001
+	Time      time.Time     `json:"time"`
+	Price     float64       `json:"price"`     //最新价(元)
+	Spread    float64       `json:"spread"`    //买卖价差(元)
+	Mid       float64       `json:"mid"`       //中间价(元)
+	Micro     float64       `json:"micro"`     //微观价格(元)
+	Imbalance float64       `json:"imbalance"` //买卖量失衡
+	Changes   []LevelChange `json:"changes"`   //和上一次快照比的挂单变化,第一次为空
+	Flow      Flow          `json:"flow"`
+	Big       []BigOrder    `json:"big"`
+}
+
+// Minute 每分钟的盘口指标,时间同分钟K线(09:31 为 09:30~09:31),
+// 可以和分钟K线存在同一个数据库文件,见 Store
+type Minute struct {
+	Unix      int64     `json:"unix" xorm:"pk"`
+	Code      string    `json:"code"`
+	Time      time.Time `json:"time"`
+	Samples   int       `json:"samples"`   //快照数量
+	Spread    float64   `json:"spread"`    //平均价差(元)
+	Mid       float64   `json:"mid"`       //最后的中间价(元)
+	Micro     float64   `json:"micro"`     //最后的微观价格(元)
+	Imbalance float64   `json:"imbalance"` //平均买卖量失衡
+	Flow      `xorm:"extends"`
+	OFI       int     `json:"ofi"`       //订单流失衡(手)
+	BigCount  int     `json:"bigCount"`  //大单数量(成交+挂单+撤单)
+	BigBuy    float64 `json:"bigBuy"`    //大单主动买成交金额(元)
+	BigSell   float64 `json:"bigSell"`   //大单主动卖成交金额(元)
+	BigAdd    float64 `json:"bigAdd"`    //大单挂单金额(元)
+	BigCancel float64 `json:"bigCancel"` //大单撤单金额(元)
+}
+
+func (*Minute) TableName() string {
+	return "orderbook_minute"
+}
+
+func (this *Minute) add(p *Point) {
+	this.Samples++
+	this.Spread += p.Spread
+	this.Imbalance += p.Imbalance
+	this.Mid, this.Micro = p.Mid, p.Micro
+	this.BuyVolume += p.Flow.BuyVolume
+	this.SellVolume += p.Flow.SellVolume
+	this.BidAdd += p.Flow.BidAdd
+	this.BidCancel += p.Flow.BidCancel
+	this.AskAdd += p.Flow.AskAdd
+	this.AskCancel += p.Flow.AskCancel
+	for _, v := range p.Big {
+		this.BigCount++
+		switch {
+		case v.Kind == BigTrade && v.Buy:
+			this.BigBuy += v.Amount
+		case v.Kind == BigTrade:
+			this.BigSell += v.Amount
+		case v.Kind == BigAdd:
+			this.BigAdd += v.Amount
+		default:
+			this.BigCancel += v.Amount
+		}
+	}
+}
+
+// done 求平均值
+func (this *Minute) done() *Minute {
+	if this.Samples > 0 {
+		this.Spread = round(this.Spread / float64(this.Samples))
+		this.Imbalance = round(this.Imbalance / float64(this.Samples))
+	}
+	this.Mid, this.Micro = round(this.Mid), round(this.Micro)
+	this.OFI = this.Flow.OFI()
+	return this
+}
+
+// minuteTime 快照所属的分钟,同分钟K线取结束时间
+func minuteTime(t time.Time) time.Time {
+	return t.Truncate(time.Minute).Add(time.Minute)
+}
+
+// Book 一个代码的盘口,按顺序传入快照
+type Book struct {
+	Code string
+
+	depth     int
+	bigAmount float64
+	lot       int
+
+	prev   *protocol.Quote
+	minute *Minute
+}
+
+// Update 传入新的快照,返回这次的指标,跨分钟时返回上一分钟的汇总(否则为nil),
+// 和上一次快照服务器时间相同的重复快照返回 nil,nil
+func (this *Book) Update(q *protocol.Quote) (*Point, *Minute) {
+	if this.prev != nil && q.ReversedBytes0 != 0 && q.ReversedBytes0 == this.prev.ReversedBytes0 {
+		return nil, nil
+	}
+	t := quoteTime(q)
+	p := &Point{
+		Code:      this.Code,
+		Time:      t,
+		Spread:    Spread(q),
+		Mid:       Mid(q),
+		Micro:     MicroPrice(q),
+		Imbalance: Imbalance(q, this.depth),
+	}
+	if q.Kline != nil {
+		p.Price = q.Kline.Close.Float64()
+	}
+	if this.prev != nil {
+		p.Changes = Changes(this.prev, q)
+		p.Flow = NewFlow(this.prev, q, p.Changes)
+		p.Big = BigOrders(q, p.Changes, p.Flow, this.bigAmount, this.lot)
+	}
+	this.prev = q
+
+	var done *Minute
+	if mt := minuteTime(t); this.minute == nil || !this.minute.Time.Equal(mt) {
+		done = this.Flush()
+		this.minute = &Minute{Unix: mt.Unix(), Code: this.Code, Time: mt}
+	}
+	this.minute.add(p)
+	return p, done
+}
+
+// Flush 结束当前分钟并返回汇总,没有时为nil
+func (this *Book) Flush() *Minute {
+	if this.minute == nil {
+		return nil
+	}
+	m := this.minute.done()
+	this.minute = nil
+	return m
+}
+
+// quoteTime 快照时间,优先服务器时间
+func quoteTime(q *protocol.Quote) time.Time {
+	if !q.Time.IsZero() {
+		return q.Time
+	}
+	if q.Kline != nil {
+		return q.Kline.Time
+	}
+	return time.Now()
+}
@@ -0,0 +1,237 @@
+package orderbook
+
+import (
+	"math"
+	"sort"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Spread 买卖价差(元),没有买一或卖一时为0
+func Spread(q *protocol.Quote) float64 {
+	bid, ask := q.BuyLevel[0].Price, q.SellLevel[0].Price
+	if bid <= 0 || ask <= 0 {
+		return 0
+	}
+	return (ask - bid).Float64()
+}
+
+// Mid 中间价(元),只有一边时取那一边,都没有时为0
+func Mid(q *protocol.Quote) float64 {
+	bid, ask := q.BuyLevel[0].Price.Float64(), q.SellLevel[0].Price.Float64()
+	switch {
+	case bid > 0 && ask > 0:
+		return (bid + ask) / 2
+	case bid > 0:
+		return bid
+	default:
+		return ask
+	}
+}
+
+// MicroPrice 按买一卖一挂单量加权的价格(元),买盘越厚越靠近卖一,
+// 微观价格 = (买一价*卖一量 + 卖一价*买一量)/(买一量+卖一量)
+func MicroPrice(q *protocol.Quote) float64 {
+	bid, ask := q.BuyLevel[0], q.SellLevel[0]
+	if bid.Price <= 0 || ask.Price <= 0 || bid.Number+ask.Number <= 0 {
+		return Mid(q)
+	}
+	return (bid.Price.Float64()*float64(ask.Number) + ask.Price.Float64()*float64(bid.Number)) / float64(bid.Number+ask.Number)
+}
+
+// Imbalance 前 depth 档的买卖量失衡 (买量-卖量)/(买量+卖量),范围 -1~1,正为买盘多
+func Imbalance(q *protocol.Quote, depth int) float64 {
+	depth = min(max(depth, 1), len(q.BuyLevel))
+	var bid, ask int
+	for i := 0; i < depth; i++ {
+		bid += q.BuyLevel[i].Number
+		ask += q.SellLevel[i].Number
+	}
+	if bid+ask == 0 {
+		return 0
+	}
+	return float64(bid-ask) / float64(bid+ask)
+}
+
+// LevelChange 一个价位的挂单量变化
+type LevelChange struct {
+	Buy    bool           `json:"buy"`
+	Price  protocol.Price `json:"price"`
+	Before int            `json:"before"` //之前的挂单量(手),之前不在五档内为0
+	After  int            `json:"after"`  //之后的挂单量(手)
+}
+
+// Delta 变化量,正为增加
+func (this LevelChange) Delta() int {
+	return this.After - this.Before
+}
+
+// Changes 两次快照之间每个价位的挂单变化(按价格对齐,不是按档位),
+// 只比较两次都看得到的价格范围: 买盘不低于两次买五的较高者,卖盘不高于两次卖五的较低者,
+// 范围外的价位可能只是移出了五档,无法判断
+func Changes(prev, cur *protocol.Quote) []LevelChange {
+	ls := sideChanges(prev.BuyLevel, cur.BuyLevel, true)
+	return append(ls, sideChanges(prev.SellLevel, cur.SellLevel, false)...)
+}
+
+func sideChanges(prev, cur protocol.PriceLevels, buy bool) []LevelChange {
+	before, after := levelMap(prev), levelMap(cur)
+	edge, ok := visibleEdge(prev, cur, buy)
+	if !ok {
+		return nil
+	}
+	prices := map[protocol.Price]bool{}
+	for p := range before {
+		prices[p] = true
+	}
+	for p := range after {
+		prices[p] = true
+	}
+	var ls []LevelChange
+	for p := range prices {
+		if (buy && p < edge) || (!buy && p > edge) || before[p] == after[p] {
+			continue
+		}
+		ls = append(ls, LevelChange{Buy: buy, Price: p, Before: before[p], After: after[p]})
+	}
+	//买盘从高到低,卖盘从低到高,即从一档往外
+	sort.Slice(ls, func(i, j int) bool {
+		if buy {
+			return ls[i].Price > ls[j].Price
+		}
+		return ls[i].Price < ls[j].Price
+	})
+	return ls
+}
+
+func levelMap(ls protocol.PriceLevels) map[protocol.Price]int {
+	m := map[protocol.Price]int{}
+	for _, v := range ls {
+		if v.Price > 0 && v.Number > 0 {
+			m[v.Price] += v.Number
+		}
+	}
+	return m
+}
+
+// visibleEdge 两次快照都看得到的最远价格,任意一次没有挂单时返回false
+func visibleEdge(prev, cur protocol.PriceLevels, buy bool) (protocol.Price, bool) {
+	a, okA := farthest(prev)
+	b, okB := farthest(cur)
+	if !okA || !okB {
+		return 0, false
+	}
+	if buy {
+		return max(a, b), true
+	}
+	return min(a, b), true
+}
+
+// farthest 最远一档有效价格
+func farthest(ls protocol.PriceLevels) (protocol.Price, bool) {
+	for i := len(ls) - 1; i >= 0; i-- {
+		if ls[i].Price > 0 && ls[i].Number > 0 {
+			return ls[i].Price, true
+		}
+	}
+	return 0, false
+}
+
+// Flow 两次快照之间估算的挂单/撤单(手)
+//
+// 每一边减少的挂单量先算作成交(外盘增量消耗卖盘,内盘增量消耗买盘),剩下的算作撤单,
+// 增加的挂单量算作新挂单。快照间隔内先挂后撤的单看不到,所以是下限估计。
+type Flow struct {
+	BuyVolume  int `json:"buyVolume"`  //主动买成交(外盘增量)
+	SellVolume int `json:"sellVolume"` //主动卖成交(内盘增量)
+	BidAdd     int `json:"bidAdd"`     //买盘新挂单
+	BidCancel  int `json:"bidCancel"`  //买盘撤单
+	AskAdd     int `json:"askAdd"`     //卖盘新挂单
+	AskCancel  int `json:"askCancel"`  //卖盘撤单
+}
+
+// OFI 订单流失衡,买盘净增加 - 卖盘净增加(手)
+func (this Flow) OFI() int {
+	return (this.BidAdd - this.BidCancel - this.SellVolume) - (this.AskAdd - this.AskCancel - this.BuyVolume)
+}
+
+// NewFlow 估算两次快照之间的挂单/撤单,changes 为 Changes(prev, cur)
+func NewFlow(prev, cur *protocol.Quote, changes []LevelChange) Flow {
+	f := Flow{
+		BuyVolume:  max(cur.OuterDisc-prev.OuterDisc, 0),
+		SellVolume: max(cur.InsideDish-prev.InsideDish, 0),
+	}
+	var bidRemove, askRemove int
+	for _, v := range changes {
+		d := v.Delta()
+		switch {
+		case v.Buy && d > 0:
+			f.BidAdd += d
+		case v.Buy:
+			bidRemove -= d
+		case d > 0:
+			f.AskAdd += d
+		default:
+			askRemove -= d
+		}
+	}
+	f.BidCancel = max(bidRemove-f.SellVolume, 0)
+	f.AskCancel = max(askRemove-f.BuyVolume, 0)
+	return f
+}
+
+const (
+	BigTrade  = "trade"  //大单成交
+	BigAdd    = "add"    //大单挂单
+	BigCancel = "cancel" //大单撤单
+)
+
+// BigOrder 大单,金额超过阈值的成交、挂单或撤单
+type BigOrder struct {
+	Kind   string         `json:"kind"` //BigTrade BigAdd BigCancel
+	Buy    bool           `json:"buy"`  //成交为主动买,挂撤单为买盘
+	Price  protocol.Price `json:"price"`
+	Volume int            `json:"volume"` //手
+	Amount float64        `json:"amount"` //元
+}
+
+// BigOrders 两次快照之间的大单,amount 为金额阈值(元),lot 为每手股数
+//   - 成交: 外盘/内盘增量的金额,价格取最新价
+//   - 挂单/撤单: 单个价位的挂单增加/减少的金额,减少的量扣除该边的成交后才算撤单
+func BigOrders(cur *protocol.Quote, changes []LevelChange, flow Flow, amount float64, lot int) []BigOrder {
+	if amount <= 0 {
+		return nil
+	}
+	var ls []BigOrder
+	money := func(p protocol.Price, v int) float64 { return p.Float64() * float64(v*lot) }
+	if cur.Kline != nil {
+		for _, v := range []struct {
+			buy    bool
+			volume int
+		}{{true, flow.BuyVolume}, {false, flow.SellVolume}} {
+			if m := money(cur.Kline.Close, v.volume); v.volume > 0 && m >= amount {
+				ls = append(ls, BigOrder{Kind: BigTrade, Buy: v.buy, Price: cur.Kline.Close, Volume: v.volume, Amount: m})
+			}
+		}
+	}
+	//成交从一档开始消耗,剩下的减少才是撤单
+	traded := map[bool]int{true: flow.SellVolume, false: flow.BuyVolume}
+	for _, v := range changes {
+		d := v.Delta()
+		kind := BigAdd
+		if d < 0 {
+			used := min(traded[v.Buy], -d)
+			traded[v.Buy] -= used
+			d, kind = -d-used, BigCancel
+		}
+		if m := money(v.Price, d); d > 0 && m >= amount {
+			ls = append(ls, BigOrder{Kind: kind, Buy: v.Buy, Price: v.Price, Volume: d, Amount: m})
+		}
+	}
+	return ls
+}
+
+// round 保留4位小数,用于存储
+func round(f float64) float64 {
+	return math.Round(f*1e4) / 1e4
+}
@@ -0,0 +1,176 @@
+// Package orderbook 五档盘口分析.
+//
+// 对连续的五档行情快照(protocol.Quote)计算价差、中间价、微观价格、买卖量失衡、
+// 每个价位的挂单变化、两次快照之间估算的挂单/撤单和大单,
+// 按分钟汇总成 Minute,可以和分钟K线存在同一个数据库文件(见 Store)。
+package orderbook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+const (
+	DefaultDepth     = 5       //默认计算失衡的档数
+	DefaultBigAmount = 1000000 //默认大单金额(元)
+	DefaultLot       = 100     //默认每手股数
+)
+
+type Option func(*Analyzer)
+
+// WithDepth 计算买卖量失衡的档数,默认 DefaultDepth
+func WithDepth(n int) Option {
+	return func(a *Analyzer) {
+		a.depth = n
+	}
+}
+
+// WithBigAmount 大单的金额阈值(元),默认 DefaultBigAmount,0不检测
+func WithBigAmount(amount float64) Option {
+	return func(a *Analyzer) {
+		a.bigAmount = amount
+	}
+}
+
+// WithLot 每手股数,默认 DefaultLot,期货等按手计价的设为1
+func WithLot(lot int) Option {
+	return func(a *Analyzer) {
+		a.lot = lot
+	}
+}
+
+// WithOnPoint 每次快照的指标
+func WithOnPoint(f func(p *Point)) Option {
+	return func(a *Analyzer) {
+		a.onPoint = f
+	}
+}
+
+// WithOnMinute 每分钟的汇总,例如用 Store.Save 保存
+func WithOnMinute(f func(m *Minute)) Option {
+	return func(a *Analyzer) {
+		a.onMinute = f
+	}
+}
+
+func New(op ...Option) *Analyzer {
+	a := &Analyzer{
+		depth:     DefaultDepth,
+		bigAmount: DefaultBigAmount,
+		lot:       DefaultLot,
+		books:     map[string]*Book{},
+	}
+	for _, v := range op {
+		if v != nil {
+			v(a)
+		}
+	}
+	return a
+}
+
+// Analyzer 多个代码的盘口分析
+type Analyzer struct {
+	depth     int
+	bigAmount float64
+	lot       int
+	onPoint   func(p *Point)
+	onMinute  func(m *Minute)
+
+	books map[string]*Book
+	mu    sync.Mutex
+}
+
+// Book 代码的盘口,没有时新建
+func (this *Analyzer) Book(code string) *Book {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	b := this.books[code]
+	if b == nil {
+		b = &Book{Code: code, depth: this.depth, bigAmount: this.bigAmount, lot: this.lot}
+		this.books[code] = b
+	}
+	return b
+}
+
+// Feed 传入一个快照,返回指标,重复的快照返回nil
+func (this *Analyzer) Feed(q *protocol.Quote) *Point {
+	if q == nil {
+		return nil
+	}
+	p, m := this.Book(q.Exchange.String() + q.Code).Update(q)
+	if m != nil && this.onMinute != nil {
+		this.onMinute(m)
+	}
+	if p != nil && this.onPoint != nil {
+		this.onPoint(p)
+	}
+	return p
+}
+
+// Flush 结束所有代码的当前分钟
+func (this *Analyzer) Flush() {
+	this.mu.Lock()
+	ls := make([]*Book, 0, len(this.books))
+	for _, v := range this.books {
+		ls = append(ls, v)
+	}
+	this.mu.Unlock()
+	for _, v := range ls {
+		if m := v.Flush(); m != nil && this.onMinute != nil {
+			this.onMinute(m)
+		}
+	}
+}
+
+// Run 处理快照直到通道关闭或 ctx 结束,结束时 Flush
+func (this *Analyzer) Run(ctx context.Context, ch <-chan *protocol.Quote) error {
+	defer this.Flush()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case q, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			this.Feed(q)
+		}
+	}
+}
+
+// Poll 每隔 interval 通过 Facade 获取一次五档行情,作为 Run 的订阅流,ctx 结束时关闭通道
+func Poll(ctx context.Context, f *tdx.Facade, interval time.Duration, codes ...string) <-chan *protocol.Quote {
+	ch := make(chan *protocol.Quote, len(codes))
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			ls, err := f.Quote(append([]string(nil), codes...)...)
+			if err != nil {
+				logs.Err(err)
+			}
+			for _, q := range ls {
+				if q == nil {
+					continue
+				}
+				select {
+				case ch <- q:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return ch
+}
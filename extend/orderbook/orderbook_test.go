@@ -0,0 +1,137 @@
+package orderbook
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// quote 五档快照,价格为元,bids/asks 为 [价,量] 从一档开始
+func quote(t time.Time, price float64, inside, outer int, bids, asks [][2]float64) *protocol.Quote {
+	q := &protocol.Quote{
+		Exchange:   protocol.ExchangeSZ,
+		Code:       "000001",
+		Time:       t,
+		Kline:      &protocol.Kline{Close: protocol.Price(math.Round(price * 1000))},
+		InsideDish: inside,
+		OuterDisc:  outer,
+	}
+	for i, v := range bids {
+		q.BuyLevel[i] = protocol.PriceLevel{Buy: true, Price: protocol.Price(math.Round(v[0] * 1000)), Number: int(v[1])}
+	}
+	for i, v := range asks {
+		q.SellLevel[i] = protocol.PriceLevel{Price: protocol.Price(math.Round(v[0] * 1000)), Number: int(v[1])}
+	}
+	return q
+}
+
+func TestMetrics(t *testing.T) {
+	q := quote(time.Now(), 10.01, 0, 0,
+		[][2]float64{{10.00, 300}, {9.99, 100}},
+		[][2]float64{{10.01, 100}, {10.02, 100}},
+	)
+	if v := Spread(q); math.Abs(v-0.01) > 1e-9 {
+		t.Errorf("Spread = %v", v)
+	}
+	if v := Mid(q); math.Abs(v-10.005) > 1e-9 {
+		t.Errorf("Mid = %v", v)
+	}
+	//买一量大,微观价格靠近卖一: (10*100+10.01*300)/400
+	if v := MicroPrice(q); math.Abs(v-10.0075) > 1e-9 {
+		t.Errorf("MicroPrice = %v", v)
+	}
+	if v := Imbalance(q, 1); v != 0.5 {
+		t.Errorf("Imbalance(1) = %v", v)
+	}
+	if v := Imbalance(q, 5); v != (400.0-200)/600 {
+		t.Errorf("Imbalance(5) = %v", v)
+	}
+}
+
+func TestFlow(t *testing.T) {
+	t0 := time.Date(2025, 6, 3, 9, 30, 10, 0, time.Local)
+	prev := quote(t0, 10.01, 1000, 2000,
+		[][2]float64{{10.00, 300}, {9.99, 100}, {9.98, 100}, {9.97, 100}, {9.96, 100}},
+		[][2]float64{{10.01, 100}, {10.02, 100}, {10.03, 100}, {10.04, 100}, {10.05, 100}},
+	)
+	//主动买 150 手吃掉卖一 100 和卖二 50,卖三撤了 80,买一撤了 100,
+	//买二新挂 2000 手(约200万),这次只有4档买盘,9.96 看不到不算撤单,卖盘的 10.06 同理
+	cur := quote(t0.Add(3*time.Second), 10.02, 1000, 2150,
+		[][2]float64{{10.00, 200}, {9.99, 2100}, {9.98, 100}, {9.97, 100}},
+		[][2]float64{{10.02, 50}, {10.03, 20}, {10.04, 100}, {10.05, 100}, {10.06, 100}},
+	)
+
+	changes := Changes(prev, cur)
+	f := NewFlow(prev, cur, changes)
+	want := Flow{BuyVolume: 150, SellVolume: 0, BidAdd: 2000, BidCancel: 100, AskAdd: 0, AskCancel: 80}
+	if f != want {
+		t.Errorf("Flow = %+v, want %+v", f, want)
+	}
+	for _, v := range changes {
+		if v.Price == 9960 || v.Price == 10060 {
+			t.Errorf("五档外的价位不应比较: %+v", v)
+		}
+	}
+
+	big := BigOrders(cur, changes, f, 1000000, 100)
+	if len(big) != 1 || big[0].Kind != BigAdd || !big[0].Buy || big[0].Volume != 2000 || big[0].Price != 9990 {
+		t.Errorf("BigOrders = %+v", big)
+	}
+	//阈值降低后,成交和撤单也算大单
+	big = BigOrders(cur, changes, f, 50000, 100)
+	kinds := map[string]int{}
+	for _, v := range big {
+		kinds[v.Kind]++
+	}
+	if kinds[BigTrade] != 1 || kinds[BigAdd] != 1 || kinds[BigCancel] != 2 {
+		t.Errorf("BigOrders = %+v", big)
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	var minutes []*Minute
+	var points int
+	a := New(WithOnMinute(func(m *Minute) { minutes = append(minutes, m) }), WithOnPoint(func(p *Point) { points++ }))
+
+	t0 := time.Date(2025, 6, 3, 9, 30, 0, 0, time.Local)
+	book := [][2]float64{{10.00, 100}}
+	ask := [][2]float64{{10.02, 100}}
+	for i, sec := range []int{10, 20, 20, 50, 65} {
+		q := quote(t0.Add(time.Duration(sec)*time.Second), 10.01, 0, i*10, book, ask)
+		q.ReversedBytes0 = sec //重复的服务器时间跳过
+		a.Feed(q)
+	}
+	a.Flush()
+
+	if points != 4 {
+		t.Errorf("points = %d", points)
+	}
+	if len(minutes) != 2 {
+		t.Fatalf("minutes = %d", len(minutes))
+	}
+	m := minutes[0]
+	if m.Code != "sz000001" || !m.Time.Equal(t0.Add(time.Minute)) || m.Samples != 3 || m.Spread != 0.02 || m.BuyVolume != 30 {
+		t.Errorf("minute = %+v", m)
+	}
+	if !minutes[1].Time.Equal(t0.Add(2*time.Minute)) || minutes[1].Samples != 1 {
+		t.Errorf("minute = %+v", minutes[1])
+	}
+
+	s := NewStore(t.TempDir())
+	defer s.Close()
+	if err := s.Save(minutes...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(minutes[0]); err != nil {
+		t.Fatal(err)
+	}
+	ls, err := s.Minutes("sz000001", t0, t0.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 2 || ls[0].Unix != minutes[0].Unix || ls[0].BuyVolume != 30 {
+		t.Errorf("Minutes = %+v", ls)
+	}
+}
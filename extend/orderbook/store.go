@@ -0,0 +1,95 @@
+package orderbook
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/lib/xorms"
+	"xorm.io/xorm"
+)
+
+// NewStore 按 代码/年 保存每分钟的盘口指标,文件同 extend.PullKline 的分钟K线
+// (dir/min-kline/sz000001/sz000001-2025.db),表名 orderbook_minute
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, dbs: map[string]*xorms.Engine{}}
+}
+
+type Store struct {
+	dir string
+	dbs map[string]*xorms.Engine
+	mu  sync.Mutex
+}
+
+func (this *Store) filename(code string, year int) string {
+	return filepath.Join(this.dir, extend.DirMinute, code, code+"-"+strconv.Itoa(year)+".db")
+}
+
+func (this *Store) db(code string, year int) (*xorms.Engine, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	filename := this.filename(code, year)
+	if db := this.dbs[filename]; db != nil {
+		return db, nil
+	}
+	db, err := xorms.NewSqlite(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Sync2(new(Minute)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	this.dbs[filename] = db
+	return db, nil
+}
+
+// Save 保存,同一分钟已存在时覆盖
+func (this *Store) Save(ls ...*Minute) error {
+	for _, v := range ls {
+		db, err := this.db(v.Code, v.Time.Year())
+		if err != nil {
+			return err
+		}
+		err = db.SessionFunc(func(session *xorm.Session) error {
+			if _, err := session.Where("Unix=?", v.Unix).Delete(new(Minute)); err != nil {
+				return err
+			}
+			_, err := session.Insert(v)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Minutes 时间范围内的每分钟盘口指标,按时间升序
+func (this *Store) Minutes(code string, start, end time.Time) ([]*Minute, error) {
+	var res []*Minute
+	for year := start.Year(); year <= end.Year(); year++ {
+		db, err := this.db(code, year)
+		if err != nil {
+			return nil, err
+		}
+		var ls []*Minute
+		if err = db.Where("Unix>=? AND Unix<=?", start.Unix(), end.Unix()).Asc("Unix").Find(&ls); err != nil {
+			return nil, err
+		}
+		res = append(res, ls...)
+	}
+	return res, nil
+}
+
+func (this *Store) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for k, v := range this.dbs {
+		v.Close()
+		delete(this.dbs, k)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package overseas
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Action 公司行动(除权除息),同一天的分红和拆股可以写在一条里
+type Action struct {
+	Market      protocol.Exchange `json:"market"`
+	Code        string            `json:"code"`        //例 00700 AAPL
+	Date        time.Time         `json:"date"`        //除权除息日,交易所时区0点
+	Dividend    float64           `json:"dividend"`    //每股现金分红(交易货币)
+	Split       float64           `json:"split"`       //拆合股,每1股变为n股,例 2(1拆2) 0.1(10合1),送股也用这个(10送1=1.1),0或1为没有
+	Rights      float64           `json:"rights"`      //供股/配股,每1股配n股
+	RightsPrice float64           `json:"rightsPrice"` //供股价
+}
+
+// XRXD 转为A股的除权除息结构(每10股),用 protocol.XRXDs 计算复权因子
+func (this *Action) XRXD() *protocol.XRXD {
+	split := this.Split
+	if split == 0 {
+		split = 1
+	}
+	return &protocol.XRXD{
+		Code:        this.Code,
+		Time:        this.Date,
+		Fenhong:     this.Dividend * 10,
+		Peigujia:    this.RightsPrice,
+		Songzhuangu: (split - 1) * 10,
+		Peigu:       this.Rights * 10,
+	}
+}
+
+type Actions []*Action
+
+func (this Actions) XRXDs() protocol.XRXDs {
+	ls := make(protocol.XRXDs, len(this))
+	for i, v := range this {
+		ls[i] = v.XRXD()
+	}
+	return ls
+}
+
+// ActionSource 公司行动数据源,先提供本地CSV(MemActions),也可以接入其他数据源
+type ActionSource interface {
+	Actions(market protocol.Exchange, code string) (Actions, error)
+}
+
+// NewMemActions 内存中的公司行动,用 Add/ImportCSV 导入
+func NewMemActions() *MemActions {
+	return &MemActions{m: map[string]Actions{}}
+}
+
+type MemActions struct {
+	m  map[string]Actions
+	mu sync.RWMutex
+}
+
+// Add 添加公司行动,按日期升序保存
+func (this *MemActions) Add(ls ...*Action) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, v := range ls {
+		key := fullCode(v.Market, v.Code)
+		this.m[key] = append(this.m[key], v)
+		sort.SliceStable(this.m[key], func(i, j int) bool { return this.m[key][i].Date.Before(this.m[key][j].Date) })
+	}
+}
+
+func (this *MemActions) Actions(market protocol.Exchange, code string) (Actions, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.m[fullCode(market, code)], nil
+}
+
+// ImportCSV 导入CSV,表头为 code,date,dividend,split[,rights,rights_price],
+// 代码见 ParseSymbol(00700.HK AAPL),日期为 YYYY-MM-DD 或 YYYYMMDD,空的数值为0
+func (this *MemActions) ImportCSV(r io.Reader) error {
+	ls, err := ReadActionsCSV(r)
+	if err != nil {
+		return err
+	}
+	this.Add(ls...)
+	return nil
+}
+
+// LoadActionsCSV 从CSV文件加载公司行动,见 MemActions.ImportCSV
+func LoadActionsCSV(filename string) (*MemActions, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m := NewMemActions()
+	return m, m.ImportCSV(f)
+}
+
+// ReadActionsCSV 解析CSV,格式见 MemActions.ImportCSV
+func ReadActionsCSV(r io.Reader) (Actions, error) {
+	t, err := readCSV(r, "code", "date")
+	if err != nil || t == nil {
+		return nil, err
+	}
+	get := t.get
+	num := func(row []string, name string) (float64, error) {
+		s := get(row, name)
+		if s == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(s, 64)
+	}
+
+	ls := make(Actions, 0, len(t.rows))
+	for n, row := range t.rows {
+		s, err := ParseSymbol(get(row, "code"))
+		if err != nil {
+			return nil, lineErr(n+2, err)
+		}
+		date, err := parseDate(get(row, "date"), protocol.ExLocation(s.Market()))
+		if err != nil {
+			return nil, lineErr(n+2, err)
+		}
+		a := &Action{Market: s.Exchange, Code: s.Code, Date: date}
+		for _, v := range []struct {
+			name string
+			p    *float64
+		}{
+			{"dividend", &a.Dividend},
+			{"split", &a.Split},
+			{"rights", &a.Rights},
+			{"rights_price", &a.RightsPrice},
+		} {
+			if *v.p, err = num(row, v.name); err != nil {
+				return nil, lineErr(n+2, err)
+			}
+		}
+		ls = append(ls, a)
+	}
+	return ls, nil
+}
+
+func fullCode(market protocol.Exchange, code string) string {
+	return strconv.Itoa(int(market)) + ":" + strings.ToUpper(code)
+}
+
+func parseDate(s string, loc *time.Location) (time.Time, error) {
+	layout := "20060102"
+	if strings.Contains(s, "-") {
+		layout = time.DateOnly
+	}
+	return time.ParseInLocation(layout, s, loc)
+}
+
+// csvTable 带表头的CSV,列名不区分大小写
+type csvTable struct {
+	rows  [][]string //不含表头
+	index map[string]int
+}
+
+// readCSV 读取带表头的CSV,required 为必须有的列,没有内容时返回nil
+func readCSV(r io.Reader, required ...string) (*csvTable, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	t := &csvTable{rows: rows[1:], index: map[string]int{}}
+	for i, v := range rows[0] {
+		t.index[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+	for _, v := range required {
+		if _, ok := t.index[v]; !ok {
+			return nil, errors.New("CSV缺少列: " + v)
+		}
+	}
+	return t, nil
+}
+
+// get 一行中某列的值,没有这一列时为空
+func (this *csvTable) get(row []string, name string) string {
+	if i, ok := this.index[name]; ok && i < len(row) {
+		return strings.TrimSpace(row[i])
+	}
+	return ""
+}
+
+func lineErr(line int, err error) error {
+	return errors.New("第" + strconv.Itoa(line) + "行: " + err.Error())
+}
@@ -0,0 +1,95 @@
+package overseas
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+func TestReadActionsCSV(t *testing.T) {
+	ls, err := ReadActionsCSV(strings.NewReader(`code,date,dividend,split
+700.HK,2025-05-16,4.2,
+AAPL,20200831,,4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 2 {
+		t.Fatalf("len = %d", len(ls))
+	}
+	if v := ls[0]; v.Market != protocol.ExchangeHK || v.Code != "00700" || v.Dividend != 4.2 || v.Split != 0 || v.Date.Format(time.DateOnly) != "2025-05-16" {
+		t.Errorf("HK = %+v", v)
+	}
+	if v := ls[1]; v.Market != protocol.ExchangeUS || v.Code != "AAPL" || v.Split != 4 || v.Date.Location() != protocol.ExLocation(uint8(protocol.ExchangeUS)) {
+		t.Errorf("US = %+v", v)
+	}
+
+	if _, err := ReadActionsCSV(strings.NewReader("code,date\nrb2510.SHF,2025-01-01\n")); err == nil {
+		t.Error("期货代码应该报错")
+	}
+}
+
+func TestFactors(t *testing.T) {
+	at := func(day int, market protocol.Exchange) time.Time {
+		loc := protocol.ExLocation(uint8(market))
+		hour := 15
+		if market == protocol.ExchangeUS {
+			hour = 16
+		}
+		return time.Date(2025, 6, day, hour, 0, 0, 0, loc)
+	}
+	klines := func(market protocol.Exchange, closes ...float64) protocol.Klines {
+		ls := make(protocol.ExRangeKlines, len(closes))
+		for i, v := range closes {
+			ls[i] = protocol.ExRangeKline{Time: at(2+i, market), Open: v, High: v, Low: v, Close: v}
+		}
+		return ls.Klines()
+	}
+
+	//港股 6月4日 1拆2
+	actions := NewMemActions()
+	actions.Add(&Action{Market: protocol.ExchangeHK, Code: "00700", Date: time.Date(2025, 6, 4, 0, 0, 0, 0, protocol.ExLocation(uint8(protocol.ExchangeHK))), Split: 2})
+	actions.Add(&Action{Market: protocol.ExchangeHK, Code: "08001", Date: time.Date(2025, 6, 4, 0, 0, 0, 0, protocol.ExLocation(uint8(protocol.ExchangeHK))), Split: 2})
+	//美股 6月5日 每股分红1美元
+	actions.Add(&Action{Market: protocol.ExchangeUS, Code: "AAPL", Date: time.Date(2025, 6, 5, 0, 0, 0, 0, protocol.ExLocation(uint8(protocol.ExchangeUS))), Dividend: 1})
+	cli := &Client{actions: actions}
+
+	for _, v := range []struct {
+		code string
+		ks   protocol.Klines
+		qfq  []protocol.Price
+		hfq  []protocol.Price
+	}{
+		{"00700.HK", klines(protocol.ExchangeHK, 100, 102, 51, 52), []protocol.Price{50000, 51000, 51000, 52000}, []protocol.Price{100000, 102000, 102000, 104000}},
+		{"AAPL", klines(protocol.ExchangeUS, 100, 101, 102, 100), []protocol.Price{99000, 100000, 101000, 100000}, []protocol.Price{100000, 101000, 102000, 101000}},
+		//港股仙股保留到厘,四舍五入到分会变成 0.05
+		{"08001.HK", klines(protocol.ExchangeHK, 0.102, 0.106, 0.053, 0.054), []protocol.Price{51, 53, 53, 54}, []protocol.Price{102, 106, 106, 108}},
+	} {
+		fs, err := cli.Factors(v.code, v.ks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _ := ParseSymbol(v.code)
+		qfq, hfq := ApplyFQ(s.Exchange, v.ks, fs, true), ApplyFQ(s.Exchange, v.ks, fs, false)
+		for i := range v.ks {
+			if qfq[i].Close != v.qfq[i] || hfq[i].Close != v.hfq[i] {
+				t.Errorf("%s %s qfq=%v hfq=%v, want %v %v", v.code, v.ks[i].Time.Format(time.DateOnly), qfq[i].Close, hfq[i].Close, v.qfq[i], v.hfq[i])
+			}
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	if s, err := ParseSymbol("hk700"); err != nil || s.Code != "00700" {
+		t.Errorf("hk700 = %+v %v", s, err)
+	}
+	if DefaultCurrency(protocol.ExchangeHK, "80700") != "CNY" || DefaultCurrency(protocol.ExchangeHK, "00700") != "HKD" || DefaultCurrency(protocol.ExchangeUS, "AAPL") != "USD" {
+		t.Error("DefaultCurrency")
+	}
+	m, err := ReadMetaCSV(strings.NewReader("code,lot_size,currency\n00700.HK,100,\n"))
+	if err != nil || m[fullCode(protocol.ExchangeHK, "00700")].LotSize != 100 {
+		t.Errorf("ReadMetaCSV = %v %v", m, err)
+	}
+}
@@ -0,0 +1,96 @@
+package overseas
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Instrument 港股/美股的品种信息
+type Instrument struct {
+	Market   protocol.Exchange `json:"market"`
+	Code     string            `json:"code"`     //例 00700 AAPL
+	Name     string            `json:"name"`     //名称,代码表里没有时为空
+	Currency string            `json:"currency"` //交易货币,HKD USD CNY
+	LotSize  int               `json:"lotSize"`  //每手股数,港股每只股票不同,没有导入时为0
+}
+
+// Meta 扩展行情没有的品种信息,用 WithMetas/LoadMetaCSV 导入
+type Meta struct {
+	LotSize  int    `json:"lotSize"`
+	Currency string `json:"currency"`
+}
+
+// ParseSymbol 解析港股/美股代码,例 00700.HK hk700 AAPL usAAPL,港股补齐为5位
+func ParseSymbol(code string) (protocol.Symbol, error) {
+	s, err := protocol.ParseSymbol(code)
+	if err != nil {
+		return s, err
+	}
+	switch s.Exchange {
+	case protocol.ExchangeHK:
+		if len(s.Code) < 5 {
+			s.Code = strings.Repeat("0", 5-len(s.Code)) + s.Code
+		}
+	case protocol.ExchangeUS:
+		s.Code = strings.ToUpper(s.Code)
+	default:
+		return s, errors.New("不是港股/美股代码: " + code)
+	}
+	return s, nil
+}
+
+// DefaultCurrency 市场默认的交易货币,港股人民币柜台(8xxxx)为CNY
+func DefaultCurrency(market protocol.Exchange, code string) string {
+	switch market {
+	case protocol.ExchangeUS:
+		return "USD"
+	case protocol.ExchangeHK:
+		if len(code) == 5 && code[0] == '8' {
+			return "CNY"
+		}
+		return "HKD"
+	}
+	return ""
+}
+
+// ReadMetaCSV 解析品种信息,表头为 code,lot_size[,currency],返回 市场:代码→信息
+func ReadMetaCSV(r io.Reader) (map[string]Meta, error) {
+	t, err := readCSV(r, "code")
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]Meta{}
+	if t == nil {
+		return m, nil
+	}
+	get := t.get
+	for n, row := range t.rows {
+		s, err := ParseSymbol(get(row, "code"))
+		if err != nil {
+			return nil, lineErr(n+2, err)
+		}
+		meta := Meta{Currency: strings.ToUpper(get(row, "currency"))}
+		if v := get(row, "lot_size"); v != "" {
+			if meta.LotSize, err = strconv.Atoi(v); err != nil {
+				return nil, lineErr(n+2, err)
+			}
+		}
+		m[fullCode(s.Exchange, s.Code)] = meta
+	}
+	return m, nil
+}
+
+// LoadMetaCSV 从CSV文件加载品种信息,见 ReadMetaCSV
+func LoadMetaCSV(filename string) (map[string]Meta, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMetaCSV(f)
+}
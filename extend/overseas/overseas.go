@@ -0,0 +1,240 @@
+// Package overseas 港股/美股.
+//
+// 扩展行情(7727)有港股(市场31)和美股(市场74)的行情和K线,但没有每手股数、交易货币和除权除息数据,
+// 这里补上品种信息,从可替换的公司行动数据源(先支持本地CSV)计算复权因子,
+// 复权因子和A股一样是 protocol.Factor,前复权/后复权日线见 ApplyFQ(港股保留到厘,美股同A股四舍五入到分)。
+package overseas
+
+import (
+	"math"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+const (
+	barsPage = 700 //ExBars 每页数量
+	dayKline = 4   //日线
+)
+
+type Option func(*Client)
+
+// WithClient 扩展行情客户端,见 tdx.DialExHqDefault
+func WithClient(c *tdx.Client) Option {
+	return func(cli *Client) {
+		cli.c = c
+	}
+}
+
+func WithDialClient(dial tdx.DialClientFunc) Option {
+	return func(cli *Client) {
+		cli.dialClient = dial
+	}
+}
+
+// WithCodes 扩展代码表,用于品种名称,默认 tdx.NewExCodes
+func WithCodes(codes tdx.IExCodes) Option {
+	return func(cli *Client) {
+		cli.codes = codes
+	}
+}
+
+// WithActions 公司行动数据源,默认没有(不复权),例 LoadActionsCSV
+func WithActions(source ActionSource) Option {
+	return func(cli *Client) {
+		cli.actions = source
+	}
+}
+
+// WithMetas 品种信息(每手股数/交易货币),见 LoadMetaCSV
+func WithMetas(m map[string]Meta) Option {
+	return func(cli *Client) {
+		cli.metas = m
+	}
+}
+
+func New(op ...Option) (*Client, error) {
+	cli := &Client{}
+	for _, v := range op {
+		if v != nil {
+			v(cli)
+		}
+	}
+	if cli.actions == nil {
+		cli.actions = NewMemActions()
+	}
+
+	var err error
+	if cli.c == nil {
+		if cli.dialClient == nil {
+			cli.dialClient = func() (*tdx.Client, error) { return tdx.DialExHqDefault() }
+		}
+		cli.c, err = cli.dialClient()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cli.codes == nil {
+		cli.codes, err = tdx.NewExCodes(tdx.WithExCodesClient(cli.c))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cli, nil
+}
+
+type Client struct {
+	dialClient tdx.DialClientFunc
+	actions    ActionSource
+	metas      map[string]Meta
+
+	c     *tdx.Client
+	codes tdx.IExCodes
+}
+
+// Instrument 品种信息,代码见 ParseSymbol
+func (this *Client) Instrument(code string) (*Instrument, error) {
+	s, err := ParseSymbol(code)
+	if err != nil {
+		return nil, err
+	}
+	return this.instrument(s.Exchange, s.Code, ""), nil
+}
+
+// Instruments 市场(protocol.ExchangeHK/ExchangeUS)的全部品种
+func (this *Client) Instruments(market protocol.Exchange) []*Instrument {
+	ls := this.codes.GetMarket(market)
+	res := make([]*Instrument, len(ls))
+	for i, v := range ls {
+		res[i] = this.instrument(market, v.Code, v.Name)
+	}
+	return res
+}
+
+func (this *Client) instrument(market protocol.Exchange, code, name string) *Instrument {
+	if name == "" {
+		if m := this.codes.Get(market, code); m != nil {
+			name = m.Name
+		}
+	}
+	i := &Instrument{Market: market, Code: code, Name: name, Currency: DefaultCurrency(market, code)}
+	if market == protocol.ExchangeUS {
+		i.LotSize = 1
+	}
+	if m, ok := this.metas[fullCode(market, code)]; ok {
+		if m.LotSize > 0 {
+			i.LotSize = m.LotSize
+		}
+		if m.Currency != "" {
+			i.Currency = m.Currency
+		}
+	}
+	return i
+}
+
+// Bars 不复权K线,Type 见 protocol.TypeKlineDay 等,昨收取前一根的收盘价
+func (this *Client) Bars(Type uint8, code string, start, count uint16) (protocol.Klines, error) {
+	s, err := ParseSymbol(code)
+	if err != nil {
+		return nil, err
+	}
+	ls, err := this.c.ExBars(Type, s.Market(), s.Code, start, count)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.ExKlines(ls).Klines(), nil
+}
+
+// DayBars 全部不复权日线,按时间升序
+func (this *Client) DayBars(code string) (protocol.Klines, error) {
+	s, err := ParseSymbol(code)
+	if err != nil {
+		return nil, err
+	}
+	var ls []protocol.ExKline
+	for start := 0; ; start += barsPage {
+		page, err := this.c.ExBars(dayKline, s.Market(), s.Code, uint16(start), barsPage)
+		if err != nil {
+			return nil, err
+		}
+		ls = append(page, ls...)
+		if len(page) < barsPage || start+barsPage > 0xFFFF-barsPage {
+			break
+		}
+	}
+	return protocol.ExKlines(ls).Klines(), nil
+}
+
+// Factors 日线 ks 每天的复权因子,和A股的 Gbbq.GetFactors 一样
+func (this *Client) Factors(code string, ks protocol.Klines) ([]*protocol.Factor, error) {
+	s, err := ParseSymbol(code)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := this.actions.Actions(s.Exchange, s.Code)
+	if err != nil {
+		return nil, err
+	}
+	return actions.XRXDs().Pre(ks).Factors(), nil
+}
+
+// QFQ 前复权日线,美股同 protocol.ApplyQFQ 四舍五入到分,
+// 港股保留到厘(仙股价格低于1港元,四舍五入到分误差太大),见 ApplyFQ
+func (this *Client) QFQ(code string) (protocol.Klines, error) {
+	return this.fq(code, true)
+}
+
+// HFQ 后复权日线,见 QFQ
+func (this *Client) HFQ(code string) (protocol.Klines, error) {
+	return this.fq(code, false)
+}
+
+func (this *Client) fq(code string, qfq bool) (protocol.Klines, error) {
+	s, err := ParseSymbol(code)
+	if err != nil {
+		return nil, err
+	}
+	ks, err := this.DayBars(code)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := this.Factors(code, ks)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyFQ(s.Exchange, ks, fs, qfq), nil
+}
+
+// ApplyFQ 按市场复权,港股价格四舍五入到厘,其它同 protocol.ApplyQFQ/ApplyHFQ 四舍五入到分
+func ApplyFQ(market protocol.Exchange, ks protocol.Klines, fs []*protocol.Factor, qfq bool) protocol.Klines {
+	switch {
+	case market != protocol.ExchangeHK && qfq:
+		return protocol.ApplyQFQ(ks, fs)
+	case market != protocol.ExchangeHK:
+		return protocol.ApplyHFQ(ks, fs)
+	}
+	fm := make(map[int64]*protocol.Factor, len(fs))
+	for _, f := range fs {
+		fm[f.Time.Unix()] = f
+	}
+	out := make(protocol.Klines, len(ks))
+	for i, k := range ks {
+		nk := *k
+		if f := fm[k.Time.Unix()]; f != nil {
+			mul, add := f.QFQMul, f.QFQAdd
+			if !qfq {
+				mul, add = f.HFQMul, f.HFQAdd
+			}
+			price := func(raw protocol.Price) protocol.Price {
+				return protocol.Price(math.Round((mul*raw.Float64() + add) * 1000))
+			}
+			nk.Last = price(k.Last)
+			nk.Open = price(k.Open)
+			nk.High = price(k.High)
+			nk.Low = price(k.Low)
+			nk.Close = price(k.Close)
+		}
+		out[i] = &nk
+	}
+	return out
+}
@@ -1,6 +1,7 @@
 package extend
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -74,6 +75,11 @@ func (this *PullKline) Run(m *tdx.Manage, spec string) error {
 }
 
 func (this *PullKline) Update(m *tdx.Manage, must ...bool) error {
+	return this.UpdateContext(context.Background(), m, must...)
+}
+
+// UpdateContext 同 Update,ctx 结束后不再拉取新的代码,返回 ctx.Err(),并且不记录为已更新
+func (this *PullKline) UpdateContext(ctx context.Context, m *tdx.Manage, must ...bool) error {
 	if len(must) == 0 || !must[0] {
 		if !m.Workday.TodayIs() {
 			return nil
@@ -97,17 +103,20 @@ func (this *PullKline) Update(m *tdx.Manage, must ...bool) error {
 	for _, v := range this.Types {
 		switch v {
 		case Day:
-			err := this.updateDayKline(m, codes)
+			err := this.updateDayKline(ctx, m, codes)
 			if err != nil {
 				return err
 			}
 		case Minute:
-			err := this.updateMinKline(m, codes)
+			err := this.updateMinKline(ctx, m, codes)
 			if err != nil {
 				return err
 			}
 		}
 	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
 	err = this.Updated.Update("pull")
 	return err
 }
@@ -212,7 +221,7 @@ func (this *PullKline) MinKlines(code string, start, end time.Time) (protocol.Kl
 	return ks, nil
 }
 
-func (this *PullKline) updateDayKline(m *tdx.Manage, codes []string) error {
+func (this *PullKline) updateDayKline(ctx context.Context, m *tdx.Manage, codes []string) error {
 
 	_ = os.MkdirAll(this.Config.Dir, os.ModePerm)
 
@@ -225,6 +234,11 @@ func (this *PullKline) updateDayKline(m *tdx.Manage, codes []string) error {
 
 		b.GoRetry(func() (err error) {
 
+			//已取消,剩下的代码直接跳过
+			if ctx.Err() != nil {
+				return nil
+			}
+
 			b.SetPrefix(fmt.Sprintf("[%s]", code))
 			b.Flush()
 
@@ -299,10 +313,10 @@ func (this *PullKline) updateDayKline(m *tdx.Manage, codes []string) error {
 	}
 
 	b.Wait()
-	return nil
+	return ctx.Err()
 }
 
-func (this *PullKline) updateMinKline(m *tdx.Manage, codes []string) error {
+func (this *PullKline) updateMinKline(ctx context.Context, m *tdx.Manage, codes []string) error {
 
 	_ = os.MkdirAll(this.Config.Dir, os.ModePerm)
 
@@ -317,6 +331,11 @@ func (this *PullKline) updateMinKline(m *tdx.Manage, codes []string) error {
 
 		b.GoRetry(func() (err error) {
 
+			//已取消,剩下的代码直接跳过
+			if ctx.Err() != nil {
+				return nil
+			}
+
 			b.SetPrefix(fmt.Sprintf("[%s]", code))
 			b.Flush()
 
@@ -347,7 +366,7 @@ func (this *PullKline) updateMinKline(m *tdx.Manage, codes []string) error {
 	}
 
 	b.Wait()
-	return nil
+	return ctx.Err()
 }
 
 func (this *PullKline) updateMinuteKlineYear(m *tdx.Manage, code string, year int, ks protocol.Klines) (protocol.Klines, error) {
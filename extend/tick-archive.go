@@ -0,0 +1,226 @@
+package extend
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 分笔成交归档
+// 每个代码每天一个文件: <dir>/<code>/<yyyy>/<yyyymmdd>.tick
+// 文件内容为 zstd 压缩后的二进制:
+//
+//	00~03  魔数 "TDXT"
+//	04     版本号
+//	05~08  uint32 日期(YYYYMMDD)
+//	uvarint 条数
+//	每条:  zigzag 秒数差(当天0点起) / zigzag 价格差(厘) / uvarint 成交量(手) / uvarint 状态 / uvarint 单数
+//
+// 首条的差值基准为0,时间按本地时区还原
+
+const (
+	tickMagic   = "TDXT"
+	tickVersion = 1
+	tickExt     = ".tick"
+)
+
+var (
+	tickEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	tickDecoder, _ = zstd.NewReader(nil)
+)
+
+// EncodeTicks 编码一天的分笔成交
+func EncodeTicks(date time.Time, ts protocol.Trades) []byte {
+	buf := make([]byte, 0, 9+len(ts)*8)
+	buf = append(buf, tickMagic...)
+	buf = append(buf, tickVersion)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(date.Year()*10000+int(date.Month())*100+date.Day()))
+	buf = binary.AppendUvarint(buf, uint64(len(ts)))
+	lastSec, lastPrice := int64(0), int64(0)
+	for _, v := range ts {
+		sec := int64(v.Time.Hour()*3600 + v.Time.Minute()*60 + v.Time.Second())
+		buf = binary.AppendVarint(buf, sec-lastSec)
+		buf = binary.AppendVarint(buf, int64(v.Price)-lastPrice)
+		buf = binary.AppendUvarint(buf, uint64(v.Volume))
+		buf = binary.AppendUvarint(buf, uint64(v.Status))
+		buf = binary.AppendUvarint(buf, uint64(v.Number))
+		lastSec, lastPrice = sec, int64(v.Price)
+	}
+	return tickEncoder.EncodeAll(buf, nil)
+}
+
+// DecodeTicks 解码一天的分笔成交,返回日期和成交明细
+func DecodeTicks(bs []byte) (time.Time, protocol.Trades, error) {
+	bs, err := tickDecoder.DecodeAll(bs, nil)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if len(bs) < 9 || string(bs[:4]) != tickMagic {
+		return time.Time{}, nil, errors.New("无效的分笔归档数据")
+	}
+	if bs[4] != tickVersion {
+		return time.Time{}, nil, fmt.Errorf("不支持的分笔归档版本: %d", bs[4])
+	}
+	d := binary.LittleEndian.Uint32(bs[5:9])
+	date := time.Date(int(d/10000), time.Month(d/100%100), int(d%100), 0, 0, 0, 0, time.Local)
+
+	r := bytes.NewReader(bs[9:])
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	//数量来自文件,每笔至少5个字节,按剩余长度限制预分配,避免损坏的文件申请过大的内存
+	ts := make(protocol.Trades, 0, min(count, uint64(r.Len()/5)))
+	sec, price := int64(0), int64(0)
+	for i := uint64(0); i < count; i++ {
+		var vals [5]int64
+		for j := range vals {
+			if j < 2 {
+				vals[j], err = binary.ReadVarint(r)
+			} else {
+				var u uint64
+				u, err = binary.ReadUvarint(r)
+				vals[j] = int64(u)
+			}
+			if err != nil {
+				return time.Time{}, nil, fmt.Errorf("分笔归档数据长度不足: %w", err)
+			}
+		}
+		sec += vals[0]
+		price += vals[1]
+		ts = append(ts, &protocol.Trade{
+			Time:   date.Add(time.Duration(sec) * time.Second),
+			Price:  protocol.Price(price),
+			Volume: int(vals[2]),
+			Status: int(vals[3]),
+			Number: int(vals[4]),
+		})
+	}
+	return date, ts, nil
+}
+
+// NewTickArchive 新建分笔成交归档,dir为归档根目录
+func NewTickArchive(dir string) *TickArchive {
+	return &TickArchive{Dir: dir}
+}
+
+// TickArchive 分笔成交归档,按代码和日期分文件存储
+type TickArchive struct {
+	Dir string
+}
+
+// Filename 归档文件路径
+func (this *TickArchive) Filename(code string, date time.Time) string {
+	return filepath.Join(this.Dir, code, date.Format("2006"), date.Format("20060102")+tickExt)
+}
+
+// Exists 是否已归档
+func (this *TickArchive) Exists(code string, date time.Time) bool {
+	return exists(this.Filename(code, date))
+}
+
+// Write 写入一天的分笔成交,会覆盖
+func (this *TickArchive) Write(code string, date time.Time, ts protocol.Trades) error {
+	return newFile(this.Filename(code, date), EncodeTicks(date, ts))
+}
+
+// Read 读取一天的分笔成交
+func (this *TickArchive) Read(code string, date time.Time) (protocol.Trades, error) {
+	bs, err := os.ReadFile(this.Filename(code, date))
+	if err != nil {
+		return nil, err
+	}
+	_, ts, err := DecodeTicks(bs)
+	return ts, err
+}
+
+// ReadRange 读取区间[start,end]内已归档的分笔成交,未归档的日期跳过
+func (this *TickArchive) ReadRange(code string, start, end time.Time) (protocol.Trades, error) {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+	ls := protocol.Trades{}
+	for t := start; !t.After(end); t = t.AddDate(0, 0, 1) {
+		if !this.Exists(code, t) {
+			continue
+		}
+		ts, err := this.Read(code, t)
+		if err != nil {
+			return nil, err
+		}
+		ls = append(ls, ts...)
+	}
+	return ls, nil
+}
+
+// ReadKlines 读取区间[start,end]内的分笔成交,并合成1分钟K线,其他周期可通过Merge合成
+func (this *TickArchive) ReadKlines(code string, start, end time.Time) (protocol.Klines, error) {
+	ts, err := this.ReadRange(code, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return ts.Klines(), nil
+}
+
+// Backfill 补全区间[start,end)内每个交易日的分笔成交,已归档的日期跳过
+// 当天未收盘(15:30前)的数据不完整,不会归档。
+// 某个代码失败时记录日志并继续下一个代码,最后返回所有失败的错误
+func (this *TickArchive) Backfill(ctx context.Context, m *tdx.Manage, codes []string, start, end time.Time) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if now.Before(today.Add(time.Hour*15 + time.Minute*30)) {
+		end = conv.Select(end.After(today), today, end)
+	} else {
+		end = conv.Select(end.After(today.AddDate(0, 0, 1)), today.AddDate(0, 0, 1), end)
+	}
+	var errs []error
+	for _, code := range codes {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if err := this.backfill(ctx, m, code, start, end); err != nil {
+			if ctx.Err() != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			logs.Errf("[%s] 分笔归档失败: %v\n", code, err)
+			errs = append(errs, fmt.Errorf("%s: %w", code, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backfill 补全一个代码,遇到错误时返回,不再继续这个代码后面的日期
+func (this *TickArchive) backfill(ctx context.Context, m *tdx.Manage, code string, start, end time.Time) error {
+	for t := range m.Workday.Iter(start, end) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if this.Exists(code, t) {
+			continue
+		}
+		var resp *protocol.TradeResp
+		err := m.Do(func(c *tdx.Client) (err error) {
+			resp, err = c.GetHistoryTradeDay(t.Format("20060102"), code)
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Format(time.DateOnly), err)
+		}
+		if err = this.Write(code, t, resp.List); err != nil {
+			return err
+		}
+		logs.Debugf("[%s] 分笔归档 %s 数量: %d\n", code, t.Format(time.DateOnly), len(resp.List))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package extend
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+func TestTickArchive(t *testing.T) {
+	date := time.Date(2025, 3, 14, 0, 0, 0, 0, time.Local)
+	ts := protocol.Trades{
+		{Time: date.Add(9*time.Hour + 25*time.Minute), Price: protocol.Yuan(11.05), Volume: 3021, Status: 2, Number: 120},
+		{Time: date.Add(9*time.Hour + 30*time.Minute), Price: protocol.Yuan(11.04), Volume: 152, Status: 1, Number: 9},
+		{Time: date.Add(9*time.Hour + 30*time.Minute), Price: protocol.Yuan(11.06), Volume: 87, Status: 0, Number: 5},
+		{Time: date.Add(14*time.Hour + 57*time.Minute), Price: protocol.Yuan(10.98), Volume: 12, Status: 0},
+		{Time: date.Add(15 * time.Hour), Price: protocol.Yuan(11.00), Volume: 8123, Status: 2},
+	}
+
+	ta := NewTickArchive(t.TempDir())
+	if err := ta.Write("sz000001", date, ts); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ta.Read("sz000001", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(ts) {
+		t.Fatalf("数量不一致: got %d want %d", len(got), len(ts))
+	}
+	for i := range ts {
+		if !got[i].Time.Equal(ts[i].Time) || *got[i] != *ts[i] {
+			t.Errorf("第%d条不一致: got %v want %v", i, got[i], ts[i])
+		}
+	}
+
+	ks, err := ta.ReadKlines("sz000001", date, date.Add(time.Hour*24))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != 241 {
+		t.Fatalf("1分钟K线数量不一致: got %d want 241", len(ks))
+	}
+	if ks[0].Open != protocol.Yuan(11.05) || ks[0].Volume != 3021 {
+		t.Errorf("首根K线不一致: %v", ks[0])
+	}
+	if ks[1].High != protocol.Yuan(11.06) || ks[1].Volume != 152+87 {
+		t.Errorf("9:31K线不一致: %v", ks[1])
+	}
+
+	if _, _, err = DecodeTicks([]byte("TDXT")); err == nil {
+		t.Errorf("无效数据应返回错误")
+	}
+
+	//数量被改成很大的值,应返回长度不足而不是按数量申请内存
+	raw := binary.LittleEndian.AppendUint32(append([]byte(tickMagic), tickVersion), 20250314)
+	raw = binary.AppendUvarint(raw, 1<<60)
+	raw = append(raw, 2, 2, 1, 0, 1)
+	if _, _, err = DecodeTicks(tickEncoder.EncodeAll(raw, nil)); err == nil {
+		t.Errorf("截断的数据应返回错误")
+	}
+}
@@ -0,0 +1,196 @@
+package extend
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 通达信 vipdoc 目录同步
+// 从网络拉取K线,增量写入 <Dir>/vipdoc/<sh|sz|bj>/{lday,minline,fzline},
+// 可直接作为通达信客户端安装目录或 pytdx 等工具的数据目录使用。
+//
+// 增量规则: 读取文件最后一条记录的日期,截掉该交易日的全部记录(可能是盘中不完整数据或 .lc1 尾盘占位),
+// 再从该交易日起重新拉取并追加,文件不存在则全量拉取。
+
+const (
+	VipdocDay     = "lday"    //日线 .day
+	VipdocMinute1 = "minline" //1分钟 .lc1
+	VipdocMinute5 = "fzline"  //5分钟 .lc5
+)
+
+// NewVipdocSync 新建 vipdoc 同步,dir 为目标根目录(对应通达信安装目录),subs 为同步的目录,默认全部
+func NewVipdocSync(dir string, subs ...string) *VipdocSync {
+	if len(subs) == 0 {
+		subs = []string{VipdocDay, VipdocMinute1, VipdocMinute5}
+	}
+	return &VipdocSync{
+		Dir:  dir,
+		Subs: subs,
+	}
+}
+
+// VipdocSync 维护完整的通达信 vipdoc 目录
+type VipdocSync struct {
+	Dir  string   //目标根目录
+	Subs []string //同步的目录,lday/minline/fzline
+}
+
+// SyncAll 同步全部股票、ETF和指数
+func (this *VipdocSync) SyncAll(ctx context.Context, m *tdx.Manage) error {
+	codes := []string(nil)
+	m.RangeStocks(func(code string) { codes = append(codes, code) })
+	m.RangeETFs(func(code string) { codes = append(codes, code) })
+	m.RangeIndexes(func(code string) { codes = append(codes, code) })
+	return this.Sync(ctx, m, codes...)
+}
+
+// Sync 同步指定代码,code 需带交易所前缀(如 sz000001)
+func (this *VipdocSync) Sync(ctx context.Context, m *tdx.Manage, codes ...string) error {
+	for _, code := range codes {
+		for _, sub := range this.Subs {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if err := this.SyncCode(m, code, sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SyncCode 增量同步单个代码的单个目录
+func (this *VipdocSync) SyncCode(m *tdx.Manage, code, sub string) error {
+	ex, c, err := decodeCode(code)
+	if err != nil {
+		return err
+	}
+
+	var (
+		Type   uint8
+		ext    string
+		encode func(code string, ks protocol.Klines) ([]byte, error)
+		date   func(rec []byte) uint32
+	)
+	switch sub {
+	case VipdocDay:
+		Type, ext, encode, date = protocol.TypeKlineDay, ".day", WriteDay, dayRecordDate
+	case VipdocMinute1:
+		Type, ext, encode, date = protocol.TypeKlineMinute, ".lc1", WriteMinute1, minuteRecordDate
+	case VipdocMinute5:
+		Type, ext, encode, date = protocol.TypeKline5Minute, ".lc5", WriteMinute5, minuteRecordDate
+	default:
+		return fmt.Errorf("未知的vipdoc目录: %s", sub)
+	}
+
+	filename := this.Filename(ex, sub, c+ext)
+	if err = os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	//找到最后一个交易日的起始位置
+	offset, last, err := lastDayOffset(f, date)
+	if err != nil {
+		return err
+	}
+
+	//从最后一个交易日起拉取
+	until := func(k *protocol.Kline) bool { return last > 0 && klineDate(k) < last }
+	var resp *protocol.KlineResp
+	err = m.Do(func(cli *tdx.Client) error {
+		if protocol.IsIndex(c) {
+			resp, err = cli.GetIndexUntil(Type, c, until)
+		} else {
+			resp, err = cli.GetKlineUntil(Type, c, until)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	ks := protocol.Klines{}
+	for _, k := range resp.List {
+		if klineDate(k) >= last {
+			ks = append(ks, k)
+		}
+	}
+	if len(ks) == 0 {
+		return nil
+	}
+
+	bs, err := encode(c, ks)
+	if err != nil {
+		return err
+	}
+	if err = f.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err = f.WriteAt(bs, offset); err != nil {
+		return err
+	}
+	logs.Debugf("[%s] 同步%s 数量: %d\n", c, sub, len(ks))
+	return nil
+}
+
+// Filename 本地数据文件路径
+func (this *VipdocSync) Filename(ex protocol.Exchange, sub, name string) string {
+	return filepath.Join(this.Dir, "vipdoc", ex.String(), sub, name)
+}
+
+// lastDayOffset 从文件末尾向前查找,返回最后一个交易日首条记录的偏移和日期(YYYYMMDD),空文件返回0,0
+func lastDayOffset(f *os.File, date func(rec []byte) uint32) (int64, uint32, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	size := info.Size() / 32 * 32 //丢弃不完整的尾部记录
+	if size == 0 {
+		return 0, 0, nil
+	}
+	rec := make([]byte, 32)
+	var last uint32
+	offset := size
+	for offset > 0 {
+		if _, err = f.ReadAt(rec, offset-32); err != nil && err != io.EOF {
+			return 0, 0, err
+		}
+		d := date(rec)
+		if last > 0 && d != last {
+			break
+		}
+		last = d
+		offset -= 32
+	}
+	return offset, last, nil
+}
+
+// dayRecordDate .day 记录日期 YYYYMMDD
+func dayRecordDate(rec []byte) uint32 {
+	return binary.LittleEndian.Uint32(rec[0:4])
+}
+
+// minuteRecordDate .lc1/.lc5 记录日期 YYYYMMDD
+func minuteRecordDate(rec []byte) uint32 {
+	d := uint32(binary.LittleEndian.Uint16(rec[0:2]))
+	return (d/2048+2004)*10000 + d%2048
+}
+
+// klineDate K线日期 YYYYMMDD
+func klineDate(k *protocol.Kline) uint32 {
+	return uint32(k.Time.Year()*10000 + int(k.Time.Month())*100 + k.Time.Day())
+}
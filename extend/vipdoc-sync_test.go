@@ -0,0 +1,54 @@
+package extend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// TestLastDayOffset 验证增量同步时能定位到最后一个交易日(含 .lc1 尾盘占位)的起始位置
+func TestLastDayOffset(t *testing.T) {
+	mk := func(day int, h, m int) *protocol.Kline {
+		return &protocol.Kline{
+			Open: protocol.Yuan(10.5), High: protocol.Yuan(11.0), Low: protocol.Yuan(10.0), Close: protocol.Yuan(10.8),
+			Amount: 15534000, Volume: 100, Time: time.Date(2026, 7, day, h, m, 0, 0, time.Local),
+		}
+	}
+	ks := protocol.Klines{
+		mk(8, 9, 31), mk(8, 15, 0),
+		mk(9, 9, 31), mk(9, 9, 32), mk(9, 15, 0),
+	}
+	bs, err := WriteMinute1("sz000001", ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "sz000001.lc1")
+	if err = os.WriteFile(filename, bs, 0666); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	offset, last, err := lastDayOffset(f, minuteRecordDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第一天 2 条真实数据 + 1 条占位
+	if offset != 3*32 || last != 20260709 {
+		t.Errorf("got offset=%d last=%d, want offset=%d last=20260709", offset, last, 3*32)
+	}
+
+	day, err := WriteDay("sz000001", ks[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := dayRecordDate(day); d != 20260708 {
+		t.Errorf("日线日期不一致: got %d", d)
+	}
+}
@@ -0,0 +1,279 @@
+package tdx
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+// 统一入口,按代码(protocol.Symbol)把请求分到标准行情(7709)或扩展行情(7727)的连接,
+// 返回标准行情的结构(protocol.Quote/Kline/Trade),扩展行情的价格由元转为厘。
+// 连接在第一次用到时才建立,只查期货就不会连接标准行情。
+// 标准行情的ETF/基金/可转债报价需要代码表修正价格,代码表也在第一次用到时才加载,见 WithFacadeCodes。
+
+type FacadeOption func(*Facade)
+
+func WithFacadePool(p IPool) FacadeOption {
+	return func(f *Facade) {
+		f.std.pool = p
+	}
+}
+
+func WithFacadeDialPool(dial DialPoolFunc) FacadeOption {
+	return func(f *Facade) {
+		f.std.dial = dial
+	}
+}
+
+// WithFacadeExPool 扩展行情的连接池,见 DialExHqDefault
+func WithFacadeExPool(p IPool) FacadeOption {
+	return func(f *Facade) {
+		f.ex.pool = p
+	}
+}
+
+func WithFacadeDialExPool(dial DialPoolFunc) FacadeOption {
+	return func(f *Facade) {
+		f.ex.dial = dial
+	}
+}
+
+// WithFacadeCodes 代码表,用于修正ETF/基金/可转债等报价的价格,见 Client.GetQuoteWithCodes
+func WithFacadeCodes(cs ICodes) FacadeOption {
+	return func(f *Facade) {
+		f.codes = cs
+	}
+}
+
+// WithFacadeDialCodes 第一次需要代码表时加载,默认优先 DefaultCodes,没有时 NewCodes
+func WithFacadeDialCodes(dial DialCodesFunc) FacadeOption {
+	return func(f *Facade) {
+		f.dialCodes = dial
+	}
+}
+
+func NewFacade(op ...FacadeOption) *Facade {
+	f := &Facade{
+		std: &lazyPool{dial: func() (IPool, error) {
+			return NewPool(func() (*Client, error) { return DialDefault() }, DefaultClients)
+		}},
+		ex: &lazyPool{dial: func() (IPool, error) {
+			return NewPool(func() (*Client, error) { return DialExHqDefault() }, DefaultClients)
+		}},
+		dialCodes: func(c *Client) (ICodes, error) {
+			if DefaultCodes != nil {
+				return DefaultCodes, nil
+			}
+			return NewCodes(WithCodesClient(c))
+		},
+	}
+	for _, v := range op {
+		if v != nil {
+			v(f)
+		}
+	}
+	return f
+}
+
+type Facade struct {
+	std *lazyPool
+	ex  *lazyPool
+
+	codes     ICodes
+	dialCodes DialCodesFunc
+	codesMu   sync.Mutex
+}
+
+// getCodes 代码都是股票/指数时不需要代码表,返回nil
+func (this *Facade) getCodes(c *Client, codes []string) (ICodes, error) {
+	need := false
+	for _, v := range codes {
+		if v = protocol.AddPrefix(v); !protocol.IsStock(v) && !protocol.IsIndex(v) {
+			need = true
+			break
+		}
+	}
+	if !need {
+		return nil, nil
+	}
+	this.codesMu.Lock()
+	defer this.codesMu.Unlock()
+	if this.codes == nil {
+		cs, err := this.dialCodes(c)
+		if err != nil {
+			return nil, err
+		}
+		this.codes = cs
+	}
+	return this.codes, nil
+}
+
+// Do 用代码对应的连接执行
+func (this *Facade) Do(s protocol.Symbol, fn func(c *Client) error) error {
+	p, err := this.pool(s).get()
+	if err != nil {
+		return err
+	}
+	return p.Do(fn)
+}
+
+func (this *Facade) pool(s protocol.Symbol) *lazyPool {
+	if s.IsEx() {
+		return this.ex
+	}
+	return this.std
+}
+
+// Quote 五档报价,代码见 protocol.ParseSymbol,例 sz000001 sh510300 00700.HK rb2510.SHF,
+// 标准行情有ETF/基金/可转债时加载代码表修正价格,见 WithFacadeCodes
+// 结果和 codes 一一对应,服务器没有返回的代码为 nil
+func (this *Facade) Quote(codes ...string) (protocol.QuotesResp, error) {
+	ss, err := parseSymbols(codes)
+	if err != nil {
+		return nil, err
+	}
+	res := make(protocol.QuotesResp, len(ss))
+
+	//标准行情一次请求,扩展行情逐个请求
+	var std []string
+	var index []int
+	for i, s := range ss {
+		if !s.IsEx() {
+			std = append(std, s.String())
+			index = append(index, i)
+			continue
+		}
+		err = this.Do(s, func(c *Client) error {
+			q, err := c.ExQuote(s.Market(), s.Code)
+			if err != nil {
+				return err
+			}
+			res[i] = q.Quote()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(std) > 0 {
+		err = this.Do(ss[index[0]], func(c *Client) error {
+			cs, err := this.getCodes(c, std)
+			if err != nil {
+				return err
+			}
+			ls, err := c.GetQuoteWithCodes(cs, std...)
+			if err != nil {
+				return err
+			}
+			matchQuotes(res, ss, index, ls)
+			return nil
+		})
+	}
+	return res, err
+}
+
+// matchQuotes 按市场+代码把 ls 填到 res 中 index 对应的位置,服务器没返回的保持 nil
+func matchQuotes(res protocol.QuotesResp, ss []protocol.Symbol, index []int, ls protocol.QuotesResp) {
+	m := make(map[string]*protocol.Quote, len(ls))
+	for _, q := range ls {
+		m[q.Exchange.String()+q.Code] = q
+	}
+	for _, i := range index {
+		res[i] = m[ss[i].String()]
+	}
+}
+
+// Kline K线,Type 见 protocol.TypeKlineDay 等,扩展行情的昨收(Last)按前一根补上
+func (this *Facade) Kline(Type uint8, code string, start, count uint16) ([]*protocol.Kline, error) {
+	s, err := protocol.ParseSymbol(code)
+	if err != nil {
+		return nil, err
+	}
+	var ls []*protocol.Kline
+	err = this.Do(s, func(c *Client) error {
+		if !s.IsEx() {
+			get := c.GetKline
+			if protocol.IsIndex(s.String()) {
+				get = c.GetIndex
+			}
+			resp, err := get(Type, s.String(), start, count)
+			if err != nil {
+				return err
+			}
+			ls = resp.List
+			return nil
+		}
+		resp, err := c.ExBars(Type, s.Market(), s.Code, start, count)
+		if err != nil {
+			return err
+		}
+		ls = protocol.ExKlines(resp).Klines()
+		return nil
+	})
+	return ls, err
+}
+
+// Trade 当天的分笔成交
+func (this *Facade) Trade(code string, start, count uint16) (protocol.Trades, error) {
+	s, err := protocol.ParseSymbol(code)
+	if err != nil {
+		return nil, err
+	}
+	var ls protocol.Trades
+	err = this.Do(s, func(c *Client) error {
+		if !s.IsEx() {
+			resp, err := c.GetTrade(s.String(), start, count)
+			if err != nil {
+				return err
+			}
+			ls = resp.List
+			return nil
+		}
+		resp, err := c.ExTrade(s.Market(), s.Code, start, count)
+		if err != nil {
+			return err
+		}
+		ls = make(protocol.Trades, len(resp))
+		for i := range resp {
+			ls[i] = resp[i].Trade()
+		}
+		return nil
+	})
+	return ls, err
+}
+
+func parseSymbols(codes []string) ([]protocol.Symbol, error) {
+	if len(codes) == 0 {
+		return nil, errors.New("代码不能为空")
+	}
+	ss := make([]protocol.Symbol, len(codes))
+	for i, v := range codes {
+		s, err := protocol.ParseSymbol(v)
+		if err != nil {
+			return nil, err
+		}
+		ss[i] = s
+	}
+	return ss, nil
+}
+
+// lazyPool 第一次使用时才连接,失败后下次重新连接
+type lazyPool struct {
+	dial DialPoolFunc
+	pool IPool
+	mu   sync.Mutex
+}
+
+func (this *lazyPool) get() (IPool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.pool == nil {
+		p, err := this.dial()
+		if err != nil {
+			return nil, err
+		}
+		this.pool = p
+	}
+	return this.pool, nil
+}
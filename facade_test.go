@@ -0,0 +1,80 @@
+package tdx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+func TestFacadeRoute(t *testing.T) {
+	errStd, errEx := errors.New("std"), errors.New("ex")
+	dials := map[string]int{}
+	f := NewFacade(
+		WithFacadeDialPool(func() (IPool, error) { dials["std"]++; return nil, errStd }),
+		WithFacadeDialExPool(func() (IPool, error) { dials["ex"]++; return nil, errEx }),
+	)
+
+	for code, want := range map[string]error{
+		"sz000001":   errStd,
+		"600000.SH":  errStd,
+		"00700.HK":   errEx,
+		"rb2510.SHF": errEx,
+		"IF2506":     errEx,
+	} {
+		if _, err := f.Kline(9, code, 0, 1); err != want {
+			t.Errorf("Kline(%s) = %v, want %v", code, err, want)
+		}
+	}
+	if _, err := f.Trade("47:IF2506", 0, 1); err != errEx {
+		t.Errorf("Trade = %v", err)
+	}
+	if _, err := f.Quote("XX2509"); err == nil {
+		t.Error("无法识别的代码应返回错误")
+	}
+	//连接失败后下次重新连接
+	if dials["std"] != 2 || dials["ex"] != 4 {
+		t.Errorf("dials = %v", dials)
+	}
+}
+
+func TestMatchQuotes(t *testing.T) {
+	ss, err := parseSymbols([]string{"sz000001", "00700.HK", "sh600000", "sh600008"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//服务器返回的顺序不同,且少了 sh600000
+	a := &protocol.Quote{Exchange: protocol.ExchangeSH, Code: "600008"}
+	b := &protocol.Quote{Exchange: protocol.ExchangeSZ, Code: "000001"}
+	res := make(protocol.QuotesResp, len(ss))
+	matchQuotes(res, ss, []int{0, 2, 3}, protocol.QuotesResp{a, b})
+	if res[0] != b || res[1] != nil || res[2] != nil || res[3] != a {
+		t.Errorf("res = %v", res)
+	}
+}
+
+func TestFacadeCodes(t *testing.T) {
+	dials := 0
+	cs := NewCodesBase()
+	f := NewFacade(WithFacadeDialCodes(func(c *Client) (ICodes, error) { dials++; return cs, nil }))
+
+	//股票/指数不需要代码表
+	if got, err := f.getCodes(nil, []string{"sz000001", "sh000001"}); got != nil || err != nil || dials != 0 {
+		t.Errorf("getCodes = %v %v, dials = %d", got, err, dials)
+	}
+	//ETF第一次用到时加载,之后复用
+	for i := 0; i < 2; i++ {
+		if got, err := f.getCodes(nil, []string{"sz000001", "sh510300"}); got != cs || err != nil {
+			t.Errorf("getCodes = %v %v", got, err)
+		}
+	}
+	if dials != 1 {
+		t.Errorf("dials = %d", dials)
+	}
+
+	//指定代码表时不加载
+	f = NewFacade(WithFacadeCodes(cs), WithFacadeDialCodes(func(c *Client) (ICodes, error) { return nil, errors.New("不应加载") }))
+	if got, err := f.getCodes(nil, []string{"sh510050"}); got != cs || err != nil {
+		t.Errorf("getCodes = %v %v", got, err)
+	}
+}
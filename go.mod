@@ -10,8 +10,11 @@ require (
 	github.com/injoyai/conv v1.2.8
 	github.com/injoyai/ios v1.2.6
 	github.com/injoyai/logs v1.0.12
+	github.com/klauspost/compress v1.17.9
 	github.com/robfig/cron/v3 v3.0.1
+	golang.org/x/net v0.50.0
 	golang.org/x/text v0.34.0
+	gopkg.in/yaml.v3 v3.0.1
 	xorm.io/core v0.7.3
 	xorm.io/xorm v1.3.9
 )
@@ -32,10 +35,8 @@ require (
 	github.com/pelletier/go-toml/v2 v2.1.1 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/syndtr/goleveldb v1.0.0 // indirect
-	golang.org/x/net v0.50.0 // indirect
 	golang.org/x/sys v0.42.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	modernc.org/libc v1.37.6 // indirect
 	modernc.org/mathutil v1.6.0 // indirect
 	modernc.org/memory v1.7.2 // indirect
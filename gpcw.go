@@ -0,0 +1,194 @@
+package tdx
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx/protocol"
+)
+
+type GpcwOption func(s *Gpcw)
+
+func WithGpcwRetry(retry int) GpcwOption {
+	return func(s *Gpcw) {
+		s.retry = retry
+	}
+}
+
+func WithGpcwSpec(spec string) GpcwOption {
+	return func(s *Gpcw) {
+		s.spec = spec
+	}
+}
+
+// WithGpcwDir 缓存目录,默认 ./data/gpcw
+func WithGpcwDir(dir string) GpcwOption {
+	return func(s *Gpcw) {
+		s.dir = dir
+	}
+}
+
+func WithGpcwClient(c *Client) GpcwOption {
+	return func(s *Gpcw) {
+		s.c = c
+	}
+}
+
+func WithGpcwDialClient(dial DialClientFunc) GpcwOption {
+	return func(s *Gpcw) {
+		s.dialClient = dial
+	}
+}
+
+func WithGpcwOption(op ...GpcwOption) GpcwOption {
+	return func(s *Gpcw) {
+		for _, o := range op {
+			if o != nil {
+				o(s)
+			}
+		}
+	}
+}
+
+// NewGpcw 专业财务数据(gpcw),按报告期缓存原始zip到本地目录,定时按md5增量更新
+func NewGpcw(op ...GpcwOption) (*Gpcw, error) {
+	s := &Gpcw{
+		spec:  DefaultGpcwSpec,
+		retry: DefaultRetry,
+		dir:   filepath.Join(DefaultDataDir, "gpcw"),
+	}
+
+	WithGpcwOption(op...)(s)
+
+	var err error
+
+	//初始化客户端
+	if s.c == nil {
+		if s.dialClient == nil {
+			s.dialClient = func() (*Client, error) { return DialDefault() }
+		}
+		s.c, err = s.dialClient()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = os.MkdirAll(s.dir, 0777); err != nil {
+		return nil, err
+	}
+
+	// 定时/立即更新
+	err = NewTimer(s.spec, s.retry, s)
+
+	return s, err
+}
+
+type Gpcw struct {
+	spec       string
+	retry      int
+	dir        string
+	dialClient DialClientFunc
+
+	c     *Client
+	files []*protocol.FinancialReportFile
+	mu    sync.RWMutex
+}
+
+// List 报告期文件列表,按报告期升序
+func (this *Gpcw) List() []*protocol.FinancialReportFile {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return append([]*protocol.FinancialReportFile(nil), this.files...)
+}
+
+// Dates 已知的全部报告期,升序,例 20240930
+func (this *Gpcw) Dates() []string {
+	ls := []string(nil)
+	for _, v := range this.List() {
+		ls = append(ls, v.Date())
+	}
+	return ls
+}
+
+// Get 获取指定报告期的全市场专业财务数据,优先读取本地缓存,缓存不存在则下载
+func (this *Gpcw) Get(date string) ([]*protocol.FinancialReport, error) {
+	filename := this.filename("gpcw" + date + ".zip")
+	bs, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		bs, err = this.c.GetFinancialReportFile("gpcw" + date + ".zip")
+		if err != nil {
+			return nil, err
+		}
+		err = os.WriteFile(filename, bs, 0666)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return protocol.DecodeFinancialReportZip(bs)
+}
+
+// GetCode 获取单只股票的历史财务数据,按报告期升序,需要逐个解析报告期文件,耗时较长
+func (this *Gpcw) GetCode(code string) ([]*protocol.Financial, error) {
+	_, number, err := protocol.DecodeCode(code)
+	if err != nil {
+		return nil, err
+	}
+	ls := []*protocol.Financial(nil)
+	for _, date := range this.Dates() {
+		reports, err := this.Get(date)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range reports {
+			if v.Code == number {
+				ls = append(ls, v.Financial())
+				break
+			}
+		}
+	}
+	return ls, nil
+}
+
+// Update 更新文件列表,并下载本地缺失或md5不一致的文件
+func (this *Gpcw) Update() error {
+	files, err := this.c.GetFinancialReportList()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+
+	for _, v := range files {
+		filename := this.filename(v.Filename)
+		if bs, err := os.ReadFile(filename); err == nil && strings.EqualFold(fileMD5(bs), v.Hash) {
+			continue
+		}
+		bs, err := this.c.GetFinancialReportFile(v.Filename)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filename, bs, 0666); err != nil {
+			return err
+		}
+		logs.Debugf("[gpcw] 更新 %s 大小: %d\n", v.Filename, len(bs))
+	}
+
+	this.mu.Lock()
+	this.files = files
+	this.mu.Unlock()
+	return nil
+}
+
+func (this *Gpcw) filename(name string) string {
+	return filepath.Join(this.dir, name)
+}
+
+func fileMD5(bs []byte) string {
+	sum := md5.Sum(bs)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package tdx
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/injoyai/ios/client"
+)
+
+// Hook 客户端和连接池的观测回调,用于统计请求耗时、超时、重连等(见 extend/httpserver 的 /metrics),
+// 以及把通达信请求关联到上游的链路追踪。方法在请求的协程中同步调用,需要并发安全且尽快返回。
+// 只关心部分回调时可以嵌入 NopHook。
+type Hook interface {
+	// RequestStart 发送请求前调用,ctx 为 DoContext 传入的上下文,返回的上下文会传给 RequestFinish,
+	// 可以在这里创建追踪的 span
+	RequestStart(ctx context.Context, info *RequestInfo) context.Context
+	// RequestFinish 请求完成(成功、超时或出错)后调用
+	RequestFinish(ctx context.Context, info *RequestInfo)
+	// DecodeError 响应解析失败,请求会因此超时
+	DecodeError(typ uint16, err error)
+	// Connected 连接成功,reconnect 表示是断线重连
+	Connected(addr string, reconnect bool)
+	// Disconnected 连接断开
+	Disconnected(addr string, err error)
+	// PoolWait 从连接池获取连接,d 为等待时间
+	PoolWait(d time.Duration, err error)
+}
+
+// RequestInfo 一次请求的信息
+type RequestInfo struct {
+	Type     uint16        //请求类型,见 protocol.TypeName
+	MsgID    uint32        //消息ID
+	Addr     string        //服务器地址
+	Sent     int           //发送字节数
+	Received int           //响应数据字节数(解压后)
+	Start    time.Time     //开始时间
+	Duration time.Duration //耗时
+	Timeout  bool          //是否超时
+	Err      error         //错误
+}
+
+// NopHook 空实现,嵌入后只需要实现关心的方法
+type NopHook struct{}
+
+func (NopHook) RequestStart(ctx context.Context, info *RequestInfo) context.Context { return ctx }
+func (NopHook) RequestFinish(ctx context.Context, info *RequestInfo)                {}
+func (NopHook) DecodeError(typ uint16, err error)                                   {}
+func (NopHook) Connected(addr string, reconnect bool)                               {}
+func (NopHook) Disconnected(addr string, err error)                                 {}
+func (NopHook) PoolWait(d time.Duration, err error)                                 {}
+
+// MultiHook 依次调用多个 Hook,nil 会被忽略
+func MultiHook(hooks ...Hook) Hook {
+	ls := multiHook{}
+	for _, h := range hooks {
+		if h != nil {
+			ls = append(ls, h)
+		}
+	}
+	if len(ls) == 1 {
+		return ls[0]
+	}
+	return ls
+}
+
+type multiHook []Hook
+
+func (this multiHook) RequestStart(ctx context.Context, info *RequestInfo) context.Context {
+	for _, h := range this {
+		ctx = h.RequestStart(ctx, info)
+	}
+	return ctx
+}
+
+func (this multiHook) RequestFinish(ctx context.Context, info *RequestInfo) {
+	for _, h := range this {
+		h.RequestFinish(ctx, info)
+	}
+}
+
+func (this multiHook) DecodeError(typ uint16, err error) {
+	for _, h := range this {
+		h.DecodeError(typ, err)
+	}
+}
+
+func (this multiHook) Connected(addr string, reconnect bool) {
+	for _, h := range this {
+		h.Connected(addr, reconnect)
+	}
+}
+
+func (this multiHook) Disconnected(addr string, err error) {
+	for _, h := range this {
+		h.Disconnected(addr, err)
+	}
+}
+
+func (this multiHook) PoolWait(d time.Duration, err error) {
+	for _, h := range this {
+		h.PoolWait(d, err)
+	}
+}
+
+const tagHook = "tdx.hook"
+
+// WithHook 设置观测回调,连接前设置才能收到首次连接的事件
+func WithHook(h Hook) client.Option {
+	return func(c *client.Client) {
+		c.Tag.Set(tagHook, h)
+	}
+}
+
+// hookBox atomic.Value 需要相同的具体类型
+type hookBox struct{ Hook }
+
+// ErrTimeout 等待响应超时,可以用 errors.Is 判断
+var ErrTimeout = errors.New("超时")
+
+// SetHook 设置观测回调,nil 为取消
+func (this *Client) SetHook(h Hook) {
+	this.root().hook.Store(hookBox{h})
+}
+
+// Hook 当前的观测回调,未设置时返回nil
+func (this *Client) Hook() Hook {
+	h, _ := this.root().hook.Load().(hookBox)
+	return h.Hook
+}
+
+// root WithContext 的原客户端
+func (this *Client) root() *Client {
+	if this.base != nil {
+		return this.base
+	}
+	return this
+}
+
+// context 请求的上下文,见 WithContext
+func (this *Client) context() context.Context {
+	if this.ctx != nil {
+		return this.ctx
+	}
+	return context.Background()
+}
+
+// WithContext 返回带上 ctx 的客户端(传给 Hook.RequestStart),和原客户端共用连接,
+// 只影响通过返回的客户端发出的请求,原客户端和其他协程的请求不受影响
+func (this *Client) WithContext(ctx context.Context) *Client {
+	b := this.root()
+	return &Client{
+		Client:       b.Client,
+		Wait:         b.Wait,
+		m:            b.m,
+		ctx:          ctx,
+		exTradingDay: b.exTradingDay,
+		base:         b,
+	}
+}
+
+// DoContext 同 IPool.Do,fn 拿到的客户端的请求带上 ctx(见 WithContext),
+// 用于把通达信请求关联到上游的请求(如 HTTP 请求的追踪)
+func DoContext(ctx context.Context, p IPool, fn func(c *Client) error) error {
+	return p.Do(func(c *Client) error {
+		if c == nil {
+			return fn(c)
+		}
+		return fn(c.WithContext(ctx))
+	})
+}
+
+// sizedResp 设置了 Hook 时,响应带上数据大小
+type sizedResp struct {
+	resp any
+	size int
+}
@@ -0,0 +1,105 @@
+package tdx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/injoyai/ios"
+	"github.com/injoyai/tdx/protocol"
+)
+
+type countHook struct {
+	NopHook
+	waits int
+}
+
+func (this *countHook) PoolWait(d time.Duration, err error) { this.waits++ }
+
+func TestMultiHook(t *testing.T) {
+	a, b := &countHook{}, &countHook{}
+	if h := MultiHook(nil, a); h != a {
+		t.Errorf("单个 Hook 应原样返回: %T", h)
+	}
+	MultiHook(a, nil, b).PoolWait(time.Millisecond, nil)
+	if a.waits != 1 || b.waits != 1 {
+		t.Errorf("waits = %d %d", a.waits, b.waits)
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	p, err := NewPool(func() (*Client, error) { return &Client{}, nil }, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &countHook{}
+	p.SetHook(h)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "v")
+	var got *Client
+	err = DoContext(ctx, p, func(c *Client) error {
+		got = c
+		if c.context().Value(key{}) != "v" {
+			t.Error("请求中没有带上 ctx")
+		}
+		if c.root().context().Value(key{}) != nil {
+			t.Error("ctx 不应影响连接池中的客户端")
+		}
+		c.SetHook(h)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.root().Hook() != h {
+		t.Error("应和原客户端共用 Hook")
+	}
+	if h.waits != 1 {
+		t.Errorf("PoolWait = %d", h.waits)
+	}
+}
+
+type ctxHook struct {
+	NopHook
+	ctx  context.Context
+	info *RequestInfo
+}
+
+func (this *ctxHook) RequestStart(ctx context.Context, info *RequestInfo) context.Context {
+	this.ctx = ctx
+	return ctx
+}
+
+func (this *ctxHook) RequestFinish(ctx context.Context, info *RequestInfo) { this.info = info }
+
+func TestTimeout(t *testing.T) {
+	//服务端只读不回,请求必然超时
+	c, err := DialWith(func(ctx context.Context) (ios.ReadWriteCloser, string, error) {
+		a, b := net.Pipe()
+		go io.Copy(io.Discard, b)
+		return a, "pipe", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetTimeout(50 * time.Millisecond)
+	h := &ctxHook{}
+	c.SetHook(h)
+
+	type key struct{}
+	_, err = c.WithContext(context.WithValue(context.Background(), key{}, "v")).GetCount(protocol.ExchangeSH)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v", err)
+	}
+	if h.info == nil || !h.info.Timeout || h.ctx.Value(key{}) != "v" {
+		t.Errorf("info = %+v", h.info)
+	}
+	if _, err = c.GetCount(protocol.ExchangeSH); !errors.Is(err, ErrTimeout) || h.ctx.Value(key{}) != nil {
+		t.Errorf("原客户端: err = %v, ctx 泄漏 = %v", err, h.ctx.Value(key{}) != nil)
+	}
+}
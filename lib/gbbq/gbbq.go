@@ -123,6 +123,7 @@ func Decode(content []byte) (GBBQs, error) {
 		c4 := float64(math.Float32frombits(binary.LittleEndian.Uint32(clearData[25:29])))
 
 		g := &GBBQ{
+			Market:   int(clearData[0]),
 			Category: int(category),
 			Code:     code,
 			Date:     dateTime,
@@ -174,6 +175,7 @@ func decryptBlock(keys, encrypted []byte) []byte {
 }
 
 type GBBQ struct {
+	Market   int       //0深,1沪,2北
 	Category int       //2, 3, 5, 7, 8, 9, 10
 	Code     string    //600000
 	Date     time.Time //取最新
@@ -15,6 +15,8 @@ const (
 	DefaultCodesSpec   = "0 1 9 * * *"
 	DefaultWorkdaySpec = "0 3 9 * * *"
 	DefaultGbbqSpec    = "0 5 9 * * *"
+	DefaultGpcwSpec    = "0 10 9 * * *"
+	DefaultExCodesSpec = "0 15 9 * * *"
 )
 
 func NewManageMysql(dsn string, op ...Option) (*Manage, error) {
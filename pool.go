@@ -2,6 +2,7 @@ package tdx
 
 import (
 	"errors"
+	"time"
 
 	"github.com/injoyai/base/safe"
 )
@@ -40,11 +41,27 @@ func NewPool(dial func() (*Client, error), number int) (*Pool, error) {
 }
 
 type Pool struct {
-	ch chan *Client
+	ch   chan *Client
+	hook Hook
 	*safe.Closer
 }
 
+// SetHook 设置观测回调(PoolWait),在使用前设置,不会设置到池中的客户端
+func (this *Pool) SetHook(h Hook) {
+	this.hook = h
+}
+
 func (this *Pool) Get() (*Client, error) {
+	if this.hook != nil {
+		start := time.Now()
+		c, err := this.get()
+		this.hook.PoolWait(time.Since(start), err)
+		return c, err
+	}
+	return this.get()
+}
+
+func (this *Pool) get() (*Client, error) {
 	select {
 	case <-this.Done():
 		return nil, this.Err()
@@ -1,6 +1,9 @@
 package protocol
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const (
 	TypeConnect            = 0x000D //建立连接
@@ -17,6 +20,46 @@ const (
 	TypeKline              = 0x052D //K线图
 )
 
+// TypeName 请求类型的名称,用于日志和监控,未知类型返回十六进制
+func TypeName(t uint16) string {
+	if s, ok := typeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("0x%04X", t)
+}
+
+var typeNames = map[uint16]string{
+	TypeConnect:            "connect",
+	TypeHeart:              "heart",
+	TypeGbbq:               "gbbq",
+	TypeCount:              "count",
+	TypeCode:               "code",
+	TypeQuote:              "quote",
+	TypeMinute:             "minute",
+	TypeCallAuction:        "call_auction",
+	TypeMinuteTrade:        "minute_trade",
+	TypeHistoryMinute:      "history_minute",
+	TypeHistoryMinuteTrade: "history_minute_trade",
+	TypeKline:              "kline",
+	TypeFinance:            "finance",
+	TypeCompanyCat:         "company_category",
+	TypeCompanyContent:     "company_content",
+	TypeBlockMeta:          "block_meta",
+	TypeBlockInfo:          "block_info",
+	TypeExSetup:            "ex_setup",
+	TypeExMarkets:          "ex_markets",
+	TypeExCount:            "ex_count",
+	TypeExInstrument:       "ex_instrument",
+	TypeExQuote:            "ex_quote",
+	TypeExQuoteList:        "ex_quote_list",
+	TypeExBars:             "ex_bars",
+	TypeExMinute:           "ex_minute",
+	TypeExHistMinute:       "ex_hist_minute",
+	TypeExTrade:            "ex_trade",
+	TypeExHistTrade:        "ex_hist_trade",
+	TypeExBarsRange:        "ex_bars_range",
+}
+
 var (
 	// ExchangeEstablish 交易所成立时间
 	ExchangeEstablish = time.Date(1990, 12, 19, 0, 0, 0, 0, time.Local)
@@ -0,0 +1,453 @@
+package protocol
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+F10 文本解析
+
+GetCompanyContent 返回的是港澳资讯排版好的纯文本,小节以【...】开头,表格用制表符绘制:
+
+	【1.基本资料】
+	┌──────┬──────────────┐
+	│公司名称    │平安银行股份有限公司        │
+	├──────┼──────────────┤
+	│英文全称    │Ping An Bank Co., Ltd.      │
+	└──────┴──────────────┘
+
+这里先把每个分类解析成通用的表格(CompanyTable),再按表头关键字提取常用的结构化数据,
+各分类的排版偶有调整,提取不到时可以回退使用 CompanySection.Raw 或 Tables。
+*/
+
+// 按分类名归类的表格,股东/分红/高管/主营构成按表头识别,不依赖分类名
+const (
+	F10Profile = "公司概况"
+	F10Capital = "股本结构"
+	F10Finance = "财务分析"
+)
+
+var f10Date = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+
+// CompanyF10 单只股票的 F10 解析结果
+type CompanyF10 struct {
+	Code     string             `json:"code"`
+	Sections []*CompanySection  `json:"sections"` //全部分类,含原文,解析不到的内容从这里取
+	Profile  map[string]string  `json:"profile"`  //公司概况键值,如 公司名称/上市日期/主营业务
+	Holders  []*Shareholder     `json:"holders"`  //十大股东/十大流通股东
+	Capital  []*CompanyTable    `json:"capital"`  //股本结构
+	Dividend []*Dividend        `json:"dividend"` //分红历史
+	Managers []*Executive       `json:"managers"` //高管
+	Segments []*BusinessSegment `json:"segments"` //主营构成
+	Finance  []*CompanyTable    `json:"finance"`  //财务分析
+}
+
+// Section 按分类名获取,不存在返回nil
+func (this *CompanyF10) Section(name string) *CompanySection {
+	for _, v := range this.Sections {
+		if strings.Contains(v.Name, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Add 添加一个分类的原文,并按分类名提取结构化数据
+func (this *CompanyF10) Add(name, text string) *CompanySection {
+	s := ParseCompanySection(name, text)
+	this.Sections = append(this.Sections, s)
+	if this.Profile == nil {
+		this.Profile = map[string]string{}
+	}
+
+	for _, t := range s.Tables {
+		switch {
+		case strings.Contains(name, F10Profile) && t.IsKV():
+			for k, v := range t.KV() {
+				if _, ok := this.Profile[k]; !ok {
+					this.Profile[k] = v
+				}
+			}
+		case strings.Contains(name, F10Capital):
+			this.Capital = append(this.Capital, t)
+		case strings.Contains(name, F10Finance):
+			this.Finance = append(this.Finance, t)
+		}
+		this.Holders = append(this.Holders, t.Shareholders()...)
+		this.Dividend = append(this.Dividend, t.Dividends()...)
+		this.Managers = append(this.Managers, t.Executives()...)
+		this.Segments = append(this.Segments, t.BusinessSegments()...)
+	}
+	return s
+}
+
+// CompanySection F10 的一个分类
+type CompanySection struct {
+	Name   string          `json:"name"`   //分类名
+	Raw    string          `json:"raw"`    //原文
+	Tables []*CompanyTable `json:"tables"` //解析出的表格
+}
+
+// CompanyTable F10 中的一个表格
+type CompanyTable struct {
+	Title string     `json:"title"` //所在小节标题,例 【2.十大股东】
+	Date  string     `json:"date"`  //表格前最近出现的日期,例 2024-09-30
+	Rows  [][]string `json:"rows"`  //单元格,已去掉首尾空格
+}
+
+// IsKV 是否是两列的键值表格
+func (this *CompanyTable) IsKV() bool {
+	for _, row := range this.Rows {
+		if len(row) != 2 {
+			return false
+		}
+	}
+	return len(this.Rows) > 0
+}
+
+// KV 按前两列转成键值,键去掉空格
+func (this *CompanyTable) KV() map[string]string {
+	m := map[string]string{}
+	for _, row := range this.Rows {
+		if len(row) >= 2 && len(row[0]) > 0 {
+			m[strings.ReplaceAll(row[0], " ", "")] = row[1]
+		}
+	}
+	return m
+}
+
+// header 查找包含全部关键字的表头行,返回表头行号和每个关键字所在列,未找到返回-1
+func (this *CompanyTable) header(keys ...string) (int, []int) {
+	for i, row := range this.Rows {
+		idx := make([]int, len(keys))
+		found := true
+		for j, key := range keys {
+			idx[j] = -1
+			for k, cell := range row {
+				if strings.Contains(cell, key) {
+					idx[j] = k
+					break
+				}
+			}
+			if idx[j] < 0 {
+				found = false
+				break
+			}
+		}
+		if found {
+			return i, idx
+		}
+	}
+	return -1, nil
+}
+
+// column 在表头中查找第一个包含任一关键字的列,未找到返回-1
+func (this *CompanyTable) column(head int, keys ...string) int {
+	for _, key := range keys {
+		for k, cell := range this.Rows[head] {
+			if strings.Contains(cell, key) {
+				return k
+			}
+		}
+	}
+	return -1
+}
+
+// date 表格内的截止日期(键值行),没有则用表格前的日期
+func (this *CompanyTable) date() string {
+	for _, row := range this.Rows {
+		if len(row) >= 2 && strings.Contains(row[0], "日期") {
+			if d := f10Date.FindString(row[1]); d != "" {
+				return d
+			}
+		}
+	}
+	return this.Date
+}
+
+// Shareholder 十大股东/十大流通股东
+type Shareholder struct {
+	Kind   string  `json:"kind"`   //所在小节,例 【2.十大股东】
+	Date   string  `json:"date"`   //截止日期
+	Name   string  `json:"name"`   //股东名称
+	Shares float64 `json:"shares"` //持股数(股)
+	Ratio  float64 `json:"ratio"`  //占比(%)
+	Change string  `json:"change"` //增减,原文,例 新进/不变/-1234
+	Nature string  `json:"nature"` //股份性质
+}
+
+// ChangeShares 增减股数,新进为全部持股,不变/无法解析为0
+func (this *Shareholder) ChangeShares() float64 {
+	if strings.Contains(this.Change, "新进") {
+		return this.Shares
+	}
+	return ParseF10Number(this.Change)
+}
+
+// Shareholders 按表头(股东名称+持股)提取股东列表
+func (this *CompanyTable) Shareholders() []*Shareholder {
+	head, idx := this.header("股东名称", "持股")
+	if head < 0 {
+		return nil
+	}
+	ratio := this.column(head, "比例", "占")
+	change := this.column(head, "增减")
+	nature := this.column(head, "性质")
+	date := this.date()
+	out := []*Shareholder(nil)
+	for _, row := range this.Rows[head+1:] {
+		if len(row) != len(this.Rows[head]) || row[idx[0]] == "" || f10Date.MatchString(row[idx[0]]) {
+			continue
+		}
+		out = append(out, &Shareholder{
+			Kind:   this.Title,
+			Date:   date,
+			Name:   row[idx[0]],
+			Shares: ParseF10Number(row[idx[1]]),
+			Ratio:  ParseF10Number(cell(row, ratio)),
+			Change: cell(row, change),
+			Nature: cell(row, nature),
+		})
+	}
+	return out
+}
+
+// Dividend 分红方案
+type Dividend struct {
+	Year       string `json:"year"`       //分红年度/报告期
+	Plan       string `json:"plan"`       //方案,例 10派2.46元(含税)
+	AnnounceAt string `json:"announceAt"` //公告日期
+	RecordAt   string `json:"recordAt"`   //股权登记日
+	ExAt       string `json:"exAt"`       //除权除息日
+}
+
+// Dividends 按表头(方案+除权除息日)提取分红列表
+func (this *CompanyTable) Dividends() []*Dividend {
+	head, idx := this.header("方案", "除")
+	if head < 0 {
+		return nil
+	}
+	year := this.column(head, "年度", "报告期")
+	announce := this.column(head, "公告")
+	record := this.column(head, "登记")
+	out := []*Dividend(nil)
+	for _, row := range this.Rows[head+1:] {
+		if len(row) != len(this.Rows[head]) || row[idx[0]] == "" {
+			continue
+		}
+		out = append(out, &Dividend{
+			Year:       cell(row, year),
+			Plan:       row[idx[0]],
+			AnnounceAt: cell(row, announce),
+			RecordAt:   cell(row, record),
+			ExAt:       row[idx[1]],
+		})
+	}
+	return out
+}
+
+// Executive 高管
+type Executive struct {
+	Name      string `json:"name"`      //姓名
+	Title     string `json:"title"`     //职务
+	Gender    string `json:"gender"`    //性别
+	Age       string `json:"age"`       //年龄
+	Education string `json:"education"` //学历
+	Term      string `json:"term"`      //任期/任职日期
+}
+
+// Executives 按表头(姓名+职务)提取高管列表
+func (this *CompanyTable) Executives() []*Executive {
+	head, idx := this.header("姓名", "职务")
+	if head < 0 {
+		return nil
+	}
+	gender := this.column(head, "性别")
+	age := this.column(head, "年龄")
+	edu := this.column(head, "学历")
+	term := this.column(head, "任期", "任职", "起始")
+	out := []*Executive(nil)
+	for _, row := range this.Rows[head+1:] {
+		if len(row) != len(this.Rows[head]) || row[idx[0]] == "" {
+			continue
+		}
+		out = append(out, &Executive{
+			Name:      row[idx[0]],
+			Title:     row[idx[1]],
+			Gender:    cell(row, gender),
+			Age:       cell(row, age),
+			Education: cell(row, edu),
+			Term:      cell(row, term),
+		})
+	}
+	return out
+}
+
+// BusinessSegment 主营构成
+type BusinessSegment struct {
+	Kind         string  `json:"kind"`         //所在小节,例 按行业/按产品/按地区
+	Date         string  `json:"date"`         //报告期
+	Name         string  `json:"name"`         //项目名称
+	Revenue      float64 `json:"revenue"`      //营业收入(元)
+	RevenueRatio float64 `json:"revenueRatio"` //收入占比(%)
+	Cost         float64 `json:"cost"`         //营业成本(元)
+	Profit       float64 `json:"profit"`       //营业利润/毛利(元)
+	GrossMargin  float64 `json:"grossMargin"`  //毛利率(%)
+}
+
+// BusinessSegments 按表头(收入+比例/占比)提取主营构成
+func (this *CompanyTable) BusinessSegments() []*BusinessSegment {
+	head, idx := this.header("收入", "比")
+	if head < 0 {
+		return nil
+	}
+	//第一列为项目名称,且表头不能是其他已知表格
+	if idx[0] == 0 || this.column(head, "股东", "方案", "姓名") >= 0 {
+		return nil
+	}
+	ratio := -1
+	for k, v := range this.Rows[head] {
+		if k > idx[0] && strings.Contains(v, "比") {
+			ratio = k
+			break
+		}
+	}
+	cost := this.column(head, "成本")
+	profit := this.column(head, "利润", "毛利(")
+	margin := this.column(head, "毛利率")
+	date := this.date()
+	out := []*BusinessSegment(nil)
+	for _, row := range this.Rows[head+1:] {
+		if len(row) != len(this.Rows[head]) || row[0] == "" {
+			continue
+		}
+		if d := f10Date.FindString(row[0]); d != "" {
+			date = d
+			continue
+		}
+		out = append(out, &BusinessSegment{
+			Kind:         this.Title,
+			Date:         date,
+			Name:         row[0],
+			Revenue:      ParseF10Number(row[idx[0]]),
+			RevenueRatio: ParseF10Number(cell(row, ratio)),
+			Cost:         ParseF10Number(cell(row, cost)),
+			Profit:       ParseF10Number(cell(row, profit)),
+			GrossMargin:  ParseF10Number(cell(row, margin)),
+		})
+	}
+	return out
+}
+
+// ParseCompanySection 解析一个分类的原文,提取其中的表格
+func ParseCompanySection(name, text string) *CompanySection {
+	s := &CompanySection{Name: name, Raw: text}
+	var (
+		title string
+		date  string
+		table *CompanyTable
+		rows  []f10Row
+		sep   = true //上一行是否是表格分隔线
+	)
+	flush := func() {
+		if table != nil {
+			table.Rows = mergeF10Rows(rows)
+			if len(table.Rows) > 0 {
+				s.Tables = append(s.Tables, table)
+			}
+		}
+		table, rows = nil, nil
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		trim := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trim, "│") || strings.HasPrefix(trim, "|"):
+			if table == nil {
+				table = &CompanyTable{Title: title, Date: date}
+			}
+			rows = append(rows, f10Row{cells: splitF10Row(trim), sep: sep})
+			sep = false
+		case strings.HasPrefix(trim, "├") || strings.HasPrefix(trim, "┌") || strings.HasPrefix(trim, "+"):
+			sep = true
+		case strings.HasPrefix(trim, "└"):
+			flush()
+			sep = true
+		default:
+			flush()
+			if strings.HasPrefix(trim, "【") {
+				title = trim
+			}
+			if d := f10Date.FindString(trim); d != "" {
+				date = d
+			}
+		}
+	}
+	flush()
+	return s
+}
+
+type f10Row struct {
+	cells []string
+	sep   bool //前面是否有分隔线
+}
+
+// mergeF10Rows 合并同一格内换行的行。
+// 行与行之间普遍有分隔线的表格(如十大股东),没有分隔线的行都是上一行的换行;
+// 否则(如主营构成,只有表头下有分隔线)只合并首列为空的行
+func mergeF10Rows(rows []f10Row) [][]string {
+	seps := 0
+	for _, v := range rows {
+		if v.sep {
+			seps++
+		}
+	}
+	out := [][]string(nil)
+	for _, v := range rows {
+		last := len(out) - 1
+		if !v.sep && last >= 0 && len(v.cells) == len(out[last]) && (seps >= 3 || v.cells[0] == "") {
+			for i := range v.cells {
+				out[last][i] += v.cells[i]
+			}
+			continue
+		}
+		out = append(out, v.cells)
+	}
+	return out
+}
+
+// splitF10Row 按竖线拆分一行表格
+func splitF10Row(line string) []string {
+	line = strings.NewReplacer("│", "|", "┃", "|").Replace(line)
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// ParseF10Number 解析 F10 中的数值,支持千分位、百分号和 万/亿 单位,无法解析返回0
+func ParseF10Number(s string) float64 {
+	s = strings.NewReplacer(",", "", " ", "", "%", "", "元", "", "股", "").Replace(s)
+	unit := 1.0
+	switch {
+	case strings.HasSuffix(s, "亿"):
+		unit, s = 1e8, strings.TrimSuffix(s, "亿")
+	case strings.HasSuffix(s, "万"):
+		unit, s = 1e4, strings.TrimSuffix(s, "万")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f * unit
+}
+
+func cell(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
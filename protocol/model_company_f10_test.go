@@ -0,0 +1,123 @@
+package protocol
+
+import (
+	"testing"
+)
+
+const testF10Holder = `☆股东研究☆ ◇000001 平安银行 更新日期：2024-10-28◇
+【1.控股股东与实际控制人】
+截止日期:2024-09-30
+【2.十大股东】
+┌──────────────┬──────┬─────┬──────┬────┐
+│股东名称                    │持股数(股)  │占总股本比│增减情况(股)│股份性质│
+│                            │            │例(%)     │            │        │
+├──────────────┼──────┼─────┼──────┼────┤
+│中国平安保险(集团)股份有限公│9618540236  │49.56     │不变        │流通A股 │
+│司-集团本级-自有资金        │            │          │            │        │
+├──────────────┼──────┼─────┼──────┼────┤
+│香港中央结算有限公司        │1,012.35万  │5.22      │-3250000    │流通A股 │
+├──────────────┼──────┼─────┼──────┼────┤
+│某某基金                    │300000000   │1.55      │新进        │流通A股 │
+└──────────────┴──────┴─────┴──────┴────┘
+`
+
+const testF10Profile = `☆公司概况☆
+【1.基本资料】
+┌──────┬──────────────┐
+│公司名称    │平安银行股份有限公司        │
+├──────┼──────────────┤
+│英文全称    │Ping An Bank Co., Ltd.      │
+├──────┼──────────────┤
+│主营业务    │经有关监管机构批准的各项商业│
+│            │银行业务                    │
+└──────┴──────────────┘
+【2.发行上市】
+┌──────┬──────────────┐
+│上市日期    │1991-04-03                  │
+└──────┴──────────────┘
+`
+
+const testF10Business = `☆经营分析☆
+【1.主营构成分析】
+截止日期:2024-06-30
+┌──────┬──────┬─────┬──────┬─────┐
+│项目名称    │营业收入(元)│收入比例(%│营业成本(元)│毛利率(%) │
+├──────┼──────┼─────┼──────┼─────┤
+│按行业      │            │          │            │          │
+│零售金融业务│4.5亿       │60.00     │1.2亿       │73.33     │
+│对公金融业务│3.0亿       │40.00     │--          │--        │
+└──────┴──────┴─────┴──────┴─────┘
+`
+
+const testF10Dividend = `☆分红融资☆
+【1.分红】
+┌─────┬───────────┬──────┬──────┬──────┐
+│分红年度  │分红方案              │公告日期    │股权登记日  │除权除息日  │
+├─────┼───────────┼──────┼──────┼──────┤
+│2023年度  │10派7.19元(含税)      │2024-06-06  │2024-06-13  │2024-06-14  │
+├─────┼───────────┼──────┼──────┼──────┤
+│2022年度  │10派2.85元(含税)      │2023-06-07  │2023-06-13  │2023-06-14  │
+└─────┴───────────┴──────┴──────┴──────┘
+`
+
+func TestCompanyF10(t *testing.T) {
+	f := &CompanyF10{Code: "sz000001"}
+	f.Add("公司概况", testF10Profile)
+	f.Add("股东研究", testF10Holder)
+	f.Add("经营分析", testF10Business)
+	f.Add("分红融资", testF10Dividend)
+	f.Add("其他", "没有表格的原文")
+
+	if f.Profile["公司名称"] != "平安银行股份有限公司" || f.Profile["上市日期"] != "1991-04-03" ||
+		f.Profile["主营业务"] != "经有关监管机构批准的各项商业银行业务" {
+		t.Fatalf("profile: %v", f.Profile)
+	}
+
+	if len(f.Holders) != 3 {
+		t.Fatalf("holders: %d", len(f.Holders))
+	}
+	h := f.Holders[0]
+	if h.Name != "中国平安保险(集团)股份有限公司-集团本级-自有资金" || h.Shares != 9618540236 ||
+		h.Ratio != 49.56 || h.Date != "2024-09-30" || h.Kind != "【2.十大股东】" || h.ChangeShares() != 0 {
+		t.Fatalf("holder: %+v", h)
+	}
+	if f.Holders[1].Shares != 10123500 || f.Holders[1].ChangeShares() != -3250000 {
+		t.Fatalf("holder: %+v", f.Holders[1])
+	}
+	if f.Holders[2].ChangeShares() != 300000000 {
+		t.Fatalf("holder: %+v", f.Holders[2])
+	}
+
+	if len(f.Segments) != 3 {
+		t.Fatalf("segments: %d", len(f.Segments))
+	}
+	s := f.Segments[1]
+	if s.Name != "零售金融业务" || s.Revenue != 4.5e8 || s.RevenueRatio != 60 || s.Cost != 1.2e8 ||
+		s.GrossMargin != 73.33 || s.Date != "2024-06-30" {
+		t.Fatalf("segment: %+v", s)
+	}
+
+	if len(f.Dividend) != 2 || f.Dividend[0].Plan != "10派7.19元(含税)" || f.Dividend[0].ExAt != "2024-06-14" ||
+		f.Dividend[0].RecordAt != "2024-06-13" || f.Dividend[0].Year != "2023年度" {
+		t.Fatalf("dividend: %+v", f.Dividend)
+	}
+
+	if v := f.Section("其他"); v == nil || v.Raw != "没有表格的原文" || len(v.Tables) != 0 {
+		t.Fatalf("raw: %+v", v)
+	}
+}
+
+func TestParseF10Number(t *testing.T) {
+	for s, want := range map[string]float64{
+		"1,234.5": 1234.5,
+		"1.2亿":    1.2e8,
+		"-3万":     -3e4,
+		"12.5%":   12.5,
+		"--":      0,
+		"100000股": 100000,
+	} {
+		if got := ParseF10Number(s); got != want {
+			t.Errorf("%s: %v != %v", s, got, want)
+		}
+	}
+}
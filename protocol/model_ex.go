@@ -2,6 +2,8 @@ package protocol
 
 import (
 	"encoding/binary"
+	"math"
+	"time"
 )
 
 // 扩展行情协议(TdxExHq)——期货/期权/港股/外汇/全球指数。
@@ -66,50 +68,58 @@ type ExQuote struct {
 
 // ExKline 扩展K线一根。
 type ExKline struct {
-	Datetime string  `json:"datetime"`
-	Open     float64 `json:"open"`
-	High     float64 `json:"high"`
-	Low      float64 `json:"low"`
-	Close    float64 `json:"close"`
-	Position uint32  `json:"position"` // 持仓
-	Trade    uint32  `json:"trade"`    // 成交量
-	Price    float64 `json:"price"`    // 结算价
-	Amount   float64 `json:"amount"`   // 成交额(=position 字节按 float 重解释,与 pytdx 一致)
+	Datetime   string    `json:"datetime"`   // 服务器原始时间,分钟K线的日期为交易日(夜盘排在当天白盘前)
+	Time       time.Time `json:"time"`       // 实际时间(交易所时区),见 ExSession
+	TradingDay string    `json:"tradingDay"` // 交易日 YYYY-MM-DD
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Position   uint32    `json:"position"` // 持仓
+	Trade      uint32    `json:"trade"`    // 成交量
+	Price      float64   `json:"price"`    // 结算价
+	Amount     float64   `json:"amount"`   // 成交额(=position 字节按 float 重解释,与 pytdx 一致)
 }
 
 // ExMinuteTick 扩展分时一笔。
 type ExMinuteTick struct {
-	Hour         int     `json:"hour"`
-	Minute       int     `json:"minute"`
-	Price        float64 `json:"price"`
-	AvgPrice     float64 `json:"avgPrice"`
-	Volume       uint32  `json:"volume"`
-	OpenInterest uint32  `json:"openInterest"`
+	Hour         int       `json:"hour"`
+	Minute       int       `json:"minute"`
+	Time         time.Time `json:"time"`       // 实际时间(交易所时区),见 ExSession
+	TradingDay   string    `json:"tradingDay"` // 交易日 YYYY-MM-DD
+	Price        float64   `json:"price"`
+	AvgPrice     float64   `json:"avgPrice"`
+	Volume       uint32    `json:"volume"`
+	OpenInterest uint32    `json:"openInterest"`
 }
 
 // ExTradeTick 扩展分笔成交一笔。
 type ExTradeTick struct {
-	Hour       int    `json:"hour"`
-	Minute     int    `json:"minute"`
-	Second     int    `json:"second"`
-	Price      uint32 `json:"price"`
-	Volume     uint32 `json:"volume"`
-	ZengCang   int32  `json:"zengCang"` // 增仓
-	Nature     uint16 `json:"nature"`
-	NatureName string `json:"natureName"`
-	Direction  int    `json:"direction"` // 1买 -1卖 0中性
+	Hour       int       `json:"hour"`
+	Minute     int       `json:"minute"`
+	Second     int       `json:"second"`
+	Time       time.Time `json:"time"`       // 实际时间(交易所时区),见 ExSession
+	TradingDay string    `json:"tradingDay"` // 交易日 YYYY-MM-DD
+	Price      uint32    `json:"price"`
+	Volume     uint32    `json:"volume"`
+	ZengCang   int32     `json:"zengCang"` // 增仓
+	Nature     uint16    `json:"nature"`
+	NatureName string    `json:"natureName"`
+	Direction  int       `json:"direction"` // 1买 -1卖 0中性
 }
 
 // ExRangeKline 扩展历史K线区间一根。
 type ExRangeKline struct {
-	Datetime        string  `json:"datetime"`
-	Open            float64 `json:"open"`
-	High            float64 `json:"high"`
-	Low             float64 `json:"low"`
-	Close           float64 `json:"close"`
-	Position        uint32  `json:"position"`
-	Trade           uint32  `json:"trade"`
-	SettlementPrice float64 `json:"settlementPrice"`
+	Datetime        string    `json:"datetime"`
+	Time            time.Time `json:"time"`       // 实际时间(交易所时区),见 ExSession
+	TradingDay      string    `json:"tradingDay"` // 交易日 YYYY-MM-DD
+	Open            float64   `json:"open"`
+	High            float64   `json:"high"`
+	Low             float64   `json:"low"`
+	Close           float64   `json:"close"`
+	Position        uint32    `json:"position"`
+	Trade           uint32    `json:"trade"`
+	SettlementPrice float64   `json:"settlementPrice"`
 }
 
 // ExQuoteListItem 批量行情列表项(期货 category=3 / 港股 category=2)。
@@ -132,6 +142,115 @@ type ExQuoteListItem struct {
 	AskVol    [5]uint32  `json:"askVol"`
 }
 
+// ---- 转换为标准行情结构 ----
+
+// Quote 转为标准行情的五档报价,价格按元转为厘,持仓等期货字段没有对应。
+func (this *ExQuote) Quote() *Quote {
+	q := &Quote{
+		Exchange: Exchange(this.Market),
+		Code:     this.Code,
+		Kline: &Kline{
+			Last:   exPrice(this.PreClose),
+			Open:   exPrice(this.Open),
+			High:   exPrice(this.High),
+			Low:    exPrice(this.Low),
+			Close:  exPrice(this.Price),
+			Volume: int64(this.ZongLiang),
+			Time:   time.Now(),
+		},
+		Intuition:  int(this.XianLiang),
+		InsideDish: int(this.NeiPan),
+		OuterDisc:  int(this.WaiPan),
+	}
+	for i := range this.Bid {
+		q.BuyLevel[i] = PriceLevel{Buy: true, Price: exPrice(this.Bid[i]), Number: int(this.BidVol[i])}
+		q.SellLevel[i] = PriceLevel{Price: exPrice(this.Ask[i]), Number: int(this.AskVol[i])}
+	}
+	return q
+}
+
+// Kline 转为标准行情的K线,昨收(Last)需要按前一根补上,见 ExKlines.Klines。
+// 没有 Time 时按 Datetime 解析(北京时间)。
+func (this *ExKline) Kline() *Kline {
+	t := this.Time
+	if t.IsZero() {
+		t, _ = time.ParseInLocation("2006-01-02 15:04", this.Datetime, exBeijing)
+	}
+	return &Kline{
+		Open:   exPrice(this.Open),
+		High:   exPrice(this.High),
+		Low:    exPrice(this.Low),
+		Close:  exPrice(this.Close),
+		Volume: int64(this.Trade),
+		Amount: exPrice(this.Amount),
+		Time:   t,
+	}
+}
+
+// Kline 转为标准行情的K线,没有成交额。
+func (this *ExRangeKline) Kline() *Kline {
+	return &Kline{
+		Open:   exPrice(this.Open),
+		High:   exPrice(this.High),
+		Low:    exPrice(this.Low),
+		Close:  exPrice(this.Close),
+		Volume: int64(this.Trade),
+		Time:   this.Time,
+	}
+}
+
+// ExKlines 扩展K线列表。
+type ExKlines []ExKline
+
+// Klines 转为标准行情的K线,昨收取前一根的收盘价,可直接用于重采样/指标/存储。
+func (this ExKlines) Klines() []*Kline {
+	ls := make([]*Kline, len(this))
+	for i := range this {
+		ls[i] = this[i].Kline()
+		if i > 0 {
+			ls[i].Last = ls[i-1].Close
+		}
+	}
+	return ls
+}
+
+// ExRangeKlines 扩展历史K线区间列表。
+type ExRangeKlines []ExRangeKline
+
+// Klines 转为标准行情的K线,昨收取前一根的收盘价。
+func (this ExRangeKlines) Klines() []*Kline {
+	ls := make([]*Kline, len(this))
+	for i := range this {
+		ls[i] = this[i].Kline()
+		if i > 0 {
+			ls[i].Last = ls[i-1].Close
+		}
+	}
+	return ls
+}
+
+// Trade 转为标准行情的分笔成交,方向 买/卖/中性 对应 Status 0/1/2。
+func (this *ExTradeTick) Trade() *Trade {
+	status := 2
+	switch this.Direction {
+	case 1:
+		status = 0
+	case -1:
+		status = 1
+	}
+	return &Trade{
+		Time:   this.Time,
+		Price:  exPrice(float64(this.Price)),
+		Volume: int(this.Volume),
+		Status: status,
+	}
+}
+
+// exPrice 元转为厘,扩展行情的价格是 float32,四舍五入避免 3800.2 变成 3800199。
+func exPrice(f float64) Price {
+	return Price(math.Round(f * 1000))
+}
+
 // ---- 协议单例 ----
 
 type exHq struct{}
@@ -296,8 +415,12 @@ func (exHq) DecodeQuote(bs []byte) (*ExQuote, error) {
 
 // ---- K线 ----
 
-// ExBarsCache K线解析所需上下文(category 决定时间编码)。
-type ExBarsCache struct{ Category uint8 }
+// ExBarsCache K线解析所需上下文(category 决定时间编码,market 决定时区和夜盘)。
+type ExBarsCache struct {
+	Category     uint8
+	Market       uint8
+	IsTradingDay func(t time.Time) bool //交易日判断,nil 为 ExWeekday,见 ExSession
+}
 
 // FrameBars K线请求。
 func (exHq) FrameBars(category uint8, market uint8, code string, start, count uint16) *Frame {
@@ -326,6 +449,7 @@ func (exHq) DecodeBars(bs []byte, c ExBarsCache) ([]ExKline, error) {
 		var dt [4]byte
 		copy(dt[:], r.bytes(4))
 		t := GetTime(dt, c.Category)
+		real, day := exBarTime(c.Market, c.Category, t, c.IsTradingDay)
 		base := r.p
 		open := r.f32()
 		high := r.f32()
@@ -336,8 +460,8 @@ func (exHq) DecodeBars(bs []byte, c ExBarsCache) ([]ExKline, error) {
 		trade := r.u32()
 		price := r.f32()
 		out = append(out, ExKline{
-			Datetime: t.Format("2006-01-02 15:04"),
-			Open:     open, High: high, Low: low, Close: cls,
+			Datetime: t.Format("2006-01-02 15:04"), Time: real, TradingDay: day,
+			Open: open, High: high, Low: low, Close: cls,
 			Position: position, Trade: trade, Price: price, Amount: amount,
 		})
 	}
@@ -354,9 +478,16 @@ func (exHq) FrameMinute(market uint8, code string) *Frame {
 	return exFrame(TypeExMinute, Control01, data)
 }
 
+// ExMinuteCache 分时解析上下文,date=YYYYMMDD 为交易日,0 为当前交易日(见 ExTradingDay)。
+type ExMinuteCache struct {
+	Market       uint8
+	Date         uint32
+	IsTradingDay func(t time.Time) bool //交易日判断,nil 为 ExWeekday,见 ExSession
+}
+
 // DecodeMinute 解析当日分时(头部 12 字节)。
-func (exHq) DecodeMinute(bs []byte) ([]ExMinuteTick, error) {
-	return decodeExMinute(bs, 12)
+func (exHq) DecodeMinute(bs []byte, c ExMinuteCache) ([]ExMinuteTick, error) {
+	return decodeExMinute(bs, 12, NewExSession(c.Market, c.Date, c.IsTradingDay))
 }
 
 // FrameHistMinute 历史分时请求。
@@ -369,11 +500,11 @@ func (exHq) FrameHistMinute(market uint8, code string, date uint32) *Frame {
 }
 
 // DecodeHistMinute 解析历史分时(头部 20 字节)。
-func (exHq) DecodeHistMinute(bs []byte) ([]ExMinuteTick, error) {
-	return decodeExMinute(bs, 20)
+func (exHq) DecodeHistMinute(bs []byte, c ExMinuteCache) ([]ExMinuteTick, error) {
+	return decodeExMinute(bs, 20, NewExSession(c.Market, c.Date, c.IsTradingDay))
 }
 
-func decodeExMinute(bs []byte, headerLen int) ([]ExMinuteTick, error) {
+func decodeExMinute(bs []byte, headerLen int, session ExSession) ([]ExMinuteTick, error) {
 	if len(bs) < headerLen {
 		return nil, nil
 	}
@@ -390,7 +521,7 @@ func decodeExMinute(bs []byte, headerLen int) ([]ExMinuteTick, error) {
 		vol := r.u32()
 		amt := r.u32()
 		out = append(out, ExMinuteTick{
-			Hour: raw / 60, Minute: raw % 60,
+			Hour: raw / 60, Minute: raw % 60, Time: session.Time(raw/60, raw%60, 0), TradingDay: session.Day(),
 			Price: price, AvgPrice: avg, Volume: vol, OpenInterest: amt,
 		})
 	}
@@ -399,8 +530,12 @@ func decodeExMinute(bs []byte, headerLen int) ([]ExMinuteTick, error) {
 
 // ---- 分笔成交 ----
 
-// ExTradeCache 分笔解析上下文(market 决定港股 B/S 判定)。
-type ExTradeCache struct{ Market uint8 }
+// ExTradeCache 分笔解析上下文(market 决定港股 B/S 判定和时区),date=YYYYMMDD 为交易日,0 为当前交易日。
+type ExTradeCache struct {
+	Market       uint8
+	Date         uint32
+	IsTradingDay func(t time.Time) bool //交易日判断,nil 为 ExWeekday,见 ExSession
+}
 
 // FrameTrade 当日分笔请求。
 func (exHq) FrameTrade(market uint8, code string, start, count uint16) *Frame {
@@ -414,7 +549,7 @@ func (exHq) FrameTrade(market uint8, code string, start, count uint16) *Frame {
 
 // DecodeTrade 解析当日分笔(头部 16 字节)。
 func (exHq) DecodeTrade(bs []byte, c ExTradeCache) ([]ExTradeTick, error) {
-	return decodeExTrade(bs, c.Market)
+	return decodeExTrade(bs, c.Market, NewExSession(c.Market, c.Date, c.IsTradingDay))
 }
 
 // FrameHistTrade 历史分笔请求。
@@ -430,10 +565,10 @@ func (exHq) FrameHistTrade(market uint8, code string, date uint32, start, count
 
 // DecodeHistTrade 解析历史分笔(头部 16 字节,与当日同构)。
 func (exHq) DecodeHistTrade(bs []byte, c ExTradeCache) ([]ExTradeTick, error) {
-	return decodeExTrade(bs, c.Market)
+	return decodeExTrade(bs, c.Market, NewExSession(c.Market, c.Date, c.IsTradingDay))
 }
 
-func decodeExTrade(bs []byte, market uint8) ([]ExTradeTick, error) {
+func decodeExTrade(bs []byte, market uint8, session ExSession) ([]ExTradeTick, error) {
 	if len(bs) < 16 {
 		return nil, nil
 	}
@@ -456,6 +591,7 @@ func decodeExTrade(bs []byte, market uint8) ([]ExTradeTick, error) {
 		dir, name := exTradeNature(market, direction, volume, zengcang)
 		out = append(out, ExTradeTick{
 			Hour: raw / 60, Minute: raw % 60, Second: second,
+			Time: session.Time(raw/60, raw%60, second), TradingDay: session.Day(),
 			Price: price, Volume: volume, ZengCang: zengcang,
 			Nature: direction, NatureName: name, Direction: dir,
 		})
@@ -546,8 +682,14 @@ func (exHq) FrameBarsRange(market uint8, code string, date, date2 uint32) *Frame
 	return exFrame(TypeExBarsRange, Control01, data)
 }
 
+// ExBarsRangeCache 历史K线区间解析上下文(market 决定时区和夜盘)。
+type ExBarsRangeCache struct {
+	Market       uint8
+	IsTradingDay func(t time.Time) bool //交易日判断,nil 为 ExWeekday,见 ExSession
+}
+
 // DecodeBarsRange 解析历史K线区间。
-func (exHq) DecodeBarsRange(bs []byte) ([]ExRangeKline, error) {
+func (exHq) DecodeBarsRange(bs []byte, c ExBarsRangeCache) ([]ExRangeKline, error) {
 	if len(bs) < 14 {
 		return nil, nil
 	}
@@ -572,8 +714,10 @@ func (exHq) DecodeBarsRange(bs []byte) ([]ExRangeKline, error) {
 		day := (d1 % 2048) % 100
 		hour := d2 / 60
 		minute := d2 % 60
+		session := ExSession{Market: c.Market, TradingDay: time.Date(year, time.Month(month), day, 0, 0, 0, 0, exBeijing), IsTradingDay: c.IsTradingDay}
 		out = append(out, ExRangeKline{
 			Datetime: fmtDatetime(year, month, day, hour, minute),
+			Time:     session.Time(hour, minute, 0), TradingDay: session.Day(),
 			Open:     open, High: high, Low: low, Close: cls,
 			Position: position, Trade: trade, SettlementPrice: settle,
 		})
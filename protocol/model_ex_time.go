@@ -0,0 +1,128 @@
+package protocol
+
+import (
+	"time"
+)
+
+// 扩展行情(7727)的时间只有 时:分(:秒),并且都是北京时间,
+// 期货夜盘(21:00~次日02:30)归属下一个交易日,美股按北京时间跨越两个自然日,
+// 这里按 市场+交易日 还原成交易所时区的真实时间。
+
+var (
+	exBeijing = exLoadLocation("Asia/Shanghai", 8*60*60)
+	exNewYork = exLoadLocation("America/New_York", -5*60*60)
+)
+
+func exLoadLocation(name string, offset int) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		//没有时区数据库时用固定时区,美股夏令时会差1小时
+		return time.FixedZone(name, offset)
+	}
+	return loc
+}
+
+// ExWeekday 默认的交易日判断,只按周一到周五,不排除节假日(可以用 tdx.Workday.Is)
+func ExWeekday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// ExLocation 市场所在的时区,美股为纽约,其他为北京
+func ExLocation(market uint8) *time.Location {
+	if Exchange(market) == ExchangeUS {
+		return exNewYork
+	}
+	return exBeijing
+}
+
+// exNight 有夜盘的市场(上期所/能源中心/大商所/郑商所及其期权)
+func exNight(market uint8) bool {
+	switch Exchange(market) {
+	case ExchangeSHF, ExchangeDCE, ExchangeCZC, ExchangeSHFO, ExchangeDCEO, ExchangeCZCO:
+		return true
+	}
+	return false
+}
+
+// ExTradingDay 时间 now 所在的交易日(北京时间0点),
+// 有夜盘的市场20点后算下一个交易日,美股按纽约的日期。isTradingDay 为nil时用 ExWeekday
+func ExTradingDay(market uint8, now time.Time, isTradingDay func(t time.Time) bool) time.Time {
+	if Exchange(market) == ExchangeUS {
+		ny := now.In(exNewYork)
+		t := time.Date(ny.Year(), ny.Month(), ny.Day(), 0, 0, 0, 0, exBeijing)
+		return exRollTradingDay(t, -1, isTradingDay)
+	}
+	now = now.In(exBeijing)
+	t := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, exBeijing)
+	if exNight(market) && now.Hour() >= 20 {
+		t = t.AddDate(0, 0, 1)
+	}
+	return exRollTradingDay(t, 1, isTradingDay)
+}
+
+// exRollTradingDay 不是交易日时往前(step=-1)或往后(step=1)找
+func exRollTradingDay(t time.Time, step int, isTradingDay func(t time.Time) bool) time.Time {
+	if isTradingDay == nil {
+		isTradingDay = ExWeekday
+	}
+	for i := 0; i < 30 && !isTradingDay(t); i++ {
+		t = t.AddDate(0, 0, step)
+	}
+	return t
+}
+
+// ExSession 一个市场的一个交易日,用于把 时:分:秒 还原成真实时间
+type ExSession struct {
+	Market       uint8
+	TradingDay   time.Time              //交易日,北京时间0点
+	IsTradingDay func(t time.Time) bool //交易日判断,用于找夜盘的上一个交易日,nil 为 ExWeekday
+}
+
+// NewExSession date=YYYYMMDD,为0时取当前交易日,见 ExTradingDay
+func NewExSession(market uint8, date uint32, isTradingDay func(t time.Time) bool) ExSession {
+	if date == 0 {
+		return ExSession{Market: market, TradingDay: ExTradingDay(market, time.Now(), isTradingDay), IsTradingDay: isTradingDay}
+	}
+	t := time.Date(int(date/10000), time.Month(date%10000/100), int(date%100), 0, 0, 0, 0, exBeijing)
+	return ExSession{Market: market, TradingDay: t, IsTradingDay: isTradingDay}
+}
+
+// Day 交易日 YYYY-MM-DD
+func (this ExSession) Day() string {
+	return this.TradingDay.Format(time.DateOnly)
+}
+
+// Time 北京时间的 时:分:秒 还原成交易所时区的真实时间:
+//   - 美股: 12点前为交易日的下一个自然日(北京时间)
+//   - 18点后: 夜盘,为上一个交易日
+//   - 6点前: 夜盘跨过0点,为上一个交易日的下一个自然日(周五夜盘为周六)
+func (this ExSession) Time(hour, minute, second int) time.Time {
+	day := this.TradingDay
+	switch {
+	case Exchange(this.Market) == ExchangeUS:
+		if hour < 12 {
+			day = day.AddDate(0, 0, 1)
+		}
+	case hour >= 18:
+		day = exRollTradingDay(day.AddDate(0, 0, -1), -1, this.IsTradingDay)
+	case hour < 6:
+		day = exRollTradingDay(day.AddDate(0, 0, -1), -1, this.IsTradingDay).AddDate(0, 0, 1)
+	}
+	t := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, 0, exBeijing)
+	return t.In(ExLocation(this.Market))
+}
+
+// exBarTime K线的真实时间和交易日,
+// 分钟K线的日期为交易日,日线及以上为收盘时间(北京15:00,美股纽约16:00)
+func exBarTime(market, category uint8, t time.Time, isTradingDay func(t time.Time) bool) (time.Time, string) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, exBeijing)
+	session := ExSession{Market: market, TradingDay: day, IsTradingDay: isTradingDay}
+	switch category {
+	case TypeKlineMinute, TypeKlineMinute2, TypeKline5Minute, TypeKline15Minute, TypeKline30Minute, TypeKline60Minute:
+		return session.Time(t.Hour(), t.Minute(), 0), session.Day()
+	}
+	if Exchange(market) == ExchangeUS {
+		return time.Date(t.Year(), t.Month(), t.Day(), 16, 0, 0, 0, exNewYork), session.Day()
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 15, 0, 0, 0, exBeijing), session.Day()
+}
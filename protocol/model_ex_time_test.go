@@ -0,0 +1,89 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExSession(t *testing.T) {
+	//周一(2025-06-09)的交易日,夜盘是上周五晚上和周六凌晨
+	s := NewExSession(uint8(ExchangeSHF), 20250609, nil)
+	for _, v := range []struct {
+		hour, minute int
+		want         string
+	}{
+		{21, 0, "2025-06-06 21:00"},
+		{1, 30, "2025-06-07 01:30"},
+		{9, 0, "2025-06-09 09:00"},
+		{14, 59, "2025-06-09 14:59"},
+	} {
+		if got := s.Time(v.hour, v.minute, 0).Format("2006-01-02 15:04"); got != v.want {
+			t.Errorf("%02d:%02d = %s, want %s", v.hour, v.minute, got, v.want)
+		}
+	}
+	if s.Day() != "2025-06-09" {
+		t.Errorf("Day = %s", s.Day())
+	}
+
+	//美股,北京时间 21:30 和次日 03:59 都是纽约当天
+	us := NewExSession(uint8(ExchangeUS), 20250606, nil)
+	if got := us.Time(21, 30, 0); got.Format(time.DateTime) != "2025-06-06 09:30:00" || got.Location() != ExLocation(uint8(ExchangeUS)) {
+		t.Errorf("US open = %v", got)
+	}
+	if got := us.Time(3, 59, 0).Format(time.DateTime); got != "2025-06-06 15:59:00" {
+		t.Errorf("US close = %s", got)
+	}
+}
+
+func TestExTradingDay(t *testing.T) {
+	at := func(s string) time.Time {
+		v, _ := time.ParseInLocation(time.DateTime, s, exBeijing)
+		return v
+	}
+	for _, v := range []struct {
+		market uint8
+		now    string
+		want   string
+	}{
+		{uint8(ExchangeSHF), "2025-06-05 10:00:00", "2025-06-05"},
+		{uint8(ExchangeSHF), "2025-06-05 21:00:00", "2025-06-06"},
+		{uint8(ExchangeSHF), "2025-06-06 21:00:00", "2025-06-09"},
+		{uint8(ExchangeSHF), "2025-06-07 01:00:00", "2025-06-09"},
+		{uint8(ExchangeCFF), "2025-06-05 21:00:00", "2025-06-05"},
+		{uint8(ExchangeUS), "2025-06-07 03:00:00", "2025-06-06"},
+		{uint8(ExchangeUS), "2025-06-09 10:00:00", "2025-06-06"},
+	} {
+		if got := ExTradingDay(v.market, at(v.now), nil).Format(time.DateOnly); got != v.want {
+			t.Errorf("%d %s = %s, want %s", v.market, v.now, got, v.want)
+		}
+	}
+
+	//6月9日(周一)休市,周五夜盘归到周二,周二的夜盘还是上周五晚上
+	holiday := func(t time.Time) bool { return ExWeekday(t) && t.Format(time.DateOnly) != "2025-06-09" }
+	if got := ExTradingDay(uint8(ExchangeSHF), at("2025-06-06 21:00:00"), holiday).Format(time.DateOnly); got != "2025-06-10" {
+		t.Errorf("holiday = %s", got)
+	}
+	if got := NewExSession(uint8(ExchangeSHF), 20250610, holiday).Time(21, 0, 0).Format(time.DateTime); got != "2025-06-06 21:00:00" {
+		t.Errorf("holiday night = %s", got)
+	}
+}
+
+func TestExKlines(t *testing.T) {
+	day := time.Date(2025, 6, 9, 0, 0, 0, 0, time.Local)
+	real, tradingDay := exBarTime(uint8(ExchangeDCE), TypeKlineMinute, day.Add(21*time.Hour+time.Minute), nil)
+	if real.Format(time.DateTime) != "2025-06-06 21:01:00" || tradingDay != "2025-06-09" {
+		t.Errorf("minute bar = %v %s", real, tradingDay)
+	}
+	real, tradingDay = exBarTime(uint8(ExchangeDCE), TypeKlineDay, day.Add(15*time.Hour), nil)
+	if real.Format(time.DateTime) != "2025-06-09 15:00:00" || tradingDay != "2025-06-09" {
+		t.Errorf("day bar = %v %s", real, tradingDay)
+	}
+
+	ls := ExRangeKlines{
+		{Time: real, Open: 3000, High: 3010, Low: 2990, Close: 3005, Trade: 10},
+		{Time: real.AddDate(0, 0, 1), Open: 3005, High: 3020, Low: 3000, Close: 3018.5, Trade: 20},
+	}.Klines()
+	if len(ls) != 2 || ls[0].Last != 0 || ls[1].Last != 3005000 || ls[1].Close != 3018500 || ls[1].Volume != 20 || !ls[1].Time.Equal(real.AddDate(0, 0, 1)) {
+		t.Errorf("Klines = %+v %+v", ls[0], ls[1])
+	}
+}
@@ -448,3 +448,36 @@ func applyFQ(ks Klines, fs []*Factor, qfq bool) Klines {
 	}
 	return out
 }
+
+// ApplyFactors 按交易日复权,分钟线也适用,fs 按时间升序(如 PreKlines.Factors 的结果,也可以只保留除权除息日)。
+// 返回新切片,不改原 ks
+func ApplyFactors(ks Klines, fs []*Factor, qfq bool) Klines {
+	out := make(Klines, len(ks))
+	for i, k := range ks {
+		nk := *k
+		if f := factorAt(fs, k.Time); f != nil {
+			price := f.QFQPrice
+			if !qfq {
+				price = f.HFQPrice
+			}
+			nk.Last = price(k.Last)
+			nk.Open = price(k.Open)
+			nk.High = price(k.High)
+			nk.Low = price(k.Low)
+			nk.Close = price(k.Close)
+		}
+		out[i] = &nk
+	}
+	return out
+}
+
+// factorAt t 所在交易日的因子,fs 按时间升序,早于第一个时用第一个
+func factorAt(fs []*Factor, t time.Time) *Factor {
+	if len(fs) == 0 {
+		return nil
+	}
+	y, m, d := t.Date()
+	day := time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+	i := sort.Search(len(fs), func(i int) bool { return !fs[i].Time.Before(day) })
+	return fs[max(i-1, 0)]
+}
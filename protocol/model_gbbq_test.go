@@ -371,3 +371,30 @@ var ev000651 = []fixEvent{
 	{20250829, 20.0, 0.0, 0.0, 0.0},
 	{20260123, 10.0, 0.0, 0.0, 0.0},
 }
+
+func TestApplyFactors(t *testing.T) {
+	ks := Klines{testDayKline("20240102", 10, 10), testDayKline("20240103", 10, 10), testDayKline("20240104", 9, 9), testDayKline("20240105", 9, 9)}
+	for i := 1; i < len(ks); i++ {
+		ks[i].Last = ks[i-1].Close
+	}
+	//20240104 10股派10元
+	ex := time.Date(2024, 1, 4, 0, 0, 0, 0, time.Local)
+	fs := XRXDs{{Time: ex, Fenhong: 10}}.Pre(ks).Factors()
+	//只保留除权除息日也能按交易日查到
+	for _, fs := range [][]*Factor{fs, {fs[0], fs[2]}} {
+		//分钟线按所在交易日的因子
+		minute := testDayKline("20240103", 10, 10)
+		minute.Time = minute.Time.Add(-4 * time.Hour)
+		early := testDayKline("20231229", 10, 10)
+		got := ApplyFactors(Klines{minute, early, ks[2]}, fs, true)
+		if got[0].Close != Yuan(9) || got[1].Close != Yuan(9) || got[2].Close != Yuan(9) {
+			t.Errorf("qfq = %v", got)
+		}
+		if minute.Close != Yuan(10) {
+			t.Error("不应修改原K线")
+		}
+		if got := ApplyFactors(Klines{ks[3]}, fs, false); got[0].Close != Yuan(10) {
+			t.Errorf("hfq = %v", got)
+		}
+	}
+}
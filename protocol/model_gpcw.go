@@ -0,0 +1,218 @@
+package protocol
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	FileGpcwList = "tdxfin/gpcw.txt" // 专业财务数据文件列表,每行 文件名,md5,大小
+	DirGpcw      = "tdxfin/"         // 专业财务数据文件目录,report file 下载路径为 DirGpcw+文件名
+)
+
+/*
+通达信专业财务数据 gpcwYYYYMMDD.dat(一般打包为同名 zip),参考 pytdx HistoryFinancialReader
+
+头部 20 字节:
+	00~01 int16  保留
+	02~05 uint32 报告期 YYYYMMDD
+	06~07 uint16 股票数量
+	08~11 uint32 保留
+	12~15 uint32 每只股票的报表长度(字节),列数=长度/4
+	16~19 uint32 保留
+索引 每只股票 11 字节:
+	00~05 代码(6位)
+	06    保留
+	07~10 uint32 报表数据在文件中的偏移
+报表 列数×float32
+*/
+
+// FinancialReport 一只股票一期的专业财务数据,Values[i] 对应第 i+1 列
+type FinancialReport struct {
+	Code   string    //6位代码,不带交易所前缀
+	Date   uint32    //报告期 YYYYMMDD
+	Values []float64 //原始列数据
+}
+
+// ParseFinancialReport 解析 gpcwYYYYMMDD.dat 内容
+func ParseFinancialReport(data []byte) ([]*FinancialReport, error) {
+	if len(data) < 20 {
+		return nil, errors.New("gpcw 数据长度不足")
+	}
+	date := binary.LittleEndian.Uint32(data[2:6])
+	count := int(binary.LittleEndian.Uint16(data[6:8]))
+	size := int(binary.LittleEndian.Uint32(data[12:16]))
+	fields := size / 4
+	out := make([]*FinancialReport, 0, count)
+	for i := 0; i < count; i++ {
+		pos := 20 + i*11
+		if pos+11 > len(data) {
+			return nil, errors.New("gpcw 索引长度不足")
+		}
+		code := strings.TrimRight(string(data[pos:pos+6]), "\x00")
+		offset := int(binary.LittleEndian.Uint32(data[pos+7 : pos+11]))
+		if offset+fields*4 > len(data) {
+			return nil, errors.New("gpcw 报表长度不足")
+		}
+		values := make([]float64, fields)
+		for j := range values {
+			values[j] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset+j*4:])))
+		}
+		out = append(out, &FinancialReport{Code: code, Date: date, Values: values})
+	}
+	return out, nil
+}
+
+// DecodeFinancialReportZip 解压并解析 gpcwYYYYMMDD.zip
+func DecodeFinancialReportZip(bs []byte) ([]*FinancialReport, error) {
+	zr, err := zip.NewReader(bytes.NewReader(bs), int64(len(bs)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if !strings.EqualFold(filepath.Ext(f.Name), ".dat") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		return ParseFinancialReport(data)
+	}
+	return nil, errors.New("gpcw 压缩包中缺少 .dat 文件")
+}
+
+// FinancialReportFile 专业财务数据文件信息,来自 tdxfin/gpcw.txt
+type FinancialReportFile struct {
+	Filename string //文件名,例 gpcw20240930.zip
+	Hash     string //md5
+	Size     int    //文件大小
+}
+
+// Date 报告期,例 20240930
+func (this *FinancialReportFile) Date() string {
+	return strings.TrimSuffix(strings.TrimPrefix(this.Filename, "gpcw"), filepath.Ext(this.Filename))
+}
+
+// ParseFinancialReportList 解析 tdxfin/gpcw.txt,每行 文件名,md5,大小
+func ParseFinancialReportList(data []byte) []*FinancialReportFile {
+	out := []*FinancialReportFile(nil)
+	for _, ln := range strings.Split(string(data), "\n") {
+		f := strings.Split(strings.TrimSpace(ln), ",")
+		if len(f) < 3 || len(f[0]) == 0 {
+			continue
+		}
+		size, _ := strconv.Atoi(f[2])
+		out = append(out, &FinancialReportFile{Filename: f[0], Hash: f[1], Size: size})
+	}
+	return out
+}
+
+// Column 第 n 列的值(从1开始,与通达信公式 FINVALUE(n)/pytdx col{n} 一致),不存在返回0
+func (this *FinancialReport) Column(n int) float64 {
+	if n < 1 || n > len(this.Values) {
+		return 0
+	}
+	return this.Values[n-1]
+}
+
+// Financial 常用列转成结构体
+func (this *FinancialReport) Financial() *Financial {
+	c := this.Column
+	return &Financial{
+		Code: this.Code,
+		Date: this.Date,
+
+		EPS:                    c(1),
+		EPSDeducted:            c(2),
+		UndistributedPerShare:  c(3),
+		BVPS:                   c(4),
+		CapitalReservePerShare: c(5),
+		ROE:                    c(6),
+		OCFPS:                  c(7),
+
+		Cash:               c(8),
+		AccountsReceivable: c(11),
+		Inventory:          c(17),
+		CurrentAssets:      c(21),
+		TotalAssets:        c(40),
+		CurrentLiabilities: c(54),
+		TotalLiabilities:   c(63),
+		ShareCapital:       c(64),
+		Equity:             c(72),
+
+		Revenue:          c(74),
+		OperatingCost:    c(75),
+		SellingExpense:   c(77),
+		AdminExpense:     c(78),
+		FinanceExpense:   c(80),
+		InvestmentIncome: c(83),
+		OperatingProfit:  c(86),
+		TotalProfit:      c(92),
+		IncomeTax:        c(93),
+		NetProfit:        c(95),
+		NetProfitParent:  c(96),
+		MinorityInterest: c(97),
+
+		OperatingCashFlow: c(107),
+		InvestingCashFlow: c(119),
+		FinancingCashFlow: c(128),
+	}
+}
+
+// Financial 专业财务数据常用字段,金额单位元,列号见 FinancialReport.Financial
+type Financial struct {
+	Code string //6位代码
+	Date uint32 //报告期 YYYYMMDD
+
+	//每股指标
+	EPS                    float64 //基本每股收益
+	EPSDeducted            float64 //扣除非经常性损益每股收益
+	UndistributedPerShare  float64 //每股未分配利润
+	BVPS                   float64 //每股净资产
+	CapitalReservePerShare float64 //每股资本公积金
+	ROE                    float64 //净资产收益率(%)
+	OCFPS                  float64 //每股经营现金流量
+
+	//资产负债表
+	Cash               float64 //货币资金
+	AccountsReceivable float64 //应收账款
+	Inventory          float64 //存货
+	CurrentAssets      float64 //流动资产合计
+	TotalAssets        float64 //资产总计
+	CurrentLiabilities float64 //流动负债合计
+	TotalLiabilities   float64 //负债合计
+	ShareCapital       float64 //实收资本(或股本)
+	Equity             float64 //所有者权益(或股东权益)合计
+
+	//利润表
+	Revenue          float64 //营业收入
+	OperatingCost    float64 //营业成本
+	SellingExpense   float64 //销售费用
+	AdminExpense     float64 //管理费用
+	FinanceExpense   float64 //财务费用
+	InvestmentIncome float64 //投资收益
+	OperatingProfit  float64 //营业利润
+	TotalProfit      float64 //利润总额
+	IncomeTax        float64 //所得税
+	NetProfit        float64 //净利润
+	NetProfitParent  float64 //归属于母公司所有者的净利润
+	MinorityInterest float64 //少数股东损益
+
+	//现金流量表
+	OperatingCashFlow float64 //经营活动产生的现金流量净额
+	InvestingCashFlow float64 //投资活动产生的现金流量净额
+	FinancingCashFlow float64 //筹资活动产生的现金流量净额
+}
@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestParseFinancialReportList(t *testing.T) {
+	ls := ParseFinancialReportList([]byte("gpcw20240630.zip,0b0c6bd1a0b0aa4c15e0b7c4ad3a30c7,4571520\r\ngpcw20240930.zip,9ef2a0ad85bd1a6ad6c2f9f3a0e3f6d1,4680311\r\n"))
+	if len(ls) != 2 {
+		t.Fatalf("数量不一致: %d", len(ls))
+	}
+	if ls[1].Filename != "gpcw20240930.zip" || ls[1].Size != 4680311 || ls[1].Date() != "20240930" {
+		t.Errorf("解析不一致: %+v", ls[1])
+	}
+}
+
+func TestDecodeFinancialReportZip(t *testing.T) {
+	const fields = 130
+	data := make([]byte, 20+11)
+	binary.LittleEndian.PutUint32(data[2:6], 20240930)
+	binary.LittleEndian.PutUint16(data[6:8], 1)
+	binary.LittleEndian.PutUint32(data[12:16], fields*4)
+	copy(data[20:], "000001")
+	binary.LittleEndian.PutUint32(data[27:], uint32(len(data)))
+	for i := 1; i <= fields; i++ {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(i)))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("gpcw20240930.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	zw.Close()
+
+	ls, err := DecodeFinancialReportZip(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 1 || ls[0].Code != "000001" || ls[0].Date != 20240930 {
+		t.Fatalf("解析不一致: %+v", ls)
+	}
+	f := ls[0].Financial()
+	if f.EPS != 1 || f.TotalAssets != 40 || f.Revenue != 74 || f.NetProfitParent != 96 || f.OperatingCashFlow != 107 || f.FinancingCashFlow != 128 {
+		t.Errorf("列映射不一致: %+v", f)
+	}
+	if ls[0].Column(0) != 0 || ls[0].Column(fields+1) != 0 {
+		t.Errorf("越界列应返回0")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/injoyai/base/types"
@@ -466,6 +467,58 @@ func (ks Klines) Merge(n int) Klines {
 	return res
 }
 
+// KlinePeriods 周期名称对应的K线类型,httpserver 的 period 参数和命令行的 -period 都用这个
+var KlinePeriods = map[string]uint8{
+	"1m":      TypeKlineMinute,
+	"5m":      TypeKline5Minute,
+	"15m":     TypeKline15Minute,
+	"30m":     TypeKline30Minute,
+	"60m":     TypeKline60Minute,
+	"day":     TypeKlineDay,
+	"week":    TypeKlineWeek,
+	"month":   TypeKlineMonth,
+	"quarter": TypeKlineQuarter,
+	"year":    TypeKlineYear,
+}
+
+// KlineGroup 周及以上K线的分组方式,其他类型返回 nil
+func KlineGroup(typ uint8) func(t time.Time) string {
+	switch typ {
+	case TypeKlineWeek:
+		return func(t time.Time) string {
+			y, w := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", y, w)
+		}
+	case TypeKlineMonth:
+		return func(t time.Time) string { return t.Format("2006-01") }
+	case TypeKlineQuarter:
+		return func(t time.Time) string { return fmt.Sprintf("%d-Q%d", t.Year(), (t.Month()+2)/3) }
+	case TypeKlineYear:
+		return func(t time.Time) string { return strconv.Itoa(t.Year()) }
+	}
+	return nil
+}
+
+// MergeKlines 按 group 把日线合成周/月/季/年线,时间为周期内最后一个交易日,昨收为上一根的收盘价
+func MergeKlines(ks Klines, group func(t time.Time) string) Klines {
+	res := Klines{}
+	for i := 0; i < len(ks); {
+		j, key := i+1, group(ks[i].Time)
+		for j < len(ks) && group(ks[j].Time) == key {
+			j++
+		}
+		ls := ks[i:j]
+		k := ls.Kline(ls[len(ls)-1].Time, ls[0].Open)
+		k.Last = ls[0].Last
+		if len(res) > 0 {
+			k.Last = res[len(res)-1].Close
+		}
+		res = append(res, k)
+		i = j
+	}
+	return res
+}
+
 // Merge241 合并成其他类型的K线
 func (ks Klines) Merge241(n int) Klines {
 	mDay := make(map[string]Klines)
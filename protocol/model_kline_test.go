@@ -3,6 +3,7 @@ package protocol
 import (
 	"encoding/hex"
 	"testing"
+	"time"
 )
 
 func Test_stockKline_Frame(t *testing.T) {
@@ -53,3 +54,32 @@ func TestNormalizeKlineVolume(t *testing.T) {
 		})
 	}
 }
+
+func testDayKline(date string, open, close float64) *Kline {
+	t, _ := time.ParseInLocation("20060102 15:04", date+" 15:00", time.Local)
+	return &Kline{Time: t, Open: Yuan(open), High: Yuan(max(open, close)),
+		Low: Yuan(min(open, close)), Close: Yuan(close), Volume: 100}
+}
+
+func TestMergeKlines(t *testing.T) {
+	ks := Klines{
+		testDayKline("20240102", 10, 11), testDayKline("20240103", 11, 12), testDayKline("20240104", 12, 13),
+		testDayKline("20240108", 13, 14), testDayKline("20240109", 14, 15),
+	}
+
+	//20240102-04 为一周,08-09 为下一周
+	w := MergeKlines(ks, KlineGroup(TypeKlineWeek))
+	if len(w) != 2 || w[0].Open != ks[0].Open || w[0].Close != ks[2].Close || w[0].Time != ks[2].Time ||
+		w[0].Volume != 300 || w[1].Last != w[0].Close || w[1].High != ks[4].High {
+		t.Errorf("week = %v", w)
+	}
+	if m := MergeKlines(ks, KlineGroup(TypeKlineMonth)); len(m) != 1 || m[0].Low != ks[0].Low {
+		t.Errorf("month = %v", m)
+	}
+	if KlineGroup(TypeKlineDay) != nil {
+		t.Error("日线不需要合成")
+	}
+	if KlinePeriods["week"] != TypeKlineWeek {
+		t.Error("period week")
+	}
+}
@@ -3,6 +3,7 @@ package protocol
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 type QuotesResp []*Quote
@@ -15,20 +16,24 @@ func (this QuotesResp) String() string {
 	return strings.Join(ls, "\n")
 }
 
+// Quote 五档行情,用两条样本核对过的见 Test_quote_Decode。
+// 涨跌停价、最小变动价位、停牌/集合竞价等盘口标志在剩下的保留字段里都对不上,不在这个报文里,不做推测
 type Quote struct {
-	Exchange       Exchange // 市场
-	Code           string   // 股票代码 6个ascii字符串
-	Active1        uint16   // 活跃度
-	Kline          *Kline   //k线,这里的时间取得是当前时间,昨收盘好像不太对
-	ServerTime     string   // 时间
-	ReversedBytes0 int      // 保留(时间 ServerTime)
-	ReversedBytes1 int      // 保留 这个等于 负的收盘价格?
-	Intuition      int      // 现量（东财的盘口-现量）现在成交量
-	InsideDish     int      // 内盘（东财的盘口-内盘）（和东财对不上）
-	OuterDisc      int      // 外盘（东财的盘口-外盘）（和东财对不上）
-
-	ReversedBytes2 int         // 保留，未知
-	ReversedBytes3 int         // 保留，未知,基金的昨收净值?
+	Exchange       Exchange  // 市场
+	Code           string    // 股票代码 6个ascii字符串
+	Active1        uint16    // 活跃度,和 Active2 相同
+	Kline          *Kline    //k线,这里的时间取得是当前时间,昨收盘好像不太对
+	ServerTime     string    // 时间,服务器原始数字,新代码用 Time
+	Time           time.Time // 服务器时间(当天),由 ReversedBytes0 解析
+	Speed          float64   // 涨速(%),由 ReversedBytes9 按有符号解析
+	ReversedBytes0 int       // 服务器时间原始值,HHMM+分钟的万分比,见 Time
+	ReversedBytes1 int       // 负的收盘价(分),即 -Close/10
+	Intuition      int       // 现量（东财的盘口-现量）现在成交量
+	InsideDish     int       // 内盘(手),内盘+外盘=成交量,和东财的差异是主动买卖的统计口径不同
+	OuterDisc      int       // 外盘(手)
+
+	ReversedBytes2 int         // 保留，未知,样本里都是0
+	ReversedBytes3 int         // 保留，未知,基金(ETF)时疑似参考净值(IOPV),没有样本核对,暂不解析
 	BuyLevel       PriceLevels // 5档买盘(买1-5)
 	SellLevel      PriceLevels // 5档卖盘(卖1-5)
 
@@ -37,8 +42,8 @@ type Quote struct {
 	ReversedBytes6 int     // 保留，未知
 	ReversedBytes7 int     // 保留，未知
 	ReversedBytes8 int     // 保留，未知
-	ReversedBytes9 uint16  // 保留，未知
-	Rate           float64 // 涨速，好像都是0
+	ReversedBytes9 uint16  // 涨速原始值,有符号 int16 的补码,见 Speed
+	Rate           float64 // 涨速,按无符号解析(负的涨速会变成600多),新代码用 Speed
 	Active2        uint16  // 活跃度
 }
 
@@ -55,6 +60,19 @@ func (this *Quote) String() string {
 	)
 }
 
+// decodeServerTime 解析服务器时间(同 pytdx 的 _format_time),例 13252999 → 13:25:17.994,
+// 前面是小时,后6位的前2位小于60时为 分钟+秒(万分之一分钟),否则整个后6位为百万分之一小时
+func decodeServerTime(v int, day time.Time) time.Time {
+	hour, rest := v/1000000, v%1000000
+	var ms int
+	if rest/10000 < 60 {
+		ms = rest/10000*60000 + rest%10000*6
+	} else {
+		ms = rest * 3600 / 1000
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, day.Location()).Add(time.Duration(ms) * time.Millisecond)
+}
+
 type quote struct{}
 
 func (this quote) Frame(codes ...string) (*Frame, error) {
@@ -124,6 +142,7 @@ func (this quote) Decode(bs []byte) QuotesResp {
 		}
 		bs, sec.Kline = DecodeKline(bs[9:])
 		bs, sec.ReversedBytes0 = CutInt(bs)
+		sec.Time = decodeServerTime(sec.ReversedBytes0, sec.Kline.Time)
 		sec.ServerTime = fmt.Sprintf("%d", sec.ReversedBytes0)
 		bs, sec.ReversedBytes1 = CutInt(bs)
 		bs, sec.Kline.Volume = CutInt64(bs)
@@ -158,6 +177,7 @@ func (this quote) Decode(bs []byte) QuotesResp {
 		bs, sec.ReversedBytes8 = CutInt(bs)
 		sec.ReversedBytes9 = Uint16(bs[:2])
 
+		sec.Speed = float64(int16(sec.ReversedBytes9)) / 100
 		sec.Rate = float64(sec.ReversedBytes9) / 100
 		sec.Active2 = Uint16(bs[2:4])
 
@@ -1,7 +1,10 @@
 package protocol
 
 import (
+	"encoding/hex"
+	"fmt"
 	"testing"
+	"time"
 )
 
 func Test_quote_Frame(t *testing.T) {
@@ -13,3 +16,63 @@ func Test_quote_Frame(t *testing.T) {
 	}
 	t.Log(f.Bytes().HEX())
 }
+
+// quoteGolden 五档行情的样本(sz000001 sh600008),见 quote.Decode 的注释
+const quoteGolden = "0136" + "0200" +
+	"00" + "303030303031" + "320b" + "b212" + "4c" + "56" + "10" + "59" +
+	"87e6d10cf212b78fa801ae01293dc54e8bd740acb8670086ca1e0001af36ba0c4102b467b6054203a68a0184094304891992114405862685108d0100000000e8ff320b" +
+	"01" + "363030303038" + "5909" + "8005" + "46" + "45" + "02" + "46" + "8defd10c" +
+	"c005bed2668e05be15804d8ba12cb3b13a0083c3034100badc029d014201bc990384f70443029da503b7af074403a6e501b9db044504a6e2028dd5048d050000000000005909"
+
+func Test_quote_Decode(t *testing.T) {
+	bs, err := hex.DecodeString(quoteGolden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ls := MQuote.Decode(bs)
+	if len(ls) != 2 {
+		t.Fatalf("len = %d", len(ls))
+	}
+	a, b := ls[0], ls[1]
+
+	//ServerTime/Rate 保持原来的格式
+	want := [4]string{"13252999", "13253581", "655.12", "0"}
+	got := [4]string{a.ServerTime, b.ServerTime, fmt.Sprint(a.Rate), fmt.Sprint(b.Rate)}
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if a.Time.Format("15:04:05.000") != "13:25:17.994" || a.Speed != -0.24 || b.Speed != 0 {
+		t.Errorf("Time=%v Speed=%v %v", a.Time, a.Speed, b.Speed)
+	}
+	if a.Kline.Last != 11900 || a.Kline.Close != 12020 || b.Kline.Last != 3140 || b.Kline.Close != 3200 {
+		t.Errorf("Kline = %v %v", a.Kline, b.Kline)
+	}
+	for _, q := range ls {
+		//已核对的关系: 内盘+外盘=成交量, ReversedBytes1=-收盘价(分), 两个活跃度相同
+		if int64(q.InsideDish+q.OuterDisc) != q.Kline.Volume {
+			t.Errorf("%s 内盘%d+外盘%d != 成交量%d", q.Code, q.InsideDish, q.OuterDisc, q.Kline.Volume)
+		}
+		if Price(-q.ReversedBytes1*10) != q.Kline.Close {
+			t.Errorf("%s ReversedBytes1=%d Close=%v", q.Code, q.ReversedBytes1, q.Kline.Close)
+		}
+		if q.Active1 != q.Active2 {
+			t.Errorf("%s Active1=%d Active2=%d", q.Code, q.Active1, q.Active2)
+		}
+	}
+}
+
+func Test_decodeServerTime(t *testing.T) {
+	day := time.Date(2025, 6, 3, 0, 0, 0, 0, time.Local)
+	for v, want := range map[int]string{
+		13252999: "13:25:17.994",
+		9300000:  "09:30:00.000",
+		14999553: "14:59:58.390",
+		11600000: "11:36:00.000",
+	} {
+		if got := decodeServerTime(v, day).Format("15:04:05.000"); got != want {
+			t.Errorf("%d = %s, want %s", v, got, want)
+		}
+	}
+}
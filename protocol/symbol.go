@@ -0,0 +1,146 @@
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Symbol 统一的证券代码,标准行情(A股/指数/基金)和扩展行情(港股/美股/期货)通用,
+// 扩展行情的市场编号即 Exchange 的值(见 ExchangeHK/ExchangeCFF 等)
+type Symbol struct {
+	Exchange Exchange //市场
+	Code     string   //不带市场的代码,例 000001 00700 IF2506
+	Category uint8    //扩展行情分类,2=港股 3=期货,标准行情和未知的为0
+}
+
+// String 带市场前缀的代码,例 sz000001 hk00700 cffIF2506,可再用 DecodeCode/ParseSymbol 解析
+func (this Symbol) String() string {
+	return this.Exchange.String() + this.Code
+}
+
+// IsEx 是否扩展行情(7727)的代码,沪深京以外的市场都是
+func (this Symbol) IsEx() bool {
+	switch this.Exchange {
+	case ExchangeSZ, ExchangeSH, ExchangeBJ:
+		return false
+	}
+	return true
+}
+
+// Market 扩展行情的市场编号
+func (this Symbol) Market() uint8 {
+	return this.Exchange.Uint8()
+}
+
+// NewSymbol 按市场补上扩展行情的分类
+func NewSymbol(exchange Exchange, code string) Symbol {
+	s := Symbol{Exchange: exchange, Code: code}
+	switch exchange {
+	case ExchangeHK:
+		s.Category = 2
+	case ExchangeCFF, ExchangeCZC, ExchangeDCE, ExchangeSHF, ExchangeGFE, ExchangeQHZ:
+		s.Category = 3
+	}
+	return s
+}
+
+// ParseSymbol 解析常见写法的代码,在 DecodeCode 的基础上支持:
+//   - 期货交易所的常见后缀/前缀,例 rb2501.SHF IF2412.CFFEX SR509.ZCE SHFE.rb2510
+//   - 裸期货合约,按品种推断交易所,例 IF2412 rb2501 SR509 IFL8
+//   - 扩展行情的 市场编号:代码,例 47:IF2506 31:00700
+//
+// 期货合约按交易所统一大小写,中金所/郑商所大写,上期所(含上期能源)/大商所/广期所小写,例 CU2501→cu2501 ta501→TA501
+func ParseSymbol(s string) (Symbol, error) {
+	s = strings.TrimSpace(s)
+
+	//市场编号:代码
+	if i := strings.Index(s, ":"); i > 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil || n < 0 || n > 255 || s[i+1:] == "" {
+			return Symbol{}, fmt.Errorf("无法识别的代码: %q", s)
+		}
+		return newParsedSymbol(Exchange(n), s[i+1:]), nil
+	}
+
+	//期货交易所的别名
+	if i := strings.LastIndex(s, "."); i > 0 {
+		if ex, ok := futuresExchangeAlias[strings.ToLower(s[i+1:])]; ok {
+			return newParsedSymbol(ex, s[:i]), nil
+		}
+		if ex, ok := futuresExchangeAlias[strings.ToLower(s[:i])]; ok {
+			return newParsedSymbol(ex, s[i+1:]), nil
+		}
+	}
+
+	ex, code, err := DecodeCode(s)
+	if err == nil {
+		return newParsedSymbol(ex, code), nil
+	}
+	if isFuturesCode(s) {
+		if ex, ok := FuturesExchange(futuresRoot(s)); ok {
+			return newParsedSymbol(ex, s), nil
+		}
+	}
+	return Symbol{}, err
+}
+
+// newParsedSymbol 同 NewSymbol,期货合约按交易所统一大小写
+func newParsedSymbol(exchange Exchange, code string) Symbol {
+	s := NewSymbol(exchange, code)
+	switch exchange {
+	case ExchangeCFF, ExchangeCZC:
+		s.Code = strings.ToUpper(code)
+	case ExchangeSHF, ExchangeDCE, ExchangeGFE:
+		//只改品种,主连/指数的 L8/L9 保持大写
+		root := futuresRoot(code)
+		s.Code = strings.ToLower(root) + strings.ToUpper(code[len(root):])
+	}
+	return s
+}
+
+// FuturesExchange 期货品种所在的交易所,例 IF→中金所 rb→上期所(含上期能源) SR→郑商所,不区分大小写
+func FuturesExchange(root string) (Exchange, bool) {
+	ex, ok := futuresRoots[strings.ToLower(root)]
+	return ex, ok
+}
+
+// futuresRoot 合约代码开头的字母
+func futuresRoot(code string) string {
+	for i, c := range code {
+		if c >= '0' && c <= '9' {
+			root := code[:i]
+			//主连/指数,例 IFL8 rbL9
+			if len(root) > 1 && root[len(root)-1] == 'L' && i+1 == len(code) {
+				root = root[:len(root)-1]
+			}
+			return root
+		}
+	}
+	return code
+}
+
+var futuresExchangeAlias = map[string]Exchange{
+	"cff": ExchangeCFF, "cfx": ExchangeCFF, "cffex": ExchangeCFF,
+	"shf": ExchangeSHF, "shfe": ExchangeSHF, "ine": ExchangeINE,
+	"dce": ExchangeDCE,
+	"czc": ExchangeCZC, "zce": ExchangeCZC, "czce": ExchangeCZC,
+	"gfe": ExchangeGFE, "gfex": ExchangeGFE,
+}
+
+// futuresRoots 期货品种→交易所,小写
+var futuresRoots = func() map[string]Exchange {
+	m := map[string]Exchange{}
+	for ex, roots := range map[Exchange]string{
+		ExchangeCFF: "if ih ic im t tf ts tl",
+		ExchangeSHF: "cu al zn pb ni sn au ag rb wr hc ss fu bu ru sp ao br op sc lu nr bc ec",
+		ExchangeDCE: "a b m y p c cs jd l v pp j jm i eg eb pg rr lh fb bb lg bz",
+		ExchangeCZC: "sr cf cy ta ma fg rm oi ap cj ur sa pf pk sf sm zc wh pm ri jr lr rs sh px pr",
+		ExchangeGFE: "si lc ps pt pd",
+	} {
+		for _, v := range strings.Fields(roots) {
+			m[v] = ex
+		}
+	}
+	return m
+}()
@@ -0,0 +1,82 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSymbol(t *testing.T) {
+	for in, want := range map[string]Symbol{
+		"sz000001":     {Exchange: ExchangeSZ, Code: "000001"},
+		"600000.SH":    {Exchange: ExchangeSH, Code: "600000"},
+		"000001":       {Exchange: ExchangeSZ, Code: "000001"},
+		"00700.HK":     {Exchange: ExchangeHK, Code: "00700", Category: 2},
+		"AAPL":         {Exchange: ExchangeUS, Code: "AAPL"},
+		"rb2501.SHF":   {Exchange: ExchangeSHF, Code: "rb2501", Category: 3},
+		"IF2412.CFFEX": {Exchange: ExchangeCFF, Code: "IF2412", Category: 3},
+		"SR509.ZCE":    {Exchange: ExchangeCZC, Code: "SR509", Category: 3},
+		"SHFE.rb2510":  {Exchange: ExchangeSHF, Code: "rb2510", Category: 3},
+		"IF2412":       {Exchange: ExchangeCFF, Code: "IF2412", Category: 3},
+		"T2509":        {Exchange: ExchangeCFF, Code: "T2509", Category: 3},
+		"m2509":        {Exchange: ExchangeDCE, Code: "m2509", Category: 3},
+		"SR509":        {Exchange: ExchangeCZC, Code: "SR509", Category: 3},
+		"IFL8":         {Exchange: ExchangeCFF, Code: "IFL8", Category: 3},
+		"si2509":       {Exchange: ExchangeGFE, Code: "si2509", Category: 3},
+		"cffIF2506":    {Exchange: ExchangeCFF, Code: "IF2506", Category: 3},
+		"47:IF2506":    {Exchange: ExchangeCFF, Code: "IF2506", Category: 3},
+		"60:IFL8":      {Exchange: 60, Code: "IFL8"},
+		"CU2501":       {Exchange: ExchangeSHF, Code: "cu2501", Category: 3},
+		"ta501":        {Exchange: ExchangeCZC, Code: "TA501", Category: 3},
+		"if2412":       {Exchange: ExchangeCFF, Code: "IF2412", Category: 3},
+		"M2509.DCE":    {Exchange: ExchangeDCE, Code: "m2509", Category: 3},
+		"rbL9":         {Exchange: ExchangeSHF, Code: "rbL9", Category: 3},
+		"30:RB2510":    {Exchange: ExchangeSHF, Code: "rb2510", Category: 3},
+	} {
+		got, err := ParseSymbol(in)
+		if err != nil {
+			t.Errorf("ParseSymbol(%q) error: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseSymbol(%q) = %+v, want %+v", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "XX2509", "abc:IF2506", "47:", "1234567"} {
+		if s, err := ParseSymbol(in); err == nil {
+			t.Errorf("ParseSymbol(%q) = %+v, 应返回错误", in, s)
+		}
+	}
+
+	//String 可以再解析回来
+	for _, in := range []string{"sz000001", "hk00700", "cffIF2506", "shfrb2510", "czcSR509", "usAAPL"} {
+		s, err := ParseSymbol(in)
+		if err != nil || s.String() != in {
+			t.Errorf("ParseSymbol(%q).String() = %q, %v", in, s.String(), err)
+		}
+	}
+	if s, _ := ParseSymbol("sh600000"); s.IsEx() {
+		t.Error("sh600000 不是扩展行情")
+	}
+	if s, _ := ParseSymbol("rb2510.SHF"); !s.IsEx() || s.Market() != 30 {
+		t.Errorf("rb2510.SHF = %+v", s)
+	}
+}
+
+func TestExConvert(t *testing.T) {
+	k := (&ExKline{Datetime: "2025-06-03 15:00", Open: float64(float32(3800.2)), High: 3810, Low: 3790.4, Close: 3805.6, Trade: 100, Amount: 1e6}).Kline()
+	if k.Open != 3800200 || k.Close != 3805600 || k.Volume != 100 || k.Time.Format("2006-01-02 15:04") != "2025-06-03 15:00" {
+		t.Errorf("Kline = %+v", k)
+	}
+
+	q := (&ExQuote{Market: 47, Code: "IF2506", PreClose: 3800, Price: 3805, ZongLiang: 50, Bid: [5]float64{3804}, BidVol: [5]uint32{3}, Ask: [5]float64{3806}}).Quote()
+	if q.Exchange != ExchangeCFF || q.Kline.Last != 3800000 || q.Kline.Close != 3805000 || !q.BuyLevel[0].Buy || q.BuyLevel[0].Number != 3 || q.SellLevel[0].Price != 3806000 {
+		t.Errorf("Quote = %+v", q)
+	}
+
+	session := NewExSession(47, 20250603, nil)
+	tr := (&ExTradeTick{Hour: 9, Minute: 30, Second: 5, Time: session.Time(9, 30, 5), Price: 3805, Volume: 2, Direction: -1}).Trade()
+	if tr.Status != 1 || tr.Price != 3805000 || tr.Time.Format(time.DateTime) != "2025-06-03 09:30:05" {
+		t.Errorf("Trade = %+v", tr)
+	}
+}